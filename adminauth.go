@@ -0,0 +1,87 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// BasicAuth 는 Authorization: Basic header 의 username, password 가 같은 요청을 허용한다. password 가 비어 있으면 모두 거부한다.
+func BasicAuth(username, password string) func(r *http.Request) bool {
+	// 길이가 달라도 같은 시간에 비교하도록 hash 를 비교.
+	usernameHash, passwordHash := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(password))
+	return func(r *http.Request) bool {
+		givenUsername, givenPassword, ok := r.BasicAuth()
+		if !ok || len(password) == 0 {
+			return false
+		}
+		givenUsernameHash, givenPasswordHash := sha256.Sum256([]byte(givenUsername)), sha256.Sum256([]byte(givenPassword))
+		usernameMatch := subtle.ConstantTimeCompare(givenUsernameHash[:], usernameHash[:])
+		passwordMatch := subtle.ConstantTimeCompare(givenPasswordHash[:], passwordHash[:])
+		return usernameMatch&passwordMatch == 1
+	}
+}
+
+// ClientCertificate 는 검증한 client 인증서로 연결한 요청을 허용한다. (mTLS 전용)
+// identities 가 있으면 인증서의 Subject, CommonName 또는 SAN 이 하나와 일치해야 한다. (TLSIdentityOptions.Methods 와 같은 형식)
+// Listener 의 TLS 설정에서 client 인증서를 검증해야 한다. (ClientCAs, ClientAuth)
+func ClientCertificate(identities ...string) func(r *http.Request) bool {
+	var patterns []identityPattern
+	for _, identity := range identities {
+		patterns = append(patterns, compileIdentityPattern(identity))
+	}
+	return func(r *http.Request) bool {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return false
+		}
+		if len(patterns) == 0 {
+			return true
+		}
+		identity := newTLSIdentity(r.TLS.VerifiedChains[0][0])
+		for _, pattern := range patterns {
+			if pattern.match(identity) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AnyOf 는 authorizers 중 하나라도 허용하는 요청을 허용한다. (e.g. 사람은 BasicAuth, 자동화는 BearerToken)
+func AnyOf(authorizers ...func(r *http.Request) bool) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, authorize := range authorizers {
+			if authorize != nil && authorize(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RequireAuthorization 은 authorize 가 true 를 반환한 요청만 handler 로 전달하고, 나머지는 401 로 거부한다.
+// authorize 가 nil 이면 모든 요청을 거부한다. pprof, expvar 같은 관리용 Handler 를 보호할 때 사용한다.
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/debug/", http.DefaultServeMux)  // net/http/pprof, expvar
+//	grpcServer.AddListener("admin", "tcp", "127.0.0.1:9090", server.ListenerOptions{
+//		Handler:   mux,
+//		Authorize: server.AnyOf(server.BasicAuth("admin", os.Getenv("ADMIN_PASSWORD")), server.BearerToken(os.Getenv("ADMIN_TOKEN"))),
+//	})
+func RequireAuthorization(handler http.Handler, authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			addMetric("admin_requests_rejected", 1)
+			unauthorized(w)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// unauthorized 는 401 로 응답한다. client 가 고를 수 있도록 Bearer 와 Basic 을 모두 알린다.
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Add("WWW-Authenticate", "Bearer")
+	w.Header().Add("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+const (
+	// ALPNHTTP2 는 HTTP/2 의 ALPN protocol ID 이다. gRPC 는 HTTP/2 만 사용한다.
+	ALPNHTTP2 = "h2"
+	// ALPNHTTP11 은 HTTP/1.1 의 ALPN protocol ID 이다.
+	ALPNHTTP11 = "http/1.1"
+)
+
+// ALPNOptions 는 TLS Listener 가 ALPN 으로 협상할 protocol 이다.
+type ALPNOptions struct {
+	// GRPCOnly 가 true 이면 gRPC Listener 는 ALPN 으로 h2 를 협상한 연결만 수락한다.
+	// ALPN 을 보내지 않는 client (e.g. 오래된 gRPC client, TLS 로 연결하는 port scanner) 는 handshake 에서 거부한다.
+	GRPCOnly bool
+	// Gateway 는 gRPC Gateway (Http Proxy) 가 TLS 에서 허용할 protocol 이다. (ALPNHTTP2, ALPNHTTP11)
+	// 비어 있으면 둘 다 허용하며, 있으면 목록에 없는 protocol 이나 ALPN 을 보내지 않는 연결은 handshake 에서 거부한다.
+	// gRPC Gateway 는 WithHttp3 를 사용할 때만 TLS 를 사용한다.
+	Gateway []string
+}
+
+// WithALPN 은 TLS Listener 가 협상할 ALPN protocol 을 제한한다. 협상하지 못한 연결은 alpn_rejected 지표로 확인한다.
+func WithALPN(alpnOptions ALPNOptions) Option {
+	return func(options *serverOptions) {
+		options.alpn = &alpnOptions
+	}
+}
+
+// checkALPN 은 지원하는 protocol 인지 확인한다.
+func checkALPN(options *serverOptions) error {
+	if options.alpn == nil {
+		return nil
+	}
+	for _, protocol := range options.alpn.Gateway {
+		if protocol != ALPNHTTP2 && protocol != ALPNHTTP11 {
+			return fmt.Errorf("unsupported gateway ALPN protocol: %s", protocol)
+		}
+	}
+	return nil
+}
+
+// applyALPN 은 gRPC Listener 의 TLS 설정에 GRPCOnly 를 적용한다.
+// HTTP/3 는 h3 를 협상하므로, 기본 TLS 설정을 그대로 사용하도록 checkHttp3 다음에 호출한다.
+func applyALPN(options *serverOptions) {
+	if options.alpn == nil || !options.alpn.GRPCOnly {
+		return
+	}
+	options.tlsConfig = options.alpn.grpc(options.tlsConfig)
+	for i := range options.additionalListeners {
+		options.additionalListeners[i].TLS = options.alpn.grpc(options.additionalListeners[i].TLS)
+	}
+}
+
+// grpc 는 GRPCOnly 이면 h2 만 협상하는 tlsConfig 의 복사본이다.
+func (pSelf *ALPNOptions) grpc(tlsConfig *tls.Config) *tls.Config {
+	if pSelf == nil || !pSelf.GRPCOnly {
+		return tlsConfig
+	}
+	return restrictALPN(tlsConfig, []string{ALPNHTTP2})
+}
+
+// gateway 는 Gateway 의 protocol 만 협상하도록 Http Proxy Server 를 설정한다.
+func (pSelf *ALPNOptions) gateway(httpServer *http.Server) {
+	if pSelf == nil || len(pSelf.Gateway) == 0 || httpServer.TLSConfig == nil {
+		return
+	}
+	httpServer.TLSConfig = restrictALPN(httpServer.TLSConfig, pSelf.Gateway)
+	if !slices.Contains(pSelf.Gateway, ALPNHTTP2) {
+		// TLSNextProto 가 nil 이 아니면 net/http 가 h2 를 설정하지 않는다.
+		httpServer.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+}
+
+// restrictALPN 은 protocols 만 알리고, 다른 protocol 을 협상하거나 ALPN 을 보내지 않은 연결을 거부하는 tlsConfig 의 복사본이다.
+// net/http 는 NextProtos 에 http/1.1 을 항상 추가하므로 알리는 것과 별개로 handshake 에서 확인한다.
+func restrictALPN(tlsConfig *tls.Config, protocols []string) *tls.Config {
+	if tlsConfig == nil {
+		return nil
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = slices.Clone(protocols)
+	verifyConnection := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if !slices.Contains(protocols, state.NegotiatedProtocol) {
+			addLabeledMetric("alpn_rejected", state.NegotiatedProtocol, 1)
+			return fmt.Errorf("ALPN protocol %q is not allowed", state.NegotiatedProtocol)
+		}
+		if verifyConnection != nil {
+			return verifyConnection(state)
+		}
+		return nil
+	}
+	return tlsConfig
+}
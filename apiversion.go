@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ApiVersion 은 RegisterHttpProxyVersion 으로 등록할 API version 이다.
+type ApiVersion struct {
+	// Prefix 는 version 의 URL path prefix 이다. (e.g. /v1)
+	Prefix string
+	// StripPrefix 이면 Prefix 를 제거한 path 로 mux 에 전달한다.
+	// gRPC Gateway annotation 의 path 에 version 이 없는 경우 사용한다.
+	StripPrefix bool
+	// Deprecated 는 version 이 deprecated 된 시각이다. 지정하면 응답에 Deprecation header 를 추가한다. (RFC 9745)
+	Deprecated time.Time
+	// Sunset 은 version 을 제거할 시각이다. 지정하면 응답에 Sunset header 를 추가한다. (RFC 8594)
+	Sunset time.Time
+	// Link 는 deprecation 안내 문서의 URL 이다. 지정하면 응답에 Link header 를 추가한다.
+	Link string
+	// Gone 이면 Sunset 이후의 요청에 410 Gone 으로 응답한다.
+	Gone bool
+}
+
+// RegisterHttpProxyVersion 은 path 가 version.Prefix 로 시작하는 요청을 처리하는 gRPC Gateway 를 등록한다.
+// version 별로 다른 handler 를 등록하며, deprecated 된 version 의 응답에는 Deprecation, Sunset header 를 추가한다.
+// deprecated 된 version 의 사용량은 api_version_deprecated_requests 지표로 확인한다.
+// 등록한 version 과 일치하지 않는 요청은 RegisterHttpProxyServer 의 mux 가 처리하고, 없으면 404 로 응답한다.
+//
+//	grpcServer.RegisterHttpProxyVersion(server.ApiVersion{Prefix: "/v1", Deprecated: deprecated, Sunset: sunset}, v1Handlers, nil, nil, nil, -1)
+//	grpcServer.RegisterHttpProxyVersion(server.ApiVersion{Prefix: "/v2"}, v2Handlers, nil, nil, nil, -1)
+func (pSelf *GrpcServer) RegisterHttpProxyVersion(version ApiVersion, httpProxyServerHandlerFuncSlice []HttpProxyServerHandler, ctx context.Context, mux *runtime.ServeMux, opts []grpc.DialOption, httpProxyPort int) {
+	if len(httpProxyServerHandlerFuncSlice) == 0 {
+		pSelf.options.recoverable("Http Proxy Server of %s is nil...", version.Prefix)
+		return
+	}
+	version.Prefix = "/" + strings.Trim(version.Prefix, "/")
+	if version.Prefix == "/" {
+		pSelf.options.recoverable("api version prefix is required")
+		return
+	}
+
+	if mux == nil {
+		mux = runtime.NewServeMux()
+	}
+	pSelf.httpProxyVersions = append(pSelf.httpProxyVersions, &apiVersionMount{version: version, mux: mux})
+	// 긴 prefix 부터 비교. (e.g. /v1beta 가 /v1 보다 먼저)
+	slices.SortStableFunc(pSelf.httpProxyVersions, func(a, b *apiVersionMount) int {
+		return len(b.version.Prefix) - len(a.version.Prefix)
+	})
+
+	pSelf.setHttpProxyPort(httpProxyPort)
+	pSelf.registerHttpGateways(httpProxyServerHandlerFuncSlice, ctx, mux, opts)
+}
+
+type apiVersionMount struct {
+	version ApiVersion
+	mux     *runtime.ServeMux
+}
+
+// match 는 path 가 version 의 prefix 와 일치하는지 여부이다.
+func (pSelf *apiVersionMount) match(path string) bool {
+	rest, ok := strings.CutPrefix(path, pSelf.version.Prefix)
+	return ok && (len(rest) == 0 || rest[0] == '/')
+}
+
+func (pSelf *apiVersionMount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	version := pSelf.version
+	if !version.Deprecated.IsZero() {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", version.Deprecated.Unix()))
+		addLabeledMetric("api_version_deprecated_requests", version.Prefix, 1)
+	}
+	if !version.Sunset.IsZero() {
+		w.Header().Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if len(version.Link) > 0 {
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", version.Link))
+	}
+	if version.Gone && !version.Sunset.IsZero() && !time.Now().Before(version.Sunset) {
+		addLabeledMetric("api_version_gone", version.Prefix, 1)
+		http.Error(w, "api version "+version.Prefix+" is no longer available", http.StatusGone)
+		return
+	}
+
+	if version.StripPrefix {
+		http.StripPrefix(version.Prefix, pSelf.mux).ServeHTTP(w, r)
+		return
+	}
+	pSelf.mux.ServeHTTP(w, r)
+}
+
+// apiVersionHandler 는 URL path prefix 로 API version 의 ServeMux 를 선택하는 handler 이다.
+type apiVersionHandler struct {
+	versions []*apiVersionMount
+	fallback http.Handler
+}
+
+func (pSelf *apiVersionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, mount := range pSelf.versions {
+		if mount.match(r.URL.Path) {
+			mount.ServeHTTP(w, r)
+			return
+		}
+	}
+	pSelf.fallback.ServeHTTP(w, r)
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"golang.org/x/sys/unix"
+	"net"
+	"syscall"
+)
+
+// setListenBacklog 는 listen(2) 를 다시 호출하여 Listener 의 backlog 를 변경한다.
+func setListenBacklog(listener net.Listener, backlog int) error {
+	syscallConn, ok := listener.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+
+	rawConn, err := syscallConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var listenErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		listenErr = unix.Listen(int(fd), backlog)
+	}); err != nil {
+		return err
+	}
+	return listenErr
+}
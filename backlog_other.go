@@ -0,0 +1,12 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+func setListenBacklog(_ net.Listener, _ int) error {
+	return errors.New("listen backlog is not supported on this platform")
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+)
+
+// ServerBuilder 는 New 의 인자를 이름 있는 method 로 설정하는 Builder 이다.
+//
+//	grpcServer := server.Builder().
+//		TCP(":9090").
+//		WithTLS(tlsConfig).
+//		WithGateway(9091, pb.RegisterGreeterHandlerFromEndpoint).
+//		WithHealth().
+//		Build()
+type ServerBuilder struct {
+	network string
+	address string
+	port    int
+	err     error
+
+	unaryServerInterceptors  []grpc.UnaryServerInterceptor
+	streamServerInterceptors []grpc.StreamServerInterceptor
+	opts                     []Option
+
+	gatewayHandlers []HttpProxyServerHandler
+	gatewayPort     int
+	gatewayMux      *runtime.ServeMux
+}
+
+// Builder 는 ServerBuilder 를 생성한다. network 를 지정하지 않으면 tcp, port 50051 을 사용한다.
+func Builder() *ServerBuilder {
+	return &ServerBuilder{
+		network:     defaultConfigNetwork,
+		port:        defaultConfigPort,
+		gatewayPort: -1,
+	}
+}
+
+// TCP 는 "host:port" 형식의 주소로 listen 한다. (e.g. ":9090", "127.0.0.1:9090")
+func (pSelf *ServerBuilder) TCP(address string) *ServerBuilder {
+	return pSelf.Listen("tcp", address)
+}
+
+// Unix 는 Unix Domain Socket 으로 listen 한다.
+func (pSelf *ServerBuilder) Unix(path string) *ServerBuilder {
+	return pSelf.Listen("unix", path)
+}
+
+// Vsock 은 vsock 의 port 로 listen 한다.
+func (pSelf *ServerBuilder) Vsock(port int) *ServerBuilder {
+	pSelf.network, pSelf.address, pSelf.port = "vsock", "", port
+	return pSelf
+}
+
+// Listen 은 network 와 "host:port" 형식의 주소로 listen 한다. unix 는 address 가 socket 파일 경로이다.
+func (pSelf *ServerBuilder) Listen(network, address string) *ServerBuilder {
+	host, port, err := splitAddress(network, address)
+	if err != nil {
+		pSelf.fail(fmt.Errorf("invalid %s address %q: %w", network, address, err))
+		return pSelf
+	}
+	pSelf.network, pSelf.address, pSelf.port = network, host, port
+	return pSelf
+}
+
+// WithTLS 는 TLS 를 사용한다. (WithTLS Option 참고)
+func (pSelf *ServerBuilder) WithTLS(tlsConfig *tls.Config) *ServerBuilder {
+	return pSelf.With(WithTLS(tlsConfig))
+}
+
+// WithGateway 는 gRPC Gateway (Http Proxy) Server 를 port 로 실행한다.
+// port 가 -1 이면 gRPC Server 의 port + 1 을 사용한다.
+func (pSelf *ServerBuilder) WithGateway(port int, handlers ...HttpProxyServerHandler) *ServerBuilder {
+	pSelf.gatewayPort = port
+	pSelf.gatewayHandlers = append(pSelf.gatewayHandlers, handlers...)
+	return pSelf
+}
+
+// WithGatewayMux 는 gRPC Gateway 가 사용할 ServeMux 이다. 지정하지 않으면 새로 만든다.
+func (pSelf *ServerBuilder) WithGatewayMux(mux *runtime.ServeMux) *ServerBuilder {
+	pSelf.gatewayMux = mux
+	return pSelf
+}
+
+// WithHealth 는 grpc.health.v1.Health Service 를 등록한다. (WithHealthCheck Option 참고)
+func (pSelf *ServerBuilder) WithHealth() *ServerBuilder {
+	return pSelf.With(WithHealthCheck())
+}
+
+// WithReflection 은 Server Reflection Service 를 등록한다. hiddenServices 는 Service 목록에서 제외한다.
+func (pSelf *ServerBuilder) WithReflection(hiddenServices ...string) *ServerBuilder {
+	return pSelf.With(WithReflection(hiddenServices...))
+}
+
+// WithRecovery 는 Handler 의 panic 을 복구한다.
+func (pSelf *ServerBuilder) WithRecovery() *ServerBuilder {
+	return pSelf.With(WithRecovery())
+}
+
+// WithUnaryInterceptors 는 Unary Interceptor 를 추가한다. 추가한 순서대로 실행된다.
+func (pSelf *ServerBuilder) WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) *ServerBuilder {
+	pSelf.unaryServerInterceptors = append(pSelf.unaryServerInterceptors, interceptors...)
+	return pSelf
+}
+
+// WithStreamInterceptors 는 Stream Interceptor 를 추가한다. 추가한 순서대로 실행된다.
+func (pSelf *ServerBuilder) WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) *ServerBuilder {
+	pSelf.streamServerInterceptors = append(pSelf.streamServerInterceptors, interceptors...)
+	return pSelf
+}
+
+// With 는 Builder method 가 없는 Option 을 추가한다.
+func (pSelf *ServerBuilder) With(opts ...Option) *ServerBuilder {
+	pSelf.opts = append(pSelf.opts, opts...)
+	return pSelf
+}
+
+func (pSelf *ServerBuilder) fail(err error) {
+	if pSelf.err == nil {
+		pSelf.err = err
+	}
+}
+
+// Build 는 설정한 값으로 gRPC Server 를 생성한다. 잘못된 설정이 있으면 종료한다.
+func (pSelf *ServerBuilder) Build() *GrpcServer {
+	if pSelf.err != nil {
+		gLogger.Fatal(pSelf.err)
+	}
+
+	grpcServer := New(pSelf.network, pSelf.address, pSelf.port, pSelf.unaryServerInterceptors, pSelf.streamServerInterceptors, pSelf.opts...)
+	if len(pSelf.gatewayHandlers) > 0 {
+		mux := pSelf.gatewayMux
+		if mux == nil {
+			mux = runtime.NewServeMux()
+		}
+		grpcServer.RegisterHttpProxyServer(pSelf.gatewayHandlers, context.Background(), mux, nil, pSelf.gatewayPort)
+	}
+	return grpcServer
+}
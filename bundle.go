@@ -0,0 +1,117 @@
+package server
+
+import (
+	"google.golang.org/grpc"
+	"reflect"
+	"slices"
+)
+
+// MiddlewareBundle 은 ServerGroup 의 모든 Server 에 함께 적용할 Interceptor 와 Option 의 묶음이다. (e.g. 인증, 지표)
+// Name 으로 Server 별로 제외하거나 (WithoutBundles) 바꿀 수 있다. (WithBundle)
+type MiddlewareBundle struct {
+	Name    string
+	Unary   []grpc.UnaryServerInterceptor
+	Stream  []grpc.StreamServerInterceptor
+	Options []Option
+}
+
+// WithBundle 은 ServerGroup 의 같은 이름의 MiddlewareBundle 대신 bundle 을 적용한다.
+// 같은 이름의 MiddlewareBundle 이 없으면 ServerGroup 의 MiddlewareBundle 다음에 추가한다.
+// ServerGroup.New 로 생성하는 Server 에만 적용된다.
+func WithBundle(bundle MiddlewareBundle) Option {
+	return func(options *serverOptions) {
+		options.bundleOverrides = append(options.bundleOverrides, bundle)
+	}
+}
+
+// WithoutBundles 는 ServerGroup 의 MiddlewareBundle 중 names 를 적용하지 않는다.
+// ServerGroup.New 로 생성하는 Server 에만 적용된다.
+func WithoutBundles(names ...string) Option {
+	return func(options *serverOptions) {
+		options.excludedBundles = append(options.excludedBundles, names...)
+	}
+}
+
+// bundleSelectionFuncs 는 WithBundle, WithoutBundles 가 반환하는 Option 의 함수 주소이다.
+// 같은 함수에서 만든 closure 는 같은 주소이므로 Option 을 적용하지 않고 구분할 수 있다.
+var bundleSelectionFuncs = []uintptr{
+	reflect.ValueOf(WithBundle(MiddlewareBundle{})).Pointer(),
+	reflect.ValueOf(WithoutBundles()).Pointer(),
+}
+
+// bundleSelectionOption 은 opt 가 WithBundle 또는 WithoutBundles 인지 여부이다.
+func bundleSelectionOption(opt Option) bool {
+	return opt != nil && slices.Contains(bundleSelectionFuncs, reflect.ValueOf(opt).Pointer())
+}
+
+// Use 는 ServerGroup.New 로 생성하는 모든 Server 에 bundles 를 적용한다.
+// Interceptor 는 Use 로 추가한 순서대로, Server 의 Interceptor 보다 먼저 실행된다.
+func (pSelf *ServerGroup) Use(bundles ...MiddlewareBundle) {
+	pSelf.bundles = append(pSelf.bundles, bundles...)
+}
+
+// New 는 ServerGroup 의 MiddlewareBundle 을 적용하여 gRPC Server 를 생성하고 name 으로 추가한다.
+// opts 는 MiddlewareBundle 의 Option 보다 나중에 적용된다.
+func (pSelf *ServerGroup) New(
+	name string,
+	network, address string,
+	port int,
+	unaryServerInterceptors []grpc.UnaryServerInterceptor,
+	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
+) *GrpcServer {
+	// WithBundle, WithoutBundles 만 먼저 적용하여 MiddlewareBundle 을 정하고, 나머지 Option 은 New 에서 한 번만 적용한다.
+	selection := &serverOptions{}
+	serverOpts := make([]Option, 0, len(opts))
+	for _, opt := range opts {
+		if bundleSelectionOption(opt) {
+			opt(selection)
+			continue
+		}
+		serverOpts = append(serverOpts, opt)
+	}
+
+	var bundleUnary []grpc.UnaryServerInterceptor
+	var bundleStream []grpc.StreamServerInterceptor
+	var bundleOptions []Option
+	for _, bundle := range pSelf.resolveBundles(selection) {
+		bundleUnary = append(bundleUnary, bundle.Unary...)
+		bundleStream = append(bundleStream, bundle.Stream...)
+		bundleOptions = append(bundleOptions, bundle.Options...)
+	}
+
+	grpcServer := New(
+		network, address, port,
+		append(bundleUnary, unaryServerInterceptors...),
+		append(bundleStream, streamServerInterceptors...),
+		append(bundleOptions, serverOpts...)...,
+	)
+	pSelf.Add(name, grpcServer)
+	return grpcServer
+}
+
+// resolveBundles 는 Server 별 WithBundle, WithoutBundles 를 반영한 MiddlewareBundle 목록이다.
+func (pSelf *ServerGroup) resolveBundles(options *serverOptions) []MiddlewareBundle {
+	overrides := slices.Clone(options.bundleOverrides)
+	takeOverride := func(name string) (MiddlewareBundle, bool) {
+		for i, bundle := range overrides {
+			if len(name) > 0 && bundle.Name == name {
+				overrides = slices.Delete(overrides, i, i+1)
+				return bundle, true
+			}
+		}
+		return MiddlewareBundle{}, false
+	}
+
+	var bundles []MiddlewareBundle
+	for _, bundle := range pSelf.bundles {
+		if override, ok := takeOverride(bundle.Name); ok {
+			bundle = override
+		}
+		if len(bundle.Name) > 0 && slices.Contains(options.excludedBundles, bundle.Name) {
+			continue
+		}
+		bundles = append(bundles, bundle)
+	}
+	return append(bundles, overrides...)
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultCertificateExpiryInterval = time.Hour
+
+// defaultCertificateExpiryWarnings 는 만료까지 남은 시간이 이 값보다 짧아질 때마다 경고하는 기본 시점이다.
+var defaultCertificateExpiryWarnings = []time.Duration{30 * 24 * time.Hour, 14 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour}
+
+// CertificateExpiryOptions 는 인증서 만료 감시 설정이다.
+type CertificateExpiryOptions struct {
+	// Interval 은 인증서를 확인하는 주기이다. (기본값: 1h)
+	Interval time.Duration
+	// Warnings 는 경고할 만료 전 시점이다. 남은 시간이 더 짧은 시점을 지날 때마다 다시 경고한다. (기본값: 30일, 14일, 7일, 1일)
+	Warnings []time.Duration
+	// Files 는 함께 확인할 PEM 인증서 파일이다. (e.g. WithTLS 의 ClientCAs 로 읽은 CA 파일)
+	// NewFromConfig 로 생성하면 tls.client_ca_file 은 자동으로 확인한다.
+	Files []string
+	// OnWarning 은 경고할 때 호출한다. (e.g. 알림 발송)
+	OnWarning func(expiry CertificateExpiry)
+}
+
+// CertificateExpiry 는 Server 가 사용하는 인증서 하나의 만료 정보이다.
+type CertificateExpiry struct {
+	// Source 는 인증서를 사용하는 곳이다. (e.g. tls, grpc-1, admin, http3, client_ca, 파일 경로)
+	Source   string
+	Subject  string
+	NotAfter time.Time
+}
+
+// Remaining 은 만료까지 남은 시간이다. 만료했으면 음수이다.
+func (pSelf CertificateExpiry) Remaining() time.Duration {
+	return time.Until(pSelf.NotAfter)
+}
+
+// WithCertificateExpiry 는 Server 가 사용하는 인증서 (WithTLS, Listener, HTTP/3, client CA) 의 만료 시각을 주기적으로 확인한다.
+// certificate_not_after 지표로 "source:subject" 별 만료 시각 (Unix 초) 을 노출하고, 만료가 가까워지면 Warnings 의 시점마다 log 로 경고한다.
+// 설정을 다시 읽어 바꾼 인증서도 다음 확인부터 반영한다.
+func WithCertificateExpiry(expiryOptions CertificateExpiryOptions) Option {
+	return func(options *serverOptions) {
+		if expiryOptions.Interval <= 0 {
+			expiryOptions.Interval = defaultCertificateExpiryInterval
+		}
+		if len(expiryOptions.Warnings) == 0 {
+			expiryOptions.Warnings = defaultCertificateExpiryWarnings
+		}
+		expiryOptions.Warnings = slices.Clone(expiryOptions.Warnings)
+		slices.SortFunc(expiryOptions.Warnings, func(a, b time.Duration) int { return cmp.Compare(b, a) })
+		options.certificateExpiry = &certificateExpiry{options: expiryOptions, warned: map[string]int{}, cStop: make(chan struct{})}
+	}
+}
+
+// CertificateExpiries 는 Server 가 사용하는 인증서의 만료 정보이다.
+func (pSelf *GrpcServer) CertificateExpiries() []CertificateExpiry {
+	var expiries []CertificateExpiry
+	add := func(source string, certificates ...*x509.Certificate) {
+		for _, certificate := range certificates {
+			expiries = append(expiries, CertificateExpiry{Source: source, Subject: certificate.Subject.String(), NotAfter: certificate.NotAfter})
+		}
+	}
+	addTLS := func(source string, tlsConfig *tls.Config) {
+		if tlsConfig == nil {
+			return
+		}
+		for _, certificate := range tlsConfig.Certificates {
+			if leaf := leafCertificate(certificate); leaf != nil {
+				add(source, leaf)
+			}
+		}
+	}
+
+	if pSelf.options.tlsStore != nil {
+		if certificate := pSelf.options.tlsStore.certificate.Load(); certificate != nil {
+			if leaf := leafCertificate(*certificate); leaf != nil {
+				add("tls", leaf)
+			}
+		}
+	} else {
+		addTLS("tls", pSelf.options.tlsConfig)
+	}
+	for _, l := range pSelf.namedListeners {
+		addTLS(l.name, l.tlsConfig)
+	}
+	if pSelf.options.http3 != nil && pSelf.options.http3.TLS != pSelf.options.tlsConfig {
+		addTLS("http3", pSelf.options.http3.TLS)
+	}
+
+	var files []string
+	if pSelf.options.certificateExpiry != nil {
+		files = pSelf.options.certificateExpiry.options.Files
+	}
+	if config := pSelf.EffectiveConfig(); config != nil && len(config.TLS.ClientCAFile) > 0 {
+		certificates, err := readCertificates(config.TLS.ClientCAFile)
+		if err != nil {
+			gLogger.Printf("Failed to read client CA for expiry check: %v\n", err)
+		}
+		add("client_ca", certificates...)
+	}
+	for _, file := range files {
+		certificates, err := readCertificates(file)
+		if err != nil {
+			gLogger.Printf("Failed to read certificate for expiry check: %v\n", err)
+		}
+		add(file, certificates...)
+	}
+	return expiries
+}
+
+// certificateExpiry 는 인증서별로 마지막으로 경고한 시점을 기억한다.
+type certificateExpiry struct {
+	options CertificateExpiryOptions
+
+	mutex sync.Mutex
+	// warned 는 인증서 (source, subject, 만료 시각) 별로 경고한 Warnings 의 수이다. 인증서를 바꾸면 처음부터 경고한다.
+	warned map[string]int
+
+	// cStop 은 Server 를 종료할 때 닫아 감시를 멈춘다.
+	cStop    chan struct{}
+	stopOnce sync.Once
+}
+
+// stop 은 인증서 만료 감시를 멈춘다.
+func (pSelf *certificateExpiry) stop() {
+	pSelf.stopOnce.Do(func() {
+		close(pSelf.cStop)
+	})
+}
+
+// watchCertificateExpiry 는 Server 를 종료할 때까지 Interval 마다 인증서 만료를 확인한다.
+func (pSelf *GrpcServer) watchCertificateExpiry() {
+	ticker := time.NewTicker(pSelf.options.certificateExpiry.options.Interval)
+	defer ticker.Stop()
+	for {
+		pSelf.checkCertificateExpiry()
+		select {
+		case <-ticker.C:
+		case <-pSelf.options.certificateExpiry.cStop:
+			return
+		}
+	}
+}
+
+// checkCertificateExpiry 는 지표를 갱신하고, 경고할 시점을 지난 인증서를 경고한다.
+func (pSelf *GrpcServer) checkCertificateExpiry() {
+	expiries := pSelf.CertificateExpiries()
+	watcher := pSelf.options.certificateExpiry
+
+	values := map[string]int64{}
+	current := map[string]bool{}
+	for _, expiry := range expiries {
+		values[expiry.Source+":"+expiry.Subject] = expiry.NotAfter.Unix()
+
+		key := fmt.Sprintf("%s|%s|%d", expiry.Source, expiry.Subject, expiry.NotAfter.Unix())
+		current[key] = true
+		remaining := expiry.Remaining()
+		level := 0
+		for _, warning := range watcher.options.Warnings {
+			if remaining <= warning {
+				level++
+			}
+		}
+		if remaining <= 0 {
+			level = len(watcher.options.Warnings) + 1
+		}
+
+		watcher.mutex.Lock()
+		escalated := level > watcher.warned[key]
+		if escalated {
+			watcher.warned[key] = level
+		}
+		watcher.mutex.Unlock()
+		if !escalated {
+			continue
+		}
+
+		addLabeledMetric("certificate_expiry_warnings", expiry.Source, 1)
+		if remaining <= 0 {
+			gLogger.Printf("ERROR: certificate expired: source=%s subject=%q not_after=%s\n", expiry.Source, expiry.Subject, expiry.NotAfter.Format(time.RFC3339))
+		} else {
+			gLogger.Printf("WARNING: certificate expires in %s: source=%s subject=%q not_after=%s\n", remaining.Round(time.Minute), expiry.Source, expiry.Subject, expiry.NotAfter.Format(time.RFC3339))
+		}
+		if watcher.options.OnWarning != nil {
+			watcher.options.OnWarning(expiry)
+		}
+	}
+	setLabeledMetrics("certificate_not_after", values)
+
+	// 바꾼 인증서의 경고 기록은 지운다.
+	watcher.mutex.Lock()
+	for key := range watcher.warned {
+		if !current[key] {
+			delete(watcher.warned, key)
+		}
+	}
+	watcher.mutex.Unlock()
+}
+
+// leafCertificate 는 인증서 chain 의 첫 인증서이다.
+func leafCertificate(certificate tls.Certificate) *x509.Certificate {
+	if certificate.Leaf != nil {
+		return certificate.Leaf
+	}
+	if len(certificate.Certificate) == 0 {
+		return nil
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	return leaf
+}
+
+// readCertificates 는 PEM 파일의 모든 인증서를 읽는다.
+func readCertificates(file string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	certificates, err := parseCertificates(data)
+	if err != nil {
+		return certificates, fmt.Errorf("%s: %w", file, err)
+	}
+	return certificates, nil
+}
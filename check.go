@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// CheckResult 는 설정 확인 항목 하나의 결과이다. Err 가 nil 이면 통과한 것이다.
+type CheckResult struct {
+	Name   string
+	Detail string
+	Err    error
+}
+
+// CheckReport 는 CheckConfig 의 결과이다.
+type CheckReport struct {
+	Results []CheckResult
+}
+
+// Err 는 실패한 항목의 오류를 모두 모아서 반환한다.
+func (pSelf CheckReport) Err() error {
+	var errs []error
+	for _, result := range pSelf.Results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Name, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteTo 는 항목별 결과를 한 줄씩 기록한다.
+func (pSelf CheckReport) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	for _, result := range pSelf.Results {
+		switch {
+		case result.Err != nil:
+			// errors.Join 으로 모은 오류는 한 줄씩 기록.
+			for _, line := range strings.Split(result.Err.Error(), "\n") {
+				fmt.Fprintf(&sb, "FAIL  %s: %s\n", result.Name, line)
+			}
+		case len(result.Detail) > 0:
+			fmt.Fprintf(&sb, "ok    %s (%s)\n", result.Name, result.Detail)
+		default:
+			fmt.Fprintf(&sb, "ok    %s\n", result.Name)
+		}
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// CheckConfig 는 Server 를 실행하지 않고 config 로 실행할 수 있는지 확인한다. (CI, 배포 전 확인 용도)
+// 설정을 검증하고, TLS 인증서를 읽고, New 가 여는 Listener 를 bind 한 뒤 바로 닫는다. 파일이나 socket 은 바꾸지 않는다.
+func CheckConfig(config *Config) CheckReport {
+	var report CheckReport
+	check := func(name, detail string, err error) {
+		report.Results = append(report.Results, CheckResult{Name: name, Detail: detail, Err: err})
+	}
+
+	if err := config.Validate(); err != nil {
+		// 잘못된 설정으로 bind 하지 않는다.
+		check("config", "", err)
+		return report
+	}
+	check("config", "", nil)
+
+	if len(config.TLS.CertFile) > 0 {
+		detail, err := checkCertificate(config.TLS)
+		check("tls", detail, err)
+	}
+
+	options := &serverOptions{}
+	check("listen grpc", joinAddress(config.Network, config.Address, config.Port), checkListen(config.Network, config.Address, config.Port, options))
+	// HttpPort 가 없으면 gRPC Gateway 를 등록했을 때 사용하는 기본 port (gRPC port + 1) 를 확인한다.
+	if httpPort := checkHttpPort(config); httpPort > 0 {
+		network := "tcp"
+		switch strings.ToLower(config.Network) {
+		case "vsock", "tcp4", "tcp6":
+			network = config.Network
+		}
+		check("listen http", joinAddress(network, config.Address, httpPort), checkListen(network, config.Address, httpPort, options))
+	}
+
+	if len(config.PIDFile) > 0 {
+		check("pid file", config.PIDFile, checkPIDFile(config.PIDFile))
+	}
+
+	return report
+}
+
+// DryRun 은 CheckConfig 의 결과를 표준 출력에 기록하고 종료한다. 실패한 항목이 있으면 종료 코드는 1 이다.
+//
+//	if *checkOnly {
+//		server.DryRun(config)
+//	}
+func DryRun(config *Config) {
+	report := CheckConfig(config)
+	_, _ = report.WriteTo(os.Stdout)
+	if report.Err() != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func checkCertificate(tlsConfig TLSConfig) (string, error) {
+	_, store, err := tlsConfig.load()
+	if err != nil {
+		return "", err
+	}
+	leaf := store.certificate.Load().Leaf
+	if leaf == nil {
+		return "", nil
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return "", fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+	return "expires " + leaf.NotAfter.Format(time.RFC3339), nil
+}
+
+// checkHttpPort 는 New 가 gRPC Gateway 에 사용할 port 이다. 확인할 수 없으면 (임의의 port, unix socket) 0 이다.
+func checkHttpPort(config *Config) int {
+	if config.HttpPort > 0 {
+		return config.HttpPort
+	}
+	if strings.EqualFold("unix", config.Network) || config.Port <= 0 {
+		return 0
+	}
+	return config.Port + 1
+}
+
+// checkListen 은 주소를 bind 할 수 있는지 확인하고 바로 닫는다.
+// unix socket 은 남아 있는 파일을 지우지 않으며, 비정상 종료로 남은 파일이면 bind 하지 않고 통과한다. (New 가 지우고 bind 한다)
+func checkListen(network, address string, port int, options *serverOptions) error {
+	if strings.EqualFold("unix", network) {
+		stale, err := probeUnixSocket(address)
+		if err != nil || stale {
+			return err
+		}
+		// 닫으면 만든 socket 파일도 지운다.
+		listener, err := net.Listen("unix", address)
+		if err != nil {
+			return err
+		}
+		return listener.Close()
+	}
+
+	listener, err := listen(network, address, port, options)
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/proto"
+	"slices"
+	"strings"
+)
+
+// RegisterCodec 은 content-subtype 이 codec.Name() 인 요청 (e.g. application/grpc+flatbuffers) 을 처리할 codec 을 process 전체에 등록한다.
+// gRPC 의 codec 등록은 thread-safe 하지 않으므로 Server 를 생성하기 전, init 에서 호출한다.
+// gRPC 는 content-subtype 을 소문자로 바꾸어 찾으므로 이름은 소문자여야 하고 proto 일 수 없다.
+//
+//	func init() {
+//		server.RegisterCodec(flatbuffers.FlatbuffersCodec{})
+//	}
+func RegisterCodec(codec encoding.Codec) {
+	if err := checkCodecName(codec.Name()); err != nil {
+		gLogger.Fatal(err)
+	}
+	encoding.RegisterCodec(codec)
+}
+
+// WithCodec 은 RegisterCodec 으로 등록한 codec 으로 content-subtype 이 codec.Name() 인 요청을 처리한다.
+// content-subtype 이 없거나 proto 인 요청은 그대로 proto 로 처리하므로 FlatBuffers 와 proto Service 를 함께 등록할 수 있다. (WithForcedCodec 참고)
+func WithCodec(codec encoding.Codec) Option {
+	return func(options *serverOptions) {
+		name := codec.Name()
+		if err := checkCodecName(name); err != nil {
+			gLogger.Fatal(err)
+		}
+		if encoding.GetCodecV2(name) == nil {
+			gLogger.Fatalf("Codec %s is not registered: call RegisterCodec in init\n", name)
+		}
+		if !slices.Contains(options.codecs, name) {
+			options.codecs = append(options.codecs, name)
+		}
+	}
+}
+
+// checkCodecName 은 content-subtype 으로 찾을 수 있는 codec 이름인지 확인한다.
+func checkCodecName(name string) error {
+	if len(name) == 0 || name == "proto" {
+		return fmt.Errorf("invalid codec name: %q", name)
+	}
+	if name != strings.ToLower(name) {
+		return fmt.Errorf("codec name must be lowercase: %q", name)
+	}
+	return nil
+}
+
+// WithForcedCodec 은 content-subtype 과 관계없이 모든 요청을 codec 으로 처리한다. (e.g. message 를 decode 하지 않고 전달하는 BytesCodec)
+// 등록한 proto Service 와 기본 Service (health check, reflection) 도 codec 으로 처리하므로 codec 이 proto message 도 처리해야 한다.
+// WithProxy 와 함께 사용할 수 없다.
+func WithForcedCodec(codec encoding.Codec) Option {
+	return func(options *serverOptions) {
+		options.forcedCodec = codec
+	}
+}
+
+// BytesCodec 은 []byte 는 그대로 보내고 받으며, 나머지 message 는 proto 로 처리하는 codec 이다.
+// WithForcedCodec 과 grpc.UnknownServiceHandler 로 message 를 decode 하지 않고 전달할 때 사용한다.
+//
+//	forward := func(srv any, stream grpc.ServerStream) error {
+//		var frame []byte
+//		if err := stream.RecvMsg(&frame); err != nil {
+//			return err
+//		}
+//		...
+//	}
+//	grpcServer := server.New("tcp", "", 50051, nil, nil,
+//		server.WithForcedCodec(server.BytesCodec{}),
+//		server.WithServerFactory(func(serverOptions ...grpc.ServerOption) (server.ServiceServer, error) {
+//			return grpc.NewServer(append(serverOptions, grpc.UnknownServiceHandler(forward))...), nil
+//		}))
+type BytesCodec struct{}
+
+func (BytesCodec) Marshal(v any) ([]byte, error) {
+	switch message := v.(type) {
+	case []byte:
+		return message, nil
+	case *[]byte:
+		return *message, nil
+	case proto.Message:
+		return proto.Marshal(message)
+	}
+	return nil, fmt.Errorf("bytes codec: unsupported message type %T", v)
+}
+
+func (BytesCodec) Unmarshal(data []byte, v any) error {
+	switch message := v.(type) {
+	case *[]byte:
+		// data 는 Unmarshal 이 끝나면 재사용되므로 복사한다.
+		*message = append((*message)[:0], data...)
+		return nil
+	case proto.Message:
+		return proto.Unmarshal(data, message)
+	}
+	return fmt.Errorf("bytes codec: unsupported message type %T", v)
+}
+
+// Name 은 proto 이다. content-subtype 이 없는 일반 gRPC client 의 요청을 받기 위해 WithForcedCodec 으로 사용한다.
+func (BytesCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// CompressionGzip 은 gzip 압축이다.
+	CompressionGzip = "gzip"
+	// CompressionZstd 는 zstd 압축이다. gzip 보다 빠르고 압축률이 높지만 client 도 zstd 를 등록해야 한다.
+	CompressionZstd = "zstd"
+
+	// zstdMaxWindow 는 압축을 풀 때 허용하는 가장 큰 window 이다. (zstd 명세의 권장값)
+	zstdMaxWindow = 8 << 20
+	// defaultCompressionMinSize 는 압축하는 가장 작은 응답 message 의 크기이다.
+	defaultCompressionMinSize = 1024
+)
+
+var (
+	gzipCompression = &gzipCompressor{}
+	zstdCompression = &zstdCompressor{}
+)
+
+// encoding.RegisterCompressor 는 init 에서만 호출할 수 있으므로 package 초기화 때 등록하고, 압축 수준은 WithCompression 에서 설정한다.
+func init() {
+	encoding.RegisterCompressor(gzipCompression)
+	encoding.RegisterCompressor(zstdCompression)
+}
+
+// CompressionOptions 는 gRPC message 압축 설정이다.
+type CompressionOptions struct {
+	// Compressors 는 사용할 압축 방식이다. client 가 지원하는 (grpc-accept-encoding) 방식 중 앞의 것으로 응답을 압축한다.
+	// (기본값: zstd, gzip)
+	Compressors []string
+	// GzipLevel 은 gzip 압축 수준이다. (gzip.BestSpeed ~ gzip.BestCompression, 기본값: gzip.DefaultCompression)
+	GzipLevel int
+	// ZstdLevel 은 zstd 압축 수준이다. (1 ~ 22 의 zstd 수준, 기본값: 3)
+	ZstdLevel int
+	// MinSize 는 압축하는 가장 작은 응답 message 의 크기 (bytes) 이다. Stream 은 첫 응답 message 의 크기로 정한다. (기본값: 1024)
+	MinSize int
+}
+
+// WithCompression 은 client 가 지원하면 gzip, zstd 로 응답을 압축한다.
+// 압축한 요청은 방식과 관계없이 압축을 풀며, 압축하지 않은 요청에도 client 가 지원하면 압축하여 응답한다.
+// 같은 host 의 client (gRPC Gateway, WebTransport 등 Server 가 직접 연결한 client) 와 MinSize 보다 작은 응답은 압축하지 않는다.
+// gzip, zstd 는 package 초기화 때 process 전체에 등록하므로 여러 Server 의 GzipLevel, ZstdLevel 은 처음 설정한 값을 사용한다.
+// 압축한 응답은 compressed_responses 지표로 확인한다.
+func WithCompression(compressionOptions CompressionOptions) Option {
+	return func(options *serverOptions) {
+		if len(compressionOptions.Compressors) == 0 {
+			compressionOptions.Compressors = []string{CompressionZstd, CompressionGzip}
+		}
+		if compressionOptions.GzipLevel == 0 {
+			compressionOptions.GzipLevel = gzip.DefaultCompression
+		}
+		if compressionOptions.ZstdLevel <= 0 {
+			compressionOptions.ZstdLevel = 3
+		}
+		if compressionOptions.MinSize <= 0 {
+			compressionOptions.MinSize = defaultCompressionMinSize
+		}
+		for _, name := range compressionOptions.Compressors {
+			switch name {
+			case CompressionGzip:
+				if _, err := gzip.NewWriterLevel(io.Discard, compressionOptions.GzipLevel); err != nil {
+					gLogger.Fatalf("Invalid gzip level: %v", err)
+				}
+				gzipCompression.configure(compressionOptions.GzipLevel)
+			case CompressionZstd:
+				zstdCompression.configure(zstd.EncoderLevelFromZstd(compressionOptions.ZstdLevel))
+			default:
+				if encoding.GetCompressor(name) == nil {
+					gLogger.Fatalf("Unknown compressor: %s", name)
+				}
+			}
+		}
+		options.compression = &compressionOptions
+	}
+}
+
+// sendCompressor 는 client 가 지원하는 방식 중 응답을 압축할 방식을 정한다.
+func (pSelf *CompressionOptions) sendCompressor(ctx context.Context, resp any) {
+	if message, ok := resp.(proto.Message); ok && proto.Size(message) < pSelf.MinSize {
+		return
+	}
+	// 같은 host 의 client 는 압축해도 전송량의 이득이 없다.
+	if p, ok := peer.FromContext(ctx); ok && localPeer(p) {
+		return
+	}
+	supported, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		return
+	}
+	for _, name := range pSelf.Compressors {
+		if slices.Contains(supported, name) {
+			if err := grpc.SetSendCompressor(ctx, name); err == nil {
+				addLabeledMetric("compressed_responses", name, 1)
+			}
+			return
+		}
+	}
+}
+
+func (pSelf *CompressionOptions) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	// 응답 header 는 handler 가 끝난 뒤 보내므로, 응답 message 의 크기를 보고 압축 방식을 정한다.
+	if err == nil && !builtinMethod(info.FullMethod) {
+		pSelf.sendCompressor(ctx, resp)
+	}
+	return resp, err
+}
+
+func (pSelf *CompressionOptions) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if builtinMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	return handler(srv, &compressionServerStream{ServerStream: ss, options: pSelf})
+}
+
+// compressionServerStream 은 첫 응답 message 의 크기를 보고 압축 방식을 정하는 grpc.ServerStream 이다.
+type compressionServerStream struct {
+	grpc.ServerStream
+	options *CompressionOptions
+	sent    bool
+}
+
+func (pSelf *compressionServerStream) SendHeader(md metadata.MD) error {
+	pSelf.sent = true
+	return pSelf.ServerStream.SendHeader(md)
+}
+
+func (pSelf *compressionServerStream) SendMsg(m any) error {
+	if !pSelf.sent {
+		pSelf.sent = true
+		pSelf.options.sendCompressor(pSelf.Context(), m)
+	}
+	return pSelf.ServerStream.SendMsg(m)
+}
+
+// gzipCompressor 는 gzip writer, reader 를 재사용하는 encoding.Compressor 이다.
+type gzipCompressor struct {
+	// level 은 WithCompression 에서 처음 설정한 압축 수준이다. 설정하지 않으면 gzip.DefaultCompression 이다.
+	level   atomic.Pointer[int]
+	writers sync.Pool
+	readers sync.Pool
+}
+
+// configure 는 처음 설정한 압축 수준만 사용한다.
+func (pSelf *gzipCompressor) configure(level int) {
+	pSelf.level.CompareAndSwap(nil, &level)
+}
+
+func (pSelf *gzipCompressor) Name() string {
+	return CompressionGzip
+}
+
+func (pSelf *gzipCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if writer, ok := pSelf.writers.Get().(*gzipWriter); ok {
+		writer.Reset(w)
+		return writer, nil
+	}
+	level := gzip.DefaultCompression
+	if configured := pSelf.level.Load(); configured != nil {
+		level = *configured
+	}
+	writer, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipWriter{Writer: writer, pool: &pSelf.writers}, nil
+}
+
+func (pSelf *gzipCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	reader, ok := pSelf.readers.Get().(*gzipReader)
+	if !ok {
+		gzReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &gzipReader{Reader: gzReader, pool: &pSelf.readers}, nil
+	}
+	if err := reader.Reset(r); err != nil {
+		pSelf.readers.Put(reader)
+		return nil, err
+	}
+	reader.returned = false
+	return reader, nil
+}
+
+type gzipWriter struct {
+	*gzip.Writer
+	pool *sync.Pool
+}
+
+func (pSelf *gzipWriter) Close() error {
+	defer pSelf.pool.Put(pSelf)
+	return pSelf.Writer.Close()
+}
+
+// gzipReader 는 끝까지 읽으면 pool 로 돌아간다.
+type gzipReader struct {
+	*gzip.Reader
+	pool     *sync.Pool
+	returned bool
+}
+
+func (pSelf *gzipReader) Read(p []byte) (int, error) {
+	n, err := pSelf.Reader.Read(p)
+	if err == io.EOF && !pSelf.returned {
+		pSelf.returned = true
+		pSelf.pool.Put(pSelf)
+	}
+	return n, err
+}
+
+// zstdCompressor 는 zstd encoder, decoder 를 재사용하는 encoding.Compressor 이다.
+type zstdCompressor struct {
+	// level 은 WithCompression 에서 처음 설정한 압축 수준이다. 설정하지 않으면 zstd.SpeedDefault 이다.
+	level    atomic.Pointer[zstd.EncoderLevel]
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+// configure 는 처음 설정한 압축 수준만 사용한다.
+func (pSelf *zstdCompressor) configure(level zstd.EncoderLevel) {
+	pSelf.level.CompareAndSwap(nil, &level)
+}
+
+func (pSelf *zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+func (pSelf *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if writer, ok := pSelf.encoders.Get().(*zstdWriter); ok {
+		writer.Reset(w)
+		return writer, nil
+	}
+	level := zstd.SpeedDefault
+	if configured := pSelf.level.Load(); configured != nil {
+		level = *configured
+	}
+	encoder, err := zstd.NewWriter(w, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{Encoder: encoder, pool: &pSelf.encoders}, nil
+}
+
+func (pSelf *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	reader, ok := pSelf.decoders.Get().(*zstdReader)
+	if !ok {
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return nil, err
+		}
+		return &zstdReader{Decoder: decoder, pool: &pSelf.decoders}, nil
+	}
+	if err := reader.Reset(r); err != nil {
+		pSelf.decoders.Put(reader)
+		return nil, err
+	}
+	reader.returned = false
+	return reader, nil
+}
+
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (pSelf *zstdWriter) Close() error {
+	defer pSelf.pool.Put(pSelf)
+	return pSelf.Encoder.Close()
+}
+
+// zstdReader 는 끝까지 읽으면 pool 로 돌아간다.
+type zstdReader struct {
+	*zstd.Decoder
+	pool     *sync.Pool
+	returned bool
+}
+
+func (pSelf *zstdReader) Read(p []byte) (int, error) {
+	n, err := pSelf.Decoder.Read(p)
+	if err == io.EOF && !pSelf.returned {
+		pSelf.returned = true
+		pSelf.pool.Put(pSelf)
+	}
+	return n, err
+}
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config 는 설정 파일이나 환경 변수로 gRPC Server 를 생성하기 위한 설정이다.
+type Config struct {
+	Network string `json:"network" yaml:"network" toml:"network"`
+	Address string `json:"address" yaml:"address" toml:"address"`
+	Port    int    `json:"port" yaml:"port" toml:"port"`
+	// HttpPort 는 RegisterHttpProxyServer 의 httpProxyPort 가 -1 일 때 사용할 Http Proxy Server 의 port 이다.
+	HttpPort int `json:"http_port" yaml:"http_port" toml:"http_port"`
+
+	TLS        TLSConfig        `json:"tls" yaml:"tls" toml:"tls"`
+	Limits     LimitsConfig     `json:"limits" yaml:"limits" toml:"limits"`
+	Middleware MiddlewareConfig `json:"middleware" yaml:"middleware" toml:"middleware"`
+	// Discovery 는 WithRegistrar 로 service discovery 에 등록할 Server 정보이다.
+	Discovery Registration `json:"discovery" yaml:"discovery" toml:"discovery"`
+
+	PIDFile string `json:"pid_file" yaml:"pid_file" toml:"pid_file"`
+}
+
+// TLSConfig 는 인증서 파일 설정이다. CertFile 이 비어 있으면 TLS 를 사용하지 않는다.
+type TLSConfig struct {
+	CertFile string `json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	// KeyFile 은 개인 키의 위치를 드러내지 않도록 ConfigHandler 에서 가린다.
+	KeyFile string `json:"key_file" yaml:"key_file" toml:"key_file" redact:"true"`
+	// ClientCAFile 이 있으면 client 인증서를 요구하고 검증한다. (mTLS)
+	ClientCAFile string `json:"client_ca_file" yaml:"client_ca_file" toml:"client_ca_file"`
+	// Peers 는 Method 별로 호출할 수 있는 client 인증서의 identity 이다. (TLSIdentityOptions.Methods 참고)
+	Peers map[string][]string `json:"peers" yaml:"peers" toml:"peers"`
+}
+
+// LimitsConfig 는 연결 수와 연결 수명 설정이다. 0 인 항목은 제한하지 않는다.
+type LimitsConfig struct {
+	MaxConnections        int      `json:"max_connections" yaml:"max_connections" toml:"max_connections"`
+	MaxConnectionsPerIP   int      `json:"max_connections_per_ip" yaml:"max_connections_per_ip" toml:"max_connections_per_ip"`
+	TrustedProxies        []string `json:"trusted_proxies" yaml:"trusted_proxies" toml:"trusted_proxies"`
+	IdleTimeout           Duration `json:"idle_timeout" yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxConnectionAge      Duration `json:"max_connection_age" yaml:"max_connection_age" toml:"max_connection_age"`
+	MaxConnectionAgeGrace Duration `json:"max_connection_age_grace" yaml:"max_connection_age_grace" toml:"max_connection_age_grace"`
+	// RateLimit, RateBurst 는 principal 별 초당 요청 수와 한 번에 허용하는 요청 수이다. (RateLimitOptions.Default 참고)
+	RateLimit float64 `json:"rate_limit" yaml:"rate_limit" toml:"rate_limit"`
+	RateBurst int     `json:"rate_burst" yaml:"rate_burst" toml:"rate_burst"`
+	// Principals 는 principal 별 요청 제한이다. (RateLimitOptions.Overrides 참고)
+	Principals map[string]RateLimit `json:"principals" yaml:"principals" toml:"principals"`
+}
+
+// MiddlewareConfig 는 기본 Interceptor 와 Service 사용 여부이다.
+type MiddlewareConfig struct {
+	Recovery   bool `json:"recovery" yaml:"recovery" toml:"recovery"`
+	Health     bool `json:"health" yaml:"health" toml:"health"`
+	Reflection bool `json:"reflection" yaml:"reflection" toml:"reflection"`
+}
+
+// Duration 은 설정 파일에서 "30s", "1m" 과 같은 문자열로 쓰는 time.Duration 이다.
+type Duration time.Duration
+
+func (pSelf Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(pSelf).String()), nil
+}
+
+func (pSelf *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*pSelf = Duration(duration)
+	return nil
+}
+
+// LoadConfig 는 확장자 (.yaml, .yml, .json, .toml) 에 맞게 설정 파일을 읽는다. 설정 파일에 없는 항목은 DefaultConfig 의 값을 사용한다.
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+	if err := decodeConfigFile(path, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// decodeConfigFile 은 설정 파일을 config 에 읽는다. 설정 파일에 없는 항목은 config 의 값을 유지한다.
+func decodeConfigFile(path string, config *Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(b))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	case ".toml":
+		var metaData toml.MetaData
+		metaData, err = toml.Decode(string(b), config)
+		if err == nil && len(metaData.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown fields: %v", metaData.Undecoded())
+		}
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Options 는 설정을 New 의 Option 으로 바꾼다.
+func (pSelf *Config) Options() ([]Option, error) {
+	var opts []Option
+
+	if len(pSelf.TLS.CertFile) > 0 || len(pSelf.TLS.KeyFile) > 0 {
+		tlsConfig, store, err := pSelf.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithTLS(tlsConfig), func(options *serverOptions) {
+			options.tlsStore = store
+		})
+		if len(pSelf.TLS.Peers) > 0 {
+			opts = append(opts, WithTLSIdentity(TLSIdentityOptions{Methods: pSelf.TLS.Peers}))
+		}
+	}
+
+	if pSelf.HttpPort != 0 {
+		opts = append(opts, WithHttpProxyPort(pSelf.HttpPort))
+	}
+
+	if pSelf.Limits.MaxConnections > 0 {
+		opts = append(opts, WithMaxConnections(pSelf.Limits.MaxConnections))
+	}
+	if pSelf.Limits.MaxConnectionsPerIP > 0 {
+		opts = append(opts, WithMaxConnectionsPerIP(pSelf.Limits.MaxConnectionsPerIP, pSelf.Limits.TrustedProxies...))
+	}
+	if pSelf.Limits.IdleTimeout > 0 {
+		opts = append(opts, WithIdleTimeout(time.Duration(pSelf.Limits.IdleTimeout)))
+	}
+	if pSelf.Limits.MaxConnectionAge > 0 {
+		opts = append(opts, WithMaxConnectionAge(time.Duration(pSelf.Limits.MaxConnectionAge), time.Duration(pSelf.Limits.MaxConnectionAgeGrace)))
+	}
+	if pSelf.Limits.RateLimit > 0 || len(pSelf.Limits.Principals) > 0 {
+		opts = append(opts, WithPrincipalRateLimit(RateLimitOptions{
+			Default:   RateLimit{Rate: pSelf.Limits.RateLimit, Burst: pSelf.Limits.RateBurst},
+			Overrides: pSelf.Limits.Principals,
+		}))
+	}
+
+	if pSelf.Middleware.Recovery {
+		opts = append(opts, WithRecovery())
+	}
+	if pSelf.Middleware.Health {
+		opts = append(opts, WithHealthCheck())
+	}
+	if pSelf.Middleware.Reflection {
+		opts = append(opts, WithReflection())
+	}
+
+	if len(pSelf.PIDFile) > 0 {
+		opts = append(opts, WithPIDFile(pSelf.PIDFile))
+	}
+
+	opts = append(opts, WithRegistration(pSelf.Discovery))
+
+	return opts, nil
+}
+
+// load 는 인증서를 읽는다. 인증서는 설정을 다시 읽을 때 바꿀 수 있도록 tlsStore 에서 가져온다.
+func (pSelf *TLSConfig) load() (*tls.Config, *tlsStore, error) {
+	if len(pSelf.CertFile) == 0 || len(pSelf.KeyFile) == 0 {
+		return nil, nil, errors.New("both TLS cert_file and key_file are required")
+	}
+
+	store := &tlsStore{}
+	if err := store.load(*pSelf); err != nil {
+		return nil, nil, err
+	}
+	tlsConfig := &tls.Config{GetCertificate: store.getCertificate}
+
+	if len(pSelf.ClientCAFile) > 0 {
+		b, err := os.ReadFile(pSelf.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load TLS client CA: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(b) {
+			return nil, nil, fmt.Errorf("no certificate found in TLS client CA file: %s", pSelf.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, store, nil
+}
+
+// Validate 는 설정의 모든 문제를 찾아 한 번에 반환한다.
+func (pSelf *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	isUnix := strings.EqualFold("unix", pSelf.Network)
+	// vsock 은 32-bit port 를 사용하므로 TCP port 범위로 확인하지 않는다. (Gateway 도 vsock 으로 listen)
+	checkPort := func(name string, port int) {
+		if strings.EqualFold("vsock", pSelf.Network) {
+			if _, err := parseVsockPort(port); err != nil {
+				invalid("%s %d is out of range (0-%d)", name, port, uint32(math.MaxUint32))
+			}
+		} else if port < 0 || port > 65535 {
+			invalid("%s %d is out of range (0-65535)", name, port)
+		}
+	}
+	switch {
+	case len(pSelf.Network) == 0:
+		invalid("network is required")
+	case !slices.Contains(supportedNetworks, pSelf.Network):
+		invalid("network %q is not supported (supported: %s)", pSelf.Network, strings.Join(supportedNetworks, ", "))
+	}
+
+	if isUnix {
+		if len(pSelf.Address) == 0 {
+			invalid("address is required for unix network")
+		} else if dir := filepath.Dir(pSelf.Address); !strings.HasPrefix(pSelf.Address, "@") && !isDir(dir) {
+			invalid("directory of unix socket %s does not exist", pSelf.Address)
+		}
+	} else {
+		if err := checkAddressFamily(pSelf.Network, pSelf.Address); err != nil {
+			invalid("%v", err)
+		}
+		if strings.ContainsAny(pSelf.Address, "/ ") || strings.Count(pSelf.Address, ":") == 1 {
+			invalid("address %q must be a host or IP without port", pSelf.Address)
+		}
+		checkPort("port", pSelf.Port)
+	}
+
+	checkPort("http_port", pSelf.HttpPort)
+	if !isUnix && pSelf.HttpPort != 0 && pSelf.HttpPort == pSelf.Port {
+		invalid("port and http_port must be different: %d", pSelf.Port)
+	}
+
+	if len(pSelf.TLS.CertFile) > 0 || len(pSelf.TLS.KeyFile) > 0 || len(pSelf.TLS.ClientCAFile) > 0 {
+		if len(pSelf.TLS.CertFile) == 0 || len(pSelf.TLS.KeyFile) == 0 {
+			invalid("both TLS cert_file and key_file are required")
+		}
+		for _, file := range []string{pSelf.TLS.CertFile, pSelf.TLS.KeyFile, pSelf.TLS.ClientCAFile} {
+			if len(file) == 0 {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				invalid("TLS file %s: %v", file, errors.Unwrap(err))
+			}
+		}
+	}
+	if len(pSelf.TLS.Peers) > 0 && len(pSelf.TLS.ClientCAFile) == 0 {
+		invalid("TLS peers requires client_ca_file")
+	}
+	for method := range pSelf.TLS.Peers {
+		if !strings.HasPrefix(method, "/") || strings.Count(method, "/") != 2 {
+			invalid("TLS peers method %s must be /package.Service/Method or /package.Service/*", method)
+		}
+	}
+
+	if pSelf.Limits.MaxConnections < 0 {
+		invalid("max_connections must not be negative: %d", pSelf.Limits.MaxConnections)
+	}
+	if pSelf.Limits.MaxConnectionsPerIP < 0 {
+		invalid("max_connections_per_ip must not be negative: %d", pSelf.Limits.MaxConnectionsPerIP)
+	}
+	if pSelf.Limits.MaxConnections > 0 && pSelf.Limits.MaxConnectionsPerIP > pSelf.Limits.MaxConnections {
+		invalid("max_connections_per_ip (%d) is greater than max_connections (%d)", pSelf.Limits.MaxConnectionsPerIP, pSelf.Limits.MaxConnections)
+	}
+	if _, err := parseIPNets(pSelf.Limits.TrustedProxies); err != nil {
+		invalid("trusted_proxies: %v", err)
+	}
+	if len(pSelf.Limits.TrustedProxies) > 0 && pSelf.Limits.MaxConnectionsPerIP == 0 {
+		invalid("trusted_proxies requires max_connections_per_ip")
+	}
+	for name, duration := range map[string]Duration{
+		"idle_timeout":             pSelf.Limits.IdleTimeout,
+		"max_connection_age":       pSelf.Limits.MaxConnectionAge,
+		"max_connection_age_grace": pSelf.Limits.MaxConnectionAgeGrace,
+	} {
+		if duration < 0 {
+			invalid("%s must not be negative: %s", name, time.Duration(duration))
+		}
+	}
+	if pSelf.Limits.MaxConnectionAgeGrace > 0 && pSelf.Limits.MaxConnectionAge == 0 {
+		invalid("max_connection_age_grace requires max_connection_age")
+	}
+	if pSelf.Limits.RateLimit < 0 || pSelf.Limits.RateBurst < 0 {
+		invalid("rate_limit and rate_burst must not be negative: %g, %d", pSelf.Limits.RateLimit, pSelf.Limits.RateBurst)
+	}
+	for principal, limit := range pSelf.Limits.Principals {
+		if limit.Rate < 0 || limit.Burst < 0 {
+			invalid("principals %s: rate and burst must not be negative: %g, %d", principal, limit.Rate, limit.Burst)
+		}
+	}
+
+	if len(pSelf.PIDFile) > 0 && !isDir(filepath.Dir(pSelf.PIDFile)) {
+		invalid("directory of pid_file %s does not exist", pSelf.PIDFile)
+	}
+
+	return errors.Join(errs...)
+}
+
+func isDir(path string) bool {
+	fileInfo, err := os.Stat(path)
+	return err == nil && fileInfo.IsDir()
+}
+
+// NewFromConfig 는 설정 파일을 읽어 gRPC Server 를 생성한다.
+// opts 는 설정 파일의 설정보다 나중에 적용된다.
+func NewFromConfig(
+	path string,
+	unaryServerInterceptors []grpc.UnaryServerInterceptor,
+	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
+) *GrpcServer {
+	config, err := LoadConfig(path)
+	if err != nil {
+		gLogger.Fatal(err)
+	}
+	return NewWithConfig(config, unaryServerInterceptors, streamServerInterceptors, append([]Option{withConfig(config, path)}, opts...)...)
+}
+
+// NewWithConfig 는 config 로 gRPC Server 를 생성한다.
+// opts 는 config 의 설정보다 나중에 적용된다.
+func NewWithConfig(
+	config *Config,
+	unaryServerInterceptors []grpc.UnaryServerInterceptor,
+	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
+) *GrpcServer {
+	if err := config.Validate(); err != nil {
+		gLogger.Fatalf("Invalid server config:\n%v\n", err)
+	}
+
+	configOptions, err := config.Options()
+	if err != nil {
+		gLogger.Fatal(err)
+	}
+	configOptions = append(configOptions, withConfig(config, ""))
+	return New(config.Network, config.Address, config.Port, unaryServerInterceptors, streamServerInterceptors, append(configOptions, opts...)...)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigChange 는 두 설정에서 바뀐 항목 하나이다. redact tag 가 있는 항목의 값은 가린다.
+type ConfigChange struct {
+	// Key 는 설정 파일의 항목 이름이다. (e.g. limits.max_connections)
+	Key string
+	Old string
+	New string
+	// RestartRequired 는 다시 시작해야 적용되는 변경인지 여부이다.
+	RestartRequired bool
+}
+
+func (pSelf ConfigChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", pSelf.Key, pSelf.Old, pSelf.New)
+}
+
+// DiffConfig 는 oldConfig 와 newConfig 에서 바뀐 항목을 설정 파일의 순서대로 반환한다.
+func DiffConfig(oldConfig, newConfig *Config) []ConfigChange {
+	oldValues := flattenConfig(reflect.ValueOf(oldConfig).Elem(), "")
+	newValues := flattenConfig(reflect.ValueOf(newConfig).Elem(), "")
+
+	var changes []ConfigChange
+	for i, oldValue := range oldValues {
+		newValue := newValues[i]
+		if oldValue.value != newValue.value {
+			changes = append(changes, ConfigChange{Key: oldValue.key, Old: oldValue.display(), New: newValue.display()})
+		}
+	}
+	return changes
+}
+
+type configValue struct {
+	key    string
+	value  string
+	redact bool
+}
+
+// display 는 log 에 기록할 값이다. 값이 있는 redact 항목은 가린다.
+func (pSelf configValue) display() string {
+	if pSelf.redact && len(pSelf.value) > 0 {
+		return redacted
+	}
+	return pSelf.value
+}
+
+// flattenConfig 는 설정을 json tag 이름의 항목과 문자열 값으로 나열한다.
+func flattenConfig(value reflect.Value, prefix string) []configValue {
+	var values []configValue
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := prefix + strings.Split(field.Tag.Get("json"), ",")[0]
+
+		fieldValue := value.Field(i)
+		if duration, ok := fieldValue.Interface().(Duration); ok {
+			values = append(values, configValue{key: key, value: time.Duration(duration).String()})
+			continue
+		}
+		if fieldValue.Kind() == reflect.Struct {
+			values = append(values, flattenConfig(fieldValue, key+".")...)
+			continue
+		}
+
+		values = append(values, configValue{
+			key:    key,
+			value:  fmt.Sprint(fieldValue.Interface()),
+			redact: field.Tag.Get("redact") == "true",
+		})
+	}
+	return values
+}
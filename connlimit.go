@@ -0,0 +1,245 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// WithMaxConnections 는 gRPC Server 와 Http Proxy Server 의 모든 Listener 에서 동시에 유지할 수 있는 연결 수를 제한한다.
+// 제한을 넘는 연결은 수락 즉시 닫는다.
+func WithMaxConnections(maxConnections int) Option {
+	return func(options *serverOptions) {
+		if maxConnections > 0 {
+			options.connectionLimiter = &connectionLimiter{maxConnections: maxConnections}
+		}
+	}
+}
+
+// WithMaxConnectionsPerIP 는 client IP 별로 동시에 유지할 수 있는 연결 수를 제한한다.
+// allowlist 의 IP 또는 CIDR (e.g. 신뢰하는 proxy, load balancer) 에서 들어오는 연결은 제한하지 않는다.
+// 제한을 넘는 연결은 수락 즉시 닫는다.
+// WithProxyProtocol 과 함께 사용하면 PROXY header 의 client 주소로 제한하며, 제한을 넘는 연결은 header 를 읽은 뒤 닫는다.
+func WithMaxConnectionsPerIP(maxConnections int, allowlist ...string) Option {
+	return func(options *serverOptions) {
+		if maxConnections > 0 {
+			options.ipConnectionLimiter = &ipConnectionLimiter{
+				maxConnections: maxConnections,
+				allowlist:      allowlist,
+				connections:    map[string]int{},
+			}
+		}
+	}
+}
+
+// connectionLimiter 는 여러 Listener 가 공유하는 연결 수 제한이다. maxConnections 가 0 이면 제한하지 않는다.
+type connectionLimiter struct {
+	mutex          sync.Mutex
+	maxConnections int
+	active         int
+}
+
+func (pSelf *connectionLimiter) tryAcquire() bool {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	if pSelf.maxConnections > 0 && pSelf.active >= pSelf.maxConnections {
+		return false
+	}
+	pSelf.active++
+	return true
+}
+
+func (pSelf *connectionLimiter) release() {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.active--
+}
+
+// setMaxConnections 는 제한을 바꾼다. 이미 수락한 연결은 닫지 않는다.
+func (pSelf *connectionLimiter) setMaxConnections(maxConnections int) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.maxConnections = maxConnections
+}
+
+type connectionLimitListener struct {
+	net.Listener
+	limiter *connectionLimiter
+}
+
+func (pSelf *connectionLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := pSelf.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if !pSelf.limiter.tryAcquire() {
+			addLabeledMetric("connections_rejected", "max_connections", 1)
+			_ = conn.Close()
+			continue
+		}
+
+		addMetric("connections_active", 1)
+		addMetric("connections_accepted", 1)
+		return &connectionLimitConn{Conn: conn, release: func() {
+			addMetric("connections_active", -1)
+			pSelf.limiter.release()
+		}}, nil
+	}
+}
+
+type connectionLimitConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (pSelf *connectionLimitConn) Close() error {
+	err := pSelf.Conn.Close()
+	pSelf.once.Do(pSelf.release)
+	return err
+}
+
+// ipConnectionLimiter 는 여러 Listener 가 공유하는 client IP 별 연결 수 제한이다. maxConnections 가 0 이면 제한하지 않는다.
+type ipConnectionLimiter struct {
+	maxConnections int
+	allowlist      []string
+
+	once         sync.Once
+	allowlistErr error
+
+	mutex          sync.Mutex
+	trustedProxies []*net.IPNet
+	connections    map[string]int
+}
+
+func (pSelf *ipConnectionLimiter) init() error {
+	pSelf.once.Do(func() {
+		pSelf.mutex.Lock()
+		defer pSelf.mutex.Unlock()
+		pSelf.trustedProxies, pSelf.allowlistErr = parseIPNets(pSelf.allowlist)
+	})
+	return pSelf.allowlistErr
+}
+
+// setLimits 는 제한과 allowlist (trustedProxies 는 해석한 allowlist) 를 바꾼다. 이미 수락한 연결은 닫지 않는다.
+func (pSelf *ipConnectionLimiter) setLimits(maxConnections int, allowlist []string, trustedProxies []*net.IPNet) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.maxConnections = maxConnections
+	pSelf.allowlist = allowlist
+	pSelf.trustedProxies = trustedProxies
+}
+
+// tryAcquire 는 ip 의 연결을 추가할 수 있으면 연결을 닫을 때 호출할 함수를 반환한다.
+func (pSelf *ipConnectionLimiter) tryAcquire(ip net.IP) (release func(), ok bool) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	if ip == nil || containsIP(pSelf.trustedProxies, ip) {
+		return func() {}, true
+	}
+
+	key := ip.String()
+	if pSelf.maxConnections > 0 && pSelf.connections[key] >= pSelf.maxConnections {
+		return nil, false
+	}
+	pSelf.connections[key]++
+
+	return func() {
+		pSelf.mutex.Lock()
+		defer pSelf.mutex.Unlock()
+
+		pSelf.connections[key]--
+		if pSelf.connections[key] <= 0 {
+			delete(pSelf.connections, key)
+		}
+	}, true
+}
+
+type ipConnectionLimitListener struct {
+	net.Listener
+	limiter *ipConnectionLimiter
+}
+
+func (pSelf *ipConnectionLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := pSelf.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		// PROXY header 로 받은 client 주소는 처음 읽거나 쓸 때 확인한다. Accept 에서 header 를 기다리지 않기 위함이다.
+		if _, ok := conn.(*proxyProtocolConn); ok {
+			return &ipConnectionLimitConn{Conn: conn, limiter: pSelf.limiter}, nil
+		}
+
+		release, ok := pSelf.limiter.tryAcquire(addrIP(conn.RemoteAddr()))
+		if !ok {
+			addLabeledMetric("connections_rejected", "max_connections_per_ip", 1)
+			_ = conn.Close()
+			continue
+		}
+
+		return &connectionLimitConn{Conn: conn, release: release}, nil
+	}
+}
+
+// errTooManyConnectionsPerIP 는 client IP 의 연결 수 제한을 넘어 닫은 연결의 오류이다.
+var errTooManyConnectionsPerIP = errors.New("too many connections from client ip")
+
+// ipConnectionLimitConn 은 PROXY header 를 읽은 뒤 client IP 의 연결 수를 확인하는 연결이다.
+type ipConnectionLimitConn struct {
+	net.Conn
+	limiter *ipConnectionLimiter
+
+	once      sync.Once
+	err       error
+	release   func()
+	closeOnce sync.Once
+}
+
+// acquire 는 처음 호출할 때 client IP 의 연결을 추가한다. 제한을 넘으면 연결을 닫는다.
+func (pSelf *ipConnectionLimitConn) acquire() error {
+	pSelf.once.Do(func() {
+		release, ok := pSelf.limiter.tryAcquire(addrIP(pSelf.Conn.RemoteAddr()))
+		if !ok {
+			addLabeledMetric("connections_rejected", "max_connections_per_ip", 1)
+			pSelf.err = errTooManyConnectionsPerIP
+			_ = pSelf.Conn.Close()
+			return
+		}
+		pSelf.release = release
+	})
+	return pSelf.err
+}
+
+func (pSelf *ipConnectionLimitConn) Read(b []byte) (int, error) {
+	if err := pSelf.acquire(); err != nil {
+		return 0, err
+	}
+	return pSelf.Conn.Read(b)
+}
+
+func (pSelf *ipConnectionLimitConn) Write(b []byte) (int, error) {
+	if err := pSelf.acquire(); err != nil {
+		return 0, err
+	}
+	return pSelf.Conn.Write(b)
+}
+
+func (pSelf *ipConnectionLimitConn) Close() error {
+	err := pSelf.Conn.Close()
+	// 확인하기 전에 닫은 연결은 더 이상 확인하지 않는다.
+	pSelf.once.Do(func() {
+		pSelf.err = net.ErrClosed
+	})
+	pSelf.closeOnce.Do(func() {
+		if pSelf.release != nil {
+			pSelf.release()
+		}
+	})
+	return err
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// defaultContentTypes 는 gRPC Gateway 의 기본 Marshaler 가 읽는 media type 이다.
+var defaultContentTypes = []string{"application/json"}
+
+// ContentTypeOptions 는 gRPC Gateway (Http Proxy) 가 body 가 있는 요청에서 허용할 Content-Type 이다.
+type ContentTypeOptions struct {
+	// Allowed 는 Routes 에 없는 경로에서 허용할 media type 이다. (기본값: application/json)
+	// parameter (e.g. charset) 는 비교하지 않으며, "image/*" 처럼 subtype 을 생략할 수 있다.
+	Allowed []string
+	// Routes 는 경로별 허용 media type 이다. key 는 경로 prefix 이며, "POST /v1/files" 처럼 HTTP method 를 지정할 수 있다.
+	// 가장 긴 prefix 를 사용하고, 길이가 같으면 method 를 지정한 route 를 사용한다.
+	Routes map[string][]string
+}
+
+// WithContentTypes 는 gRPC Gateway 로 받은 요청 중 body 가 있는 요청의 Content-Type 을 확인하고, 허용하지 않은 요청은 415 로 거부한다.
+// Content-Type 이 없거나, 여러 개이거나, 해석할 수 없는 요청도 거부하여 proxy 와 parser 가 body 를 다르게 해석하지 않도록 한다.
+// 거부한 요청은 content_type_rejected 지표로 확인한다.
+func WithContentTypes(contentTypeOptions ContentTypeOptions) Option {
+	return func(options *serverOptions) {
+		if len(contentTypeOptions.Allowed) == 0 {
+			contentTypeOptions.Allowed = defaultContentTypes
+		}
+		options.contentTypes = &contentTypeOptions
+	}
+}
+
+// handler 는 body 가 있는 요청의 Content-Type 을 확인한다.
+func (pSelf *ContentTypeOptions) handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		values := r.Header.Values("Content-Type")
+		if len(values) != 1 {
+			addLabeledMetric("content_type_rejected", "missing_or_multiple", 1)
+			http.Error(w, "exactly one Content-Type is required", http.StatusUnsupportedMediaType)
+			return
+		}
+		mediaType, _, err := mime.ParseMediaType(values[0])
+		if err != nil {
+			addLabeledMetric("content_type_rejected", "invalid", 1)
+			http.Error(w, "invalid Content-Type", http.StatusUnsupportedMediaType)
+			return
+		}
+		allowed := pSelf.allowed(r.Method, r.URL.Path)
+		if !matchMediaType(allowed, mediaType) {
+			addLabeledMetric("content_type_rejected", "unsupported", 1)
+			switch r.Method {
+			case http.MethodPost:
+				w.Header().Set("Accept-Post", strings.Join(allowed, ", "))
+			case http.MethodPatch:
+				w.Header().Set("Accept-Patch", strings.Join(allowed, ", "))
+			}
+			http.Error(w, "unsupported Content-Type: "+mediaType, http.StatusUnsupportedMediaType)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// allowed 는 요청 경로의 허용 media type 이다.
+func (pSelf *ContentTypeOptions) allowed(method, path string) []string {
+	allowed, matched, methodMatched := pSelf.Allowed, -1, false
+	for route, mediaTypes := range pSelf.Routes {
+		routeMethod, routePath, hasMethod := strings.Cut(route, " ")
+		if !hasMethod {
+			routePath = routeMethod
+		} else if !strings.EqualFold(routeMethod, method) {
+			continue
+		}
+		if !strings.HasPrefix(path, routePath) {
+			continue
+		}
+		if len(routePath) > matched || (len(routePath) == matched && hasMethod && !methodMatched) {
+			allowed, matched, methodMatched = mediaTypes, len(routePath), hasMethod
+		}
+	}
+	return allowed
+}
+
+// matchMediaType 은 mediaType 이 allowed 중 하나와 같은지 여부이다. "type/*" 는 subtype 과 관계없이 같다.
+func matchMediaType(allowed []string, mediaType string) bool {
+	return slices.ContainsFunc(allowed, func(allowedType string) bool {
+		allowedType = strings.ToLower(strings.TrimSpace(allowedType))
+		if prefix, ok := strings.CutSuffix(allowedType, "/*"); ok {
+			return strings.HasPrefix(mediaType, prefix+"/")
+		}
+		return allowedType == mediaType
+	})
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"net"
+)
+
+// WithTLS 는 gRPC Server 의 기본 Listener 에 TLS 를 적용한다.
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(options *serverOptions) {
+		options.tlsConfig = tlsConfig
+	}
+}
+
+// credentialsListener 는 수락한 연결에 Listener 별 TransportCredentials 를 붙인다.
+type credentialsListener struct {
+	net.Listener
+	creds credentials.TransportCredentials
+}
+
+func newCredentialsListener(listener net.Listener, tlsConfig *tls.Config) *credentialsListener {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	return &credentialsListener{Listener: listener, creds: creds}
+}
+
+func (pSelf *credentialsListener) Accept() (net.Conn, error) {
+	conn, err := pSelf.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &credentialsConn{Conn: conn, creds: pSelf.creds}, nil
+}
+
+type credentialsConn struct {
+	net.Conn
+	creds credentials.TransportCredentials
+}
+
+// listenerCredentials 는 연결을 수락한 Listener 의 TransportCredentials 로 handshake 한다.
+// gRPC Server 는 하나의 TransportCredentials 만 가질 수 있으므로 Listener 별 TLS 설정은 이 credentials 로 처리한다.
+// 연결의 보안 protocol 은 handshake 가 반환한 AuthInfo (AuthType) 로, 연결을 수락한 Listener 의 값이다.
+type listenerCredentials struct {
+	// info 는 기본 Listener 의 TransportCredentials 정보이다.
+	info credentials.ProtocolInfo
+}
+
+func (listenerCredentials) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("listener credentials can not be used by client")
+}
+
+func (listenerCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if conn, ok := rawConn.(*credentialsConn); ok {
+		return conn.creds.ServerHandshake(conn.Conn)
+	}
+	return insecure.NewCredentials().ServerHandshake(rawConn)
+}
+
+// Info 는 연결과 관계없는 값이므로 기본 Listener 의 정보를 반환한다.
+func (pSelf listenerCredentials) Info() credentials.ProtocolInfo {
+	return pSelf.info
+}
+
+func (pSelf listenerCredentials) Clone() credentials.TransportCredentials {
+	return pSelf
+}
+
+func (listenerCredentials) OverrideServerName(string) error {
+	return nil
+}
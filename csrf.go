@@ -0,0 +1,154 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultCSRFCookieName = "csrf_token"
+	defaultCSRFHeader     = "X-CSRF-Token"
+)
+
+// CSRFMode 는 state 를 바꾸는 요청 (GET, HEAD, OPTIONS, TRACE 외) 을 확인하는 방법이다.
+type CSRFMode int
+
+const (
+	// CSRFHeaderCheck 는 Header 가 있는 요청만 허용한다. browser 는 CORS preflight 없이 다른 origin 에 custom header 를 보낼 수 없다.
+	CSRFHeaderCheck CSRFMode = iota
+	// CSRFDoubleSubmit 은 안전한 요청의 응답으로 token cookie 를 발급하고, cookie 와 Header 의 token 이 같은 요청만 허용한다.
+	CSRFDoubleSubmit
+)
+
+// CSRFOptions 는 gRPC Gateway (Http Proxy) 의 CSRF 방어 설정이다.
+type CSRFOptions struct {
+	Mode CSRFMode
+	// Header 는 token 을 담은 요청 header 이다. (기본값: X-CSRF-Token)
+	Header string
+	// CookieName 은 CSRFDoubleSubmit 의 token cookie 이름이다. (기본값: csrf_token)
+	// script 로 읽어 Header 에 담아야 하므로 HttpOnly 가 아니며, SameSite=Strict 이다.
+	CookieName string
+	// TrustedOrigins 는 요청의 Host 외에 허용할 Origin 이다. (e.g. https://app.example.com)
+	// Origin (없으면 Referer) 이 있는 요청은 Host 또는 TrustedOrigins 와 같아야 한다.
+	TrustedOrigins []string
+	// ExemptPaths 는 확인하지 않을 경로이다. (e.g. 외부에서 호출하는 webhook)
+	// 경로와 같거나 경로의 하위 경로이면 확인하지 않는다. (e.g. "/webhook" 은 "/webhook", "/webhook/github" 과 일치하고 "/webhooks-admin" 과는 일치하지 않는다)
+	ExemptPaths []string
+}
+
+// WithCSRF 는 gRPC Gateway 의 state 를 바꾸는 요청에서 Origin 과 CSRF token 을 확인하고, 맞지 않는 요청은 403 으로 거부한다.
+// browser 가 아닌 client 도 Header 를 보내야 하므로, 이런 client 만 사용하는 경로는 ExemptPaths 에 추가한다.
+func WithCSRF(csrfOptions CSRFOptions) Option {
+	return func(options *serverOptions) {
+		if len(csrfOptions.Header) == 0 {
+			csrfOptions.Header = defaultCSRFHeader
+		}
+		if len(csrfOptions.CookieName) == 0 {
+			csrfOptions.CookieName = defaultCSRFCookieName
+		}
+		options.csrf = &csrfOptions
+	}
+}
+
+// safeMethod 는 state 를 바꾸지 않는 요청인지 여부이다.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions || method == http.MethodTrace
+}
+
+// handler 는 state 를 바꾸는 요청을 확인하고, CSRFDoubleSubmit 이면 token cookie 를 발급한다.
+func (pSelf *CSRFOptions) handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range pSelf.ExemptPaths {
+			if exemptPath(r.URL.Path, path) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if safeMethod(r.Method) {
+			if pSelf.Mode == CSRFDoubleSubmit {
+				pSelf.issueToken(w, r)
+			}
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if reason, ok := pSelf.verify(r); !ok {
+			addLabeledMetric("csrf_rejected", reason, 1)
+			http.Error(w, "CSRF check failed: "+reason, http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// verify 는 요청의 Origin 과 token 을 확인한다. 거부하면 이유를 반환한다.
+func (pSelf *CSRFOptions) verify(r *http.Request) (string, bool) {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" && len(pSelf.TrustedOrigins) == 0 {
+		return "cross_site", false
+	}
+
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 || origin == "null" {
+		if referer, err := url.Parse(r.Referer()); err == nil && len(referer.Host) > 0 {
+			origin = referer.Scheme + "://" + referer.Host
+		}
+	}
+	if len(origin) > 0 && !pSelf.trustedOrigin(origin, r.Host) {
+		return "origin", false
+	}
+
+	token := r.Header.Get(pSelf.Header)
+	if len(token) == 0 {
+		return "missing_token", false
+	}
+	if pSelf.Mode == CSRFDoubleSubmit {
+		cookie, err := r.Cookie(pSelf.CookieName)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1 {
+			return "token_mismatch", false
+		}
+	}
+	return "", true
+}
+
+func (pSelf *CSRFOptions) trustedOrigin(origin, host string) bool {
+	if slices.Contains(pSelf.TrustedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, host)
+}
+
+// issueToken 은 token cookie 가 없으면 발급한다.
+func (pSelf *CSRFOptions) issueToken(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(pSelf.CookieName); err == nil && len(cookie.Value) > 0 {
+		return
+	}
+
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     pSelf.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     "/",
+		Secure:   r.TLS != nil || r.ProtoMajor == 3,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// exemptPath 는 requestPath 가 exempt 와 같거나 exempt 의 하위 경로인지 여부이다.
+func exemptPath(requestPath, exempt string) bool {
+	if requestPath == exempt {
+		return true
+	}
+	prefix := exempt
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.HasPrefix(requestPath, prefix)
+}
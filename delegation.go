@@ -0,0 +1,463 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TokenTypeAccessToken 은 RFC 8693 의 OAuth access token 형식이다.
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	// TokenTypeJWT 는 RFC 8693 의 JWT 형식이다.
+	TokenTypeJWT = "urn:ietf:params:oauth:token-type:jwt"
+
+	tokenExchangeGrantType    = "urn:ietf:params:oauth:grant-type:token-exchange"
+	defaultDelegationHeader   = "authorization"
+	defaultDelegationMaxChain = 5
+	delegationCacheMargin     = 30 * time.Second
+	// delegationFailureBackoff 는 교환에 실패한 token 을 다시 교환하지 않는 시간이다.
+	delegationFailureBackoff = 5 * time.Second
+	// defaultTokenExchangeTimeout 은 TokenExchangeClient 의 기본 HTTP client 의 timeout 이다.
+	defaultTokenExchangeTimeout = 10 * time.Second
+)
+
+type delegationContextKey struct{}
+
+// Delegation 은 다른 service 가 사용자를 대신하여 호출한 요청의 위임 정보이다.
+type Delegation struct {
+	// Subject 는 요청의 주체인 사용자이다. (token 의 sub)
+	Subject string `json:"subject"`
+	// Actors 는 사용자를 대신하여 호출한 service 이다. 처음 호출한 service 부터 현재 요청을 보낸 service 까지의 순서이다.
+	Actors []string `json:"actors,omitempty"`
+	// Scopes 는 위임받은 권한이다.
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresAt 은 token 의 만료 시각이다. 비어 있을 수 있다.
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// Token 은 이 service 가 사용할 token 이다. 하위 service 를 호출할 때 전달한다.
+	Token string `json:"-"`
+}
+
+// Actor 는 현재 요청을 보낸 service 이다. 사용자가 직접 호출했으면 빈 문자열이다.
+func (pSelf *Delegation) Actor() string {
+	if len(pSelf.Actors) == 0 {
+		return ""
+	}
+	return pSelf.Actors[len(pSelf.Actors)-1]
+}
+
+// Chain 은 "사용자 > 처음 호출한 service > ... > 현재 요청을 보낸 service" 형식의 위임 경로이다.
+func (pSelf *Delegation) Chain() string {
+	return strings.Join(append([]string{pSelf.Subject}, pSelf.Actors...), " > ")
+}
+
+// DelegationOptions 는 요청의 token 을 교환하거나 확인하여 위임 정보를 얻는 설정이다.
+type DelegationOptions struct {
+	// Header 는 token 을 담은 metadata 이름이다. "Bearer " 는 지운다. (기본값: authorization)
+	Header string
+	// Exchanger 가 있으면 요청의 token 을 RFC 8693 token exchange 로 이 service 의 token 으로 바꾼다. (TokenExchangeClient 참고)
+	Exchanger TokenExchanger
+	// Validate 는 token 을 확인하여 위임 정보를 만든다. (e.g. JWT 서명을 확인하고 sub, act claim 을 읽음)
+	// Exchanger 가 있으면 교환한 token 을 확인하며, nil 이면 교환한 JWT 의 claim 을 그대로 읽는다. Exchanger 가 없으면 필요하다.
+	Validate func(ctx context.Context, token string) (*Delegation, error)
+	// Required 이면 token 이 없는 요청은 Unauthenticated 로 거부한다.
+	Required bool
+	// AllowedActors 가 있으면 위임 경로의 service 가 모두 AllowedActors 에 있어야 한다. 없는 service 가 있으면 PermissionDenied 로 거부한다.
+	AllowedActors []string
+	// MaxChain 은 허용하는 위임 경로의 service 수이다. (기본값: 5)
+	MaxChain int
+}
+
+// WithDelegation 은 요청의 token 으로 사용자를 대신한 호출인지 확인하고, 위임 정보를 context 에 기록한다.
+// Handler 에서는 DelegationFromContext 로 사용자와 위임 경로를 확인하며, log 에는 on_behalf_of, delegation 속성이 추가된다.
+// 교환한 token 은 만료 전까지, 교환에 실패한 token 은 잠시 기억하며, 같은 token 의 동시 요청은 한 번만 교환한다.
+// 결과는 delegations 지표로 확인한다.
+//
+//	exchanger := server.NewTokenExchangeClient(server.TokenExchangeClientOptions{Endpoint: "https://sts.example.com/token", Audience: "orders"})
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, server.WithDelegation(server.DelegationOptions{Exchanger: exchanger, Required: true}))
+func WithDelegation(delegationOptions DelegationOptions) Option {
+	return func(options *serverOptions) {
+		if delegationOptions.Exchanger == nil && delegationOptions.Validate == nil {
+			gLogger.Fatal("Delegation requires Exchanger or Validate")
+		}
+		if len(delegationOptions.Header) == 0 {
+			delegationOptions.Header = defaultDelegationHeader
+		}
+		if delegationOptions.MaxChain <= 0 {
+			delegationOptions.MaxChain = defaultDelegationMaxChain
+		}
+		options.delegation = &delegation{options: delegationOptions, cache: map[string]*Delegation{}, failures: map[string]delegationFailure{}}
+	}
+}
+
+// DelegationFromContext 는 WithDelegation 이 context 에 기록한 위임 정보이다.
+func DelegationFromContext(ctx context.Context) (*Delegation, bool) {
+	delegation, ok := ctx.Value(delegationContextKey{}).(*Delegation)
+	return delegation, ok
+}
+
+// delegation 은 교환한 token 의 위임 정보를 만료 전까지 기억한다.
+type delegation struct {
+	options DelegationOptions
+
+	mutex sync.Mutex
+	cache map[string]*Delegation
+	// failures 는 token 별 마지막 교환 실패이다. retryAt 까지 다시 교환하지 않는다.
+	failures  map[string]delegationFailure
+	lastSweep time.Time
+	// exchanges 는 token 별로 하나의 교환만 요청하도록 한다.
+	exchanges singleflight.Group
+}
+
+type delegationFailure struct {
+	err     error
+	retryAt time.Time
+}
+
+// token 은 요청 metadata 의 token 이다.
+func (pSelf *delegation) token(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(pSelf.options.Header)
+	if len(values) == 0 {
+		return ""
+	}
+	token := values[0]
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = token[7:]
+	}
+	return strings.TrimSpace(token)
+}
+
+// resolve 는 token 의 위임 정보이다. Exchanger 가 있으면 교환한 결과를 기억한다.
+func (pSelf *delegation) resolve(ctx context.Context, token string) (*Delegation, error) {
+	if pSelf.options.Exchanger == nil {
+		return pSelf.options.Validate(ctx, token)
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+	pSelf.mutex.Lock()
+	cached, ok := pSelf.cache[key]
+	failure, failed := pSelf.failures[key]
+	pSelf.mutex.Unlock()
+	if ok && now.Before(cached.ExpiresAt.Add(-delegationCacheMargin)) {
+		return cached, nil
+	}
+	if failed && now.Before(failure.retryAt) {
+		return nil, failure.err
+	}
+
+	// 교환은 먼저 요청한 client 가 취소해도 함께 기다리는 요청을 위해 끝까지 진행한다.
+	v, err, _ := pSelf.exchanges.Do(key, func() (any, error) {
+		result, err := pSelf.exchange(context.WithoutCancel(ctx), token, now)
+		pSelf.mutex.Lock()
+		defer pSelf.mutex.Unlock()
+		pSelf.sweep(now)
+		if err != nil {
+			pSelf.failures[key] = delegationFailure{err: err, retryAt: now.Add(delegationFailureBackoff)}
+			return nil, err
+		}
+		delete(pSelf.failures, key)
+		if !result.ExpiresAt.IsZero() {
+			pSelf.cache[key] = result
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Delegation), nil
+}
+
+// exchange 는 token 을 교환하고 교환한 token 의 위임 정보를 만든다.
+func (pSelf *delegation) exchange(ctx context.Context, token string, now time.Time) (*Delegation, error) {
+	response, err := pSelf.options.Exchanger.ExchangeToken(ctx, TokenExchangeRequest{SubjectToken: token, SubjectTokenType: TokenTypeAccessToken})
+	if err != nil {
+		return nil, err
+	}
+
+	var result *Delegation
+	if pSelf.options.Validate != nil {
+		result, err = pSelf.options.Validate(ctx, response.AccessToken)
+	} else {
+		result, err = DelegationFromJWT(response.AccessToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Token = response.AccessToken
+	if response.ExpiresIn > 0 && (result.ExpiresAt.IsZero() || now.Add(time.Duration(response.ExpiresIn)*time.Second).Before(result.ExpiresAt)) {
+		result.ExpiresAt = now.Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	if len(result.Scopes) == 0 && len(response.Scope) > 0 {
+		result.Scopes = strings.Fields(response.Scope)
+	}
+	return result, nil
+}
+
+// sweep 은 만료한 위임 정보와 다시 교환할 수 있는 실패를 지운다.
+func (pSelf *delegation) sweep(now time.Time) {
+	if now.Sub(pSelf.lastSweep) < time.Minute {
+		return
+	}
+	pSelf.lastSweep = now
+	for key, cached := range pSelf.cache {
+		if now.After(cached.ExpiresAt) {
+			delete(pSelf.cache, key)
+		}
+	}
+	for key, failure := range pSelf.failures {
+		if now.After(failure.retryAt) {
+			delete(pSelf.failures, key)
+		}
+	}
+}
+
+// authorize 는 요청의 위임 정보를 확인하여 context 에 기록한다.
+func (pSelf *delegation) authorize(ctx context.Context) (context.Context, error) {
+	token := pSelf.token(ctx)
+	if len(token) == 0 {
+		if pSelf.options.Required {
+			addLabeledMetric("delegations", "missing", 1)
+			return nil, status.Error(codes.Unauthenticated, "delegation token is required")
+		}
+		return ctx, nil
+	}
+
+	result, err := pSelf.resolve(ctx, token)
+	if err != nil {
+		addLabeledMetric("delegations", "invalid", 1)
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "invalid delegation token: %v", err)
+	}
+	if len(result.Subject) == 0 {
+		addLabeledMetric("delegations", "invalid", 1)
+		return nil, status.Error(codes.Unauthenticated, "delegation token has no subject")
+	}
+	if len(result.Actors) > pSelf.options.MaxChain {
+		addLabeledMetric("delegations", "denied", 1)
+		return nil, status.Errorf(codes.PermissionDenied, "delegation chain too long: %s", result.Chain())
+	}
+	if len(pSelf.options.AllowedActors) > 0 {
+		for _, actor := range result.Actors {
+			if !slices.Contains(pSelf.options.AllowedActors, actor) {
+				addLabeledMetric("delegations", "denied", 1)
+				return nil, status.Errorf(codes.PermissionDenied, "actor is not allowed to delegate: %s", actor)
+			}
+		}
+	}
+	if len(result.Token) == 0 {
+		result.Token = token
+	}
+
+	addLabeledMetric("delegations", "accepted", 1)
+	ctx = context.WithValue(ctx, delegationContextKey{}, result)
+	attrs := []slog.Attr{slog.String("on_behalf_of", result.Subject)}
+	if len(result.Actors) > 0 {
+		attrs = append(attrs, slog.String("delegation", result.Chain()))
+	}
+	return ContextWithLogAttrs(ctx, attrs...), nil
+}
+
+func (pSelf *delegation) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if builtinMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := pSelf.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (pSelf *delegation) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if builtinMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := pSelf.authorize(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// delegationClaims 는 JWT 의 위임 관련 claim 이다. act 는 RFC 8693 의 중첩된 actor 이다.
+type delegationClaims struct {
+	Subject   string            `json:"sub"`
+	Actor     *delegationClaims `json:"act"`
+	Scope     string            `json:"scope"`
+	ExpiresAt int64             `json:"exp"`
+}
+
+// DelegationFromJWT 는 JWT 의 sub, act, scope, exp claim 으로 위임 정보를 만든다. 서명은 확인하지 않는다.
+// STS 에서 직접 받은 token 처럼 이미 신뢰하는 token 에만 사용한다.
+func DelegationFromJWT(token string) (*Delegation, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	var claims delegationClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+
+	result := &Delegation{Subject: claims.Subject, Scopes: strings.Fields(claims.Scope), Token: token}
+	if claims.ExpiresAt > 0 {
+		result.ExpiresAt = time.Unix(claims.ExpiresAt, 0)
+	}
+	// act 는 현재 actor 가 가장 바깥이므로 뒤집어서 처음 호출한 service 부터 기록한다.
+	for actor := claims.Actor; actor != nil; actor = actor.Actor {
+		result.Actors = append([]string{actor.Subject}, result.Actors...)
+	}
+	return result, nil
+}
+
+// TokenExchanger 는 RFC 8693 token exchange 를 수행한다.
+type TokenExchanger interface {
+	ExchangeToken(ctx context.Context, request TokenExchangeRequest) (*TokenExchangeResponse, error)
+}
+
+// TokenExchangeRequest 는 RFC 8693 token exchange 요청이다.
+type TokenExchangeRequest struct {
+	SubjectToken     string
+	SubjectTokenType string
+	// ActorToken 은 사용자를 대신하는 service 의 token 이다. 비어 있으면 TokenExchangeClientOptions 의 ActorToken 을 사용한다.
+	ActorToken         string
+	ActorTokenType     string
+	Audience           string
+	Resource           string
+	Scopes             []string
+	RequestedTokenType string
+}
+
+// TokenExchangeResponse 는 RFC 8693 token exchange 응답이다.
+type TokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+	Scope           string `json:"scope"`
+}
+
+// TokenExchangeClientOptions 는 STS (Security Token Service) 의 token endpoint 설정이다.
+type TokenExchangeClientOptions struct {
+	// Endpoint 는 token endpoint 주소이다.
+	Endpoint string
+	// ClientID, ClientSecret 은 이 service 의 client 인증 정보이다. (HTTP Basic)
+	ClientID     string
+	ClientSecret string
+	// Audience, Resource, Scopes 는 요청에 값이 없을 때 사용하는 기본값이다.
+	Audience string
+	Resource string
+	Scopes   []string
+	// ActorToken 이 있으면 이 service 의 token 을 actor_token 으로 보낸다. 발급한 token 의 act claim 에 기록된다.
+	ActorToken func(ctx context.Context) (string, error)
+	// HTTPClient 가 nil 이면 timeout 이 10초인 client 를 사용한다.
+	HTTPClient *http.Client
+}
+
+// TokenExchangeClient 는 STS 의 token endpoint 로 token 을 교환하는 TokenExchanger 이다.
+type TokenExchangeClient struct {
+	options TokenExchangeClientOptions
+}
+
+// NewTokenExchangeClient 는 TokenExchangeClient 를 생성한다.
+func NewTokenExchangeClient(clientOptions TokenExchangeClientOptions) *TokenExchangeClient {
+	if clientOptions.HTTPClient == nil {
+		clientOptions.HTTPClient = &http.Client{Timeout: defaultTokenExchangeTimeout}
+	}
+	return &TokenExchangeClient{options: clientOptions}
+}
+
+// ExchangeToken 은 request 의 token 을 교환한다.
+func (pSelf *TokenExchangeClient) ExchangeToken(ctx context.Context, request TokenExchangeRequest) (*TokenExchangeResponse, error) {
+	form := url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {request.SubjectToken},
+		"subject_token_type": {request.SubjectTokenType},
+	}
+	set := func(key, value, defaultValue string) {
+		if len(value) == 0 {
+			value = defaultValue
+		}
+		if len(value) > 0 {
+			form.Set(key, value)
+		}
+	}
+	set("audience", request.Audience, pSelf.options.Audience)
+	set("resource", request.Resource, pSelf.options.Resource)
+	scopes := request.Scopes
+	if len(scopes) == 0 {
+		scopes = pSelf.options.Scopes
+	}
+	set("scope", strings.Join(scopes, " "), "")
+	set("requested_token_type", request.RequestedTokenType, "")
+
+	actorToken, actorTokenType := request.ActorToken, request.ActorTokenType
+	if len(actorToken) == 0 && pSelf.options.ActorToken != nil {
+		var err error
+		if actorToken, err = pSelf.options.ActorToken(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get actor token: %w", err)
+		}
+	}
+	if len(actorToken) > 0 {
+		form.Set("actor_token", actorToken)
+		set("actor_token_type", actorTokenType, TokenTypeAccessToken)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, pSelf.options.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpRequest.Header.Set("Accept", "application/json")
+	if len(pSelf.options.ClientID) > 0 {
+		httpRequest.SetBasicAuth(url.QueryEscape(pSelf.options.ClientID), url.QueryEscape(pSelf.options.ClientSecret))
+	}
+
+	httpResponse, err := pSelf.options.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		var body struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		_ = json.NewDecoder(httpResponse.Body).Decode(&body)
+		return nil, fmt.Errorf("token exchange failed: %s", strings.TrimSpace(strings.Join([]string{httpResponse.Status, body.Error, body.ErrorDescription}, " ")))
+	}
+	var response TokenExchangeResponse
+	if err := json.NewDecoder(httpResponse.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode token exchange response: %w", err)
+	}
+	if len(response.AccessToken) == 0 {
+		return nil, errors.New("token exchange response has no access_token")
+	}
+	return &response, nil
+}
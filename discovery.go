@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// registerTimeout 은 Registrar 에 등록, 해제하는 제한 시간이다.
+const registerTimeout = 10 * time.Second
+
+// Registration 은 service discovery 에 등록할 Server 정보이다.
+type Registration struct {
+	// Name 은 Service 이름이다. 비어 있으면 실행 파일 이름을 사용한다.
+	Name string `json:"name" yaml:"name" toml:"name"`
+	// ID 는 Server 를 구분하는 값이다. 비어 있으면 "이름-hostname-port" 를 사용한다.
+	ID string `json:"id" yaml:"id" toml:"id"`
+	// Address 는 client 가 연결할 주소이다. 비어 있으면 listen 주소, 모든 주소를 listen 하면 이 host 의 IP 를 사용한다.
+	Address  string            `json:"address" yaml:"address" toml:"address"`
+	Tags     []string          `json:"tags" yaml:"tags" toml:"tags"`
+	Metadata map[string]string `json:"metadata" yaml:"metadata" toml:"metadata"`
+}
+
+// ServiceInstance 는 Registrar 에 등록하는 실행 중인 Server 이다.
+type ServiceInstance struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	// HttpPort 는 gRPC Gateway (Http Proxy) Server 의 port 이다. 없으면 0 이다.
+	HttpPort int
+	Tags     []string
+	Metadata map[string]string
+	// Health 는 grpc.health.v1.Health Service 로 상태를 확인할 수 있는지 여부이다. (WithHealthCheck)
+	Health bool
+	// TLS 는 TLS 로 연결해야 하는지 여부이다.
+	TLS bool
+}
+
+// Registrar 는 Server 를 service discovery (e.g. Consul) 에 등록한다.
+type Registrar interface {
+	Register(ctx context.Context, instance ServiceInstance) error
+	Deregister(ctx context.Context, instance ServiceInstance) error
+}
+
+// WithRegistration 은 WithRegistrar 로 등록할 Server 정보이다. (설정 파일의 discovery)
+func WithRegistration(registration Registration) Option {
+	return func(options *serverOptions) {
+		options.registration = registration
+	}
+}
+
+// WithRegistrar 는 Server 를 시작하면 registrar 에 등록하고, 종료를 시작하면 새 요청을 받지 않도록 먼저 등록을 해제한다.
+// WithUpgrade 로 binary 를 교체하면 새 프로세스가 같은 ID 로 등록하므로, 이전 프로세스는 등록을 해제하지 않는다.
+// 여러 번 사용하면 모든 registrar 에 등록한다. 등록하지 못하면 WithErrorPolicy 에 따라 종료하거나 경고를 기록한다.
+func WithRegistrar(registrar Registrar) Option {
+	return func(options *serverOptions) {
+		options.registrars = append(options.registrars, registrar)
+	}
+}
+
+// register 는 Server 를 모든 Registrar 에 등록한다.
+func (pSelf *GrpcServer) register() {
+	instance, err := pSelf.serviceInstance()
+	if err != nil {
+		pSelf.options.recoverable("Failed to register service: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), registerTimeout)
+	defer cancel()
+	for _, registrar := range pSelf.options.registrars {
+		if err := registrar.Register(ctx, instance); err != nil {
+			pSelf.options.recoverable("Failed to register service %s: %v", instance.ID, err)
+			continue
+		}
+		pSelf.registrars = append(pSelf.registrars, registrar)
+	}
+	if len(pSelf.registrars) > 0 {
+		pSelf.instance = instance
+		gLogger.Printf("Registered service %s (%s) on %s\n", instance.ID, instance.Name, net.JoinHostPort(instance.Address, fmt.Sprint(instance.Port)))
+	}
+}
+
+// deregister 는 등록한 Registrar 에서 Server 를 해제한다.
+func (pSelf *GrpcServer) deregister(ctx context.Context) {
+	if len(pSelf.registrars) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, registerTimeout)
+	defer cancel()
+	for _, registrar := range pSelf.registrars {
+		if err := registrar.Deregister(ctx, pSelf.instance); err != nil {
+			gLogger.Printf("Failed to deregister service %s: %v\n", pSelf.instance.ID, err)
+		}
+	}
+	pSelf.registrars = nil
+	gLogger.Printf("Deregistered service %s\n", pSelf.instance.ID)
+}
+
+// serviceInstance 는 실제로 bind 한 port 로 등록할 Server 정보를 만든다.
+func (pSelf *GrpcServer) serviceInstance() (ServiceInstance, error) {
+	registration := pSelf.options.registration
+	instance := ServiceInstance{
+		ID:       registration.ID,
+		Name:     registration.Name,
+		Address:  registration.Address,
+		Port:     pSelf.port,
+		Tags:     registration.Tags,
+		Metadata: registration.Metadata,
+		Health:   pSelf.healthServer != nil,
+		TLS:      pSelf.options.tlsConfig != nil,
+	}
+	if pSelf.httpProxyServer != nil {
+		instance.HttpPort = pSelf.httpProxyPort
+	}
+
+	if len(instance.Name) == 0 {
+		instance.Name = strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+	}
+	if len(instance.Address) == 0 {
+		address, err := pSelf.advertiseAddress()
+		if err != nil {
+			return instance, err
+		}
+		instance.Address = address
+	}
+	if len(instance.ID) == 0 {
+		hostname, _ := os.Hostname()
+		instance.ID = fmt.Sprintf("%s-%s-%d", instance.Name, hostname, instance.Port)
+	}
+	return instance, nil
+}
+
+// advertiseAddress 는 다른 host 에서 연결할 수 있는 주소이다.
+func (pSelf *GrpcServer) advertiseAddress() (string, error) {
+	if !strings.HasPrefix(strings.ToLower(pSelf.network), "tcp") {
+		return "", errors.New("discovery address is required for " + pSelf.network + " network")
+	}
+	if ip := net.ParseIP(pSelf.address); len(pSelf.address) > 0 && (ip == nil || !ip.IsUnspecified()) {
+		return pSelf.address, nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	// global unicast 주소가 없으면 link-local 주소를 사용한다. (e.g. link-local 주소만 있는 LAN 의 mDNS)
+	var linkLocal net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || (ipNet.IP.To4() != nil) == strings.EqualFold("tcp6", pSelf.network) {
+			continue
+		}
+		if ipNet.IP.IsGlobalUnicast() {
+			return ipNet.IP.String(), nil
+		}
+		if linkLocal == nil && ipNet.IP.IsLinkLocalUnicast() {
+			linkLocal = ipNet.IP
+		}
+	}
+	if linkLocal != nil {
+		return linkLocal.String(), nil
+	}
+	return "", errors.New("no address to advertise: set discovery address")
+}
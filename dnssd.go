@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultDNSRecordTTL = time.Minute
+
+// DNSRecord 는 DNS 에 게시할 resource record 이다.
+type DNSRecord struct {
+	// Name 은 record 의 FQDN 이다. (e.g. _greeter._tcp.example.com.)
+	Name string
+	// Type 은 record type 이다. (SRV, TXT, PTR, A, AAAA)
+	Type string
+	TTL  time.Duration
+	// Value 는 zone 파일 형식의 값이다. (e.g. SRV "0 0 50051 host.example.com.", TXT "\"version=1.2\"")
+	Value string
+}
+
+func (pSelf DNSRecord) String() string {
+	return fmt.Sprintf("%s %d IN %s %s", pSelf.Name, int(pSelf.TTL.Seconds()), pSelf.Type, pSelf.Value)
+}
+
+// DNSUpdater 는 DNS record 를 추가, 삭제한다. (e.g. RFC 2136 dynamic update, Route 53, Cloud DNS)
+// 같은 이름과 type 의 다른 record 는 유지하고 전달한 record 만 추가, 삭제한다. (여러 Server 가 같은 SRV 이름을 사용)
+type DNSUpdater interface {
+	AddRecords(ctx context.Context, records []DNSRecord) error
+	DeleteRecords(ctx context.Context, records []DNSRecord) error
+}
+
+// DNSSDOptions 는 DNS-SD (RFC 6763) record 게시 설정이다.
+type DNSSDOptions struct {
+	// Domain 은 record 를 게시할 domain 이다. (e.g. example.com)
+	Domain string
+	// Target 은 SRV record 가 가리킬 host 이름이다.
+	// 비어 있으면 "ID.Domain" 을 사용하고, 등록 주소가 IP 이면 A, AAAA record 도 게시한다.
+	Target string
+	// TTL 은 record 의 TTL 이다. (기본값: 1분)
+	TTL time.Duration
+	// Priority, Weight 는 SRV record 의 우선 순위와 가중치이다.
+	Priority uint16
+	Weight   uint16
+}
+
+// NewDNSSDRegistrar 는 Server 를 DNS SRV, TXT record 로 게시하는 Registrar 이다. WithRegistrar 에 전달한다.
+// 다음 record 를 게시하며, client 는 net.LookupSRV(이름, "tcp", Domain) 으로 모든 Server 를 찾는다.
+//
+//	_이름._tcp.Domain     SRV  Priority Weight port Target
+//	_이름._tcp.Domain     PTR  ID._이름._tcp.Domain
+//	ID._이름._tcp.Domain  SRV  Priority Weight port Target
+//	ID._이름._tcp.Domain  TXT  "tag=..." "key=value" "http_port=..."
+func NewDNSSDRegistrar(updater DNSUpdater, dnssdOptions DNSSDOptions) (Registrar, error) {
+	if updater == nil {
+		return nil, errors.New("dns updater is nil")
+	}
+	if len(dnssdOptions.Domain) == 0 {
+		return nil, errors.New("dns-sd domain is required")
+	}
+	if dnssdOptions.TTL <= 0 {
+		dnssdOptions.TTL = defaultDNSRecordTTL
+	}
+	dnssdOptions.Domain = strings.TrimSuffix(dnssdOptions.Domain, ".") + "."
+	return &dnssdRegistrar{updater: updater, options: dnssdOptions}, nil
+}
+
+type dnssdRegistrar struct {
+	updater DNSUpdater
+	options DNSSDOptions
+}
+
+func (pSelf *dnssdRegistrar) Register(ctx context.Context, instance ServiceInstance) error {
+	return pSelf.updater.AddRecords(ctx, pSelf.records(instance))
+}
+
+func (pSelf *dnssdRegistrar) Deregister(ctx context.Context, instance ServiceInstance) error {
+	return pSelf.updater.DeleteRecords(ctx, pSelf.records(instance))
+}
+
+// records 는 instance 를 게시할 DNS record 이다.
+func (pSelf *dnssdRegistrar) records(instance ServiceInstance) []DNSRecord {
+	ttl := pSelf.options.TTL
+	service := "_" + dnsLabel(instance.Name) + "._tcp." + pSelf.options.Domain
+	instanceName := dnsLabel(instance.ID) + "." + service
+
+	var records []DNSRecord
+	target := pSelf.options.Target
+	if len(target) == 0 {
+		target = dnsLabel(instance.ID) + "." + pSelf.options.Domain
+		if ip := net.ParseIP(instance.Address); ip != nil {
+			recordType := "AAAA"
+			if ip.To4() != nil {
+				recordType = "A"
+			}
+			records = append(records, DNSRecord{Name: target, Type: recordType, TTL: ttl, Value: ip.String()})
+		}
+	}
+	target = strings.TrimSuffix(target, ".") + "."
+
+	srv := fmt.Sprintf("%d %d %d %s", pSelf.options.Priority, pSelf.options.Weight, instance.Port, target)
+	records = append(records,
+		DNSRecord{Name: service, Type: "SRV", TTL: ttl, Value: srv},
+		DNSRecord{Name: service, Type: "PTR", TTL: ttl, Value: instanceName},
+		DNSRecord{Name: instanceName, Type: "SRV", TTL: ttl, Value: srv},
+		DNSRecord{Name: instanceName, Type: "TXT", TTL: ttl, Value: txtValue(instance)},
+	)
+	return records
+}
+
+// txtValue 는 tag, metadata, http_port 를 key=value 문자열로 나열한 TXT 값이다. (RFC 6763 6장)
+func txtValue(instance ServiceInstance) string {
+	var items []string
+	for _, tag := range instance.Tags {
+		items = append(items, "tag="+tag)
+	}
+	keys := make([]string, 0, len(instance.Metadata))
+	for key := range instance.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		items = append(items, key+"="+instance.Metadata[key])
+	}
+	if instance.HttpPort > 0 {
+		items = append(items, "http_port="+strconv.Itoa(instance.HttpPort))
+	}
+	if len(items) == 0 {
+		// TXT record 는 비어 있을 수 없다.
+		items = append(items, "")
+	}
+
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// dnsLabel 은 DNS label 에 사용할 수 없는 문자를 '-' 로 바꾼다.
+func dnsLabel(name string) string {
+	label := []byte(strings.ToLower(name))
+	for i, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			label[i] = '-'
+		}
+	}
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return string(label)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultConfigNetwork = "tcp"
+	defaultConfigPort    = 50051
+)
+
+// DefaultConfig 는 환경 변수나 flag 가 없을 때 사용하는 기본 설정이다. (tcp, 모든 주소, port 50051)
+func DefaultConfig() *Config {
+	return &Config{
+		Network: defaultConfigNetwork,
+		Port:    defaultConfigPort,
+	}
+}
+
+// EnvError 는 환경 변수의 값을 해석하지 못한 오류이다.
+type EnvError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (pSelf *EnvError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %v", pSelf.Name, pSelf.Value, pSelf.Err)
+}
+
+func (pSelf *EnvError) Unwrap() error {
+	return pSelf.Err
+}
+
+// LoadConfigFromEnv 는 prefix 로 시작하는 환경 변수로 설정을 읽는다. 없는 환경 변수는 DefaultConfig 의 값을 사용한다.
+//
+//	PREFIX_NETWORK                   tcp, tcp4, tcp6, unix, vsock (기본값: tcp)
+//	PREFIX_ADDRESS                   listen 주소 (기본값: 모든 주소)
+//	PREFIX_PORT                      gRPC Server port (기본값: 50051)
+//	PREFIX_HTTP_PORT                 Http Proxy Server port (기본값: PORT + 1)
+//	PREFIX_TLS_CERT, PREFIX_TLS_KEY  인증서, 개인 키 파일
+//	PREFIX_TLS_CLIENT_CA             client 인증서를 검증할 CA 파일 (mTLS)
+//	PREFIX_MAX_CONNECTIONS           동시 연결 수 제한
+//	PREFIX_MAX_CONNECTIONS_PER_IP    client IP 별 동시 연결 수 제한
+//	PREFIX_TRUSTED_PROXIES           IP 별 제한에서 제외할 IP, CIDR (쉼표로 구분)
+//	PREFIX_IDLE_TIMEOUT              idle 연결 timeout (e.g. 5m)
+//	PREFIX_MAX_CONNECTION_AGE        연결 최대 수명 (e.g. 30m)
+//	PREFIX_MAX_CONNECTION_AGE_GRACE  연결 최대 수명 이후 처리 중인 요청을 기다리는 시간
+//	PREFIX_RECOVERY, PREFIX_HEALTH, PREFIX_REFLECTION  기본 Interceptor, Service 사용 여부 (true, false)
+//	PREFIX_PID_FILE                  PID 파일 경로
+//	PREFIX_DISCOVERY_NAME, PREFIX_DISCOVERY_ID, PREFIX_DISCOVERY_ADDRESS  service discovery 에 등록할 이름, ID, 주소
+//	PREFIX_DISCOVERY_TAGS            service discovery tag (쉼표로 구분)
+//	PREFIX_DISCOVERY_METADATA        service discovery metadata (e.g. version=1.2,zone=a)
+//
+// 해석하지 못한 환경 변수는 *EnvError 로 모두 모아서 반환한다.
+func LoadConfigFromEnv(prefix string) (*Config, error) {
+	config := DefaultConfig()
+	if err := config.applyEnv(prefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyEnv 는 lookup 으로 찾은 환경 변수의 값으로 설정을 바꾼다.
+func (pSelf *Config) applyEnv(prefix string, lookup func(string) (string, bool)) error {
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	var errs []error
+	env := func(name string, parse func(value string) error) {
+		value, ok := lookup(prefix + name)
+		if !ok {
+			return
+		}
+		if err := parse(strings.TrimSpace(value)); err != nil {
+			errs = append(errs, &EnvError{Name: prefix + name, Value: value, Err: err})
+		}
+	}
+
+	env("NETWORK", stringValue(&pSelf.Network))
+	env("ADDRESS", stringValue(&pSelf.Address))
+	env("PORT", intValue(&pSelf.Port))
+	env("HTTP_PORT", intValue(&pSelf.HttpPort))
+
+	env("TLS_CERT", stringValue(&pSelf.TLS.CertFile))
+	env("TLS_KEY", stringValue(&pSelf.TLS.KeyFile))
+	env("TLS_CLIENT_CA", stringValue(&pSelf.TLS.ClientCAFile))
+
+	env("MAX_CONNECTIONS", intValue(&pSelf.Limits.MaxConnections))
+	env("MAX_CONNECTIONS_PER_IP", intValue(&pSelf.Limits.MaxConnectionsPerIP))
+	env("TRUSTED_PROXIES", listValue(&pSelf.Limits.TrustedProxies))
+	env("IDLE_TIMEOUT", durationValue(&pSelf.Limits.IdleTimeout))
+	env("MAX_CONNECTION_AGE", durationValue(&pSelf.Limits.MaxConnectionAge))
+	env("MAX_CONNECTION_AGE_GRACE", durationValue(&pSelf.Limits.MaxConnectionAgeGrace))
+
+	env("RECOVERY", boolValue(&pSelf.Middleware.Recovery))
+	env("HEALTH", boolValue(&pSelf.Middleware.Health))
+	env("REFLECTION", boolValue(&pSelf.Middleware.Reflection))
+
+	env("PID_FILE", stringValue(&pSelf.PIDFile))
+
+	env("DISCOVERY_NAME", stringValue(&pSelf.Discovery.Name))
+	env("DISCOVERY_ID", stringValue(&pSelf.Discovery.ID))
+	env("DISCOVERY_ADDRESS", stringValue(&pSelf.Discovery.Address))
+	env("DISCOVERY_TAGS", listValue(&pSelf.Discovery.Tags))
+	env("DISCOVERY_METADATA", mapValue(&pSelf.Discovery.Metadata))
+
+	return errors.Join(errs...)
+}
+
+func stringValue(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func intValue(p *int) func(string) error {
+	return func(value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		*p = i
+		return nil
+	}
+}
+
+func boolValue(p *bool) func(string) error {
+	return func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		*p = b
+		return nil
+	}
+}
+
+func durationValue(p *Duration) func(string) error {
+	return func(value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("not a duration (e.g. 30s, 5m)")
+		}
+		*p = Duration(duration)
+		return nil
+	}
+}
+
+func listValue(p *[]string) func(string) error {
+	return func(value string) error {
+		*p = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				*p = append(*p, item)
+			}
+		}
+		return nil
+	}
+}
+
+// mapValue 는 쉼표로 구분한 key=value 목록이다.
+func mapValue(p *map[string]string) func(string) error {
+	return func(value string) error {
+		var items []string
+		_ = listValue(&items)(value)
+		m := make(map[string]string, len(items))
+		for _, item := range items {
+			k, v, ok := strings.Cut(item, "=")
+			if !ok {
+				return errors.New("not a key=value list (e.g. version=1.2,zone=a)")
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		*p = m
+		return nil
+	}
+}
+
+// NewFromEnv 는 prefix 로 시작하는 환경 변수로 gRPC Server 를 생성한다. (LoadConfigFromEnv 참고)
+// opts 는 환경 변수의 설정보다 나중에 적용된다.
+func NewFromEnv(
+	prefix string,
+	unaryServerInterceptors []grpc.UnaryServerInterceptor,
+	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
+) *GrpcServer {
+	config, err := LoadConfigFromEnv(prefix)
+	if err != nil {
+		gLogger.Fatal(err)
+	}
+	return NewWithConfig(config, unaryServerInterceptors, streamServerInterceptors, opts...)
+}
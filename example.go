@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exampleComments 는 WriteExampleConfig 가 항목 위에 기록하는 설명이다. key 는 yaml 항목 이름이다.
+var exampleComments = map[string]string{
+	"network":                         "listen 할 network 이다. (tcp, tcp4, tcp6, unix, vsock)",
+	"address":                         "listen 주소이다. 비어 있으면 모든 주소, unix 는 socket 파일 경로이다.",
+	"port":                            "gRPC Server port 이다. 0 이면 OS 가 할당한다.",
+	"http_port":                       "gRPC Gateway (Http Proxy) Server port 이다. 0 이면 port + 1 을 사용한다.",
+	"tls":                             "TLS 설정이다. cert_file 이 비어 있으면 TLS 를 사용하지 않는다.",
+	"tls.cert_file":                   "인증서 파일 (PEM) 이다.",
+	"tls.key_file":                    "개인 키 파일 (PEM) 이다.",
+	"tls.client_ca_file":              "client 인증서를 검증할 CA 파일이다. 있으면 client 인증서를 요구한다. (mTLS)",
+	"tls.peers":                       "Method 별로 호출할 수 있는 client 인증서의 SAN 이다. (e.g. {\"/myapp.AdminService/*\": [\"uri:spiffe://example.org/ns/ops/*\"]})",
+	"limits":                          "연결 수와 연결 수명 제한이다. 0 인 항목은 제한하지 않는다.",
+	"limits.max_connections":          "모든 Listener 에서 동시에 유지할 수 있는 연결 수이다.",
+	"limits.max_connections_per_ip":   "client IP 별로 동시에 유지할 수 있는 연결 수이다.",
+	"limits.trusted_proxies":          "IP 별 제한에서 제외할 IP, CIDR 이다. (e.g. load balancer)",
+	"limits.idle_timeout":             "요청이 없는 연결을 닫기까지의 시간이다. (e.g. 5m)",
+	"limits.max_connection_age":       "연결의 최대 수명이다. load balancer 가 연결을 고르게 나누도록 사용한다. (e.g. 30m)",
+	"limits.max_connection_age_grace": "연결 최대 수명 이후 처리 중인 요청을 기다리는 시간이다. (e.g. 30s)",
+	"limits.rate_limit":               "인증한 principal (서명 key, client 인증서) 별 초당 요청 수이다. 인증하지 않은 요청은 client IP 별로 제한한다.",
+	"limits.rate_burst":               "principal 별로 한 번에 허용하는 요청 수이다. 0 이면 rate_limit 을 올림한 값이다.",
+	"limits.principals":               "principal 별 요청 제한이다. (e.g. {\"key:batch\": {rate: 1000, burst: 2000}})",
+	"middleware":                      "기본 Interceptor 와 Service 사용 여부이다.",
+	"middleware.recovery":             "Handler 의 panic 을 복구하여 Internal 오류로 응답한다.",
+	"middleware.health":               "grpc.health.v1.Health Service 를 등록한다.",
+	"middleware.reflection":           "Server Reflection Service 를 등록한다. (grpcurl 등)",
+	"pid_file":                        "프로세스 ID 를 기록할 파일이다. 이미 실행 중인 프로세스가 있으면 시작하지 않는다.",
+	"discovery":                       "WithRegistrar 로 service discovery (e.g. Consul) 에 등록할 Server 정보이다.",
+	"discovery.name":                  "Service 이름이다. 비어 있으면 실행 파일 이름을 사용한다.",
+	"discovery.id":                    "Server 를 구분하는 값이다. 비어 있으면 이름-hostname-port 를 사용한다.",
+	"discovery.address":               "client 가 연결할 주소이다. 비어 있으면 listen 주소나 이 host 의 IP 를 사용한다.",
+	"discovery.tags":                  "등록할 tag 이다.",
+	"discovery.metadata":              "등록할 metadata 이다. (e.g. {version: \"1.2\"})",
+}
+
+// WriteExampleConfig 는 모든 항목과 설명이 있는 예시 설정 파일 (YAML) 을 w 에 기록한다.
+// 값은 DefaultConfig 의 기본값이며, Config 의 항목에서 만들기 때문에 항상 현재 Config 와 일치한다.
+//
+//	if *exampleConfig {
+//		_ = server.WriteExampleConfig(os.Stdout)
+//		return
+//	}
+func WriteExampleConfig(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("# berryons/server 설정 파일 예시\n")
+	sb.WriteString("# 기본값과 같은 항목은 지워도 된다. profile 설정 파일 (e.g. server.prod.yaml) 에는 바꿀 항목만 기록한다.\n")
+	writeExampleFields(&sb, reflect.ValueOf(DefaultConfig()).Elem(), "", 0)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeExampleFields(sb *strings.Builder, value reflect.Value, prefix string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		fieldValue := value.Field(i)
+
+		if i > 0 || depth == 0 {
+			sb.WriteString("\n")
+		}
+		if comment, ok := exampleComments[prefix+name]; ok {
+			fmt.Fprintf(sb, "%s# %s\n", indent, comment)
+		}
+
+		if fieldValue.Kind() == reflect.Struct {
+			fmt.Fprintf(sb, "%s%s:\n", indent, name)
+			writeExampleFields(sb, fieldValue, prefix+name+".", depth+1)
+			continue
+		}
+		fmt.Fprintf(sb, "%s%s: %s\n", indent, name, exampleValue(fieldValue))
+	}
+}
+
+// exampleValue 는 YAML 값 표현이다.
+func exampleValue(value reflect.Value) string {
+	if duration, ok := value.Interface().(Duration); ok {
+		return time.Duration(duration).String()
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return strconv.Quote(value.String())
+	case reflect.Slice:
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = exampleValue(value.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		items := make([]string, 0, value.Len())
+		for _, key := range value.MapKeys() {
+			items = append(items, exampleValue(key)+": "+exampleValue(value.MapIndex(key)))
+		}
+		slices.Sort(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
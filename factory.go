@@ -0,0 +1,44 @@
+package server
+
+import (
+	"google.golang.org/grpc"
+	"net"
+)
+
+// ServiceServer 는 Service 를 등록하고 요청을 처리하는 gRPC Server 이다. *grpc.Server 가 구현한다.
+type ServiceServer interface {
+	grpc.ServiceRegistrar
+	GetServiceInfo() map[string]grpc.ServiceInfo
+	Serve(listener net.Listener) error
+	Stop()
+	GracefulStop()
+}
+
+// ServerFactory 는 serverOptions 로 ServiceServer 를 생성한다.
+type ServerFactory func(serverOptions ...grpc.ServerOption) (ServiceServer, error)
+
+// WithServerFactory 는 grpc.NewServer 대신 factory 로 gRPC Server 를 생성한다.
+// factory 가 *grpc.Server 가 아닌 Server 를 생성하면 GrpcServer.Server 는 nil 이므로, Service 는 RegisterServices 로 등록한다.
+func WithServerFactory(factory ServerFactory) Option {
+	return func(options *serverOptions) {
+		options.serverFactory = factory
+		options.factoryCredentials = false
+	}
+}
+
+// WithCredentialsServerFactory 는 TransportCredentials 를 직접 설정하는 factory 로 gRPC Server 를 생성한다. (e.g. serverxds.WithXDS)
+// factory 의 credentials 가 Listener 별 TLS 설정을 대신하므로, WithTLS 나 ListenerConfig.TLS 와 함께 사용하면 시작하지 않는다.
+func WithCredentialsServerFactory(factory ServerFactory) Option {
+	return func(options *serverOptions) {
+		options.serverFactory = factory
+		options.factoryCredentials = true
+	}
+}
+
+// newServiceServer 는 Option 에 따라 gRPC Server 를 생성한다.
+func newServiceServer(options *serverOptions, serverOptions []grpc.ServerOption) (ServiceServer, error) {
+	if options.serverFactory == nil {
+		return grpc.NewServer(serverOptions...), nil
+	}
+	return options.serverFactory(serverOptions...)
+}
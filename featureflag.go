@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// FlagContext 는 feature flag 를 평가할 때 전달하는 요청 정보이다.
+type FlagContext struct {
+	// TargetingKey 는 비율로 나누어 적용할 때 기준이 되는 값이다. (request ID, 없으면 peer 주소)
+	TargetingKey string
+	// Method 는 gRPC method 의 전체 이름이다. (e.g. /pkg.Service/Method)
+	Method string
+	// Peer 는 client 주소이다.
+	Peer string
+}
+
+// FlagProvider 는 요청별로 feature flag 를 평가한다.
+// OpenFeature client 를 사용하는 경우 FlagContext 를 EvaluationContext 로 바꾸어 BooleanValue 를 호출하도록 감싼다.
+//
+//	server.FlagProviderFunc(func(ctx context.Context, flag string, defaultValue bool, flagContext server.FlagContext) bool {
+//		evalCtx := openfeature.NewEvaluationContext(flagContext.TargetingKey, map[string]any{"method": flagContext.Method})
+//		value, _ := client.BooleanValue(ctx, flag, defaultValue, evalCtx)
+//		return value
+//	})
+type FlagProvider interface {
+	BooleanValue(ctx context.Context, flag string, defaultValue bool, flagContext FlagContext) bool
+}
+
+// FlagProviderFunc 는 함수를 FlagProvider 로 사용한다.
+type FlagProviderFunc func(ctx context.Context, flag string, defaultValue bool, flagContext FlagContext) bool
+
+func (pSelf FlagProviderFunc) BooleanValue(ctx context.Context, flag string, defaultValue bool, flagContext FlagContext) bool {
+	return pSelf(ctx, flag, defaultValue, flagContext)
+}
+
+// WithFeatureFlags 는 요청을 처리하는 동안 FeatureEnabled, FlagGatedUnaryInterceptor 등이 provider 로 feature flag 를 평가하도록 한다.
+func WithFeatureFlags(provider FlagProvider) Option {
+	return func(options *serverOptions) {
+		options.flagProvider = provider
+	}
+}
+
+type flagContextKey struct{}
+
+type flagEvaluation struct {
+	provider    FlagProvider
+	flagContext FlagContext
+}
+
+// FeatureEnabled 는 현재 요청에 대해 flag 가 켜져 있는지 평가한다. WithFeatureFlags 를 사용하지 않으면 false 이다.
+func FeatureEnabled(ctx context.Context, flag string) bool {
+	evaluation, ok := ctx.Value(flagContextKey{}).(*flagEvaluation)
+	if !ok {
+		return false
+	}
+	return evaluation.provider.BooleanValue(ctx, flag, false, evaluation.flagContext)
+}
+
+// FlagGatedUnaryInterceptor 는 flag 가 켜진 요청에만 interceptor 를 실행한다.
+// (e.g. 1% 의 요청에만 payload 를 log 에 기록)
+func FlagGatedUnaryInterceptor(flag string, interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !FeatureEnabled(ctx, flag) {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+// FlagGatedStreamInterceptor 는 flag 가 켜진 요청에만 interceptor 를 실행한다.
+func FlagGatedStreamInterceptor(flag string, interceptor grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !FeatureEnabled(ss.Context(), flag) {
+			return handler(srv, ss)
+		}
+		return interceptor(srv, ss, info, handler)
+	}
+}
+
+// contextWithFlags 는 feature flag 를 평가할 수 있도록 provider 와 요청 정보를 context 에 기록한다.
+func contextWithFlags(ctx context.Context, provider FlagProvider, fullMethod string) context.Context {
+	flagContext := FlagContext{Method: fullMethod}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		flagContext.Peer = p.Addr.String()
+	}
+	flagContext.TargetingKey = RequestID(ctx)
+	if len(flagContext.TargetingKey) == 0 {
+		flagContext.TargetingKey = flagContext.Peer
+	}
+	return context.WithValue(ctx, flagContextKey{}, &flagEvaluation{provider: provider, flagContext: flagContext})
+}
+
+func featureFlagUnaryServerInterceptor(provider FlagProvider) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(contextWithFlags(ctx, provider, info.FullMethod), req)
+	}
+}
+
+func featureFlagStreamServerInterceptor(provider FlagProvider) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &contextServerStream{ServerStream: ss, ctx: contextWithFlags(ss.Context(), provider, info.FullMethod)})
+	}
+}
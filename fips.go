@@ -0,0 +1,226 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// fipsMinRSABits 는 FIPS 140-3 에서 서명에 사용할 수 있는 최소 RSA key 크기이다.
+const fipsMinRSABits = 2048
+
+// fipsCipherSuites 는 TLS 1.2 에서 사용할 수 있는 승인된 cipher suite 이다.
+// TLS 1.3 의 cipher suite 는 설정할 수 없으며, FIPS 검증 module 을 사용해야 ChaCha20-Poly1305 를 사용하지 않는다.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves 는 승인된 key 교환 curve 이다.
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// FIPSOptions 는 FIPS 140 규정 준수 모드 설정이다.
+type FIPSOptions struct {
+	// RequireModule 이 true 이면 FIPS 검증 암호 module 로 build 하지 않았거나 사용하지 않는 경우 시작하지 않는다. (FIPSModule 참고)
+	RequireModule bool
+}
+
+// WithFIPSMode 는 TLS 설정을 FIPS 140 에서 승인된 parameter 로 제한한다.
+//
+//   - 설정하지 않은 항목은 TLS 1.2 이상, AES-GCM ECDHE cipher suite, P-256/P-384/P-521 curve 로 설정한다.
+//   - 승인되지 않은 값을 직접 설정했거나 (e.g. TLS 1.1, ChaCha20, X25519), 인증서가 2048 bit 미만 RSA 또는 승인되지 않은 key 이면 시작하지 않는다.
+//   - GetCertificate 로 handshake 마다 정하는 인증서 (e.g. 설정 파일의 인증서, Vault PKI, SPIFFE) 는 handshake 할 때 확인하여, 승인되지 않은 key 이면 handshake 를 거부한다.
+//
+// 시작할 때 사용하는 암호 module 을 log 에 기록한다. 설정만으로는 규정을 준수할 수 없으며, FIPS 검증 module 로 build 해야 한다.
+// (e.g. Go 1.24 이상의 GOFIPS140=v1.0.0, 또는 GOEXPERIMENT=boringcrypto)
+func WithFIPSMode(fipsOptions FIPSOptions) Option {
+	return func(options *serverOptions) {
+		options.fips = &fipsOptions
+	}
+}
+
+// FIPSModule 은 binary 가 사용하는 FIPS 검증 암호 module 이다. 사용하지 않으면 false 이다.
+// GOFIPS140 으로 build 했거나 GODEBUG=fips140=on (또는 only) 으로 실행한 Go Cryptographic Module 과 BoringCrypto 를 확인한다.
+func FIPSModule() (string, bool) {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", false
+	}
+
+	module := ""
+	godebug := os.Getenv("GODEBUG")
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "GOFIPS140":
+			if setting.Value != "off" && len(setting.Value) > 0 {
+				module = "go-fips140 " + setting.Value
+			}
+		case "GOEXPERIMENT":
+			if slices.Contains(strings.Split(setting.Value, ","), "boringcrypto") {
+				return "boringcrypto", true
+			}
+		case "DefaultGODEBUG":
+			godebug = setting.Value + "," + godebug
+		}
+	}
+
+	// GOFIPS140 으로 build 하면 fips140=on 이 기본값이며, GODEBUG 에서 뒤의 값이 우선한다.
+	enabled := len(module) > 0
+	for _, value := range strings.Split(godebug, ",") {
+		switch strings.TrimSpace(value) {
+		case "fips140=on", "fips140=only":
+			enabled = true
+		case "fips140=off":
+			enabled = false
+		}
+	}
+	if !enabled {
+		return "", false
+	}
+	if len(module) == 0 {
+		module = "go-fips140"
+	}
+	return module, true
+}
+
+// applyFIPS 는 모든 TLS 설정이 승인된 parameter 만 사용하도록 설정하고, 승인되지 않은 설정을 찾는다.
+func applyFIPS(options *serverOptions) error {
+	if options.fips == nil {
+		return nil
+	}
+
+	module, ok := FIPSModule()
+	if !ok && options.fips.RequireModule {
+		return errors.New("binary is not using a FIPS 140 validated cryptographic module")
+	}
+	if ok {
+		gLogger.Printf("FIPS mode: using %s\n", module)
+	} else {
+		gLogger.Println("FIPS mode: TLS parameters are restricted, but no FIPS 140 validated cryptographic module is in use (TLS 1.3 may negotiate ChaCha20-Poly1305)")
+	}
+
+	var errs []error
+	var err error
+	if options.tlsConfig, err = fipsTLSConfig(options.tlsConfig); err != nil {
+		errs = append(errs, fmt.Errorf("tls: %w", err))
+	}
+	for i := range options.additionalListeners {
+		if options.additionalListeners[i].TLS, err = fipsTLSConfig(options.additionalListeners[i].TLS); err != nil {
+			errs = append(errs, fmt.Errorf("listener %d: %w", i+1, err))
+		}
+	}
+	if options.http3 != nil {
+		if options.http3.TLS, err = fipsTLSConfig(options.http3.TLS); err != nil {
+			errs = append(errs, fmt.Errorf("http3: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fipsTLSConfig 는 설정하지 않은 항목을 승인된 값으로 채운 tlsConfig 의 복사본이다. 승인되지 않은 값이 있으면 오류를 반환한다.
+func fipsTLSConfig(tlsConfig *tls.Config) (*tls.Config, error) {
+	if tlsConfig == nil {
+		return nil, nil
+	}
+
+	var errs []error
+	if tlsConfig.MinVersion != 0 && tlsConfig.MinVersion < tls.VersionTLS12 {
+		errs = append(errs, fmt.Errorf("TLS version %s is not approved", tls.VersionName(tlsConfig.MinVersion)))
+	}
+	for _, cipherSuite := range tlsConfig.CipherSuites {
+		if !slices.Contains(fipsCipherSuites, cipherSuite) {
+			errs = append(errs, fmt.Errorf("cipher suite %s is not approved", tls.CipherSuiteName(cipherSuite)))
+		}
+	}
+	for _, curve := range tlsConfig.CurvePreferences {
+		if !slices.Contains(fipsCurves, curve) {
+			errs = append(errs, fmt.Errorf("curve %s is not approved", curve))
+		}
+	}
+	for _, certificate := range tlsConfig.Certificates {
+		if err := fipsCertificate(certificate); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return tlsConfig, errors.Join(errs...)
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+	if len(tlsConfig.CipherSuites) == 0 {
+		tlsConfig.CipherSuites = slices.Clone(fipsCipherSuites)
+	}
+	if len(tlsConfig.CurvePreferences) == 0 {
+		tlsConfig.CurvePreferences = slices.Clone(fipsCurves)
+	}
+	// handshake 마다 정하는 인증서와 설정도 확인한다.
+	if getCertificate := tlsConfig.GetCertificate; getCertificate != nil {
+		tlsConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			certificate, err := getCertificate(hello)
+			if err != nil || certificate == nil {
+				return certificate, err
+			}
+			if err := fipsCertificate(*certificate); err != nil {
+				return nil, fmt.Errorf("FIPS mode: %w", err)
+			}
+			return certificate, nil
+		}
+	}
+	if getConfigForClient := tlsConfig.GetConfigForClient; getConfigForClient != nil {
+		tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			clientConfig, err := getConfigForClient(hello)
+			if err != nil || clientConfig == nil {
+				return clientConfig, err
+			}
+			if clientConfig, err = fipsTLSConfig(clientConfig); err != nil {
+				return nil, fmt.Errorf("FIPS mode: %w", err)
+			}
+			return clientConfig, nil
+		}
+	}
+	return tlsConfig, nil
+}
+
+// fipsCertificate 는 인증서의 key 가 승인된 종류와 크기인지 확인한다.
+func fipsCertificate(certificate tls.Certificate) error {
+	leaf := certificate.Leaf
+	if leaf == nil && len(certificate.Certificate) > 0 {
+		var err error
+		if leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+			return err
+		}
+	}
+	if leaf == nil {
+		return nil
+	}
+
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < fipsMinRSABits {
+			return fmt.Errorf("certificate %s: %d bit RSA key is not approved", leaf.Subject, key.N.BitLen())
+		}
+	case ed25519.PublicKey:
+		// FIPS 186-5 에서 승인.
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() && key.Curve != elliptic.P384() && key.Curve != elliptic.P521() {
+			return fmt.Errorf("certificate %s: ECDSA curve %s is not approved", leaf.Subject, key.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("certificate %s: %T key is not approved", leaf.Subject, key)
+	}
+	return nil
+}
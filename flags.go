@@ -0,0 +1,79 @@
+package server
+
+import (
+	"flag"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RegisterFlags 는 fs 에 Server 설정 flag (--grpc-port, --http-port, --tls-cert 등) 를 등록한다.
+// 반환한 설정은 fs.Parse 를 호출한 뒤 사용하며, 지정하지 않은 flag 는 DefaultConfig 의 값을 사용한다.
+func RegisterFlags(fs *flag.FlagSet) *Config {
+	config := DefaultConfig()
+
+	fs.StringVar(&config.Network, "network", config.Network, "gRPC server network (tcp, tcp4, tcp6, unix, vsock)")
+	fs.StringVar(&config.Address, "address", config.Address, "gRPC server listen address")
+	fs.IntVar(&config.Port, "grpc-port", config.Port, "gRPC server port")
+	fs.IntVar(&config.HttpPort, "http-port", config.HttpPort, "HTTP gateway port (default grpc-port + 1)")
+
+	fs.StringVar(&config.TLS.CertFile, "tls-cert", config.TLS.CertFile, "TLS certificate file")
+	fs.StringVar(&config.TLS.KeyFile, "tls-key", config.TLS.KeyFile, "TLS private key file")
+	fs.StringVar(&config.TLS.ClientCAFile, "tls-client-ca", config.TLS.ClientCAFile, "CA file to verify client certificates (mTLS)")
+
+	fs.IntVar(&config.Limits.MaxConnections, "max-connections", config.Limits.MaxConnections, "maximum concurrent connections (0: unlimited)")
+	fs.IntVar(&config.Limits.MaxConnectionsPerIP, "max-connections-per-ip", config.Limits.MaxConnectionsPerIP, "maximum concurrent connections per client IP (0: unlimited)")
+	fs.Var((*listFlag)(&config.Limits.TrustedProxies), "trusted-proxies", "comma separated `IPs` or CIDRs excluded from the per-IP limit")
+	fs.Var(&config.Limits.IdleTimeout, "idle-timeout", "close connections idle for this `duration` (e.g. 5m)")
+	fs.Var(&config.Limits.MaxConnectionAge, "max-connection-age", "recycle connections older than this `duration` (e.g. 30m)")
+	fs.Var(&config.Limits.MaxConnectionAgeGrace, "max-connection-age-grace", "`duration` to wait for in-flight requests after max-connection-age")
+
+	fs.BoolVar(&config.Middleware.Recovery, "recovery", config.Middleware.Recovery, "recover from panics in handlers")
+	fs.BoolVar(&config.Middleware.Health, "health", config.Middleware.Health, "register the gRPC health service")
+	fs.BoolVar(&config.Middleware.Reflection, "reflection", config.Middleware.Reflection, "register the gRPC reflection service")
+
+	fs.StringVar(&config.PIDFile, "pid-file", config.PIDFile, "PID file path")
+
+	fs.StringVar(&config.Discovery.Name, "discovery-name", config.Discovery.Name, "service name to register (default executable name)")
+	fs.StringVar(&config.Discovery.ID, "discovery-id", config.Discovery.ID, "service ID to register (default name-hostname-port)")
+	fs.StringVar(&config.Discovery.Address, "discovery-address", config.Discovery.Address, "address to register (default listen address or host IP)")
+	fs.Var((*listFlag)(&config.Discovery.Tags), "discovery-tags", "comma separated service `tags` to register")
+	fs.Var((*mapFlag)(&config.Discovery.Metadata), "discovery-metadata", "comma separated `key=value` service metadata to register")
+
+	return config
+}
+
+func (pSelf *Duration) String() string {
+	return time.Duration(*pSelf).String()
+}
+
+func (pSelf *Duration) Set(value string) error {
+	return pSelf.UnmarshalText([]byte(value))
+}
+
+// listFlag 는 쉼표로 구분한 목록 flag 이다.
+type listFlag []string
+
+func (pSelf *listFlag) String() string {
+	return strings.Join(*pSelf, ",")
+}
+
+func (pSelf *listFlag) Set(value string) error {
+	return listValue((*[]string)(pSelf))(value)
+}
+
+// mapFlag 는 쉼표로 구분한 key=value 목록 flag 이다.
+type mapFlag map[string]string
+
+func (pSelf *mapFlag) String() string {
+	items := make([]string, 0, len(*pSelf))
+	for k, v := range *pSelf {
+		items = append(items, k+"="+v)
+	}
+	slices.Sort(items)
+	return strings.Join(items, ",")
+}
+
+func (pSelf *mapFlag) Set(value string) error {
+	return mapValue((*map[string]string)(pSelf))(value)
+}
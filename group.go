@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultGroupShutdownTimeout = 30 * time.Second
+
+// ServerGroup 은 한 프로세스에서 여러 gRPC Server 를 함께 실행한다. (e.g. 외부 API, 내부 관리 API)
+// Signal 은 ServerGroup 이 한 번만 처리하며, 종료할 때는 모든 Server 를 동시에 graceful 하게 종료한다.
+//
+//	group := server.NewServerGroup()
+//	group.Add("public", publicServer)
+//	group.Add("admin", adminServer)
+//	if err := group.Run(); err != nil {
+//		log.Fatal(err)
+//	}
+type ServerGroup struct {
+	// ShutdownTimeout 은 종료할 때 처리 중인 요청을 기다리는 시간이다. (기본값: 30초)
+	ShutdownTimeout time.Duration
+
+	members []*groupMember
+	bundles []MiddlewareBundle
+}
+
+type groupMember struct {
+	name   string
+	server *GrpcServer
+}
+
+// NewServerGroup 은 빈 ServerGroup 을 생성한다.
+func NewServerGroup() *ServerGroup {
+	return &ServerGroup{ShutdownTimeout: defaultGroupShutdownTimeout}
+}
+
+// Add 는 name 으로 Server 를 추가한다. name 은 log 와 오류에 사용한다.
+func (pSelf *ServerGroup) Add(name string, grpcServer *GrpcServer) {
+	pSelf.members = append(pSelf.members, &groupMember{name: name, server: grpcServer})
+}
+
+// Run 은 모든 Server 를 실행하고, Signal 을 받거나 Server 하나가 실패하면 모든 Server 를 종료한다.
+// 실행, 종료 중에 발생한 오류를 Server 이름과 함께 모두 모아서 반환한다.
+func (pSelf *ServerGroup) Run() error {
+	if len(pSelf.members) == 0 {
+		return errors.New("server group is empty")
+	}
+	for _, member := range pSelf.members {
+		if member.server == nil || member.server.serviceServer == nil {
+			return fmt.Errorf("%s: gRPC server is nil", member.name)
+		}
+	}
+
+	// signal handler
+	cSig := make(chan os.Signal, 1)
+	signal.Notify(cSig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(cSig)
+
+	type serveResult struct {
+		name string
+		err  error
+	}
+	cResult := make(chan serveResult, len(pSelf.members))
+	for _, member := range pSelf.members {
+		member.server.start()
+		go func(member *groupMember, cErr <-chan error) {
+			cResult <- serveResult{name: member.name, err: <-cErr}
+		}(member, member.server.serveBackground())
+	}
+
+	var errs []error
+	select {
+	case sig := <-cSig:
+		gLogger.Printf("Caught signal: %s", sig)
+	case result := <-cResult:
+		if result.err != nil {
+			gLogger.Printf("Server %s failed: %v\n", result.name, result.err)
+			errs = append(errs, fmt.Errorf("%s: %w", result.name, result.err))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pSelf.ShutdownTimeout)
+	defer cancel()
+	if err := pSelf.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	gLogger.Println("Bye Bye!!!")
+	return errors.Join(errs...)
+}
+
+// Shutdown 은 모든 Server 를 동시에 종료하고, 실패한 Server 의 오류를 모두 모아서 반환한다.
+func (pSelf *ServerGroup) Shutdown(ctx context.Context) error {
+	errs := make([]error, len(pSelf.members))
+	var wg sync.WaitGroup
+	for i, member := range pSelf.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := member.server.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", member.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"golang.org/x/net/http2/h2c"
+	"net/http"
+)
+
+// WithH2C 는 Http Proxy Server 가 TLS 없이도 HTTP/2 (h2c) 연결을 수락하도록 한다.
+// prior knowledge 와 HTTP/1.1 Upgrade 방식을 모두 지원하며, TLS 를 사용하는 경우에는 적용하지 않는다.
+func WithH2C() Option {
+	return func(options *serverOptions) {
+		options.h2c = true
+	}
+}
+
+// h2cHandler 는 평문 HTTP/2 요청을 handler 로 전달한다.
+func (pSelf *GrpcServer) h2cHandler(handler http.Handler) http.Handler {
+	return h2c.NewHandler(handler, pSelf.options.httpTimeouts.http2Server(pSelf.options.idleTimeout))
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// HeaderLimitsOptions 는 gRPC Gateway (Http Proxy) 가 받는 요청 header 의 제한이다. 0 인 항목은 제한하지 않는다.
+type HeaderLimitsOptions struct {
+	// MaxBytes 는 요청 line 과 모든 header 의 크기이다. 넘는 요청은 header 를 모두 읽기 전에 431 로 거부한다. (0 이면 net/http 기본값 1MB)
+	// HTTP/2, HTTP/3 는 SETTINGS_MAX_HEADER_LIST_SIZE 로 client 에게 알린다.
+	MaxBytes int
+	// MaxHeaderSize 는 header 하나의 이름과 값의 크기이다.
+	MaxHeaderSize int
+	// MaxHeaders 는 header 의 수이다. 같은 이름의 header 는 값마다 센다.
+	MaxHeaders int
+}
+
+// WithHeaderLimits 는 gRPC Gateway 로 받은 요청 header 의 크기와 수를 제한하고, 넘는 요청은 Handler 를 실행하기 전에 431 로 거부한다.
+// MaxHeaderSize, MaxHeaders 로 거부한 요청은 header_limit_rejected 지표로 확인한다. (MaxBytes 는 net/http 가 거부하므로 포함하지 않는다)
+func WithHeaderLimits(headerLimitsOptions HeaderLimitsOptions) Option {
+	return func(options *serverOptions) {
+		options.headerLimits = &headerLimitsOptions
+	}
+}
+
+// handler 는 header 하나의 크기와 header 의 수를 확인한다. 전체 크기는 net/http 가 읽을 때 확인한다.
+func (pSelf *HeaderLimitsOptions) handler(handler http.Handler) http.Handler {
+	if pSelf.MaxHeaderSize <= 0 && pSelf.MaxHeaders <= 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := 0
+		for name, values := range r.Header {
+			count += len(values)
+			if pSelf.MaxHeaders > 0 && count > pSelf.MaxHeaders {
+				addLabeledMetric("header_limit_rejected", "count", 1)
+				http.Error(w, "too many headers (max "+strconv.Itoa(pSelf.MaxHeaders)+")", http.StatusRequestHeaderFieldsTooLarge)
+				return
+			}
+			for _, value := range values {
+				if pSelf.MaxHeaderSize > 0 && len(name)+len(value) > pSelf.MaxHeaderSize {
+					addLabeledMetric("header_limit_rejected", "size", 1)
+					http.Error(w, "header too large: "+name, http.StatusRequestHeaderFieldsTooLarge)
+					return
+				}
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/quic-go/quic-go/http3"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Http3Options 는 gRPC Gateway (Http Proxy) 의 HTTP/3 (QUIC) 설정이다.
+type Http3Options struct {
+	// TLS 는 Http Proxy Server 의 인증서 설정이다. nil 이면 WithTLS 의 설정을 사용한다.
+	TLS *tls.Config
+	// Port 는 HTTP/3 를 listen 할 UDP port 이다. 0 이면 Http Proxy Server 와 같은 port 를 사용한다.
+	Port int
+}
+
+// WithHttp3 는 Http Proxy Server 를 UDP 로 HTTP/3 도 제공하고, 응답의 Alt-Svc header 로 HTTP/3 를 알린다.
+// client 는 HTTPS 응답의 Alt-Svc 만 사용하므로 TCP 의 Http Proxy Server 에도 같은 TLS 를 적용한다.
+func WithHttp3(http3Options Http3Options) Option {
+	return func(options *serverOptions) {
+		options.http3 = &http3Options
+	}
+}
+
+// checkHttp3 는 HTTP/3 를 사용할 수 있는 설정인지 확인한다.
+func checkHttp3(network string, options *serverOptions) error {
+	if options.http3 == nil {
+		return nil
+	}
+
+	if options.http3.TLS == nil {
+		options.http3.TLS = options.tlsConfig
+	}
+	if options.http3.TLS == nil {
+		return errors.New("HTTP/3 requires a TLS configuration")
+	}
+	if strings.EqualFold("vsock", network) {
+		return errors.New("HTTP/3 is not supported on " + network + " network")
+	}
+	return nil
+}
+
+// http3Network 는 HTTP/3 Server 가 listen 할 UDP network 이다.
+func (pSelf *GrpcServer) http3Network() string {
+	switch strings.ToLower(pSelf.httpProxyNetwork()) {
+	case "tcp4":
+		return "udp4"
+	case "tcp6":
+		return "udp6"
+	}
+	return "udp"
+}
+
+func (pSelf *GrpcServer) listenHttp3() net.PacketConn {
+	port := pSelf.options.http3.Port
+	if port == 0 {
+		port = pSelf.httpProxyPort
+	}
+
+	conn, err := net.ListenPacket(pSelf.http3Network(), joinAddress(pSelf.http3Network(), pSelf.address, port))
+	if err != nil {
+		pSelf.options.recoverable("failed to listen HTTP/3 server: %v", err)
+		return nil
+	}
+	return conn
+}
+
+func (pSelf *GrpcServer) newHttp3Server(handler http.Handler, conn net.PacketConn) *http3.Server {
+	http3Server := &http3.Server{}
+	if pSelf.options.webTransport != nil {
+		webTransport, err := pSelf.newWebTransportGateway()
+		if err != nil {
+			pSelf.options.recoverable("failed to start WebTransport: %v", err)
+		} else {
+			pSelf.webTransport = webTransport
+			http3Server = webTransport.http3Server()
+			handler = webTransport.handler(handler)
+		}
+	}
+	http3Server.Handler = handler
+	http3Server.TLSConfig = http3.ConfigureTLSConfig(pSelf.options.http3.TLS)
+	http3Server.IdleTimeout = pSelf.options.idleTimeout
+	if pSelf.options.headerLimits != nil {
+		http3Server.MaxHeaderBytes = pSelf.options.headerLimits.MaxBytes
+	}
+	if udpAddr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		// Alt-Svc 에 알릴 port.
+		http3Server.Port = udpAddr.Port
+	}
+	return http3Server
+}
+
+func (pSelf *GrpcServer) runHttp3(conn net.PacketConn) {
+	gLogger.Printf("Start HTTP/3 server on %s, %s\n", conn.LocalAddr().Network(), conn.LocalAddr())
+
+	serve := pSelf.http3Server.Serve
+	if pSelf.webTransport != nil {
+		gLogger.Printf("Accept gRPC over WebTransport on %s\n", pSelf.webTransport.options.Path)
+		serve = pSelf.webTransport.server.Serve
+	}
+	if err := serve(conn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		gLogger.Fatalf("failed to serve HTTP/3 server: %v", err)
+	}
+}
+
+// altSvcHandler 는 응답에 HTTP/3 를 알리는 Alt-Svc header 를 추가한다.
+func (pSelf *GrpcServer) altSvcHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			_ = pSelf.http3Server.SetQUICHeaders(w.Header())
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
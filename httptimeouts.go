@@ -0,0 +1,88 @@
+package server
+
+import (
+	"golang.org/x/net/http2"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultHttpReadHeaderTimeout    = 10 * time.Second
+	defaultHttpReadTimeout          = time.Minute
+	defaultHttpIdleTimeout          = 2 * time.Minute
+	defaultHttpMaxConcurrentStreams = 250
+)
+
+// HttpTimeoutsOptions 는 gRPC Gateway (Http Proxy) 의 timeout 과 연결별 제한이다.
+// 0 인 항목은 기본값을 사용하고, 음수인 항목은 제한하지 않는다. WithHttpTimeouts 를 사용하지 않아도 기본값을 적용한다.
+type HttpTimeoutsOptions struct {
+	// ReadHeaderTimeout 은 연결 후 (keep-alive 이면 이전 응답 후) 요청 header 를 모두 읽기까지의 시간이다. (기본값: 10s)
+	// header 를 천천히 보내 연결을 점유하는 공격 (slowloris) 을 막는다.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout 은 요청 header 와 body 를 모두 읽기까지의 시간이다. (기본값: 1m)
+	// 큰 파일을 올리는 경로가 있으면 늘리거나 음수로 설정한다.
+	ReadTimeout time.Duration
+	// WriteTimeout 은 요청 header 를 읽은 뒤 응답을 모두 쓰기까지의 시간이다. (기본값: 제한하지 않음)
+	// server streaming 응답도 이 시간 안에 끝나야 한다.
+	WriteTimeout time.Duration
+	// IdleTimeout 은 keep-alive 연결이 다음 요청을 기다리는 시간이다. (기본값: WithIdleTimeout 또는 2m)
+	IdleTimeout time.Duration
+	// MaxConcurrentStreams 는 HTTP/2 연결 하나에서 동시에 처리하는 요청 수이다. (기본값: 250)
+	MaxConcurrentStreams int
+}
+
+// WithHttpTimeouts 는 gRPC Gateway 의 timeout 과 연결별 제한의 기본값을 바꾼다.
+// 연결 수는 WithMaxConnections, WithMaxConnectionsPerIP 로, header 크기는 WithHeaderLimits 로 제한한다.
+func WithHttpTimeouts(httpTimeoutsOptions HttpTimeoutsOptions) Option {
+	return func(options *serverOptions) {
+		options.httpTimeouts = httpTimeoutsOptions
+	}
+}
+
+// httpTimeout 은 value 가 0 이면 defaultValue, 음수이면 0 (제한 없음) 이다.
+func httpTimeout(value, defaultValue time.Duration) time.Duration {
+	switch {
+	case value == 0:
+		return defaultValue
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+// http2Server 는 HTTP/2 연결의 제한이다. h2c 와 TLS 의 HTTP/2 가 같이 사용한다.
+func (pSelf *HttpTimeoutsOptions) http2Server(idleTimeout time.Duration) *http2.Server {
+	maxConcurrentStreams := pSelf.MaxConcurrentStreams
+	switch {
+	case maxConcurrentStreams == 0:
+		maxConcurrentStreams = defaultHttpMaxConcurrentStreams
+	case maxConcurrentStreams < 0:
+		// http2 는 0 이면 기본값 (250) 을 사용하므로 최대값으로 설정.
+		maxConcurrentStreams = 1<<31 - 1
+	}
+	return &http2.Server{
+		IdleTimeout:          pSelf.idleTimeout(idleTimeout),
+		MaxConcurrentStreams: uint32(maxConcurrentStreams),
+	}
+}
+
+// idleTimeout 은 IdleTimeout 이 없으면 WithIdleTimeout 의 값, 둘 다 없으면 기본값이다.
+func (pSelf *HttpTimeoutsOptions) idleTimeout(idleTimeout time.Duration) time.Duration {
+	if pSelf.IdleTimeout == 0 && idleTimeout > 0 {
+		return idleTimeout
+	}
+	return httpTimeout(pSelf.IdleTimeout, defaultHttpIdleTimeout)
+}
+
+// apply 는 httpServer 에 timeout 을 설정하고, TLS 이면 HTTP/2 연결의 제한을 설정한다.
+func (pSelf *HttpTimeoutsOptions) apply(httpServer *http.Server, idleTimeout time.Duration) error {
+	httpServer.ReadHeaderTimeout = httpTimeout(pSelf.ReadHeaderTimeout, defaultHttpReadHeaderTimeout)
+	httpServer.ReadTimeout = httpTimeout(pSelf.ReadTimeout, defaultHttpReadTimeout)
+	httpServer.WriteTimeout = httpTimeout(pSelf.WriteTimeout, 0)
+	httpServer.IdleTimeout = pSelf.idleTimeout(idleTimeout)
+	if httpServer.TLSConfig == nil {
+		// TLSConfig 가 nil 이면 ConfigureServer 가 만들어 TLS 로 실행하게 되므로, 평문은 h2cHandler 에서 설정.
+		return nil
+	}
+	return http2.ConfigureServer(httpServer, pSelf.http2Server(idleTimeout))
+}
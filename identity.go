@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type tlsIdentityContextKey struct{}
+
+// TLSIdentity 는 mTLS 로 검증한 client 인증서의 subject 와 SAN 이다.
+type TLSIdentity struct {
+	// Subject 는 인증서 subject 의 DN 이다. (e.g. "CN=billing,O=example")
+	Subject    string
+	CommonName string
+	DNSNames   []string
+	Emails     []string
+	IPs        []net.IP
+	URIs       []*url.URL
+	// Certificate 는 client 인증서이다.
+	Certificate *x509.Certificate
+}
+
+// Names 는 Subject 를 제외한 CommonName 과 모든 SAN 이다. URI 와 IP 는 문자열로 바꾼다.
+func (pSelf *TLSIdentity) Names() []string {
+	var names []string
+	if len(pSelf.CommonName) > 0 {
+		names = append(names, pSelf.CommonName)
+	}
+	names = append(names, pSelf.DNSNames...)
+	names = append(names, pSelf.Emails...)
+	for _, ip := range pSelf.IPs {
+		names = append(names, ip.String())
+	}
+	for _, uri := range pSelf.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// TLSIdentityOptions 는 client 인증서로 요청을 인가하는 설정이다.
+type TLSIdentityOptions struct {
+	// Require 가 true 이면 검증한 client 인증서가 없는 요청을 Unauthenticated 로 거부한다.
+	Require bool
+	// Methods 는 Method 별로 호출할 수 있는 identity 이다. key 는 "/package.Service/Method" 또는 Service 의 모든 Method 인 "/package.Service/*" 이다.
+	// identity 가 Subject, CommonName 또는 SAN 중 하나와 일치하면 허용하며, 허용하지 않는 요청은 PermissionDenied 로 거부한다.
+	// "dns:", "uri:", "email:", "ip:", "cn:", "subject:" 를 붙이면 해당 항목과만 비교한다.
+	// "*" 는 "." 과 "/" 를 제외한 문자열, "**" 는 모든 문자열과 일치한다.
+	// (e.g. "dns:*.billing.svc.cluster.local", "uri:spiffe://example.org/ns/prod/sa/*", "spiffe://example.org/**")
+	// Methods 에 없는 Method 는 인증서와 관계 없이 허용한다. (Require 참고)
+	// 설정 파일을 읽는 library (e.g. viper, koanf) 가 key 를 소문자로 바꾸므로, 대소문자가 일치하는 key 가 없으면 대소문자를 구분하지 않고 찾는다.
+	Methods map[string][]string
+
+	patterns map[string][]identityPattern
+	// foldedPatterns 는 소문자로 바꾼 key 의 patterns 이다.
+	foldedPatterns map[string][]identityPattern
+}
+
+// identityPattern 은 허용 identity 하나이다.
+type identityPattern struct {
+	// field 는 비교할 항목이다. 비어 있으면 Subject, CommonName 과 모든 SAN 과 비교한다.
+	field   string
+	exact   string
+	pattern *regexp.Regexp
+}
+
+var identityFields = []string{"subject", "cn", "dns", "email", "ip", "uri"}
+
+func compileIdentityPattern(identity string) identityPattern {
+	var compiled identityPattern
+	if field, value, ok := strings.Cut(identity, ":"); ok && slices.Contains(identityFields, field) {
+		compiled.field, identity = field, value
+	}
+	if !strings.Contains(identity, "*") {
+		compiled.exact = identity
+		return compiled
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, part := range strings.Split(identity, "**") {
+		if i > 0 {
+			expr.WriteString(".*")
+		}
+		for j, literal := range strings.Split(part, "*") {
+			if j > 0 {
+				expr.WriteString("[^./]*")
+			}
+			expr.WriteString(regexp.QuoteMeta(literal))
+		}
+	}
+	expr.WriteString("$")
+	compiled.pattern = regexp.MustCompile(expr.String())
+	return compiled
+}
+
+// names 는 field 와 비교할 identity 의 값이다.
+func (pSelf identityPattern) names(identity *TLSIdentity) []string {
+	switch pSelf.field {
+	case "subject":
+		return []string{identity.Subject}
+	case "cn":
+		return []string{identity.CommonName}
+	case "dns":
+		return identity.DNSNames
+	case "email":
+		return identity.Emails
+	case "ip":
+		var names []string
+		for _, ip := range identity.IPs {
+			names = append(names, ip.String())
+		}
+		return names
+	case "uri":
+		var names []string
+		for _, uri := range identity.URIs {
+			names = append(names, uri.String())
+		}
+		return names
+	}
+	return append([]string{identity.Subject}, identity.Names()...)
+}
+
+func (pSelf identityPattern) match(identity *TLSIdentity) bool {
+	for _, name := range pSelf.names(identity) {
+		if pSelf.pattern != nil && pSelf.pattern.MatchString(name) {
+			return true
+		}
+		if pSelf.pattern == nil && len(name) > 0 && name == pSelf.exact {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTLSIdentity 는 mTLS 로 검증한 client 인증서를 TLSIdentity 로 context 에 기록하고, Method 별 허용 identity 를 확인한다.
+// Handler 에서는 TLSIdentityFromContext 로 확인한다. health check, reflection 같은 기본 Service 에는 적용하지 않는다.
+// gRPC Gateway 로 받은 요청은 client 인증서 없이 전달되므로 Require 나 Methods 를 적용하면 거부된다.
+func WithTLSIdentity(identityOptions TLSIdentityOptions) Option {
+	return func(options *serverOptions) {
+		identityOptions.patterns = map[string][]identityPattern{}
+		identityOptions.foldedPatterns = map[string][]identityPattern{}
+		for method, identities := range identityOptions.Methods {
+			for _, identity := range identities {
+				pattern := compileIdentityPattern(identity)
+				identityOptions.patterns[method] = append(identityOptions.patterns[method], pattern)
+				identityOptions.foldedPatterns[strings.ToLower(method)] = append(identityOptions.foldedPatterns[strings.ToLower(method)], pattern)
+			}
+		}
+		options.tlsIdentity = &identityOptions
+	}
+}
+
+// TLSIdentityFromContext 는 WithTLSIdentity 가 context 에 기록한 client 의 identity 이다.
+func TLSIdentityFromContext(ctx context.Context) (*TLSIdentity, bool) {
+	identity, ok := ctx.Value(tlsIdentityContextKey{}).(*TLSIdentity)
+	return identity, ok
+}
+
+// peerTLSIdentity 는 연결의 검증한 client 인증서에서 identity 를 읽는다.
+func peerTLSIdentity(ctx context.Context) (*TLSIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	return newTLSIdentity(tlsInfo.State.VerifiedChains[0][0]), true
+}
+
+func newTLSIdentity(certificate *x509.Certificate) *TLSIdentity {
+	return &TLSIdentity{
+		Subject:     certificate.Subject.String(),
+		CommonName:  certificate.Subject.CommonName,
+		DNSNames:    certificate.DNSNames,
+		Emails:      certificate.EmailAddresses,
+		IPs:         certificate.IPAddresses,
+		URIs:        certificate.URIs,
+		Certificate: certificate,
+	}
+}
+
+// allowedIdentities 는 fullMethod 를 호출할 수 있는 identity 이다. Method 의 설정이 Service 의 설정보다 우선한다.
+func (pSelf *TLSIdentityOptions) allowedIdentities(fullMethod string) ([]identityPattern, bool) {
+	if allowed, ok := pSelf.methodPatterns(fullMethod); ok {
+		return allowed, true
+	}
+	if i := strings.LastIndex(fullMethod, "/"); i > 0 {
+		return pSelf.methodPatterns(fullMethod[:i] + "/*")
+	}
+	return nil, false
+}
+
+// methodPatterns 는 Methods 의 key 가 method 인 identity 이다. 대소문자가 일치하는 key 를 먼저 찾는다.
+func (pSelf *TLSIdentityOptions) methodPatterns(method string) ([]identityPattern, bool) {
+	if allowed, ok := pSelf.patterns[method]; ok {
+		return allowed, true
+	}
+	allowed, ok := pSelf.foldedPatterns[strings.ToLower(method)]
+	return allowed, ok
+}
+
+// authorize 는 client 의 identity 를 context 에 기록하고 fullMethod 를 호출할 수 있는지 확인한다.
+func (pSelf *TLSIdentityOptions) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	identity, ok := peerTLSIdentity(ctx)
+	if ok {
+		ctx = context.WithValue(ctx, tlsIdentityContextKey{}, identity)
+	}
+
+	allowed, restricted := pSelf.allowedIdentities(fullMethod)
+	if !ok {
+		if pSelf.Require || restricted {
+			addLabeledMetric("tls_identity_rejected", "unauthenticated", 1)
+			return nil, status.Error(codes.Unauthenticated, "client certificate is required")
+		}
+		return ctx, nil
+	}
+	if !restricted {
+		return ctx, nil
+	}
+	for _, pattern := range allowed {
+		if pattern.match(identity) {
+			return ctx, nil
+		}
+	}
+	addLabeledMetric("tls_identity_rejected", "permission_denied", 1)
+	return nil, status.Errorf(codes.PermissionDenied, "%s is not allowed to call %s", identity.Subject, fullMethod)
+}
+
+func (pSelf *TLSIdentityOptions) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if builtinMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := pSelf.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (pSelf *TLSIdentityOptions) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if builtinMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := pSelf.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+}
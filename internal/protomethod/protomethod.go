@@ -0,0 +1,38 @@
+// Package protomethod 는 message broker bridge 가 route 의 RPC 를 protobuf registry 에서 찾을 때 사용한다.
+package protomethod
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"strings"
+)
+
+// FindUnary 는 "package.Service/Method" 의 unary Method 이다.
+func FindUnary(files *protoregistry.Files, rpc string) (protoreflect.MethodDescriptor, error) {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(rpc, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("rpc must be package.Service/Method: %s", rpc)
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", serviceName, err)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("method %s not found", rpc)
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, fmt.Errorf("streaming method %s is not supported", rpc)
+	}
+	return method, nil
+}
+
+// FullMethod 는 gRPC 호출에 사용하는 "/package.Service/Method" 이다.
+func FullMethod(method protoreflect.MethodDescriptor) string {
+	return "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// redacted 는 노출하지 않는 설정 값 대신 응답하는 값이다.
+const redacted = "[REDACTED]"
+
+// EffectiveConfig 는 실행 중인 Server 가 사용하는 설정이다.
+// 설정 파일, profile, 환경 변수, 기본값, Option 을 모두 적용하고 다시 읽은 설정까지 반영한 값이며,
+// port 0 으로 생성한 경우 OS 가 할당한 port 를 사용한다.
+func (pSelf *GrpcServer) EffectiveConfig() *Config {
+	options := pSelf.options
+	config := &Config{}
+	pSelf.configMutex.Lock()
+	if options.config != nil {
+		*config = *options.config
+	}
+	pSelf.configMutex.Unlock()
+
+	config.Network = pSelf.network
+	config.Address = pSelf.address
+	config.Port = pSelf.port
+	if pSelf.hasHttpProxy() && pSelf.httpProxyPort >= 0 {
+		config.HttpPort = pSelf.httpProxyPort
+	}
+
+	config.Limits.MaxConnections = 0
+	if options.connectionLimiter != nil {
+		config.Limits.MaxConnections = options.connectionLimiter.limit()
+	}
+	config.Limits.MaxConnectionsPerIP, config.Limits.TrustedProxies = 0, nil
+	if options.ipConnectionLimiter != nil {
+		config.Limits.MaxConnectionsPerIP, config.Limits.TrustedProxies = options.ipConnectionLimiter.limits()
+	}
+	config.Limits.IdleTimeout = Duration(options.idleTimeout)
+	config.Limits.MaxConnectionAge = Duration(options.maxConnectionAge)
+	config.Limits.MaxConnectionAgeGrace = Duration(options.maxConnectionAgeGrace)
+
+	config.Middleware = MiddlewareConfig{
+		Recovery:   options.recovery,
+		Health:     options.healthCheck,
+		Reflection: options.reflection,
+	}
+	config.PIDFile = options.pidFile
+	return config
+}
+
+// ConfigHandler 는 EffectiveConfig 를 JSON 으로 응답하는 관리용 http.Handler 이다.
+// authorize 가 true 를 반환한 요청에만 응답하며, nil 이면 모든 요청을 거부한다.
+// redact tag 가 있는 항목은 값 대신 [REDACTED] 로 응답한다.
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/config", grpcServer.ConfigHandler(server.BearerToken(os.Getenv("ADMIN_TOKEN"))))
+//	grpcServer.AddListener("admin", "tcp", "127.0.0.1:9090", server.ListenerOptions{Handler: mux})
+func (pSelf *GrpcServer) ConfigHandler(authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			pSelf.adminAuthFailure(r)
+			unauthorized(w)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		config := redact(reflect.ValueOf(pSelf.EffectiveConfig()).Elem(), false).Interface().(Config)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(&config)
+	})
+}
+
+// BearerToken 은 Authorization: Bearer token header 가 token 과 같은 요청을 허용한다. token 이 비어 있으면 모두 거부한다.
+func BearerToken(token string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && len(token) > 0 && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+	}
+}
+
+// redact 는 value 에서 `redact:"true"` tag 가 있는 항목의 문자열을 가린 복사본이다.
+// tag 가 있는 항목이 map, slice, pointer 등이면 그 안의 모든 문자열을 가린다.
+// EffectiveConfig 는 map, slice 를 Server 의 설정과 공유하므로 value 는 바꾸지 않고 새로 만든다.
+func redact(value reflect.Value, sensitive bool) reflect.Value {
+	switch value.Kind() {
+	case reflect.String:
+		if !sensitive || value.Len() == 0 {
+			return value
+		}
+		masked := reflect.New(value.Type()).Elem()
+		masked.SetString(redacted)
+		return masked
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.IsExported() {
+				copied.Field(i).Set(redact(value.Field(i), sensitive || field.Tag.Get("redact") == "true"))
+			}
+		}
+		return copied
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(redact(value.Elem(), sensitive))
+		return copied
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(redact(value.Elem(), sensitive))
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(redact(value.Index(i), sensitive))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(redact(value.Index(i), sensitive))
+		}
+		return copied
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), redact(iter.Value(), sensitive))
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// limit 은 현재 연결 수 제한이다.
+func (pSelf *connectionLimiter) limit() int {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	return pSelf.maxConnections
+}
+
+// limits 는 현재 IP 별 연결 수 제한과 allowlist 이다.
+func (pSelf *ipConnectionLimiter) limits() (int, []string) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	return pSelf.maxConnections, slices.Clone(pSelf.allowlist)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net"
+)
+
+// WithTCPKeepAlive 는 수락한 TCP 연결의 keepalive 설정이다.
+// Enable 이 false 이면 keepalive 를 사용하지 않는다. 0 인 항목은 OS 기본값을 사용한다.
+func WithTCPKeepAlive(keepAliveConfig net.KeepAliveConfig) Option {
+	return func(options *serverOptions) {
+		options.tcpKeepAlive = &keepAliveConfig
+	}
+}
+
+// WithListenBacklog 는 아직 수락하지 않은 연결을 대기시킬 수 있는 개수 (listen backlog) 이다.
+// Linux 에서만 지원하며 net.core.somaxconn 보다 크게 설정할 수 없다.
+func WithListenBacklog(backlog int) Option {
+	return func(options *serverOptions) {
+		options.listenBacklog = backlog
+	}
+}
+
+// keepAliveListener 는 수락한 TCP 연결에 keepalive 를 설정한다.
+// systemd 나 이전 프로세스로부터 넘겨받은 Listener 에도 같은 설정을 적용하기 위해 Accept 단계에서 설정한다.
+type keepAliveListener struct {
+	net.Listener
+	keepAliveConfig net.KeepAliveConfig
+}
+
+func (pSelf *keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := pSelf.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetKeepAliveConfig(pSelf.keepAliveConfig); err != nil {
+			gLogger.Printf("Failed to set keepalive of %s: %v\n", conn.RemoteAddr(), err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// KeylessOptions 는 개인 키 없이 외부 module 에 서명을 맡기는 TLS 인증서 설정이다.
+type KeylessOptions struct {
+	// Certificate 는 인증서 chain (PEM) 이다. 첫 인증서가 Signer 의 key 와 짝이어야 한다.
+	Certificate []byte
+	// Signer 는 개인 키를 가진 외부 module 이다. (e.g. Cloud KMS, PKCS#11 HSM 의 crypto.Signer 구현)
+	Signer crypto.Signer
+	// SignatureSchemes 는 Signer 가 지원하는 서명 방식이다. 비어 있으면 key 종류의 모든 방식을 사용한다.
+	// (e.g. PSS 를 지원하지 않는 HSM 의 RSA key 는 tls.PKCS1WithSHA256)
+	SignatureSchemes []tls.SignatureScheme
+}
+
+// KeylessCertificate 는 handshake 의 서명을 Signer 에 맡기는 TLS 인증서이다. 개인 키는 파일로 저장하지 않는다.
+// 인증서와 Signer 의 공개 키가 다르면 오류를 반환한다. 서명 결과와 시간은 keyless_signatures, keyless_sign_ms 지표로 확인한다.
+func KeylessCertificate(keylessOptions KeylessOptions) (tls.Certificate, error) {
+	if keylessOptions.Signer == nil {
+		return tls.Certificate{}, errors.New("keyless signer is required")
+	}
+	certificates, err := parseCertificates(keylessOptions.Certificate)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if len(certificates) == 0 {
+		return tls.Certificate{}, errors.New("no certificate found for keyless signer")
+	}
+
+	leaf := certificates[0]
+	publicKey, ok := keylessOptions.Signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(leaf.PublicKey) {
+		return tls.Certificate{}, fmt.Errorf("keyless signer public key does not match certificate %s", leaf.Subject)
+	}
+
+	certificate := tls.Certificate{
+		PrivateKey:                   &keylessSigner{Signer: keylessOptions.Signer},
+		Leaf:                         leaf,
+		SupportedSignatureAlgorithms: keylessOptions.SignatureSchemes,
+	}
+	for _, c := range certificates {
+		certificate.Certificate = append(certificate.Certificate, c.Raw)
+	}
+	return certificate, nil
+}
+
+// TLSConfigFromSigner 는 KeylessCertificate 를 사용하는 TLS 설정이다.
+//
+//	signer, err := kmsClient.Signer(ctx, "projects/myapp/locations/global/keyRings/tls/cryptoKeys/server")  // KMS SDK 의 crypto.Signer
+//	...
+//	tlsConfig, err := server.TLSConfigFromSigner(server.KeylessOptions{Certificate: certPEM, Signer: signer})
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, server.WithTLS(tlsConfig))
+func TLSConfigFromSigner(keylessOptions KeylessOptions) (*tls.Config, error) {
+	certificate, err := KeylessCertificate(keylessOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{certificate}}, nil
+}
+
+// keylessSigner 는 Signer 의 서명 결과와 시간을 기록한다.
+// crypto.Decrypter 를 구현하지 않으므로 RSA key 교환 cipher suite 는 사용하지 않는다.
+type keylessSigner struct {
+	crypto.Signer
+}
+
+func (pSelf *keylessSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	start := time.Now()
+	signature, err := pSelf.Signer.Sign(rand, digest, opts)
+	addMetric("keyless_sign_ms", time.Since(start).Milliseconds())
+	if err != nil {
+		addLabeledMetric("keyless_signatures", "failure", 1)
+		gLogger.Printf("Failed to sign TLS handshake with keyless signer: %v\n", err)
+		return nil, err
+	}
+	addLabeledMetric("keyless_signatures", "success", 1)
+	return signature, nil
+}
+
+// parseCertificates 는 PEM 의 모든 인증서를 읽는다.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return certificates, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
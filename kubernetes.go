@@ -0,0 +1,87 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultKubernetesWatchInterval = 10 * time.Second
+	// kubernetesDataDir 는 kubelet 이 ConfigMap, Secret volume 을 바꿀 때 다른 directory 로 교체하는 symlink 이다.
+	kubernetesDataDir = "..data"
+)
+
+// KubernetesWatchOptions 는 mount 된 ConfigMap, Secret 을 감시하는 방법이다.
+type KubernetesWatchOptions struct {
+	// Dirs 는 ConfigMap, Secret 을 mount 한 directory 이다. (e.g. /etc/server, /etc/server/tls)
+	Dirs []string
+	// Interval 은 변경을 확인하는 주기이다. (기본값: 10초)
+	Interval time.Duration
+}
+
+// WithKubernetesWatch 는 mount 된 ConfigMap, Secret 이 바뀌면 설정을 다시 읽는다. (Reload 참고)
+// kubelet 은 파일을 직접 고치지 않고 ..data symlink 를 새 directory 로 바꾸므로, symlink 가 가리키는 directory 를 비교한다.
+// 인증서 Secret 의 directory 를 함께 감시하면 설정 파일이 바뀌지 않아도 인증서를 다시 읽는다.
+// NewFromConfig, NewWithConfig 로 생성한 Server 에서 사용한다.
+func WithKubernetesWatch(kubernetesWatchOptions KubernetesWatchOptions) Option {
+	return func(options *serverOptions) {
+		if kubernetesWatchOptions.Interval <= 0 {
+			kubernetesWatchOptions.Interval = defaultKubernetesWatchInterval
+		}
+		options.kubernetesWatch = &kubernetesWatchOptions
+	}
+}
+
+// watchKubernetes 는 mount 된 directory 가 바뀌면 설정을 다시 읽는다.
+func (pSelf *GrpcServer) watchKubernetes() {
+	watchOptions := pSelf.options.kubernetesWatch
+	versions := make([]string, len(watchOptions.Dirs))
+	for i, dir := range watchOptions.Dirs {
+		versions[i] = mountVersion(dir)
+	}
+
+	ticker := time.NewTicker(watchOptions.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var changed []string
+		for i, dir := range watchOptions.Dirs {
+			version := mountVersion(dir)
+			// 교체 중에는 ..data 가 잠시 없을 수 있으므로 다음 확인까지 기다린다.
+			if len(version) == 0 || version == versions[i] {
+				continue
+			}
+			versions[i] = version
+			changed = append(changed, dir)
+		}
+		if len(changed) > 0 {
+			gLogger.Printf("Kubernetes volume changed: %s\n", strings.Join(changed, ", "))
+			pSelf.Reload()
+		}
+	}
+}
+
+// mountVersion 은 ConfigMap, Secret volume 의 현재 버전이다.
+// ..data symlink 가 없는 directory (e.g. subPath mount, 일반 directory) 는 파일의 가장 최근 수정 시각을 사용한다.
+func mountVersion(dir string) string {
+	if target, err := os.Readlink(filepath.Join(dir, kubernetesDataDir)); err == nil {
+		return target
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var modified time.Time
+	for _, entry := range entries {
+		fileInfo, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil || fileInfo.IsDir() {
+			continue
+		}
+		if fileInfo.ModTime().After(modified) {
+			modified = fileInfo.ModTime()
+		}
+	}
+	return modified.String()
+}
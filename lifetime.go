@@ -0,0 +1,37 @@
+package server
+
+import (
+	"google.golang.org/grpc/keepalive"
+	"time"
+)
+
+// WithIdleTimeout 은 요청 없이 idle 상태로 유지된 연결을 timeout 후에 정리한다.
+// gRPC Server 에는 keepalive MaxConnectionIdle 로 (GOAWAY 전송), Http Proxy Server 에는 IdleTimeout 으로 적용한다.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(options *serverOptions) {
+		options.idleTimeout = timeout
+	}
+}
+
+// WithMaxConnectionAge 는 연결을 maxAge 이상 유지하지 않도록 GOAWAY 를 보내 client 가 다시 연결하게 한다.
+// L4 load balancer 뒤에서 scale-out 한 뒤에도 연결이 새 instance 로 분산된다.
+// 처리 중인 RPC 는 grace 동안 기다린 뒤 연결을 강제로 닫는다. (grace 가 0 이면 무한정 기다린다.)
+func WithMaxConnectionAge(maxAge, grace time.Duration) Option {
+	return func(options *serverOptions) {
+		options.maxConnectionAge = maxAge
+		options.maxConnectionAgeGrace = grace
+	}
+}
+
+// keepaliveParams 는 연결 수명 설정으로 gRPC Server 의 keepalive 설정을 만든다.
+func (pSelf *serverOptions) keepaliveParams() (keepalive.ServerParameters, bool) {
+	var params keepalive.ServerParameters
+	if pSelf.idleTimeout > 0 {
+		params.MaxConnectionIdle = pSelf.idleTimeout
+	}
+	if pSelf.maxConnectionAge > 0 {
+		params.MaxConnectionAge = pSelf.maxConnectionAge
+		params.MaxConnectionAgeGrace = pSelf.maxConnectionAgeGrace
+	}
+	return params, params != keepalive.ServerParameters{}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ListenerConfig 는 gRPC Server 가 기본 Listener 외에 추가로 listen 할 주소이다.
+type ListenerConfig struct {
+	Network string
+	Address string
+	Port    int
+	// TLS 가 nil 이면 평문으로 연결을 수락한다.
+	TLS *tls.Config
+}
+
+// WithListener 는 같은 gRPC Service 를 제공할 Listener 를 추가한다. (e.g. tcp :8443 + unix /run/app.sock)
+func WithListener(listenerConfig ListenerConfig) Option {
+	return func(options *serverOptions) {
+		options.additionalListeners = append(options.additionalListeners, listenerConfig)
+	}
+}
+
+// WithReusePort 는 SO_REUSEPORT 로 Listener 를 bind 하여 여러 프로세스가 같은 port 를 공유할 수 있도록 한다.
+// Linux 에서는 커널이 연결을 각 프로세스에 분산한다.
+func WithReusePort() Option {
+	return func(options *serverOptions) {
+		options.reusePort = true
+	}
+}
+
+// joinAddress 는 network 에 맞게 address 와 port 를 합친다.
+// IPv6 주소는 "[::1]:8080" 과 같이 대괄호로 감싸고, unix socket 은 port 를 사용하지 않는다.
+func joinAddress(network, address string, port int) string {
+	if strings.EqualFold("unix", network) {
+		return address
+	}
+	return net.JoinHostPort(address, strconv.Itoa(port))
+}
+
+// boundPort 는 Listener 가 실제로 bind 한 port 이다. port 0 으로 listen 한 경우 OS 가 할당한 port 를 반환한다.
+func boundPort(addr net.Addr, port int) int {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.Port
+	case *net.UDPAddr:
+		return a.Port
+	}
+	if p, ok := vsockPort(addr); ok {
+		return p
+	}
+	return port
+}
+
+// checkAddressFamily 는 tcp4, tcp6 network 에 다른 주소 체계의 IP 가 지정되었는지 확인한다.
+// tcp 는 IPv4, IPv6 를 모두 사용할 수 있으며, 주소가 비어 있거나 "::" 이면 dual-stack 으로 listen 한다.
+func checkAddressFamily(network, address string) error {
+	ip, err := netip.ParseAddr(address)
+	if err != nil {
+		// Hostname 은 resolve 할 때 network 에 맞는 주소를 사용.
+		return nil
+	}
+
+	switch strings.ToLower(network) {
+	case "tcp4":
+		if !ip.Is4() && !ip.Is4In6() {
+			return fmt.Errorf("tcp4 network requires an IPv4 address: %s", address)
+		}
+	case "tcp6":
+		if !ip.Is6() || ip.Is4In6() {
+			return fmt.Errorf("tcp6 network requires an IPv6 address: %s", address)
+		}
+	}
+	return nil
+}
+
+// listen 은 network 종류에 맞는 Listener 를 생성한다.
+// 이전 프로세스로부터 넘겨받은 Listener 가 있으면 새로 bind 하지 않고 사용한다.
+func listen(network, address string, port int, options *serverOptions) (net.Listener, error) {
+	key := listenerKey(network, address, port)
+	if listener := options.upgrader.takeInherited(key); listener != nil {
+		options.upgrader.track(key, listener)
+		return listener, nil
+	}
+
+	var listener net.Listener
+	var err error
+	switch strings.ToLower(network) {
+	case "vsock":
+		listener, err = listenVsock(address, port)
+	case "unix":
+		if err := cleanupStaleUnixSocket(address); err != nil {
+			return nil, err
+		}
+		fallthrough
+	default:
+		listenConfig := net.ListenConfig{
+			Control: options.listenControl(network),
+		}
+		listener, err = listenConfig.Listen(context.Background(), strings.ToLower(network), joinAddress(network, address, port))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if options.listenBacklog > 0 {
+		if err := setListenBacklog(listener, options.listenBacklog); err != nil {
+			gLogger.Printf("Failed to set listen backlog of %s: %v\n", listener.Addr(), err)
+		}
+	}
+
+	options.upgrader.track(key, listener)
+	return listener, nil
+}
+
+// listenControl 은 Listener 를 bind 하기 전에 적용할 socket 설정을 반환한다.
+func (pSelf *serverOptions) listenControl(network string) func(network, address string, c syscall.RawConn) error {
+	var controls []func(network, address string, c syscall.RawConn) error
+	if pSelf.reusePort && !strings.EqualFold("unix", network) {
+		controls = append(controls, reusePortControl)
+	}
+	if pSelf.socketOptions != nil && !strings.EqualFold("unix", network) {
+		controls = append(controls, pSelf.socketOptions.control)
+	}
+	controls = append(controls, pSelf.listenControls...)
+
+	if len(controls) == 0 {
+		return nil
+	}
+
+	return func(network, address string, c syscall.RawConn) error {
+		for _, control := range controls {
+			if err := control(network, address, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// wrapListener 는 연결을 수락할 때 적용할 설정으로 Listener 를 감싼다.
+func (pSelf *serverOptions) wrapListener(listener net.Listener) (net.Listener, error) {
+	if pSelf.socketOptions != nil && pSelf.socketOptions.NoDelay != nil {
+		listener = &noDelayListener{Listener: listener, noDelay: *pSelf.socketOptions.NoDelay}
+	}
+
+	if pSelf.tcpKeepAlive != nil {
+		listener = &keepAliveListener{Listener: listener, keepAliveConfig: *pSelf.tcpKeepAlive}
+	}
+
+	// 전체 연결 수 제한은 PROXY header 를 읽기 전에 적용.
+	if pSelf.connectionLimiter != nil {
+		listener = &connectionLimitListener{Listener: listener, limiter: pSelf.connectionLimiter}
+	}
+
+	if pSelf.proxyProtocol != nil {
+		proxyProtocolListener, err := newProxyProtocolListener(listener, *pSelf.proxyProtocol)
+		if err != nil {
+			return nil, err
+		}
+		listener = proxyProtocolListener
+	}
+
+	// client IP 별 연결 수 제한은 PROXY header 의 client 주소로 적용.
+	if pSelf.ipConnectionLimiter != nil {
+		if err := pSelf.ipConnectionLimiter.init(); err != nil {
+			return nil, err
+		}
+		listener = &ipConnectionLimitListener{Listener: listener, limiter: pSelf.ipConnectionLimiter}
+	}
+
+	return listener, nil
+}
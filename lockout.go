@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAuthLockoutMaxFailures = 5
+	defaultAuthLockoutWindow      = 15 * time.Minute
+	defaultAuthLockoutBaseDelay   = time.Second
+	defaultAuthLockoutMaxDelay    = 15 * time.Minute
+)
+
+// AuthLockoutOptions 는 인증 실패가 반복된 client 를 잠그는 설정이다.
+type AuthLockoutOptions struct {
+	// MaxFailures 는 잠그기 전에 허용하는 연속 실패 수이다. (기본값: 5)
+	MaxFailures int
+	// Window 는 실패를 기억하는 시간이다. 마지막 실패 후 Window 동안 실패가 없으면 다시 센다. (기본값: 15m)
+	Window time.Duration
+	// BaseDelay 는 처음 잠그는 시간이다. 이후 실패할 때마다 두 배로 늘린다. (기본값: 1s)
+	BaseDelay time.Duration
+	// MaxDelay 는 잠그는 최대 시간이다. (기본값: 15m)
+	MaxDelay time.Duration
+	// Principal 이 있으면 client IP 와 함께 요청이 주장하는 principal 도 잠근다. (e.g. metadata 의 username, API key ID)
+	// 인증하기 전에 호출하므로 검증하지 않은 값이며, 여러 IP 에서 한 계정을 대입하는 공격을 막는다.
+	Principal func(ctx context.Context) (string, bool)
+	// Codes 는 인증 실패로 셀 gRPC 오류 code 이다. (기본값: Unauthenticated)
+	Codes []codes.Code
+}
+
+// AuthLockout 은 잠긴 client IP (ip:) 또는 principal (principal:) 이다.
+type AuthLockout struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// WithAuthLockout 은 client IP 와 principal 별로 인증 실패를 세고, MaxFailures 를 넘으면 지수적으로 늘어나는 시간 동안 요청을 ResourceExhausted 로 거부한다.
+// 서명, client 인증서 확인보다 먼저 실행하므로 잠긴 client 의 요청은 인증하지 않는다. 성공한 요청은 principal 의 실패 수를 지운다.
+// gRPC Gateway 를 거친 요청은 Gateway 가 확인한 client 주소로 센다. load balancer 뒤에서는 WithProxyProtocol 로 실제 client 주소를 받는다.
+// 잠금은 GrpcServer.UnlockAuth 나 AuthLockoutHandler 로 풀며, auth_failures, auth_lockouts, auth_locked_rejected 지표로 확인한다.
+func WithAuthLockout(lockoutOptions AuthLockoutOptions) Option {
+	return func(options *serverOptions) {
+		if lockoutOptions.MaxFailures <= 0 {
+			lockoutOptions.MaxFailures = defaultAuthLockoutMaxFailures
+		}
+		if lockoutOptions.Window <= 0 {
+			lockoutOptions.Window = defaultAuthLockoutWindow
+		}
+		if lockoutOptions.BaseDelay <= 0 {
+			lockoutOptions.BaseDelay = defaultAuthLockoutBaseDelay
+		}
+		if lockoutOptions.MaxDelay <= 0 {
+			lockoutOptions.MaxDelay = defaultAuthLockoutMaxDelay
+		}
+		if len(lockoutOptions.Codes) == 0 {
+			lockoutOptions.Codes = []codes.Code{codes.Unauthenticated}
+		}
+		options.authLockout = &authLockout{options: lockoutOptions, states: map[string]*authLockoutState{}}
+	}
+}
+
+// UnlockAuth 는 key (e.g. ip:10.0.0.1, principal:alice) 의 잠금과 실패 수를 지운다. 잠겨 있었으면 true 이다.
+func (pSelf *GrpcServer) UnlockAuth(key string) bool {
+	if pSelf.options.authLockout == nil {
+		return false
+	}
+	return pSelf.options.authLockout.unlock(key)
+}
+
+// AuthLockouts 는 현재 잠긴 client 이다.
+func (pSelf *GrpcServer) AuthLockouts() []AuthLockout {
+	if pSelf.options.authLockout == nil {
+		return nil
+	}
+	return pSelf.options.authLockout.locked(time.Now())
+}
+
+// AuthLockoutHandler 는 잠긴 client 를 JSON 으로 응답하고 (GET), 잠금을 푸는 (DELETE ?key=ip:10.0.0.1) 관리용 http.Handler 이다.
+// authorize 가 true 를 반환한 요청에만 응답하며, nil 이면 모든 요청을 거부한다.
+//
+//	mux.Handle("/auth/lockouts", grpcServer.AuthLockoutHandler(server.BearerToken(os.Getenv("ADMIN_TOKEN"))))
+func (pSelf *GrpcServer) AuthLockoutHandler(authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			pSelf.adminAuthFailure(r)
+			unauthorized(w)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			lockouts := pSelf.AuthLockouts()
+			if lockouts == nil {
+				lockouts = []AuthLockout{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			_ = encoder.Encode(lockouts)
+		case http.MethodDelete:
+			key := r.URL.Query().Get("key")
+			if len(key) == 0 {
+				http.Error(w, "key is required", http.StatusBadRequest)
+				return
+			}
+			if !pSelf.UnlockAuth(key) {
+				http.Error(w, "not locked: "+key, http.StatusNotFound)
+				return
+			}
+			gLogger.Printf("Unlocked authentication: %s\n", key)
+			pSelf.options.securityEvent(SecurityEvent{
+				Type:       SecurityEventAdminAction,
+				Message:    "unlocked authentication: " + key,
+				Method:     r.URL.Path,
+				Source:     requestHost(r),
+				Extensions: map[string]string{"key": key},
+			})
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// authLockout 은 key 별 인증 실패 수와 잠금이다.
+type authLockout struct {
+	options AuthLockoutOptions
+	// onLockout 은 key 를 잠글 때 호출한다. (보안 event)
+	onLockout func(key string, failures int, lockedUntil time.Time)
+
+	mutex     sync.Mutex
+	states    map[string]*authLockoutState
+	lastSweep time.Time
+}
+
+type authLockoutState struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// keys 는 요청의 client IP 와 principal key 이다.
+// 같은 host 의 Gateway 나 load balancer 를 거친 요청을 모두 잠그지 않도록, client 주소를 알 수 없는 local 요청은 IP 로 잠그지 않는다.
+func (pSelf *authLockout) keys(ctx context.Context) []string {
+	var keys []string
+	if host, ok := clientHost(ctx); ok {
+		keys = append(keys, "ip:"+host)
+	}
+	if pSelf.options.Principal != nil {
+		if principal, ok := pSelf.options.Principal(ctx); ok && len(principal) > 0 {
+			keys = append(keys, "principal:"+principal)
+		}
+	}
+	return keys
+}
+
+// check 는 keys 중 잠긴 것이 있으면 남은 시간과 함께 ResourceExhausted 를 반환한다.
+func (pSelf *authLockout) check(keys []string, now time.Time) error {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	var lockedUntil time.Time
+	for _, key := range keys {
+		if state, ok := pSelf.states[key]; ok && state.lockedUntil.After(lockedUntil) {
+			lockedUntil = state.lockedUntil
+		}
+	}
+	if lockedUntil.After(now) {
+		addMetric("auth_locked_rejected", 1)
+		return status.Errorf(codes.ResourceExhausted, "too many authentication failures, retry after %s", lockedUntil.Sub(now).Round(time.Second))
+	}
+	return nil
+}
+
+// record 는 요청의 결과를 기록한다. 인증 실패이면 실패 수를 늘리고, MaxFailures 를 넘으면 잠근다.
+// 성공이면 principal 의 실패 수만 지운다. 유효한 credential 하나로 다른 계정을 추측하는 IP 의 실패 수를 지우지 않도록, IP 의 실패 수는 Window 가 지나야 지워진다.
+func (pSelf *authLockout) record(keys []string, err error, now time.Time) {
+	if err != nil && !slices.Contains(pSelf.options.Codes, status.Code(err)) {
+		return
+	}
+
+	pSelf.mutex.Lock()
+	if err == nil {
+		for _, key := range keys {
+			if strings.HasPrefix(key, "principal:") {
+				delete(pSelf.states, key)
+			}
+		}
+		pSelf.mutex.Unlock()
+		return
+	}
+
+	var lockouts []AuthLockout
+	defer func() {
+		pSelf.mutex.Unlock()
+		if pSelf.onLockout != nil {
+			for _, lockout := range lockouts {
+				pSelf.onLockout(lockout.Key, lockout.Failures, lockout.LockedUntil)
+			}
+		}
+	}()
+	pSelf.sweep(now)
+	addMetric("auth_failures", 1)
+	for _, key := range keys {
+		state, ok := pSelf.states[key]
+		if !ok || now.Sub(state.lastFailure) > pSelf.options.Window {
+			state = &authLockoutState{}
+			pSelf.states[key] = state
+		}
+		state.failures++
+		state.lastFailure = now
+		if excess := state.failures - pSelf.options.MaxFailures; excess > 0 {
+			delay := pSelf.options.MaxDelay
+			if excess < 32 {
+				delay = min(pSelf.options.BaseDelay<<(excess-1), pSelf.options.MaxDelay)
+			}
+			state.lockedUntil = now.Add(delay)
+			addMetric("auth_lockouts", 1)
+			lockouts = append(lockouts, AuthLockout{Key: key, Failures: state.failures, LockedUntil: state.lockedUntil})
+		}
+	}
+}
+
+// sweep 은 잠기지 않았고 Window 동안 실패가 없던 key 를 지운다.
+func (pSelf *authLockout) sweep(now time.Time) {
+	if now.Sub(pSelf.lastSweep) < time.Minute {
+		return
+	}
+	pSelf.lastSweep = now
+	for key, state := range pSelf.states {
+		if now.After(state.lockedUntil) && now.Sub(state.lastFailure) > pSelf.options.Window {
+			delete(pSelf.states, key)
+		}
+	}
+}
+
+func (pSelf *authLockout) unlock(key string) bool {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	state, ok := pSelf.states[key]
+	delete(pSelf.states, key)
+	return ok && state.lockedUntil.After(time.Now())
+}
+
+func (pSelf *authLockout) locked(now time.Time) []AuthLockout {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	var lockouts []AuthLockout
+	for key, state := range pSelf.states {
+		if state.lockedUntil.After(now) {
+			lockouts = append(lockouts, AuthLockout{Key: key, Failures: state.failures, LockedUntil: state.lockedUntil})
+		}
+	}
+	sort.Slice(lockouts, func(i, j int) bool { return lockouts[i].Key < lockouts[j].Key })
+	return lockouts
+}
+
+func (pSelf *authLockout) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if builtinMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	keys := pSelf.keys(ctx)
+	if err := pSelf.check(keys, time.Now()); err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	pSelf.record(keys, err, time.Now())
+	return resp, err
+}
+
+func (pSelf *authLockout) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if builtinMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	keys := pSelf.keys(ss.Context())
+	if err := pSelf.check(keys, time.Now()); err != nil {
+		return err
+	}
+	err := handler(srv, ss)
+	pSelf.record(keys, err, time.Now())
+	return err
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/berryons/log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Logger 는 Server 가 사용하는 logger 이다. 표준 라이브러리의 *log.Logger 도 구현한다.
+type Logger interface {
+	Printf(format string, v ...any)
+	Println(v ...any)
+	Fatal(v ...any)
+	Fatalf(format string, v ...any)
+}
+
+// gLogger 는 package 전체에서 사용하는 logger 이다. (기본값: berryons/log)
+var gLogger Logger = berryonsLogger{}
+
+// SetLogger 는 Server 가 사용할 logger 를 바꾼다. New 를 호출하기 전에 설정한다.
+// nil 이면 berryons/log 를 사용한다.
+func SetLogger(logger Logger) {
+	if logger == nil {
+		logger = berryonsLogger{}
+	}
+	gLogger = logger
+}
+
+type berryonsLogger struct{}
+
+func (berryonsLogger) Printf(format string, v ...any) {
+	log.Printf(format, v...)
+}
+
+func (berryonsLogger) Println(v ...any) {
+	log.Println(v...)
+}
+
+func (berryonsLogger) Fatal(v ...any) {
+	log.Fatal(v...)
+}
+
+func (berryonsLogger) Fatalf(format string, v ...any) {
+	log.Fatalf(format, v...)
+}
+
+// NewSlogLogger 는 *slog.Logger 로 기록하는 Logger 를 만든다.
+// Printf, Println 은 Info, Fatal, Fatalf 는 Error level 로 기록한 뒤 프로세스를 종료한다.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (pSelf *slogLogger) Printf(format string, v ...any) {
+	pSelf.log(slog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (pSelf *slogLogger) Println(v ...any) {
+	pSelf.log(slog.LevelInfo, fmt.Sprintln(v...))
+}
+
+func (pSelf *slogLogger) Fatal(v ...any) {
+	pSelf.log(slog.LevelError, fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+func (pSelf *slogLogger) Fatalf(format string, v ...any) {
+	pSelf.log(slog.LevelError, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+func (pSelf *slogLogger) log(level slog.Level, msg string) {
+	pSelf.logger.Log(context.Background(), level, strings.TrimSuffix(msg, "\n"))
+}
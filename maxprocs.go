@@ -0,0 +1,53 @@
+package server
+
+import (
+	"math"
+	"os"
+	"runtime"
+)
+
+// MaxProcsOptions 는 container 의 CPU 제한에 맞춰 GOMAXPROCS 를 정하는 설정이다.
+type MaxProcsOptions struct {
+	// Min 은 GOMAXPROCS 의 최솟값이다. (기본값: 1)
+	Min int
+	// RoundUp 이면 CPU 제한이 정수가 아닐 때 올린다. (e.g. 1.5 CPU 이면 2) 기본값은 throttling 을 피하도록 내린다.
+	RoundUp bool
+}
+
+// WithMaxProcs 는 시작할 때 container 의 CPU 제한 (cgroup v1, v2 의 CPU quota) 에 맞춰 GOMAXPROCS 를 설정한다.
+// GOMAXPROCS 가 node 의 CPU 수로 정해지면 제한보다 많은 thread 가 실행되어 CFS throttling 으로 지연이 늘어난다.
+// GOMAXPROCS 환경 변수가 있거나, CPU 제한이 없거나, Linux 가 아니면 바꾸지 않는다.
+func WithMaxProcs(maxProcsOptions MaxProcsOptions) Option {
+	return func(options *serverOptions) {
+		if maxProcsOptions.Min <= 0 {
+			maxProcsOptions.Min = 1
+		}
+		options.maxProcs = &maxProcsOptions
+	}
+}
+
+// apply 는 CPU 제한으로 GOMAXPROCS 를 설정한다.
+func (pSelf *MaxProcsOptions) apply() {
+	if value, ok := os.LookupEnv("GOMAXPROCS"); ok {
+		gLogger.Printf("GOMAXPROCS is set by environment: %s\n", value)
+		return
+	}
+
+	quota, ok, err := cpuQuota()
+	if err != nil {
+		gLogger.Printf("Failed to read CPU quota: %v\n", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	procs := int(math.Floor(quota))
+	if pSelf.RoundUp {
+		procs = int(math.Ceil(quota))
+	}
+	procs = max(procs, pSelf.Min)
+	if previous := runtime.GOMAXPROCS(procs); previous != procs {
+		gLogger.Printf("Set GOMAXPROCS to %d from %d: CPU quota %g\n", procs, previous, quota)
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// cpuQuota 는 process 의 cgroup 에 설정된 CPU 수이다. 제한이 없으면 false 이다.
+// cgroup v1 의 cpu controller 가 있으면 cpu.cfs_quota_us / cpu.cfs_period_us, 없으면 cgroup v2 의 cpu.max 를 읽는다.
+func cpuQuota() (float64, bool, error) {
+	return readCPUQuota("/proc/self/cgroup", "/proc/self/mountinfo")
+}
+
+func readCPUQuota(cgroupFile, mountInfoFile string) (float64, bool, error) {
+	cgroups, err := readCgroups(cgroupFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	mounts, err := readCgroupMounts(mountInfoFile)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if path, ok := cgroups["cpu"]; ok {
+		if mount, ok := mounts["cpu"]; ok {
+			return readCgroupV1Quota(mount.path(path))
+		}
+	}
+	if path, ok := cgroups[""]; ok {
+		if mount, ok := mounts[""]; ok {
+			return readCgroupV2Quota(mount.path(path))
+		}
+	}
+	return 0, false, nil
+}
+
+// readCgroups 는 /proc/self/cgroup 의 controller 별 cgroup 경로이다. cgroup v2 는 "" 이다.
+func readCgroups(cgroupFile string) (map[string]string, error) {
+	file, err := os.Open(cgroupFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cgroups := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && len(fields[1]) == 0 {
+			cgroups[""] = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			cgroups[controller] = fields[2]
+		}
+	}
+	return cgroups, scanner.Err()
+}
+
+// cgroupMount 는 cgroup 이 mount 된 위치이다.
+type cgroupMount struct {
+	root       string
+	mountPoint string
+}
+
+// path 는 cgroup 경로의 파일 system 상의 위치이다.
+func (pSelf cgroupMount) path(cgroupPath string) string {
+	relative, err := filepath.Rel(pSelf.root, cgroupPath)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		// container 의 cgroup namespace 에서는 mount root 가 cgroup 경로와 다를 수 있다.
+		relative = "."
+	}
+	return filepath.Join(pSelf.mountPoint, relative)
+}
+
+// readCgroupMounts 는 /proc/self/mountinfo 의 controller 별 cgroup mount 이다. cgroup v2 는 "" 이다.
+func readCgroupMounts(mountInfoFile string) (map[string]cgroupMount, error) {
+	file, err := os.Open(mountInfoFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mounts := map[string]cgroupMount{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// mount-ID parent-ID major:minor root mount-point options [optional...] - fs-type source super-options
+		fields := strings.Fields(scanner.Text())
+		separator := slices.Index(fields, "-")
+		if separator < 5 || len(fields) < separator+4 {
+			continue
+		}
+		mount := cgroupMount{root: fields[3], mountPoint: fields[4]}
+		switch fields[separator+1] {
+		case "cgroup2":
+			mounts[""] = mount
+		case "cgroup":
+			for _, option := range strings.Split(fields[separator+3], ",") {
+				mounts[option] = mount
+			}
+		}
+	}
+	return mounts, scanner.Err()
+}
+
+// readCgroupV1Quota 는 cpu.cfs_quota_us / cpu.cfs_period_us 이다. quota 가 -1 이면 제한이 없다.
+func readCgroupV1Quota(dir string) (float64, bool, error) {
+	quota, err := readCgroupInt(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil || quota <= 0 {
+		return 0, false, ignoreNotExist(err)
+	}
+	period, err := readCgroupInt(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil || period <= 0 {
+		return 0, false, ignoreNotExist(err)
+	}
+	return float64(quota) / float64(period), true, nil
+}
+
+// readCgroupV2Quota 는 cpu.max 의 "quota period" 이다. quota 가 max 이면 제한이 없다.
+func readCgroupV2Quota(dir string) (float64, bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.max"))
+	if err != nil {
+		return 0, false, ignoreNotExist(err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || fields[0] == "max" {
+		return 0, false, nil
+	}
+	if len(fields) != 2 {
+		return 0, false, fmt.Errorf("invalid cpu.max: %q", data)
+	}
+	quota, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid cpu.max: %w", err)
+	}
+	period, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || period <= 0 {
+		return 0, false, fmt.Errorf("invalid cpu.max: %q", data)
+	}
+	return float64(quota) / float64(period), true, nil
+}
+
+func readCgroupInt(file string) (int64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func ignoreNotExist(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
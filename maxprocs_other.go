@@ -0,0 +1,8 @@
+//go:build !linux
+
+package server
+
+// cpuQuota 는 Linux 에서만 지원한다.
+func cpuQuota() (float64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"expvar"
+)
+
+// metrics 는 expvar 로 노출하는 지표이다. (/debug/vars 의 "berryons_server")
+var metrics = expvar.NewMap("berryons_server")
+
+// addMetric 은 name 지표에 delta 를 더한다.
+func addMetric(name string, delta int64) {
+	metrics.Add(name, delta)
+}
+
+// addLabeledMetric 은 name 지표의 label 항목에 delta 를 더한다.
+func addLabeledMetric(name, label string, delta int64) {
+	labeled, ok := metrics.Get(name).(*expvar.Map)
+	if !ok {
+		labeled = new(expvar.Map)
+		metrics.Set(name, labeled)
+	}
+	labeled.Add(label, delta)
+}
+
+// setLabeledMetrics 는 name 지표를 values 로 바꾼다. values 에 없는 이전 label 은 지운다. (e.g. 현재 값을 나타내는 지표)
+func setLabeledMetrics(name string, values map[string]int64) {
+	labeled := new(expvar.Map)
+	for label, value := range values {
+		labeled.Add(label, value)
+	}
+	metrics.Set(name, labeled)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"runtime/debug"
+)
+
+// WithRecovery 는 Handler 나 Interceptor 에서 발생한 panic 을 복구하여 Internal 오류로 응답한다.
+func WithRecovery() Option {
+	return func(options *serverOptions) {
+		options.recovery = true
+	}
+}
+
+// WithHealthCheck 는 grpc.health.v1.Health Service 를 등록한다.
+// 종료할 때는 연결을 정리하기 전에 NOT_SERVING 으로 바꾼다.
+func WithHealthCheck() Option {
+	return func(options *serverOptions) {
+		options.healthCheck = true
+	}
+}
+
+// WithReflection 은 grpcurl 등의 도구가 Service 목록을 조회할 수 있도록 Server Reflection Service 를 등록한다.
+// hiddenServices 에 지정한 Service (e.g. 내부 관리용 Service) 는 Service 목록에서 제외한다.
+// 목록에서만 제외하므로 symbol 이름을 알고 있으면 descriptor 는 조회할 수 있다.
+func WithReflection(hiddenServices ...string) Option {
+	return func(options *serverOptions) {
+		options.reflection = true
+		options.reflectionHidden = append(options.reflectionHidden, hiddenServices...)
+	}
+}
+
+func recoveryUnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverPanic(info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoveryStreamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverPanic(info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recoverPanic(fullMethod string, r any) error {
+	gLogger.Printf("Recovered from panic in %s: %v\n%s", fullMethod, r, debug.Stack())
+	addLabeledMetric("panics_recovered", fullMethod, 1)
+	return status.Errorf(codes.Internal, "internal error")
+}
+
+// registerBuiltinServices 는 Option 으로 설정한 기본 Service 를 등록한다.
+func registerBuiltinServices(grpcServer ServiceServer, options *serverOptions) *health.Server {
+	var healthServer *health.Server
+	if options.healthCheck {
+		healthServer = health.NewServer()
+		grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
+	}
+	if options.reflection {
+		// reflection.Register 와 같이 v1, v1alpha 를 모두 등록한다.
+		reflectionOptions := reflection.ServerOptions{
+			Services: &reflectionServices{server: grpcServer, hidden: options.reflectionHidden},
+		}
+		grpc_reflection_v1.RegisterServerReflectionServer(grpcServer, reflection.NewServerV1(reflectionOptions))
+		grpc_reflection_v1alpha.RegisterServerReflectionServer(grpcServer, reflection.NewServer(reflectionOptions))
+	}
+	return healthServer
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMirrorTimeout       = 5 * time.Second
+	defaultMirrorMaxConcurrent = 100
+	// mirrorStreamBuffer 는 stream 별로 shadow backend 에 보내지 못한 메시지 수이다. 넘으면 stream 복제를 중단한다.
+	mirrorStreamBuffer = 16
+)
+
+// MirrorOptions 는 요청을 복제할 shadow backend 설정이다.
+type MirrorOptions struct {
+	Backend ProxyBackend
+	// Percent 는 복제할 요청의 비율이다. (0 ~ 100)
+	Percent float64
+	// Timeout 은 복제한 요청의 제한 시간이다. (기본값: 5초)
+	Timeout time.Duration
+	// MaxConcurrent 는 동시에 처리할 복제 요청 수이다. 넘는 요청은 복제하지 않는다. (기본값: 100)
+	MaxConcurrent int
+}
+
+// WithMirror 는 요청 중 Percent 만큼을 shadow backend 에도 보낸다. 새 버전의 Service 를 실제 요청으로 시험할 때 사용한다.
+// shadow backend 의 응답과 오류는 버리며, 원래 요청의 처리를 기다리게 하지 않는다.
+// Service 에 등록한 요청과 WithProxy 로 전달하는 요청 모두 복제하며, 다른 Interceptor 를 모두 통과한 요청만 복제한다.
+// 결과는 mirrored_requests 지표 (sent, failed, dropped) 로 확인한다.
+func WithMirror(mirrorOptions MirrorOptions) Option {
+	return func(options *serverOptions) {
+		if mirrorOptions.Timeout <= 0 {
+			mirrorOptions.Timeout = defaultMirrorTimeout
+		}
+		if mirrorOptions.MaxConcurrent <= 0 {
+			mirrorOptions.MaxConcurrent = defaultMirrorMaxConcurrent
+		}
+		options.mirror = &mirrorOptions
+	}
+}
+
+// trafficMirror 는 요청을 shadow backend 에 복제한다.
+type trafficMirror struct {
+	options *MirrorOptions
+	pool    *backendPool
+	cSlots  chan struct{}
+}
+
+func newTrafficMirror(mirrorOptions *MirrorOptions) (*trafficMirror, error) {
+	pool, err := mirrorOptions.Backend.newPool()
+	if err != nil {
+		return nil, err
+	}
+	return &trafficMirror{
+		options: mirrorOptions,
+		pool:    pool,
+		cSlots:  make(chan struct{}, mirrorOptions.MaxConcurrent),
+	}, nil
+}
+
+func (pSelf *trafficMirror) close() {
+	pSelf.pool.close()
+}
+
+// acquire 는 복제할 요청이면 처리 자리를 얻는다. 자리가 없으면 복제하지 않는다.
+func (pSelf *trafficMirror) acquire() bool {
+	if rand.Float64()*100 >= pSelf.options.Percent {
+		return false
+	}
+	select {
+	case pSelf.cSlots <- struct{}{}:
+		return true
+	default:
+		addLabeledMetric("mirrored_requests", "dropped", 1)
+		return false
+	}
+}
+
+func (pSelf *trafficMirror) release(err error) {
+	<-pSelf.cSlots
+	if err != nil {
+		addLabeledMetric("mirrored_requests", "failed", 1)
+		return
+	}
+	addLabeledMetric("mirrored_requests", "sent", 1)
+}
+
+// shadowContext 는 원래 요청이 끝나도 취소되지 않고, 요청 metadata 를 전달하는 context 이다.
+func (pSelf *trafficMirror) shadowContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(outgoingMetadata(context.WithoutCancel(ctx)), pSelf.options.Timeout)
+}
+
+func (pSelf *trafficMirror) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if pSelf.acquire() {
+		// Handler 가 요청을 바꿀 수 있으므로 먼저 encode.
+		frame, err := encodeFrame(req)
+		if err != nil {
+			pSelf.release(err)
+			return handler(ctx, req)
+		}
+
+		shadowCtx, cancel := pSelf.shadowContext(ctx)
+		go func() {
+			defer cancel()
+			pSelf.release(pSelf.pool.pick().Invoke(shadowCtx, info.FullMethod, frame, &rawFrame{}, grpc.ForceCodecV2(rawCodec{})))
+		}()
+	}
+	return handler(ctx, req)
+}
+
+func (pSelf *trafficMirror) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !pSelf.acquire() {
+		return handler(srv, ss)
+	}
+
+	shadowCtx, cancel := pSelf.shadowContext(ss.Context())
+	stream := &mirrorServerStream{ServerStream: ss, cFrames: make(chan *rawFrame, mirrorStreamBuffer)}
+	go func() {
+		defer cancel()
+		pSelf.release(pSelf.mirrorStream(shadowCtx, info.FullMethod, stream))
+	}()
+	defer stream.finish()
+	return handler(srv, stream)
+}
+
+// mirrorStream 은 받은 요청 메시지를 shadow backend 의 stream 으로 보내고, 응답은 버린다.
+func (pSelf *trafficMirror) mirrorStream(ctx context.Context, fullMethod string, stream *mirrorServerStream) error {
+	clientStream, err := pSelf.pool.pick().NewStream(
+		ctx,
+		&grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		fullMethod,
+		grpc.ForceCodecV2(rawCodec{}),
+	)
+	if err != nil {
+		// 읽지 않은 메시지가 쌓이면 RecvMsg 가 복제를 중단한다.
+		return err
+	}
+
+	for frame := range stream.cFrames {
+		if stream.aborted.Load() {
+			break
+		}
+		if err := clientStream.SendMsg(frame); err != nil {
+			break
+		}
+	}
+	if stream.aborted.Load() {
+		return errors.New("mirror stream buffer is full")
+	}
+	if err := clientStream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		if err := clientStream.RecvMsg(&rawFrame{}); err != nil {
+			return ignoreEOF(err)
+		}
+	}
+}
+
+// mirrorServerStream 은 받은 요청 메시지를 shadow backend 로 보내는 ServerStream 이다.
+type mirrorServerStream struct {
+	grpc.ServerStream
+	mutex   sync.Mutex
+	cFrames chan *rawFrame
+	closed  bool
+	aborted atomic.Bool
+}
+
+func (pSelf *mirrorServerStream) RecvMsg(m any) error {
+	err := pSelf.ServerStream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+
+	frame, encodeErr := encodeFrame(m)
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if pSelf.closed {
+		return nil
+	}
+	if encodeErr != nil {
+		pSelf.aborted.Store(true)
+		pSelf.close()
+		return nil
+	}
+	select {
+	case pSelf.cFrames <- frame:
+	default:
+		// shadow backend 가 느리면 원래 요청을 기다리게 하지 않고 복제를 중단.
+		pSelf.aborted.Store(true)
+		pSelf.close()
+	}
+	return nil
+}
+
+// finish 는 더 보낼 메시지가 없음을 알린다.
+func (pSelf *mirrorServerStream) finish() {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if !pSelf.closed {
+		pSelf.close()
+	}
+}
+
+// close 는 mutex 를 잡은 상태에서 호출한다.
+func (pSelf *mirrorServerStream) close() {
+	pSelf.closed = true
+	close(pSelf.cFrames)
+}
+
+// encodeFrame 은 메시지를 rawFrame 으로 encode 한다.
+func encodeFrame(m any) (*rawFrame, error) {
+	if frame, ok := m.(*rawFrame); ok {
+		return frame, nil
+	}
+	data, err := rawCodec{}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Free()
+	return &rawFrame{data: data.Materialize()}, nil
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
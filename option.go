@@ -0,0 +1,104 @@
+package server
+
+import (
+	"crypto/tls"
+	"google.golang.org/grpc/encoding"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Option 은 New 로 생성하는 GrpcServer 의 부가 설정이다.
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	systemdSocketActivation bool
+	reusePort               bool
+	socketOptions           *SocketOptions
+	listenControls          []func(network, address string, c syscall.RawConn) error
+	proxyProtocol           *ProxyProtocolOptions
+	tlsConfig               *tls.Config
+	fips                    *FIPSOptions
+	revocation              *RevocationOptions
+	revocationChecker       *revocationChecker
+	sessionTicket           *SessionTicketOptions
+	sessionTicketKeys       *sessionTicketKeys
+	tlsIdentity             *TLSIdentityOptions
+	signature               *SignatureOptions
+	replay                  *ReplayOptions
+	additionalListeners     []ListenerConfig
+	upgrader                *upgrader
+	dropPrivileges          *privileges
+	pidFile                 string
+	tcpKeepAlive            *net.KeepAliveConfig
+	listenBacklog           int
+	connectionLimiter       *connectionLimiter
+	ipConnectionLimiter     *ipConnectionLimiter
+	idleTimeout             time.Duration
+	maxConnectionAge        time.Duration
+	maxConnectionAgeGrace   time.Duration
+	http3                   *Http3Options
+	webTransport            *WebTransportOptions
+	h2c                     bool
+	alpn                    *ALPNOptions
+	plaintextPolicy         PlaintextPolicy
+	securityHeaders         *SecurityHeadersOptions
+	csrf                    *CSRFOptions
+	contentTypes            *ContentTypeOptions
+	headerLimits            *HeaderLimitsOptions
+	httpTimeouts            HttpTimeoutsOptions
+	buffers                 *BufferOptions
+	flowControl             *FlowControlOptions
+	compression             *CompressionOptions
+	codecs                  []string
+	forcedCodec             encoding.Codec
+	pooledCodec             *pooledCodec
+	maxProcs                *MaxProcsOptions
+	portExport              *PortExportOptions
+	recovery                bool
+	healthCheck             bool
+	reflection              bool
+	// WithReflection 으로 Reflection 에서 숨길 Service 이름.
+	reflectionHidden []string
+	// RegisterServices 로 등록한 Service 이름.
+	services           serviceSet
+	requestLogContext  bool
+	flagProvider       FlagProvider
+	tenancy            *tenancy
+	rateLimiter        *rateLimiter
+	authLockout        *authLockout
+	redactor           *redactor
+	delegation         *delegation
+	securityEvents     SecurityEventExporter
+	certificateExpiry  *certificateExpiry
+	httpProxyPort      *int
+	reload             *ReloadOptions
+	kubernetesWatch    *KubernetesWatchOptions
+	config             *Config
+	configPath         string
+	configProfile      string
+	tlsStore           *tlsStore
+	bundleOverrides    []MiddlewareBundle
+	excludedBundles    []string
+	serverFactory      ServerFactory
+	factoryCredentials bool
+	registration       Registration
+	registrars         []Registrar
+	workers            []namedWorker
+	proxy              *ProxyOptions
+	mirror             *MirrorOptions
+	errorPolicy        ErrorPolicy
+	degradedMutex      sync.Mutex
+	degraded           []string
+}
+
+func newServerOptions(opts []Option) *serverOptions {
+	options := &serverOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	return options
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WithPIDFile 은 Run 이 시작될 때 path 에 프로세스 ID 를 기록하고, 종료될 때 삭제한다.
+// 이미 실행 중인 프로세스의 PID 파일이 있으면 시작하지 않으며, 종료된 프로세스의 PID 파일은 덮어쓴다.
+func WithPIDFile(path string) Option {
+	return func(options *serverOptions) {
+		options.pidFile = path
+	}
+}
+
+// checkPIDFile 은 path 의 PID 파일을 가진 다른 프로세스가 실행 중인지 확인한다.
+func checkPIDFile(path string) error {
+	pid, err := readPIDFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// 무중단 binary 교체로 실행 된 경우 이전 프로세스의 PID 파일은 덮어쓴다.
+	if pid != os.Getpid() && pid != os.Getppid() && processAlive(pid) {
+		return fmt.Errorf("process %d is already running (pid file: %s)", pid, path)
+	}
+	return nil
+}
+
+// writePIDFile 은 path 에 현재 프로세스 ID 를 기록한다.
+func writePIDFile(path string) error {
+	if err := checkPIDFile(path); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// writeFileAtomic 은 기록하는 도중의 파일을 다른 프로세스가 읽지 않도록 임시 파일에 기록한 뒤 이름을 바꾼다.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		return err
+	}
+	if err := tempFile.Chmod(perm); err != nil {
+		_ = tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
+
+// removePIDFile 은 path 에 현재 프로세스 ID 가 기록되어 있는 경우에만 삭제한다.
+func removePIDFile(path string) error {
+	pid, err := readPIDFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if pid != os.Getpid() {
+		// 다른 프로세스 (e.g. 무중단 binary 교체로 실행 된 프로세스) 의 PID 파일.
+		return nil
+	}
+	return os.Remove(path)
+}
+
+func readPIDFile(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	pidStr := strings.TrimSpace(string(b))
+	if len(pidStr) == 0 {
+		return 0, nil
+	}
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pid file %s: %q", path, pidStr)
+	}
+	return pid, nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package server
+
+import "os"
+
+// processAlive 는 pid 의 프로세스가 실행 중인지 확인한다.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/peer"
+	"net"
+)
+
+// PlaintextPolicy 는 TLS 없이 연결을 수락할 수 있는 Listener 의 정책이다.
+type PlaintextPolicy int
+
+const (
+	// PlaintextAllowed 는 모든 Listener 에서 평문을 허용한다. (기본값)
+	PlaintextAllowed PlaintextPolicy = iota
+	// PlaintextLocalOnly 는 unix socket 과 loopback 주소의 Listener 에서만 평문을 허용한다.
+	// 모든 주소 (e.g. 0.0.0.0, ::) 와 vsock 의 Listener 는 TLS 를 사용해야 한다.
+	PlaintextLocalOnly
+)
+
+// WithPlaintextPolicy 는 시작할 때 gRPC, gRPC Gateway (Http Proxy), 추가한 Listener 가 policy 를 지키는지 확인하고, 지키지 않으면 시작하지 않는다.
+// gRPC Gateway 는 WithHttp3 를 사용할 때만 TLS 를 사용한다.
+func WithPlaintextPolicy(policy PlaintextPolicy) Option {
+	return func(options *serverOptions) {
+		options.plaintextPolicy = policy
+	}
+}
+
+// checkPlaintextPolicy 는 bind 한 Listener 중 평문을 허용하지 않는 주소에서 평문으로 수락하는 Listener 를 찾는다.
+func (pSelf *GrpcServer) checkPlaintextPolicy(proxyListener net.Listener, http3Conn net.PacketConn) error {
+	if pSelf.options.plaintextPolicy == PlaintextAllowed {
+		return nil
+	}
+
+	var errs []error
+	check := func(name string, addr net.Addr, tls bool) {
+		if !tls && !localAddr(addr) {
+			errs = append(errs, fmt.Errorf("listener %s (%s) accepts plaintext on a non-local address", name, addr))
+		}
+	}
+	check("grpc", pSelf.listener.Addr(), pSelf.options.tlsConfig != nil)
+	if proxyListener != nil {
+		check("http", proxyListener.Addr(), http3Conn != nil)
+	}
+	for _, l := range pSelf.namedListeners {
+		check(l.name, l.listener.Addr(), l.tlsConfig != nil)
+	}
+	return errors.Join(errs...)
+}
+
+// localPeer 는 client 가 같은 host 에서 연결했는지 여부이다. Server 의 주소로 직접 연결한 client 도 포함한다.
+func localPeer(p *peer.Peer) bool {
+	if localAddr(p.Addr) {
+		return true
+	}
+	remote, ok := p.Addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	local, ok := p.LocalAddr.(*net.TCPAddr)
+	return ok && remote.IP.Equal(local.IP)
+}
+
+// localAddr 는 같은 host 에서만 연결할 수 있는 주소인지 여부이다.
+func localAddr(addr net.Addr) bool {
+	switch addr := addr.(type) {
+	case *net.UnixAddr:
+		return true
+	case *net.TCPAddr:
+		return addr.IP.IsLoopback()
+	}
+	return false
+}
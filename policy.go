@@ -0,0 +1,50 @@
+package server
+
+import (
+	"fmt"
+)
+
+// ErrorPolicy 는 복구할 수 있는 설정 문제를 처리하는 방법이다.
+type ErrorPolicy int
+
+const (
+	// StrictErrors 는 복구할 수 있는 설정 문제도 종료한다. (기본값)
+	StrictErrors ErrorPolicy = iota
+	// LenientErrors 는 복구할 수 있는 설정 문제를 경고로 기록하고, 해당 기능 없이 시작한다.
+	LenientErrors
+)
+
+// WithErrorPolicy 는 복구할 수 있는 설정 문제를 처리하는 방법이다.
+// LenientErrors 이면 다음 문제는 경고로 기록하고 해당 기능 없이 시작한다. 나머지 문제는 항상 종료한다.
+//   - HTTP/3 인증서가 없거나 HTTP/3 를 listen 할 수 없으면 HTTP/3 없이 시작한다.
+//   - gRPC Gateway 등록 또는 listen 에 실패하면 해당 Gateway (또는 Http Proxy Server) 없이 시작한다.
+//   - 추가 Listener 를 listen 할 수 없으면 해당 Listener 없이 시작한다.
+//   - systemd Socket Activation 에 실패하면 직접 listen 한다.
+//   - PID 파일 기록, port 내보내기에 실패하면 기록하지 않고 시작한다.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(options *serverOptions) {
+		options.errorPolicy = policy
+	}
+}
+
+// Degraded 는 LenientErrors 로 생성한 Server 에서 설정 문제로 사용하지 않는 기능과 원인이다.
+func (pSelf *GrpcServer) Degraded() []string {
+	pSelf.options.degradedMutex.Lock()
+	defer pSelf.options.degradedMutex.Unlock()
+	return append([]string(nil), pSelf.options.degraded...)
+}
+
+// recoverable 은 복구할 수 있는 설정 문제를 처리한다.
+// StrictErrors 이면 종료하고, LenientErrors 이면 경고를 기록하고 반환한다. 호출한 곳은 해당 기능 없이 계속한다.
+func (pSelf *serverOptions) recoverable(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if pSelf.errorPolicy != LenientErrors {
+		gLogger.Fatal(message)
+	}
+
+	gLogger.Printf("WARNING: %s (continue without it)\n", message)
+	addMetric("setup_warnings", 1)
+	pSelf.degradedMutex.Lock()
+	pSelf.degraded = append(pSelf.degraded, message)
+	pSelf.degradedMutex.Unlock()
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// BoundListener 는 bind 한 Listener 의 주소이다.
+type BoundListener struct {
+	// Name 은 "grpc", "http", "http3" 또는 AddListener 의 name 이다.
+	Name    string
+	Network string
+	// Address 는 port 를 포함한 전체 주소이다. (unix socket 은 파일 경로)
+	Address string
+	// Port 는 OS 가 할당한 port 를 포함한 실제 port 이다. (unix socket 은 0)
+	Port int
+}
+
+// PortExportOptions 는 port 0 으로 bind 한 Listener 의 주소를 외부에 알리는 방법이다.
+type PortExportOptions struct {
+	// EnvFile 에 "GRPC_PORT=50051", "GRPC_ADDRESS=127.0.0.1:50051" 형식으로 기록한다.
+	EnvFile string
+	// Stdout 이면 Listener 마다 "LISTEN grpc tcp 127.0.0.1:50051" 한 줄을 표준 출력에 쓴다.
+	Stdout bool
+	// Callback 은 모든 Listener 를 bind 한 뒤, 요청을 처리하기 전에 호출된다.
+	Callback func(listeners []BoundListener)
+}
+
+// WithPortExport 는 Run 에서 모든 Listener 를 bind 한 뒤 실제 주소를 알린다.
+// port 0 으로 bind 한 Server 의 port 를 test harness 나 sidecar 가 알 수 있도록 한다.
+func WithPortExport(portExportOptions PortExportOptions) Option {
+	return func(options *serverOptions) {
+		options.portExport = &portExportOptions
+	}
+}
+
+// boundListeners 는 bind 한 모든 Listener 의 주소를 반환한다.
+func (pSelf *GrpcServer) boundListeners(proxyListener net.Listener, http3Conn net.PacketConn) []BoundListener {
+	listeners := []BoundListener{{
+		Name:    "grpc",
+		Network: pSelf.network,
+		Address: joinAddress(pSelf.network, pSelf.address, pSelf.port),
+		Port:    pSelf.port,
+	}}
+	if strings.EqualFold("unix", pSelf.network) {
+		listeners[0].Port = 0
+	}
+
+	if proxyListener != nil {
+		listeners = append(listeners, BoundListener{
+			Name:    "http",
+			Network: pSelf.httpProxyNetwork(),
+			Address: joinAddress(pSelf.httpProxyNetwork(), pSelf.address, pSelf.httpProxyPort),
+			Port:    pSelf.httpProxyPort,
+		})
+	}
+	if http3Conn != nil {
+		listeners = append(listeners, BoundListener{
+			Name:    "http3",
+			Network: pSelf.http3Network(),
+			Address: http3Conn.LocalAddr().String(),
+			Port:    boundPort(http3Conn.LocalAddr(), 0),
+		})
+	}
+
+	for _, l := range pSelf.namedListeners {
+		listeners = append(listeners, BoundListener{
+			Name:    l.name,
+			Network: l.network,
+			Address: l.address,
+			Port:    l.port,
+		})
+	}
+	return listeners
+}
+
+// exportPorts 는 PortExportOptions 에 따라 Listener 의 주소를 알린다.
+func exportPorts(portExportOptions *PortExportOptions, listeners []BoundListener) error {
+	if len(portExportOptions.EnvFile) > 0 {
+		var builder strings.Builder
+		for _, l := range listeners {
+			name := envName(l.Name)
+			builder.WriteString(name + "_NETWORK=" + l.Network + "\n")
+			builder.WriteString(name + "_ADDRESS=" + l.Address + "\n")
+			builder.WriteString(name + "_PORT=" + strconv.Itoa(l.Port) + "\n")
+		}
+		if err := writeFileAtomic(portExportOptions.EnvFile, []byte(builder.String()), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if portExportOptions.Stdout {
+		for _, l := range listeners {
+			fmt.Printf("LISTEN %s %s %s\n", l.Name, l.Network, l.Address)
+		}
+	}
+
+	if portExportOptions.Callback != nil {
+		portExportOptions.Callback(listeners)
+	}
+	return nil
+}
+
+// envName 은 Listener 이름을 환경 변수 이름으로 바꾼다. (e.g. "grpc-uds" -> "GRPC_UDS")
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		}
+		return '_'
+	}, name)
+}
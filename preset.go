@@ -0,0 +1,63 @@
+package server
+
+import (
+	"google.golang.org/grpc"
+	"time"
+)
+
+const (
+	productionIdleTimeout           = 5 * time.Minute
+	productionMaxConnectionAge      = 30 * time.Minute
+	productionMaxConnectionAgeGrace = 30 * time.Second
+)
+
+// NewProduction 은 운영 환경에 맞는 기본값으로 gRPC Server 를 생성한다.
+//
+//   - panic 복구 (WithRecovery), Health Service (WithHealthCheck)
+//   - 요청 log 에 request ID, method, peer 기록 (WithRequestLogContext)
+//   - gRPC Gateway 응답에 보안 header 추가 (DefaultSecurityHeaders)
+//   - config 에 없으면 idle timeout 5분, 연결 최대 수명 30분 (grace 30초)
+//   - Server Reflection 은 사용하지 않는다.
+//
+// 연결 수 등의 지표는 항상 expvar 로 노출된다. opts 는 기본값과 config 보다 나중에 적용된다.
+func NewProduction(
+	config *Config,
+	unaryServerInterceptors []grpc.UnaryServerInterceptor,
+	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
+) *GrpcServer {
+	preset := *config
+	preset.Middleware.Recovery = true
+	preset.Middleware.Health = true
+	if preset.Limits.IdleTimeout == 0 {
+		preset.Limits.IdleTimeout = Duration(productionIdleTimeout)
+	}
+	if preset.Limits.MaxConnectionAge == 0 {
+		preset.Limits.MaxConnectionAge = Duration(productionMaxConnectionAge)
+		if preset.Limits.MaxConnectionAgeGrace == 0 {
+			preset.Limits.MaxConnectionAgeGrace = Duration(productionMaxConnectionAgeGrace)
+		}
+	}
+	return NewWithConfig(&preset, unaryServerInterceptors, streamServerInterceptors, append([]Option{WithRequestLogContext(), WithSecurityHeaders(DefaultSecurityHeaders())}, opts...)...)
+}
+
+// NewDevelopment 는 개발 환경에 맞는 기본값으로 gRPC Server 를 생성한다.
+//
+//   - panic 복구 (WithRecovery), Health Service (WithHealthCheck)
+//   - grpcurl 등으로 조회할 수 있도록 Server Reflection (WithReflection)
+//   - 요청 log 에 request ID, method, peer 기록 (WithRequestLogContext)
+//   - 디버깅 중에 연결이 끊기지 않도록 timeout 은 config 에 있는 값만 사용한다.
+//
+// opts 는 기본값과 config 보다 나중에 적용된다.
+func NewDevelopment(
+	config *Config,
+	unaryServerInterceptors []grpc.UnaryServerInterceptor,
+	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
+) *GrpcServer {
+	preset := *config
+	preset.Middleware.Recovery = true
+	preset.Middleware.Health = true
+	preset.Middleware.Reflection = true
+	return NewWithConfig(&preset, unaryServerInterceptors, streamServerInterceptors, append([]Option{WithRequestLogContext()}, opts...)...)
+}
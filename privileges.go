@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// WithDropPrivileges 는 모든 Listener 를 bind 한 뒤 userName (groupName) 의 권한으로 전환한다.
+// 1024 미만의 port 를 root 로 bind 한 뒤 권한을 낮추는 용도로 사용한다.
+// groupName 이 비어 있으면 사용자의 기본 group 을 사용한다.
+func WithDropPrivileges(userName, groupName string) Option {
+	return func(options *serverOptions) {
+		options.dropPrivileges = &privileges{userName: userName, groupName: groupName}
+	}
+}
+
+type privileges struct {
+	userName  string
+	groupName string
+}
+
+// lookup 은 사용자와 group 의 ID 를 찾는다.
+func (pSelf *privileges) lookup() (uid, gid int, err error) {
+	u, err := user.Lookup(pSelf.userName)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if uid, err = strconv.Atoi(u.Uid); err != nil {
+		return 0, 0, fmt.Errorf("invalid uid of user %s: %s", pSelf.userName, u.Uid)
+	}
+
+	gidStr := u.Gid
+	if len(pSelf.groupName) > 0 {
+		g, err := user.LookupGroup(pSelf.groupName)
+		if err != nil {
+			return 0, 0, err
+		}
+		gidStr = g.Gid
+	}
+
+	if gid, err = strconv.Atoi(gidStr); err != nil {
+		return 0, 0, fmt.Errorf("invalid gid of group %s: %s", pSelf.groupName, gidStr)
+	}
+
+	return uid, gid, nil
+}
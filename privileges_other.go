@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package server
+
+import "errors"
+
+func (pSelf *privileges) drop() error {
+	return errors.New("dropping privileges is not supported on this platform")
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// drop 은 supplementary group, gid, uid 순서로 권한을 전환한다.
+func (pSelf *privileges) drop() error {
+	uid, gid, err := pSelf.lookup()
+	if err != nil {
+		return err
+	}
+
+	if os.Getuid() == uid && os.Getgid() == gid {
+		// 이미 전환 된 권한. (e.g. 무중단 binary 교체로 실행 된 프로세스)
+		return nil
+	}
+
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %w", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %w", err)
+	}
+
+	// root 권한을 다시 얻을 수 없는지 확인.
+	if uid != 0 && syscall.Setuid(0) == nil {
+		return fmt.Errorf("privileges were not dropped")
+	}
+
+	return nil
+}
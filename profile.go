@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"google.golang.org/grpc"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultProfileEnv 는 LoadConfigProfileFromEnv 가 profile 을 읽는 기본 환경 변수이다.
+const DefaultProfileEnv = "SERVER_PROFILE"
+
+// ProfilePath 는 기본 설정 파일 path 에 대한 profile 설정 파일의 경로이다.
+// 확장자 앞에 profile 을 붙인다. (e.g. config/server.yaml, prod → config/server.prod.yaml)
+func ProfilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// LoadConfigProfile 은 기본 설정 파일 path 를 읽고, profile 설정 파일 (ProfilePath) 을 덮어쓴 설정을 반환한다.
+// profile 설정 파일에 있는 항목만 기본 설정을 덮어쓰며, 목록은 합치지 않고 바꾼다.
+// profile 이 비어 있으면 기본 설정 파일만 읽으며, 두 파일 모두에 없는 항목은 DefaultConfig 의 값을 사용한다.
+//
+//	server.yaml       (모든 환경의 공통 설정)
+//	server.dev.yaml   (dev 에서 바꿀 항목)
+//	server.prod.yaml  (prod 에서 바꿀 항목)
+func LoadConfigProfile(path, profile string) (*Config, error) {
+	config := DefaultConfig()
+	for _, configFile := range configFiles(path, profile) {
+		if err := decodeConfigFile(configFile, config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// LoadConfigProfileFromEnv 는 환경 변수 env 의 값을 profile 로 LoadConfigProfile 을 호출한다. (env 가 비어 있으면 DefaultProfileEnv)
+func LoadConfigProfileFromEnv(path, env string) (*Config, error) {
+	if len(env) == 0 {
+		env = DefaultProfileEnv
+	}
+	return LoadConfigProfile(path, os.Getenv(env))
+}
+
+// configFiles 는 순서대로 읽을 설정 파일 목록이다.
+func configFiles(path, profile string) []string {
+	profile = strings.TrimSpace(profile)
+	if len(profile) == 0 {
+		return []string{path}
+	}
+	return []string{path, ProfilePath(path, profile)}
+}
+
+// withConfigProfile 은 설정을 다시 읽을 때 사용할 profile 을 기록한다.
+func withConfigProfile(profile string) Option {
+	return func(options *serverOptions) {
+		options.configProfile = profile
+	}
+}
+
+// NewFromConfigProfile 은 기본 설정 파일에 profile 설정 파일을 덮어쓴 설정으로 gRPC Server 를 생성한다. (LoadConfigProfile 참고)
+// 설정을 다시 읽을 때 (WithReload) 도 같은 profile 을 사용한다.
+func NewFromConfigProfile(
+	path, profile string,
+	unaryServerInterceptors []grpc.UnaryServerInterceptor,
+	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
+) *GrpcServer {
+	config, err := LoadConfigProfile(path, profile)
+	if err != nil {
+		gLogger.Fatal(fmt.Errorf("failed to load config profile %q: %w", profile, err))
+	}
+	return NewWithConfig(config, unaryServerInterceptors, streamServerInterceptors, append([]Option{withConfig(config, path), withConfigProfile(profile)}, opts...)...)
+}
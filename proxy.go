@@ -0,0 +1,468 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyOptions 는 등록되지 않은 Service 의 요청을 전달할 gRPC backend 설정이다.
+type ProxyOptions struct {
+	// Backend 는 Routes 와 일치하지 않는 요청을 전달할 gRPC Server 주소이다. (grpc.NewClient 의 target, e.g. dns:///backend:50051)
+	// 비어 있으면 Routes 와 일치하지 않는 요청은 Unimplemented 로 응답한다.
+	Backend string
+	// DialOptions 는 Backend 연결 설정이다. nil 이면 TLS 없이 연결한다.
+	DialOptions []grpc.DialOption
+	// Failover 는 Backend 가 응답하지 못한 요청을 순서대로 보낼 backend 이다. (Retry 참고)
+	Failover []ProxyBackend
+	// Canary 는 Backend 로 보낼 요청 중 일부를 보낼 canary backend 이다.
+	Canary *ProxyCanary
+	// Routes 는 Service 이름 별 backend 이다. 가장 긴 Prefix 가 일치하는 Route 를 사용한다.
+	Routes []ProxyRoute
+	// Retry 는 모든 Route 에 적용하는 재시도 설정이다.
+	Retry ProxyRetry
+}
+
+// ProxyRoute 는 Service 이름의 prefix 로 backend 를 고르는 규칙이다.
+type ProxyRoute struct {
+	// Prefix 는 package 를 포함한 Service 이름의 prefix 이다. Method 까지 지정할 수도 있다.
+	// (e.g. "myapp.user.", "myapp.order.OrderService", "myapp.order.OrderService/Get")
+	Prefix  string
+	Backend ProxyBackend
+	// Failover 는 Backend 가 응답하지 못한 요청을 순서대로 보낼 backend 이다. (ProxyOptions.Retry 참고)
+	Failover []ProxyBackend
+	// Canary 는 Backend 로 보낼 요청 중 일부를 보낼 canary backend 이다.
+	Canary *ProxyCanary
+}
+
+// ProxyCanary 는 요청 일부를 canary backend 로 보내는 규칙이다. 새 버전을 단계적으로 배포할 때 사용한다.
+// Header 가 일치하는 요청은 항상 canary 로 보내고, 나머지 요청은 Weight 의 비율만큼 보낸다.
+type ProxyCanary struct {
+	Backend ProxyBackend
+	// Weight 는 canary 로 보낼 요청의 비율이다. (0 ~ 100)
+	Weight float64
+	// Header 는 canary 로 보낼 요청의 metadata (HTTP header) 이름이다. (e.g. x-canary)
+	Header string
+	// Value 는 canary 로 보낼 Header 의 값이다. 비어 있으면 Header 가 있는 요청을 보낸다.
+	Value string
+}
+
+// ProxyBackend 는 요청을 전달할 gRPC backend 이다.
+type ProxyBackend struct {
+	// Target 은 backend 주소이다. (grpc.NewClient 의 target)
+	Target string
+	// TLS 는 backend 연결의 TLS 설정이다. nil 이면 TLS 없이 연결한다.
+	TLS *tls.Config
+	// PoolSize 는 backend 와 유지할 연결 수이다. 요청은 연결에 순서대로 나눈다. (기본값: 1)
+	// 한 연결의 동시 stream 수 제한 (HTTP/2 MAX_CONCURRENT_STREAMS) 보다 요청이 많은 경우 늘린다.
+	PoolSize int
+	// DialOptions 는 추가 연결 설정이다.
+	DialOptions []grpc.DialOption
+}
+
+// WithProxy 는 Server 에 등록되지 않은 Service 의 요청을 backend 로 그대로 전달한다. (gRPC reverse proxy)
+// 메시지는 decode 하지 않고 frame 그대로 전달하므로 proto 정의 없이 모든 Service 를 전달할 수 있다.
+// 요청 metadata 와 응답 header, trailer, status 도 그대로 전달하며, 등록한 Interceptor 는 Stream Interceptor 로 실행된다.
+// backend 가 응답하지 못한 요청은 Failover backend 로 다시 보내며, 재시도는 proxy_attempts 지표로 확인한다.
+func WithProxy(proxyOptions ProxyOptions) Option {
+	return func(options *serverOptions) {
+		options.proxy = &proxyOptions
+	}
+}
+
+// grpcProxy 는 UnknownServiceHandler 로 받은 요청을 backend 로 전달한다.
+type grpcProxy struct {
+	// routes 는 Prefix 가 긴 순서이다.
+	routes   []proxyRoute
+	fallback *proxyRoute
+	retry    *proxyRetry
+}
+
+type proxyRoute struct {
+	prefix   string
+	pool     *backendPool
+	failover []*backendPool
+	canary   *canaryRoute
+}
+
+type canaryRoute struct {
+	pool   *backendPool
+	weight float64
+	header string
+	value  string
+}
+
+func newGrpcProxy(proxyOptions *ProxyOptions) (*grpcProxy, error) {
+	if len(proxyOptions.Backend) == 0 && len(proxyOptions.Routes) == 0 {
+		return nil, errors.New("proxy backend is not set")
+	}
+
+	proxy := &grpcProxy{retry: newProxyRetry(proxyOptions.Retry)}
+	if len(proxyOptions.Backend) > 0 {
+		dialOptions := proxyOptions.DialOptions
+		if dialOptions == nil {
+			dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		}
+		pool, err := newBackendPool(proxyOptions.Backend, 1, dialOptions)
+		if err != nil {
+			return nil, err
+		}
+		proxy.fallback = &proxyRoute{pool: pool}
+		if proxy.fallback.failover, err = newFailoverPools(proxyOptions.Failover); err != nil {
+			proxy.close()
+			return nil, fmt.Errorf("proxy failover: %w", err)
+		}
+		if proxy.fallback.canary, err = proxyOptions.Canary.newRoute(); err != nil {
+			proxy.close()
+			return nil, fmt.Errorf("proxy canary: %w", err)
+		}
+	}
+
+	for _, route := range proxyOptions.Routes {
+		pool, err := route.Backend.newPool()
+		if err != nil {
+			proxy.close()
+			return nil, fmt.Errorf("proxy route %q: %w", route.Prefix, err)
+		}
+		proxy.routes = append(proxy.routes, proxyRoute{prefix: strings.TrimPrefix(route.Prefix, "/"), pool: pool})
+		if proxy.routes[len(proxy.routes)-1].failover, err = newFailoverPools(route.Failover); err != nil {
+			proxy.close()
+			return nil, fmt.Errorf("proxy route %q failover: %w", route.Prefix, err)
+		}
+		if proxy.routes[len(proxy.routes)-1].canary, err = route.Canary.newRoute(); err != nil {
+			proxy.close()
+			return nil, fmt.Errorf("proxy route %q canary: %w", route.Prefix, err)
+		}
+	}
+	slices.SortStableFunc(proxy.routes, func(a, b proxyRoute) int {
+		return len(b.prefix) - len(a.prefix)
+	})
+	return proxy, nil
+}
+
+// backends 는 fullMethod 를 전달할 backend 를 시도할 순서대로 나열한다.
+func (pSelf *grpcProxy) backends(ctx context.Context, fullMethod string) []*backendPool {
+	method := strings.TrimPrefix(fullMethod, "/")
+	for _, route := range pSelf.routes {
+		if strings.HasPrefix(method, route.prefix) {
+			return route.backends(ctx)
+		}
+	}
+	if pSelf.fallback == nil {
+		return nil
+	}
+	return pSelf.fallback.backends(ctx)
+}
+
+// backends 는 요청을 전달할 backend 와 Failover backend 이다. canary 규칙과 일치하면 canary backend 를 먼저 사용한다.
+func (pSelf *proxyRoute) backends(ctx context.Context) []*backendPool {
+	backends := make([]*backendPool, 0, len(pSelf.failover)+2)
+	if pSelf.canary != nil && pSelf.canary.match(ctx) {
+		backends = append(backends, pSelf.canary.pool)
+	}
+	backends = append(backends, pSelf.pool)
+	return append(backends, pSelf.failover...)
+}
+
+func newFailoverPools(backends []ProxyBackend) ([]*backendPool, error) {
+	var pools []*backendPool
+	for _, backend := range backends {
+		pool, err := backend.newPool()
+		if err != nil {
+			for _, pool := range pools {
+				pool.close()
+			}
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func (pSelf *ProxyCanary) newRoute() (*canaryRoute, error) {
+	if pSelf == nil {
+		return nil, nil
+	}
+	pool, err := pSelf.Backend.newPool()
+	if err != nil {
+		return nil, err
+	}
+	return &canaryRoute{
+		pool:   pool,
+		weight: pSelf.Weight,
+		header: strings.ToLower(pSelf.Header),
+		value:  pSelf.Value,
+	}, nil
+}
+
+// match 는 요청을 canary backend 로 보낼지 여부이다.
+func (pSelf *canaryRoute) match(ctx context.Context) bool {
+	if len(pSelf.header) > 0 {
+		values := metadata.ValueFromIncomingContext(ctx, pSelf.header)
+		if len(values) > 0 && (len(pSelf.value) == 0 || slices.Contains(values, pSelf.value)) {
+			return true
+		}
+	}
+	return rand.Float64()*100 < pSelf.weight
+}
+
+// serverOptions 는 proxy 에 필요한 gRPC Server 설정이다.
+func (pSelf *grpcProxy) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ForceServerCodecV2(rawCodec{}),
+		grpc.UnknownServiceHandler(pSelf.handle),
+	}
+}
+
+func (pSelf *grpcProxy) close() {
+	if pSelf.fallback != nil {
+		pSelf.fallback.close()
+	}
+	for _, route := range pSelf.routes {
+		route.close()
+	}
+}
+
+func (pSelf *proxyRoute) close() {
+	pSelf.pool.close()
+	for _, pool := range pSelf.failover {
+		pool.close()
+	}
+	if pSelf.canary != nil {
+		pSelf.canary.pool.close()
+	}
+}
+
+// handle 은 요청 stream 을 backend stream 으로 전달한다.
+// backend 가 응답 header 를 보내기 전에 실패하면 다음 backend 로 다시 보내고, 응답을 받을 backend 가 정해지면 응답을 전달한다.
+func (pSelf *grpcProxy) handle(_ any, serverStream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(serverStream)
+	if !ok {
+		return status.Error(codes.Internal, "failed to get method from server stream")
+	}
+	backends := pSelf.backends(serverStream.Context(), fullMethod)
+	if len(backends) == 0 {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+	}
+
+	ctx, cancel := context.WithCancel(serverStream.Context())
+	defer cancel()
+
+	maxAttempts := pSelf.retry.maxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = len(backends)
+	}
+	if maxAttempts > 1 {
+		pSelf.retry.budget.deposit()
+	}
+
+	requests := newRequestLog(ctx, serverStream, maxAttempts > 1)
+	cResult := make(chan *proxyAttempt, maxAttempts)
+	var attempts []*proxyAttempt
+	start := func() {
+		attempts = append(attempts, startAttempt(ctx, len(attempts), backends[len(attempts)%len(backends)], fullMethod, requests, cResult))
+	}
+	// retry 는 다음 backend 로 보낼 수 있는지 확인한다.
+	retry := func(reason string) bool {
+		if len(attempts) >= maxAttempts || !requests.replayable() {
+			return false
+		}
+		if !pSelf.retry.budget.withdraw() {
+			addLabeledMetric("proxy_attempts", "budget_exhausted", 1)
+			return false
+		}
+		addLabeledMetric("proxy_attempts", reason, 1)
+		return true
+	}
+
+	start()
+	// 여러 번 실행해도 되는 Method 만 응답을 기다리지 않고 다음 backend 로도 보낸다.
+	var cHedge <-chan time.Time
+	if maxAttempts > 1 && pSelf.retry.hedgeable(strings.TrimPrefix(fullMethod, "/")) {
+		hedgeTimer := time.NewTicker(pSelf.retry.hedgeDelay)
+		defer hedgeTimer.Stop()
+		cHedge = hedgeTimer.C
+	}
+
+	inFlight := 1
+	for {
+		select {
+		case <-cHedge:
+			if len(attempts) >= maxAttempts {
+				cHedge = nil
+			} else if retry("hedge") {
+				start()
+				inFlight++
+			}
+		case attempt := <-cResult:
+			inFlight--
+			if attempt.header != nil || errors.Is(attempt.err, io.EOF) {
+				requests.commit(attempt.id)
+				for _, other := range attempts {
+					if other != attempt {
+						other.cancel()
+					}
+				}
+				return forwardResponses(ctx, cancel, serverStream, attempt)
+			}
+			if pSelf.retry.retryable(attempt.err) && retry("retry") {
+				start()
+				inFlight++
+				continue
+			}
+			if inFlight == 0 {
+				// backend 의 status 를 그대로 응답.
+				return attempt.err
+			}
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// outgoingMetadata 는 요청 metadata 를 backend 로 전달하는 context 이다.
+func outgoingMetadata(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	outgoingMD := md.Copy()
+	// backend 연결의 값을 사용.
+	delete(outgoingMD, ":authority")
+	delete(outgoingMD, "content-type")
+	delete(outgoingMD, "user-agent")
+	return metadata.NewOutgoingContext(ctx, outgoingMD)
+}
+
+// forwardResponses 는 응답을 받을 backend 의 응답 header 와 메시지를 client 로 전달한다.
+func forwardResponses(ctx context.Context, cancel context.CancelFunc, serverStream grpc.ServerStream, attempt *proxyAttempt) error {
+	if attempt.header == nil {
+		// 응답 메시지 없이 정상 종료.
+		serverStream.SetTrailer(attempt.stream.Trailer())
+		return nil
+	}
+	if err := serverStream.SendHeader(attempt.header); err != nil {
+		return err
+	}
+
+	cResponseDone := make(chan error, 1)
+	go func() {
+		for {
+			frame := &rawFrame{}
+			if err := attempt.stream.RecvMsg(frame); err != nil {
+				cResponseDone <- err
+				return
+			}
+			if err := serverStream.SendMsg(frame); err != nil {
+				cResponseDone <- err
+				return
+			}
+		}
+	}()
+
+	cRequestDone := attempt.cSent
+	for {
+		select {
+		case err := <-cRequestDone:
+			if err != nil && ctx.Err() == nil {
+				// client 의 요청을 읽지 못하면 backend 요청도 취소.
+				cancel()
+				return status.Errorf(codes.Internal, "failed to forward request: %v", err)
+			}
+			// 모든 요청을 전달했으면 응답이 끝날 때까지 대기.
+			cRequestDone = nil
+		case err := <-cResponseDone:
+			serverStream.SetTrailer(attempt.stream.Trailer())
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			// backend 의 status 를 그대로 응답.
+			return err
+		}
+	}
+}
+
+func (pSelf ProxyBackend) newPool() (*backendPool, error) {
+	if len(pSelf.Target) == 0 {
+		return nil, errors.New("backend target is not set")
+	}
+
+	transportCredentials := insecure.NewCredentials()
+	if pSelf.TLS != nil {
+		transportCredentials = credentials.NewTLS(pSelf.TLS)
+	}
+	dialOptions := append([]grpc.DialOption{grpc.WithTransportCredentials(transportCredentials)}, pSelf.DialOptions...)
+	return newBackendPool(pSelf.Target, max(pSelf.PoolSize, 1), dialOptions)
+}
+
+// backendPool 은 한 backend 와의 연결이다. 요청은 연결에 순서대로 나눈다.
+type backendPool struct {
+	conns []*grpc.ClientConn
+	next  atomic.Uint32
+}
+
+func newBackendPool(target string, size int, dialOptions []grpc.DialOption) (*backendPool, error) {
+	pool := &backendPool{}
+	for range size {
+		conn, err := grpc.NewClient(target, dialOptions...)
+		if err != nil {
+			pool.close()
+			return nil, err
+		}
+		pool.conns = append(pool.conns, conn)
+	}
+	return pool, nil
+}
+
+func (pSelf *backendPool) pick() *grpc.ClientConn {
+	return pSelf.conns[(pSelf.next.Add(1)-1)%uint32(len(pSelf.conns))]
+}
+
+func (pSelf *backendPool) close() {
+	for _, conn := range pSelf.conns {
+		if err := conn.Close(); err != nil {
+			gLogger.Printf("Failed to close proxy backend connection: %v\n", err)
+		}
+	}
+}
+
+// rawFrame 은 decode 하지 않은 gRPC 메시지이다.
+type rawFrame struct {
+	data []byte
+}
+
+// rawCodec 은 rawFrame 은 그대로 전달하고, 나머지 메시지는 proto codec 으로 처리한다.
+// Server 에 등록한 Service 는 proto 메시지를 사용하므로 함께 처리한다.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) (mem.BufferSlice, error) {
+	if frame, ok := v.(*rawFrame); ok {
+		return mem.BufferSlice{mem.SliceBuffer(frame.data)}, nil
+	}
+	return encoding.GetCodecV2("proto").Marshal(v)
+}
+
+func (rawCodec) Unmarshal(data mem.BufferSlice, v any) error {
+	if frame, ok := v.(*rawFrame); ok {
+		// data 는 Unmarshal 이 끝나면 반환되므로 복사한다.
+		frame.data = data.Materialize()
+		return nil
+	}
+	return encoding.GetCodecV2("proto").Unmarshal(data, v)
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,360 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProxyProtocolHeaderTimeout = 5 * time.Second
+
+	// PROXY protocol v1 header 의 최대 길이. (CRLF 포함)
+	proxyProtocolV1MaxLength = 107
+)
+
+var (
+	proxyProtocolV1Prefix    = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errProxyProtocolHeaderMissing  = errors.New("proxy protocol header is missing")
+	errProxyProtocolNoTrustedProxy = errors.New("proxy protocol requires at least one trusted proxy")
+)
+
+// ProxyProtocolOptions 는 HAProxy PROXY protocol (v1, v2) 설정이다.
+type ProxyProtocolOptions struct {
+	// TrustedProxies 는 PROXY header 를 신뢰할 upstream 의 IP 또는 CIDR 이다. (필수)
+	// 신뢰하지 않는 upstream 의 연결은 header 를 해석하지 않고 그대로 사용한다.
+	TrustedProxies []string
+	// Required 이면 신뢰하는 upstream 이 PROXY header 없이 연결할 때 거부한다.
+	// Http Proxy Server 가 gRPC Server 에 직접 연결할 수 있도록 loopback 연결은 항상 허용한다.
+	Required bool
+	// HeaderTimeout 은 PROXY header 를 읽을 때까지 기다리는 시간이다. (기본값: 5초)
+	HeaderTimeout time.Duration
+}
+
+// WithProxyProtocol 은 gRPC Server 와 Http Proxy Server 의 Listener 에서 PROXY protocol header 를 해석하여,
+// peer 정보와 Gateway 의 X-Forwarded-For 에 실제 client 주소가 전달되도록 한다.
+func WithProxyProtocol(proxyProtocolOptions ProxyProtocolOptions) Option {
+	return func(options *serverOptions) {
+		options.proxyProtocol = &proxyProtocolOptions
+	}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	trustedProxies []*net.IPNet
+	required       bool
+	headerTimeout  time.Duration
+}
+
+func newProxyProtocolListener(listener net.Listener, proxyProtocolOptions ProxyProtocolOptions) (*proxyProtocolListener, error) {
+	// 모든 peer 의 header 를 신뢰하면 누구나 source IP 를 위조할 수 있다.
+	if len(proxyProtocolOptions.TrustedProxies) == 0 {
+		return nil, errProxyProtocolNoTrustedProxy
+	}
+	trustedProxies, err := parseIPNets(proxyProtocolOptions.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	headerTimeout := proxyProtocolOptions.HeaderTimeout
+	if headerTimeout <= 0 {
+		headerTimeout = defaultProxyProtocolHeaderTimeout
+	}
+
+	return &proxyProtocolListener{
+		Listener:       listener,
+		trustedProxies: trustedProxies,
+		required:       proxyProtocolOptions.Required,
+		headerTimeout:  headerTimeout,
+	}, nil
+}
+
+func (pSelf *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := pSelf.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	ip := addrIP(conn.RemoteAddr())
+	if !containsIP(pSelf.trustedProxies, ip) {
+		return conn, nil
+	}
+
+	// PROXY header 는 Accept 를 막지 않도록 처음 읽을 때 해석한다.
+	return &proxyProtocolConn{
+		Conn:          conn,
+		reader:        bufio.NewReader(conn),
+		required:      pSelf.required && (ip == nil || !ip.IsLoopback()),
+		headerTimeout: pSelf.headerTimeout,
+	}, nil
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	reader        *bufio.Reader
+	required      bool
+	headerTimeout time.Duration
+
+	once       sync.Once
+	headerErr  error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+
+	// header 를 읽은 뒤 복원할 수 있도록 호출자가 설정한 read deadline 을 기록한다.
+	deadlineMutex sync.Mutex
+	readDeadline  time.Time
+}
+
+func (pSelf *proxyProtocolConn) SetDeadline(t time.Time) error {
+	pSelf.deadlineMutex.Lock()
+	defer pSelf.deadlineMutex.Unlock()
+	pSelf.readDeadline = t
+	return pSelf.Conn.SetDeadline(t)
+}
+
+func (pSelf *proxyProtocolConn) SetReadDeadline(t time.Time) error {
+	pSelf.deadlineMutex.Lock()
+	defer pSelf.deadlineMutex.Unlock()
+	pSelf.readDeadline = t
+	return pSelf.Conn.SetReadDeadline(t)
+}
+
+func (pSelf *proxyProtocolConn) Read(b []byte) (int, error) {
+	pSelf.once.Do(pSelf.readHeader)
+	if pSelf.headerErr != nil {
+		return 0, pSelf.headerErr
+	}
+	return pSelf.reader.Read(b)
+}
+
+func (pSelf *proxyProtocolConn) RemoteAddr() net.Addr {
+	pSelf.once.Do(pSelf.readHeader)
+	if pSelf.remoteAddr != nil {
+		return pSelf.remoteAddr
+	}
+	return pSelf.Conn.RemoteAddr()
+}
+
+func (pSelf *proxyProtocolConn) LocalAddr() net.Addr {
+	pSelf.once.Do(pSelf.readHeader)
+	if pSelf.localAddr != nil {
+		return pSelf.localAddr
+	}
+	return pSelf.Conn.LocalAddr()
+}
+
+func (pSelf *proxyProtocolConn) readHeader() {
+	pSelf.deadlineMutex.Lock()
+	headerDeadline := time.Now().Add(pSelf.headerTimeout)
+	if !pSelf.readDeadline.IsZero() && pSelf.readDeadline.Before(headerDeadline) {
+		headerDeadline = pSelf.readDeadline
+	}
+	_ = pSelf.Conn.SetReadDeadline(headerDeadline)
+	pSelf.deadlineMutex.Unlock()
+	defer func() {
+		pSelf.deadlineMutex.Lock()
+		defer pSelf.deadlineMutex.Unlock()
+		_ = pSelf.Conn.SetReadDeadline(pSelf.readDeadline)
+	}()
+
+	pSelf.remoteAddr, pSelf.localAddr, pSelf.headerErr = readProxyProtocolHeader(pSelf.reader)
+	if errors.Is(pSelf.headerErr, errProxyProtocolHeaderMissing) && !pSelf.required {
+		pSelf.headerErr = nil
+	}
+
+	if pSelf.headerErr != nil {
+		pSelf.headerErr = fmt.Errorf("proxy protocol from %s: %w", pSelf.Conn.RemoteAddr(), pSelf.headerErr)
+		_ = pSelf.Conn.Close()
+	}
+}
+
+// readProxyProtocolHeader 는 PROXY protocol header 를 읽어 원래의 source, destination 주소를 반환한다.
+// header 가 없으면 errProxyProtocolHeaderMissing 을 반환하고 reader 의 데이터는 소비하지 않는다.
+// LOCAL command 나 UNKNOWN protocol 인 경우 주소는 nil 이다.
+func readProxyProtocolHeader(reader *bufio.Reader) (source, destination net.Addr, err error) {
+	// v1 prefix 는 v2 signature 보다 짧으므로 v1 prefix 만큼 먼저 확인.
+	peeked, err := reader.Peek(len(proxyProtocolV1Prefix))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bytes.Equal(peeked, proxyProtocolV1Prefix) {
+		return readProxyProtocolV1(reader)
+	}
+
+	if !bytes.HasPrefix(proxyProtocolV2Signature, peeked) {
+		return nil, nil, errProxyProtocolHeaderMissing
+	}
+
+	peeked, err = reader.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(peeked, proxyProtocolV2Signature) {
+		return nil, nil, errProxyProtocolHeaderMissing
+	}
+
+	return readProxyProtocolV2(reader)
+}
+
+func readProxyProtocolV1(reader *bufio.Reader) (source, destination net.Addr, err error) {
+	var line []byte
+	for len(line) < proxyProtocolV1MaxLength {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errors.New("invalid proxy protocol v1 header")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("invalid proxy protocol v1 header: %q", line)
+	}
+
+	sourceIP := net.ParseIP(fields[2])
+	destinationIP := net.ParseIP(fields[3])
+	if sourceIP == nil || destinationIP == nil {
+		return nil, nil, fmt.Errorf("invalid proxy protocol v1 address: %q", line)
+	}
+
+	sourcePort, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid proxy protocol v1 port: %q", line)
+	}
+	destinationPort, err := strconv.ParseUint(fields[5], 10, 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid proxy protocol v1 port: %q", line)
+	}
+
+	return &net.TCPAddr{IP: sourceIP, Port: int(sourcePort)}, &net.TCPAddr{IP: destinationIP, Port: int(destinationPort)}, nil
+}
+
+func readProxyProtocolV2(reader *bufio.Reader) (source, destination net.Addr, err error) {
+	header := make([]byte, len(proxyProtocolV2Signature)+4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, nil, err
+	}
+
+	versionCommand := header[12]
+	familyProtocol := header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	if versionCommand>>4 != 2 {
+		return nil, nil, fmt.Errorf("invalid proxy protocol v2 version: %d", versionCommand>>4)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch versionCommand & 0x0F {
+	case 0x00:
+		// LOCAL: health check 등 proxy 자신의 연결.
+		return nil, nil, nil
+	case 0x01:
+		// PROXY
+	default:
+		return nil, nil, fmt.Errorf("invalid proxy protocol v2 command: %d", versionCommand&0x0F)
+	}
+
+	switch familyProtocol {
+	case 0x11, 0x12: // TCP, UDP over IPv4
+		if length < 12 {
+			return nil, nil, errors.New("invalid proxy protocol v2 IPv4 address length")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			&net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}, nil
+	case 0x21, 0x22: // TCP, UDP over IPv6
+		if length < 36 {
+			return nil, nil, errors.New("invalid proxy protocol v2 IPv6 address length")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			&net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}, nil
+	case 0x31, 0x32: // UNIX stream, datagram
+		if length < 216 {
+			return nil, nil, errors.New("invalid proxy protocol v2 unix address length")
+		}
+		return &net.UnixAddr{Name: string(bytes.TrimRight(payload[0:108], "\x00")), Net: "unix"},
+			&net.UnixAddr{Name: string(bytes.TrimRight(payload[108:216], "\x00")), Net: "unix"}, nil
+	default:
+		// UNSPEC
+		return nil, nil, nil
+	}
+}
+
+// parseIPNets 는 IP 또는 CIDR 목록을 해석한다.
+func parseIPNets(values []string) ([]*net.IPNet, error) {
+	ipNets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			ipNets = append(ipNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", value)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets, nil
+}
+
+func containsIP(ipNets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrIP 는 TCP 주소의 IP 를 반환한다. IP 가 없는 주소는 nil 이다.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
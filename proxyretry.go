@@ -0,0 +1,404 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProxyRetryBudget         = 0.2
+	defaultProxyMinRetriesPerSecond = 10
+	// maxReplayFrames, maxReplayBytes 는 다른 backend 로 다시 보내기 위해 보관하는 요청 메시지의 최대 수와 bytes 이다.
+	// backend 가 응답 header 를 보내기 전에 client 가 더 많은 메시지를 보내면 다시 시도하지 않는다.
+	// backend 로 보내지 못한 메시지가 이만큼 쌓이면 보낼 때까지 client 의 메시지를 읽지 않는다.
+	maxReplayFrames = 64
+	maxReplayBytes  = 4 << 20
+)
+
+// ProxyRetry 는 backend 가 응답하지 못한 요청을 Failover backend 로 다시 보내는 설정이다.
+// backend 가 응답 header 를 보내기 전에 실패한 요청만 다시 보내며, 이미 응답을 시작한 요청은 다시 보내지 않는다.
+type ProxyRetry struct {
+	// MaxAttempts 는 요청 하나를 보내는 최대 횟수이다. (기본값: Backend 와 Failover backend 의 수)
+	// Failover 가 없을 때 2 이상이면 같은 Backend 로 다시 보낸다.
+	MaxAttempts int
+	// Codes 는 다시 보낼 status code 이다. (기본값: Unavailable)
+	Codes []codes.Code
+	// Budget 은 요청 수 대비 다시 보낼 수 있는 요청의 비율이다. backend 장애 시 요청이 몇 배로 늘지 않도록 제한한다. (기본값: 0.2)
+	Budget float64
+	// MinRetriesPerSecond 는 요청이 적을 때도 Budget 과 별도로 허용하는 초당 재시도 수이다. (기본값: 10)
+	MinRetriesPerSecond float64
+	// HedgeDelay 가 0 보다 크면 IdempotentMethods 의 요청은 HedgeDelay 안에 응답이 없을 때 다음 backend 로도 보내고 먼저 온 응답을 사용한다.
+	HedgeDelay time.Duration
+	// IdempotentMethods 는 여러 번 실행해도 되는 Method 의 prefix 이다. (ProxyRoute.Prefix 형식, e.g. "myapp.user.UserService/Get")
+	// 다른 Method 는 hedging 하지 않는다.
+	IdempotentMethods []string
+}
+
+// proxyRetry 는 기본값을 적용한 ProxyRetry 와 재시도 budget 이다.
+type proxyRetry struct {
+	maxAttempts int
+	codes       []codes.Code
+	hedgeDelay  time.Duration
+	idempotent  []string
+	budget      *retryBudget
+}
+
+func newProxyRetry(retry ProxyRetry) *proxyRetry {
+	if len(retry.Codes) == 0 {
+		retry.Codes = []codes.Code{codes.Unavailable}
+	}
+	if retry.Budget <= 0 {
+		retry.Budget = defaultProxyRetryBudget
+	}
+	if retry.MinRetriesPerSecond <= 0 {
+		retry.MinRetriesPerSecond = defaultProxyMinRetriesPerSecond
+	}
+
+	idempotent := make([]string, len(retry.IdempotentMethods))
+	for i, method := range retry.IdempotentMethods {
+		idempotent[i] = strings.TrimPrefix(method, "/")
+	}
+	return &proxyRetry{
+		maxAttempts: retry.MaxAttempts,
+		codes:       retry.Codes,
+		hedgeDelay:  retry.HedgeDelay,
+		idempotent:  idempotent,
+		budget:      newRetryBudget(retry.Budget, retry.MinRetriesPerSecond),
+	}
+}
+
+// retryable 은 err 로 끝난 요청을 다시 보낼 수 있는지 여부이다.
+func (pSelf *proxyRetry) retryable(err error) bool {
+	return slices.Contains(pSelf.codes, status.Code(err))
+}
+
+// hedgeable 은 method 를 hedging 할 수 있는지 여부이다.
+func (pSelf *proxyRetry) hedgeable(method string) bool {
+	if pSelf.hedgeDelay <= 0 {
+		return false
+	}
+	return slices.ContainsFunc(pSelf.idempotent, func(prefix string) bool {
+		return strings.HasPrefix(method, prefix)
+	})
+}
+
+// retryBudget 은 요청마다 ratio 만큼, 초마다 minPerSecond 만큼 채워지는 재시도 token 이다.
+type retryBudget struct {
+	ratio        float64
+	minPerSecond float64
+
+	mutex      sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newRetryBudget(ratio, minPerSecond float64) *retryBudget {
+	return &retryBudget{ratio: ratio, minPerSecond: minPerSecond, tokens: minPerSecond, lastRefill: time.Now()}
+}
+
+// deposit 은 요청 하나 만큼 token 을 채운다.
+func (pSelf *retryBudget) deposit() {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.refill(time.Now())
+	pSelf.tokens = math.Min(pSelf.tokens+pSelf.ratio, pSelf.capacity())
+}
+
+// withdraw 는 재시도 하나 만큼 token 을 사용한다. token 이 없으면 false 이다.
+func (pSelf *retryBudget) withdraw() bool {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.refill(time.Now())
+	if pSelf.tokens < 1 {
+		return false
+	}
+	pSelf.tokens--
+	return true
+}
+
+func (pSelf *retryBudget) refill(now time.Time) {
+	pSelf.tokens = math.Min(pSelf.tokens+now.Sub(pSelf.lastRefill).Seconds()*pSelf.minPerSecond, pSelf.capacity())
+	pSelf.lastRefill = now
+}
+
+// capacity 는 모아 둘 수 있는 token 수이다. 장애 직후 한 번에 너무 많이 재시도하지 않도록 10초 분량으로 제한한다.
+func (pSelf *retryBudget) capacity() float64 {
+	return math.Max(pSelf.minPerSecond, 1) * 10
+}
+
+// proxyAttempt 는 backend 로 요청을 한 번 보낸 stream 이다.
+type proxyAttempt struct {
+	// id 는 요청을 보낸 순번이다.
+	id     int
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+	// cSent 는 요청을 모두 보내면 nil, 보내지 못하면 error 를 받는다.
+	cSent  <-chan error
+	header metadata.MD
+	// err 는 응답 header 없이 끝난 stream 의 status 이다. 정상 종료이면 io.EOF 이다.
+	err error
+}
+
+// startAttempt 는 pool 의 backend 로 requests 를 보내고, 응답 header 를 받거나 실패하면 cResult 로 알린다.
+func startAttempt(ctx context.Context, id int, pool *backendPool, fullMethod string, requests *requestLog, cResult chan<- *proxyAttempt) *proxyAttempt {
+	ctx, cancel := context.WithCancel(ctx)
+	attempt := &proxyAttempt{id: id, cancel: cancel}
+	// backend 에 연결하는 동안 받은 메시지를 버리지 않도록 먼저 등록한다.
+	requests.register(id)
+
+	go func() {
+		// backend 에 연결 중이면 NewStream 이 기다리므로 Goroutine 에서 실행한다.
+		clientStream, err := pool.pick().NewStream(
+			outgoingMetadata(ctx),
+			&grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+			fullMethod,
+			grpc.ForceCodecV2(rawCodec{}),
+		)
+		if err != nil {
+			requests.unregister(id)
+			attempt.err = err
+			cResult <- attempt
+			return
+		}
+		attempt.stream = clientStream
+		attempt.cSent = requests.forward(ctx, id, clientStream)
+
+		// 응답 header 없이 끝난 stream 은 header 가 nil 이고, status 는 RecvMsg 로 받는다. (Trailers-Only)
+		attempt.header, _ = clientStream.Header()
+		if attempt.header == nil {
+			attempt.err = clientStream.RecvMsg(&rawFrame{})
+		}
+		cResult <- attempt
+	}()
+	return attempt
+}
+
+// errRequestNotReplayable 은 이미 버린 요청 메시지를 다시 보내려는 경우의 오류이다.
+var errRequestNotReplayable = status.Error(codes.Unavailable, "request messages are no longer available for retry")
+
+// requestLog 는 client 의 요청 메시지이다. 응답을 받을 backend 가 정해질 때까지 다른 backend 로 다시 보낼 수 있도록 보관한다.
+// 다시 보낼 수 없게 되면 (commit, overflow) 모든 backend 로 보낸 메시지는 버린다.
+type requestLog struct {
+	mutex  sync.Mutex
+	frames []*rawFrame
+	// base 는 frames[0] 의 순번이다.
+	base int
+	// size 는 frames 의 bytes 이다.
+	size     int
+	err      error
+	cChanged chan struct{}
+	// cConsumed 는 보관한 메시지를 버리면 닫힌다.
+	cConsumed chan struct{}
+	committed bool
+	// owner 는 commit 한 proxyAttempt 의 id 이다.
+	owner    int
+	overflow bool
+	// positions 는 요청을 보내고 있는 proxyAttempt 별 다음에 보낼 메시지의 순번이다.
+	positions map[int]int
+}
+
+// newRequestLog 는 client 의 요청을 읽기 시작한다. replay 가 아니면 (다시 보낼 수 없으면) 첫 backend 로 보낸 메시지는 보관하지 않는다.
+func newRequestLog(ctx context.Context, serverStream grpc.ServerStream, replay bool) *requestLog {
+	log := &requestLog{
+		cChanged:  make(chan struct{}),
+		cConsumed: make(chan struct{}),
+		committed: !replay,
+		positions: map[int]int{},
+	}
+	go log.read(ctx, serverStream)
+	return log
+}
+
+// read 는 client 의 요청이 끝날 때까지 메시지를 읽는다. 요청이 끝나면 err 는 io.EOF 이다.
+func (pSelf *requestLog) read(ctx context.Context, serverStream grpc.ServerStream) {
+	for {
+		if err := pSelf.waitCapacity(ctx); err != nil {
+			pSelf.mutex.Lock()
+			pSelf.err = err
+			pSelf.changed()
+			pSelf.mutex.Unlock()
+			return
+		}
+
+		frame := &rawFrame{}
+		err := serverStream.RecvMsg(frame)
+
+		pSelf.mutex.Lock()
+		if err != nil {
+			pSelf.err = err
+		} else {
+			pSelf.frames = append(pSelf.frames, frame)
+			pSelf.size += len(frame.data)
+			if !pSelf.committed && (pSelf.base+len(pSelf.frames) > maxReplayFrames || pSelf.size > maxReplayBytes) {
+				pSelf.overflow = true
+				pSelf.trim()
+			}
+		}
+		pSelf.changed()
+		pSelf.mutex.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// waitCapacity 는 다시 보낼 수 없는 요청에서 backend 로 보내지 못한 메시지가 많으면 보낼 때까지 기다린다.
+func (pSelf *requestLog) waitCapacity(ctx context.Context) error {
+	for {
+		pSelf.mutex.Lock()
+		full := (pSelf.committed || pSelf.overflow) && (len(pSelf.frames) >= maxReplayFrames || pSelf.size >= maxReplayBytes)
+		cConsumed := pSelf.cConsumed
+		pSelf.mutex.Unlock()
+		if !full {
+			return nil
+		}
+
+		select {
+		case <-cConsumed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// changed 는 메시지를 기다리는 backend 에 알린다. mutex 를 잠근 상태에서 호출한다.
+func (pSelf *requestLog) changed() {
+	close(pSelf.cChanged)
+	pSelf.cChanged = make(chan struct{})
+}
+
+// trim 은 다시 보낼 수 없으면 모든 backend 로 보낸 메시지를 버린다. mutex 를 잠근 상태에서 호출한다.
+func (pSelf *requestLog) trim() {
+	end := pSelf.base + len(pSelf.frames)
+	switch {
+	case pSelf.committed:
+		if position, ok := pSelf.positions[pSelf.owner]; ok {
+			end = position
+		}
+	case pSelf.overflow:
+		for _, position := range pSelf.positions {
+			end = min(end, position)
+		}
+	default:
+		return
+	}
+	if end <= pSelf.base {
+		return
+	}
+
+	dropped := end - pSelf.base
+	for i := range dropped {
+		pSelf.size -= len(pSelf.frames[i].data)
+		pSelf.frames[i] = nil
+	}
+	pSelf.frames = pSelf.frames[dropped:]
+	pSelf.base = end
+	close(pSelf.cConsumed)
+	pSelf.cConsumed = make(chan struct{})
+}
+
+// replayable 은 요청을 다른 backend 로 다시 보낼 수 있는지 여부이다.
+func (pSelf *requestLog) replayable() bool {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	return !pSelf.committed && !pSelf.overflow
+}
+
+// commit 은 id 의 backend 가 응답을 받을 backend 로 정해졌음을 기록한다. 이후 보낸 메시지는 보관하지 않는다.
+func (pSelf *requestLog) commit(id int) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.committed, pSelf.owner = true, id
+	pSelf.trim()
+}
+
+// register 는 id 의 backend 가 요청을 보내기 시작함을 기록한다. 보내기 전의 메시지는 버리지 않는다.
+func (pSelf *requestLog) register(id int) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.positions[id] = pSelf.base
+}
+
+// unregister 는 id 의 backend 가 더 이상 요청을 보내지 않음을 기록한다.
+func (pSelf *requestLog) unregister(id int) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	delete(pSelf.positions, id)
+	pSelf.trim()
+}
+
+// next 는 id 의 backend 로 보낼 i 번째 메시지이다. 아직 받지 않았으면 받을 때까지 기다린다.
+func (pSelf *requestLog) next(ctx context.Context, id, i int) (*rawFrame, error) {
+	for {
+		pSelf.mutex.Lock()
+		if pSelf.committed && pSelf.owner != id {
+			// commit 하지 않은 backend 의 요청.
+			pSelf.mutex.Unlock()
+			return nil, context.Canceled
+		}
+		if i < pSelf.base {
+			pSelf.mutex.Unlock()
+			return nil, errRequestNotReplayable
+		}
+		if i < pSelf.base+len(pSelf.frames) {
+			frame := pSelf.frames[i-pSelf.base]
+			pSelf.positions[id] = i + 1
+			pSelf.trim()
+			pSelf.mutex.Unlock()
+			return frame, nil
+		}
+		if pSelf.err != nil {
+			err := pSelf.err
+			pSelf.mutex.Unlock()
+			return nil, err
+		}
+		cChanged := pSelf.cChanged
+		pSelf.mutex.Unlock()
+
+		select {
+		case <-cChanged:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// forward 는 요청 메시지를 처음부터 backend 로 보내고, 요청이 끝나면 backend 에 CloseSend 한다.
+func (pSelf *requestLog) forward(ctx context.Context, id int, clientStream grpc.ClientStream) <-chan error {
+	cDone := make(chan error, 1)
+	go func() {
+		defer pSelf.unregister(id)
+		for i := 0; ; i++ {
+			frame, err := pSelf.next(ctx, id, i)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					cDone <- clientStream.CloseSend()
+					return
+				}
+				cDone <- err
+				return
+			}
+			if err := clientStream.SendMsg(frame); err != nil {
+				// backend 가 stream 을 끝낸 경우, 원인은 응답 쪽에서 RecvMsg 로 받는다.
+				if errors.Is(err, io.EOF) {
+					cDone <- nil
+					return
+				}
+				cDone <- err
+				return
+			}
+		}
+	}()
+	return cDone
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitSweepInterval 은 오래 요청이 없는 principal 의 상태를 정리하는 주기이다.
+	rateLimitSweepInterval = time.Minute
+
+	defaultRateLimitMaxPrincipals = 100000
+)
+
+// RateLimit 은 principal 하나의 요청 제한이다.
+type RateLimit struct {
+	// Rate 는 초당 요청 수이다. 넘는 요청은 ResourceExhausted 로 거부한다. 0 이면 제한하지 않는다.
+	Rate float64 `json:"rate" yaml:"rate" toml:"rate"`
+	// Burst 는 한 번에 허용하는 요청 수이다. (기본값: Rate 를 올림한 값)
+	Burst int `json:"burst" yaml:"burst" toml:"burst"`
+}
+
+// RateLimitOptions 는 인증한 principal (사용자, API key, client 인증서) 별 요청 제한 설정이다.
+type RateLimitOptions struct {
+	// Principal 은 요청의 principal 이다. (기본값: DefaultPrincipal)
+	// client 가 마음대로 바꿀 수 있는 값 (e.g. header) 을 사용하면 제한을 피할 수 있으므로 인증한 값만 반환한다.
+	// 찾지 못한 요청은 client IP 별로 Default 를 적용한다.
+	Principal func(ctx context.Context) (string, bool)
+	// Default 는 Overrides, Lookup 에 없는 principal 의 제한이다.
+	Default RateLimit
+	// Overrides 는 principal 별 제한이다. (e.g. {"key:batch": {Rate: 1000}})
+	// 설정 파일을 읽는 library (e.g. viper, koanf) 가 key 를 소문자로 바꾸므로, 대소문자가 일치하는 key 가 없으면 대소문자를 구분하지 않고 찾는다.
+	Overrides map[string]RateLimit
+	// Lookup 이 있으면 Overrides 에 없는 principal 의 제한을 찾는다. (e.g. DB 의 요금제)
+	// principal 의 첫 요청과 상태를 정리한 뒤의 첫 요청에서만 호출한다.
+	Lookup func(ctx context.Context, principal string) (RateLimit, bool)
+	// MaxPrincipals 는 상태를 유지하는 principal 과 client IP 의 최대 수이다. 넘으면 임의의 principal 의 상태를 지운다. (기본값: 100000)
+	MaxPrincipals int
+}
+
+// WithPrincipalRateLimit 은 요청을 인증한 principal 별로 초당 요청 수를 제한한다.
+// 서명, client 인증서를 확인한 뒤에 실행하므로 WithRequestSignature, WithTLSIdentity 와 함께 사용할 수 있다.
+// health check, reflection 같은 기본 Service 에는 적용하지 않는다. 거부한 요청은 rate_limit_rejected 지표로 확인한다.
+func WithPrincipalRateLimit(rateLimitOptions RateLimitOptions) Option {
+	return func(options *serverOptions) {
+		if rateLimitOptions.Principal == nil {
+			rateLimitOptions.Principal = DefaultPrincipal
+		}
+		if rateLimitOptions.MaxPrincipals <= 0 {
+			rateLimitOptions.MaxPrincipals = defaultRateLimitMaxPrincipals
+		}
+		options.rateLimiter = &rateLimiter{options: rateLimitOptions, foldedOverrides: foldRateLimits(rateLimitOptions.Overrides), states: map[string]*rateLimitState{}}
+	}
+}
+
+// DefaultPrincipal 은 요청을 인증한 주체이다. 서명 key ID (key:), client 인증서 (cert:) 순서로 찾는다.
+// client 인증서는 URI SAN (e.g. SPIFFE ID) 이 있으면 URI, 없으면 Subject 를 사용한다.
+// tenant ID 는 client 가 보낸 header 이므로 principal 로 사용하지 않는다.
+func DefaultPrincipal(ctx context.Context) (string, bool) {
+	if keyID := SignatureKeyID(ctx); len(keyID) > 0 {
+		return "key:" + keyID, true
+	}
+	identity, ok := TLSIdentityFromContext(ctx)
+	if !ok {
+		identity, ok = peerTLSIdentity(ctx)
+	}
+	if ok {
+		if len(identity.URIs) > 0 {
+			return "cert:" + identity.URIs[0].String(), true
+		}
+		return "cert:" + identity.Subject, true
+	}
+	return "", false
+}
+
+// rateLimiter 는 principal 별 token bucket 이다.
+type rateLimiter struct {
+	mutex   sync.Mutex
+	options RateLimitOptions
+	// foldedOverrides 는 소문자로 바꾼 principal 의 Overrides 이다.
+	foldedOverrides map[string]RateLimit
+	states          map[string]*rateLimitState
+	lastSweep       time.Time
+}
+
+// foldRateLimits 는 key 를 소문자로 바꾼 overrides 이다.
+func foldRateLimits(overrides map[string]RateLimit) map[string]RateLimit {
+	folded := make(map[string]RateLimit, len(overrides))
+	for principal, limit := range overrides {
+		folded[strings.ToLower(principal)] = limit
+	}
+	return folded
+}
+
+// rateLimitState 는 principal 하나의 token bucket 이다.
+type rateLimitState struct {
+	limit RateLimit
+
+	mutex      sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+// setLimits 는 Default, Overrides 를 바꾼다. 이미 요청한 principal 도 다음 요청부터 바뀐 제한을 적용한다.
+func (pSelf *rateLimiter) setLimits(defaultLimit RateLimit, overrides map[string]RateLimit) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.options.Default, pSelf.options.Overrides = defaultLimit, overrides
+	pSelf.foldedOverrides = foldRateLimits(overrides)
+	clear(pSelf.states)
+}
+
+// admit 은 요청의 principal 에서 요청 하나를 뺀다.
+func (pSelf *rateLimiter) admit(ctx context.Context) error {
+	principal, ok := pSelf.options.Principal(ctx)
+	if !ok {
+		// Gateway 를 거친 요청이 모두 같은 bucket 을 쓰지 않도록 Gateway 가 확인한 client 주소를 사용.
+		host, forwarded := clientHost(ctx)
+		if !forwarded {
+			host = peerHost(ctx)
+		}
+		principal = "ip:" + host
+	}
+
+	now := time.Now()
+	if !pSelf.state(ctx, principal, ok, now).take(now) {
+		addMetric("rate_limit_rejected", 1)
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+// state 는 principal 의 token bucket 이다. authenticated 가 아니면 Overrides, Lookup 을 찾지 않는다.
+func (pSelf *rateLimiter) state(ctx context.Context, principal string, authenticated bool, now time.Time) *rateLimitState {
+	pSelf.mutex.Lock()
+	if state, ok := pSelf.states[principal]; ok {
+		pSelf.mutex.Unlock()
+		return state
+	}
+	pSelf.sweep(now)
+	limit, found := pSelf.options.Overrides[principal]
+	if !found {
+		limit, found = pSelf.foldedOverrides[strings.ToLower(principal)]
+	}
+	lookup := pSelf.options.Lookup
+	if !found {
+		limit = pSelf.options.Default
+	}
+	pSelf.mutex.Unlock()
+
+	// Lookup 은 외부 저장소를 조회할 수 있으므로 lock 밖에서 호출.
+	if authenticated && !found && lookup != nil {
+		if lookupLimit, ok := lookup(ctx, principal); ok {
+			limit = lookupLimit
+		}
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.Rate))
+	}
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if state, ok := pSelf.states[principal]; ok {
+		return state
+	}
+	if len(pSelf.states) >= pSelf.options.MaxPrincipals {
+		// 지운 principal 은 다음 요청에서 가득 찬 bucket 으로 다시 시작한다.
+		for evicted := range pSelf.states {
+			delete(pSelf.states, evicted)
+			break
+		}
+	}
+	state := &rateLimitState{limit: limit, tokens: float64(limit.Burst), lastRefill: now}
+	pSelf.states[principal] = state
+	return state
+}
+
+// sweep 은 bucket 이 다시 가득 찰 만큼 요청이 없던 principal 의 상태를 지운다. 지워도 다음 요청의 결과는 같다.
+func (pSelf *rateLimiter) sweep(now time.Time) {
+	if now.Sub(pSelf.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	pSelf.lastSweep = now
+	for principal, state := range pSelf.states {
+		state.mutex.Lock()
+		idle := state.limit.Rate <= 0 || now.Sub(state.lastRefill).Seconds()*state.limit.Rate >= float64(state.limit.Burst)
+		state.mutex.Unlock()
+		if idle {
+			delete(pSelf.states, principal)
+		}
+	}
+}
+
+// take 는 요청 하나를 token bucket 에서 뺀다. token 이 없으면 false 이다.
+func (pSelf *rateLimitState) take(now time.Time) bool {
+	if pSelf.limit.Rate <= 0 {
+		return true
+	}
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.tokens = math.Min(float64(pSelf.limit.Burst), pSelf.tokens+now.Sub(pSelf.lastRefill).Seconds()*pSelf.limit.Rate)
+	pSelf.lastRefill = now
+	if pSelf.tokens < 1 {
+		return false
+	}
+	pSelf.tokens--
+	return true
+}
+
+// peerHost 는 client 주소의 host 이다. unix socket 처럼 host 가 없으면 주소 전체이다.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// clientHost 는 요청한 client 의 host 이다.
+// 같은 host 의 gRPC Gateway, WebTransport, bridge 를 거친 요청은 그 Server 가 확인한 client 주소 (x-forwarded-for 의 마지막 주소) 를 사용한다.
+// client 주소를 전달하지 않은 local 요청은 false 이다.
+func clientHost(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "", false
+	}
+	if !localAddr(p.Addr) {
+		return peerHost(ctx), true
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := md.Get("x-forwarded-for")
+	if len(forwarded) == 0 {
+		return "", false
+	}
+	// 앞의 주소는 client 가 보낸 header 이므로 Gateway 가 덧붙인 마지막 주소만 사용.
+	hosts := strings.Split(forwarded[len(forwarded)-1], ",")
+	host := strings.TrimSpace(hosts[len(hosts)-1])
+	return host, len(host) > 0
+}
+
+func (pSelf *rateLimiter) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if builtinMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	if err := pSelf.admit(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (pSelf *rateLimiter) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if builtinMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	if err := pSelf.admit(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
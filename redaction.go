@@ -0,0 +1,269 @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"slices"
+	"sync"
+)
+
+// anyMessageName 은 다른 message 를 담는 google.protobuf.Any 의 이름이다.
+const anyMessageName protoreflect.FullName = "google.protobuf.Any"
+
+// RedactionOptions 는 호출한 client 의 role 에 따라 응답 message 의 field 를 지우는 설정이다.
+// field 는 Fields, FieldRoles, DebugRedact 순서로 확인하며, 볼 수 있는 role 이 하나도 없는 client 에게는 지운다.
+type RedactionOptions struct {
+	// Roles 는 요청한 client 의 role (또는 scope) 이다. (e.g. 인증 token 의 claim, TLSIdentity 에서 찾은 role)
+	Roles func(ctx context.Context) []string
+	// Fields 는 field 의 전체 이름별로 볼 수 있는 role 이다. 비어 있으면 누구도 볼 수 없다.
+	// (e.g. {"myapp.v1.User.email": {"admin", "support"}})
+	Fields map[string][]string
+	// FieldRoles 가 있으면 Fields 에 없는 field 를 볼 수 있는 role 을 찾는다. field 에 custom option 으로 지정한 role 을 읽을 때 사용한다.
+	// (e.g. proto.GetExtension(field.Options(), mypb.E_VisibleTo).([]string))
+	FieldRoles func(field protoreflect.FieldDescriptor) ([]string, bool)
+	// DebugRedact 가 true 이면 [debug_redact = true] 인 field 는 DebugRedactRoles 만 볼 수 있다.
+	DebugRedact      bool
+	DebugRedactRoles []string
+	// Mask 가 있으면 문자열 field 는 지우는 대신 Mask 로 바꾼다. (e.g. "****")
+	Mask string
+}
+
+// WithResponseRedaction 은 Handler 의 응답에서 client 의 role 로 볼 수 없는 field 를 지운다. 하나의 API 로 권한이 다른 client 에게 응답할 수 있다.
+// 지울 field 가 있는 message 는 복사한 뒤 지우므로 Handler 가 응답한 message 는 바뀌지 않는다. gRPC Gateway 로 받은 요청에도 적용한다.
+// google.protobuf.Any 에 담긴 message 도 type 을 protoregistry.GlobalTypes 에서 찾을 수 있으면 확인하고, 지운 뒤 다시 담는다.
+// WithProxy 로 backend 에 그대로 전달하는 응답은 decode 하지 않으므로 지우지 않는다. backend 에서 지워야 한다.
+// 지운 field 는 response_fields_redacted 지표로 확인한다.
+func WithResponseRedaction(redactionOptions RedactionOptions) Option {
+	return func(options *serverOptions) {
+		options.redactor = &redactor{options: redactionOptions, plans: map[protoreflect.FullName]*redactionPlan{}}
+	}
+}
+
+// redactor 는 message 종류별로 확인할 field 를 기억한다.
+type redactor struct {
+	options RedactionOptions
+
+	mutex sync.Mutex
+	plans map[protoreflect.FullName]*redactionPlan
+}
+
+// redactionPlan 은 message 하나에서 확인할 field 이다.
+type redactionPlan struct {
+	// restricted 는 볼 수 있는 role 이 정해진 field 이다.
+	restricted []restrictedField
+	// nested 는 지울 field 가 있을 수 있는 message 형식의 field 이다.
+	nested []protoreflect.FieldDescriptor
+	// building 은 plan 을 만드는 중이다. 재귀 message 는 지울 field 가 있다고 본다.
+	building bool
+	// any 는 google.protobuf.Any 이다. 담긴 message 에 지울 field 가 있을 수 있다.
+	any bool
+}
+
+type restrictedField struct {
+	field protoreflect.FieldDescriptor
+	roles []string
+}
+
+func (pSelf *redactionPlan) empty() bool {
+	return !pSelf.building && !pSelf.any && len(pSelf.restricted) == 0 && len(pSelf.nested) == 0
+}
+
+// fieldRoles 는 field 를 볼 수 있는 role 이다. 제한하지 않는 field 이면 false 이다.
+func (pSelf *redactor) fieldRoles(field protoreflect.FieldDescriptor) ([]string, bool) {
+	if roles, ok := pSelf.options.Fields[string(field.FullName())]; ok {
+		return roles, true
+	}
+	if pSelf.options.FieldRoles != nil {
+		if roles, ok := pSelf.options.FieldRoles(field); ok {
+			return roles, true
+		}
+	}
+	if pSelf.options.DebugRedact {
+		if fieldOptions, ok := field.Options().(*descriptorpb.FieldOptions); ok && fieldOptions.GetDebugRedact() {
+			return pSelf.options.DebugRedactRoles, true
+		}
+	}
+	return nil, false
+}
+
+// plan 은 message 의 plan 이다. 처음 보는 message 이면 만든다.
+func (pSelf *redactor) plan(descriptor protoreflect.MessageDescriptor) *redactionPlan {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	return pSelf.buildPlan(descriptor)
+}
+
+func (pSelf *redactor) buildPlan(descriptor protoreflect.MessageDescriptor) *redactionPlan {
+	if plan, ok := pSelf.plans[descriptor.FullName()]; ok {
+		return plan
+	}
+
+	plan := &redactionPlan{building: true, any: descriptor.FullName() == anyMessageName}
+	pSelf.plans[descriptor.FullName()] = plan
+	fields := descriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if roles, ok := pSelf.fieldRoles(field); ok {
+			plan.restricted = append(plan.restricted, restrictedField{field: field, roles: roles})
+		}
+		message := field.Message()
+		if field.IsMap() {
+			message = field.MapValue().Message()
+		}
+		if message != nil && !pSelf.buildPlan(message).empty() {
+			plan.nested = append(plan.nested, field)
+		}
+	}
+	plan.building = false
+	return plan
+}
+
+// redact 는 resp 에서 roles 로 볼 수 없는 field 를 지운 복사본이다. 지울 field 가 없으면 resp 를 그대로 반환한다.
+func (pSelf *redactor) redact(ctx context.Context, resp any) any {
+	message, ok := resp.(proto.Message)
+	if !ok || message == nil || pSelf.plan(message.ProtoReflect().Descriptor()).empty() {
+		return resp
+	}
+	var roles []string
+	if pSelf.options.Roles != nil {
+		roles = pSelf.options.Roles(ctx)
+	}
+	if !pSelf.hidden(message.ProtoReflect(), roles) {
+		return resp
+	}
+
+	message = proto.Clone(message)
+	pSelf.walk(message.ProtoReflect(), roles)
+	return message
+}
+
+// hidden 은 message 에 roles 로 볼 수 없는 field 가 있는지 여부이다.
+func (pSelf *redactor) hidden(message protoreflect.Message, roles []string) bool {
+	found := false
+	pSelf.visit(message, roles, func(protoreflect.Message, restrictedField) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// walk 는 message 에서 roles 로 볼 수 없는 field 를 지운다.
+func (pSelf *redactor) walk(message protoreflect.Message, roles []string) {
+	pSelf.visit(message, roles, func(message protoreflect.Message, restricted restrictedField) bool {
+		field := restricted.field
+		if len(pSelf.options.Mask) > 0 && field.Kind() == protoreflect.StringKind && field.Cardinality() != protoreflect.Repeated {
+			message.Set(field, protoreflect.ValueOfString(pSelf.options.Mask))
+		} else {
+			message.Clear(field)
+		}
+		addMetric("response_fields_redacted", 1)
+		return true
+	})
+}
+
+// visit 은 message 와 하위 message 에서 roles 로 볼 수 없고 값이 있는 field 마다 f 를 호출한다. f 가 false 를 반환하면 멈춘다.
+func (pSelf *redactor) visit(message protoreflect.Message, roles []string, f func(protoreflect.Message, restrictedField) bool) bool {
+	plan := pSelf.plan(message.Descriptor())
+	if plan.any {
+		return pSelf.visitAny(message, roles, f)
+	}
+	for _, restricted := range plan.restricted {
+		if !message.Has(restricted.field) || slices.ContainsFunc(restricted.roles, func(role string) bool { return slices.Contains(roles, role) }) {
+			continue
+		}
+		if !f(message, restricted) {
+			return false
+		}
+	}
+	for _, field := range plan.nested {
+		if !message.Has(field) {
+			continue
+		}
+		value := message.Get(field)
+		switch {
+		case field.IsList():
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				if !pSelf.visit(list.Get(i).Message(), roles, f) {
+					return false
+				}
+			}
+		case field.IsMap():
+			next := true
+			value.Map().Range(func(_ protoreflect.MapKey, mapValue protoreflect.Value) bool {
+				next = pSelf.visit(mapValue.Message(), roles, f)
+				return next
+			})
+			if !next {
+				return false
+			}
+		default:
+			if !pSelf.visit(value.Message(), roles, f) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// visitAny 는 google.protobuf.Any 에 담긴 message 에서 f 를 호출한다. f 가 field 를 지우면 담긴 message 를 다시 담는다.
+// type 을 찾을 수 없거나 decode 할 수 없는 message 는 확인하지 않는다.
+func (pSelf *redactor) visitAny(message protoreflect.Message, roles []string, f func(protoreflect.Message, restrictedField) bool) bool {
+	fields := message.Descriptor().Fields()
+	typeURL, value := fields.ByName("type_url"), fields.ByName("value")
+	messageType, err := protoregistry.GlobalTypes.FindMessageByURL(message.Get(typeURL).String())
+	if err != nil {
+		return true
+	}
+	contained := messageType.New()
+	if err := proto.Unmarshal(message.Get(value).Bytes(), contained.Interface()); err != nil {
+		return true
+	}
+
+	changed := false
+	next := pSelf.visit(contained, roles, func(message protoreflect.Message, restricted restrictedField) bool {
+		changed = true
+		return f(message, restricted)
+	})
+	// f 가 멈추면 (hidden) message 를 바꾸지 않는다.
+	if !next || !changed {
+		return next
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(contained.Interface())
+	if err != nil {
+		// 지우기 전의 값을 보내지 않도록 담긴 message 를 비운다.
+		message.Clear(value)
+		return true
+	}
+	message.Set(value, protoreflect.ValueOfBytes(data))
+	return true
+}
+
+func (pSelf *redactor) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil || builtinMethod(info.FullMethod) {
+		return resp, err
+	}
+	return pSelf.redact(ctx, resp), nil
+}
+
+func (pSelf *redactor) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if builtinMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	return handler(srv, &redactingServerStream{ServerStream: ss, redactor: pSelf})
+}
+
+// redactingServerStream 은 보내는 message 에서 볼 수 없는 field 를 지운다.
+type redactingServerStream struct {
+	grpc.ServerStream
+	redactor *redactor
+}
+
+func (pSelf *redactingServerStream) SendMsg(m any) error {
+	return pSelf.ServerStream.SendMsg(pSelf.redactor.redact(pSelf.Context(), m))
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ListenerOptions 는 AddListener 로 추가하는 Listener 의 설정이다.
+type ListenerOptions struct {
+	// Handler 가 nil 이면 gRPC Service 를 제공하고, nil 이 아니면 HTTP 요청을 처리한다. (e.g. admin, metrics)
+	Handler http.Handler
+	// TLS 가 nil 이면 평문으로 연결을 수락한다.
+	TLS *tls.Config
+	// Authorize 가 있으면 Handler 의 모든 요청에 적용한다. (RequireAuthorization 참고)
+	// e.g. BasicAuth, BearerToken, mTLS 전용이면 ClientCertificate
+	Authorize func(r *http.Request) bool
+}
+
+// namedListener 는 이름으로 관리하는 Listener 이다.
+type namedListener struct {
+	name     string
+	network  string
+	address  string
+	port     int
+	listener net.Listener
+	handler  http.Handler
+	// tlsConfig 는 연결을 수락할 TLS 설정이다. nil 이면 평문이다.
+	tlsConfig *tls.Config
+
+	httpServer *http.Server
+}
+
+// AddListener 는 name 으로 구분하는 Listener 를 추가한다. Run 을 호출하기 전에 추가해야 한다.
+// address 는 network 에 맞는 전체 주소이다. (e.g. "127.0.0.1:9090", "/run/app/admin.sock")
+// Listener 는 바로 bind 하므로 권한을 전환하기 전에 낮은 port 도 사용할 수 있다.
+func (pSelf *GrpcServer) AddListener(name, network, address string, opts ListenerOptions) error {
+	if len(name) == 0 {
+		return errors.New("listener name is empty")
+	}
+	if pSelf.findListener(name) != nil {
+		return fmt.Errorf("listener %q already exists", name)
+	}
+	if pSelf.options.fips != nil {
+		tlsConfig, err := fipsTLSConfig(opts.TLS)
+		if err != nil {
+			return fmt.Errorf("listener %q: FIPS mode: %w", name, err)
+		}
+		opts.TLS = tlsConfig
+	}
+	opts.TLS = pSelf.options.sessionTicketKeys.apply(pSelf.options.revocationChecker.apply(opts.TLS))
+	if opts.Handler == nil {
+		opts.TLS = pSelf.options.alpn.grpc(opts.TLS)
+	}
+	if opts.Handler == nil && opts.Authorize != nil {
+		return fmt.Errorf("listener %q: Authorize requires Handler", name)
+	}
+	if !pSelf.hasListenerCredentials && opts.Handler == nil && opts.TLS != nil {
+		return fmt.Errorf("listener %q: gRPC listener with TLS requires WithTLS or WithListener with TLS", name)
+	}
+
+	host, port, err := splitAddress(network, address)
+	if err != nil {
+		return fmt.Errorf("listener %q: %w", name, err)
+	}
+	if err := checkAddressFamily(network, host); err != nil {
+		return fmt.Errorf("listener %q: %w", name, err)
+	}
+
+	l, err := listen(network, host, port, pSelf.options)
+	if err != nil {
+		return fmt.Errorf("listener %q: %w", name, err)
+	}
+	if l, err = pSelf.options.wrapListener(l); err != nil {
+		return fmt.Errorf("listener %q: %w", name, err)
+	}
+
+	port = boundPort(l.Addr(), port)
+	namedListener := &namedListener{
+		name:      name,
+		network:   network,
+		address:   joinAddress(network, host, port),
+		port:      port,
+		listener:  l,
+		handler:   opts.Handler,
+		tlsConfig: opts.TLS,
+	}
+	if opts.Handler != nil && opts.Authorize != nil {
+		opts.Handler = RequireAuthorization(opts.Handler, opts.Authorize)
+		namedListener.handler = opts.Handler
+	}
+	if opts.Handler != nil {
+		namedListener.httpServer = &http.Server{
+			Handler:     opts.Handler,
+			TLSConfig:   opts.TLS,
+			IdleTimeout: pSelf.options.idleTimeout,
+		}
+	} else if pSelf.hasListenerCredentials {
+		namedListener.listener = newCredentialsListener(l, opts.TLS)
+	}
+
+	pSelf.namedListeners = append(pSelf.namedListeners, namedListener)
+	return nil
+}
+
+// splitAddress 는 전체 주소를 address 와 port 로 나눈다.
+func splitAddress(network, address string) (string, int, error) {
+	if strings.EqualFold("unix", network) {
+		return address, 0, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port: %s", portStr)
+	}
+	return host, port, nil
+}
+
+func (pSelf *GrpcServer) findListener(name string) *namedListener {
+	for _, l := range pSelf.namedListeners {
+		if l.name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+func (pSelf *GrpcServer) serveNamedListener(l *namedListener) {
+	gLogger.Printf("Start %s listener on %s, %s\n", l.name, l.network, l.address)
+
+	if l.httpServer == nil {
+		pSelf.serve(l.listener)
+		return
+	}
+
+	var err error
+	if l.httpServer.TLSConfig != nil {
+		err = l.httpServer.ServeTLS(l.listener, "", "")
+	} else {
+		err = l.httpServer.Serve(l.listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if pSelf.shuttingDown.Load() {
+			return
+		}
+		gLogger.Fatalf("failed to serve %s listener: %v", l.name, err)
+	}
+}
+
+// shutdownNamedListeners 는 HTTP 요청을 처리하는 Listener 의 Server 를 종료한다.
+// gRPC Service 를 제공하는 Listener 는 gRPC Server 와 함께 종료된다.
+func (pSelf *GrpcServer) shutdownNamedListeners(ctx context.Context) {
+	for _, l := range pSelf.namedListeners {
+		if l.httpServer == nil {
+			continue
+		}
+		if err := l.httpServer.Shutdown(ctx); err != nil {
+			gLogger.Printf("Failed to shut down %s listener gracefully: %v\n", l.name, err)
+		}
+	}
+}
+
+// closeNamedListeners 는 모든 Listener 를 닫고 unix socket 파일을 삭제한다.
+func (pSelf *GrpcServer) closeNamedListeners() {
+	for _, l := range pSelf.namedListeners {
+		if err := l.listener.Close(); err != nil {
+			gLogger.Printf("Failed to close %s listener: %v\n", l.name, err)
+		}
+		if strings.EqualFold("unix", l.network) {
+			removeUnixSocket(l.address)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ReloadOptions 는 실행 중에 설정을 다시 읽는 방법이다.
+// 연결 수 제한, trusted proxy 목록, TLS 인증서는 다시 시작하지 않고 적용하며,
+// 그 외의 변경은 ReloadReport.RestartRequired 로 알린다.
+type ReloadOptions struct {
+	// Signal 을 받으면 설정을 다시 읽는다. (기본값: SIGHUP)
+	Signal os.Signal
+	// WatchInterval 이 0 보다 크면 NewFromConfig 의 설정 파일이 바뀌었는지 주기적으로 확인한다.
+	WatchInterval time.Duration
+	// Load 는 새 설정을 읽는다. nil 이면 NewFromConfig 의 설정 파일을 다시 읽는다.
+	Load func() (*Config, error)
+	// OnReload 는 설정을 다시 읽은 뒤 호출된다. (e.g. log level 등 application 설정 적용)
+	OnReload func(report ReloadReport)
+}
+
+// ReloadReport 는 설정을 다시 읽은 결과이다.
+type ReloadReport struct {
+	// Config 는 새로 읽은 설정이다. Err 가 있으면 nil 이다.
+	Config *Config
+	// Changed 는 적용한 변경이다. (e.g. "limits.max_connections: 100 -> 200")
+	Changed []string
+	// RestartRequired 는 다시 시작해야 적용되는 변경이다.
+	RestartRequired []string
+	// Diff 는 이전 설정에서 바뀐 모든 항목이다. redact 항목의 값은 가린다.
+	Diff []ConfigChange
+	Err  error
+}
+
+// WithReload 는 Signal 이나 설정 파일의 변경으로 설정을 다시 읽도록 한다.
+// NewFromConfig, NewWithConfig 로 생성한 Server 에서 사용한다.
+func WithReload(reloadOptions ReloadOptions) Option {
+	return func(options *serverOptions) {
+		if reloadOptions.Signal == nil {
+			reloadOptions.Signal = syscall.SIGHUP
+		}
+		options.reload = &reloadOptions
+	}
+}
+
+// withConfig 는 Server 를 생성한 설정을 기록한다.
+func withConfig(config *Config, path string) Option {
+	return func(options *serverOptions) {
+		options.config = config
+		if len(path) > 0 {
+			options.configPath = path
+		}
+	}
+}
+
+// prepareReload 는 다시 읽은 설정을 적용할 수 있도록 연결 수 제한을 항상 사용한다.
+func (pSelf *serverOptions) prepareReload() {
+	if pSelf.reload == nil && pSelf.kubernetesWatch == nil {
+		return
+	}
+	if pSelf.connectionLimiter == nil {
+		pSelf.connectionLimiter = &connectionLimiter{}
+	}
+	if pSelf.ipConnectionLimiter == nil {
+		pSelf.ipConnectionLimiter = &ipConnectionLimiter{connections: map[string]int{}}
+	}
+}
+
+// tlsStore 는 다시 읽을 수 있는 TLS 인증서이다.
+type tlsStore struct {
+	certificate atomic.Pointer[tls.Certificate]
+}
+
+func (pSelf *tlsStore) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return pSelf.certificate.Load(), nil
+}
+
+func (pSelf *tlsStore) load(tlsConfig TLSConfig) error {
+	certificate, err := loadCertificate(tlsConfig)
+	if err != nil {
+		return err
+	}
+	pSelf.certificate.Store(certificate)
+	return nil
+}
+
+func loadCertificate(tlsConfig TLSConfig) (*tls.Certificate, error) {
+	certificate, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &certificate, nil
+}
+
+// Reload 는 설정을 다시 읽어 적용하고 결과를 반환한다.
+func (pSelf *GrpcServer) Reload() ReloadReport {
+	pSelf.reloadMutex.Lock()
+	defer pSelf.reloadMutex.Unlock()
+
+	report := pSelf.reload()
+	if report.Err != nil {
+		gLogger.Printf("Failed to reload config: %v\n", report.Err)
+		addLabeledMetric("config_reloads", "failure", 1)
+	} else {
+		for _, change := range report.Diff {
+			if change.RestartRequired {
+				gLogger.Printf("Config changed, restart required: key=%s old=%q new=%q\n", change.Key, change.Old, change.New)
+			} else {
+				gLogger.Printf("Config changed: key=%s old=%q new=%q\n", change.Key, change.Old, change.New)
+			}
+			addLabeledMetric("config_changes", change.Key, 1)
+		}
+		if len(report.Diff) == 0 {
+			gLogger.Println("Reloaded config: no changes")
+		}
+		addLabeledMetric("config_reloads", "success", 1)
+		if len(report.Diff) > 0 {
+			keys := make([]string, 0, len(report.Diff))
+			for _, change := range report.Diff {
+				keys = append(keys, change.Key)
+			}
+			pSelf.options.securityEvent(SecurityEvent{
+				Type:       SecurityEventAdminAction,
+				Message:    "config reloaded",
+				Extensions: map[string]string{"changed": strings.Join(keys, ",")},
+			})
+		}
+		// 다시 읽은 인증서의 만료 시각을 바로 반영한다.
+		if pSelf.options.certificateExpiry != nil {
+			pSelf.checkCertificateExpiry()
+		}
+	}
+
+	if pSelf.options.reload != nil && pSelf.options.reload.OnReload != nil {
+		pSelf.options.reload.OnReload(report)
+	}
+	return report
+}
+
+func (pSelf *GrpcServer) reload() ReloadReport {
+	options := pSelf.options
+	if options.config == nil {
+		return ReloadReport{Err: errors.New("server was not created from a config")}
+	}
+
+	load := func() (*Config, error) {
+		if len(options.configPath) == 0 {
+			return nil, errors.New("no config file to reload")
+		}
+		return LoadConfigProfile(options.configPath, options.configProfile)
+	}
+	if options.reload != nil && options.reload.Load != nil {
+		load = options.reload.Load
+	}
+
+	config, err := load()
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		return ReloadReport{Err: err}
+	}
+
+	oldConfig := options.config
+	report := ReloadReport{Config: config}
+	changed := func(name string, oldValue, newValue any) {
+		report.Changed = append(report.Changed, fmt.Sprintf("%s: %v -> %v", name, oldValue, newValue))
+	}
+	var restartRequiredKeys []string
+	restartRequired := func(name string, oldValue, newValue any) {
+		if fmt.Sprint(oldValue) != fmt.Sprint(newValue) {
+			report.RestartRequired = append(report.RestartRequired, fmt.Sprintf("%s: %v -> %v", name, oldValue, newValue))
+			restartRequiredKeys = append(restartRequiredKeys, name)
+		}
+	}
+
+	// 실패할 수 있는 작업 (인증서 읽기, trusted proxy 해석) 을 모두 마친 뒤에 적용하여, 일부만 적용되지 않도록 한다.
+	// 인증서 파일은 경로가 같아도 내용이 바뀌었을 수 있으므로 항상 다시 읽는다.
+	var certificate *tls.Certificate
+	if options.tlsStore != nil && len(config.TLS.CertFile) > 0 {
+		if certificate, err = loadCertificate(config.TLS); err != nil {
+			return ReloadReport{Err: err}
+		}
+	}
+	ipLimitsChanged := oldConfig.Limits.MaxConnectionsPerIP != config.Limits.MaxConnectionsPerIP || !slices.Equal(oldConfig.Limits.TrustedProxies, config.Limits.TrustedProxies)
+	var trustedProxies []*net.IPNet
+	if options.ipConnectionLimiter != nil && ipLimitsChanged {
+		if trustedProxies, err = parseIPNets(config.Limits.TrustedProxies); err != nil {
+			return ReloadReport{Err: err}
+		}
+	}
+
+	if certificate != nil {
+		options.tlsStore.certificate.Store(certificate)
+		report.Changed = append(report.Changed, "tls: certificate reloaded from "+config.TLS.CertFile)
+		gLogger.Printf("Reloaded TLS certificate: %s\n", config.TLS.CertFile)
+	}
+
+	if options.connectionLimiter != nil {
+		if oldConfig.Limits.MaxConnections != config.Limits.MaxConnections {
+			options.connectionLimiter.setMaxConnections(config.Limits.MaxConnections)
+			changed("limits.max_connections", oldConfig.Limits.MaxConnections, config.Limits.MaxConnections)
+		}
+	} else {
+		restartRequired("limits.max_connections", oldConfig.Limits.MaxConnections, config.Limits.MaxConnections)
+	}
+
+	if options.ipConnectionLimiter != nil {
+		if ipLimitsChanged {
+			options.ipConnectionLimiter.setLimits(config.Limits.MaxConnectionsPerIP, config.Limits.TrustedProxies, trustedProxies)
+		}
+		if oldConfig.Limits.MaxConnectionsPerIP != config.Limits.MaxConnectionsPerIP {
+			changed("limits.max_connections_per_ip", oldConfig.Limits.MaxConnectionsPerIP, config.Limits.MaxConnectionsPerIP)
+		}
+		if !slices.Equal(oldConfig.Limits.TrustedProxies, config.Limits.TrustedProxies) {
+			changed("limits.trusted_proxies", oldConfig.Limits.TrustedProxies, config.Limits.TrustedProxies)
+		}
+	} else {
+		restartRequired("limits.max_connections_per_ip", oldConfig.Limits.MaxConnectionsPerIP, config.Limits.MaxConnectionsPerIP)
+		restartRequired("limits.trusted_proxies", oldConfig.Limits.TrustedProxies, config.Limits.TrustedProxies)
+	}
+
+	rateLimitChanged := oldConfig.Limits.RateLimit != config.Limits.RateLimit || oldConfig.Limits.RateBurst != config.Limits.RateBurst
+	principalsChanged := fmt.Sprint(oldConfig.Limits.Principals) != fmt.Sprint(config.Limits.Principals)
+	if options.rateLimiter != nil {
+		if rateLimitChanged || principalsChanged {
+			options.rateLimiter.setLimits(RateLimit{Rate: config.Limits.RateLimit, Burst: config.Limits.RateBurst}, config.Limits.Principals)
+		}
+		if rateLimitChanged {
+			changed("limits.rate_limit", oldConfig.Limits.RateLimit, config.Limits.RateLimit)
+			changed("limits.rate_burst", oldConfig.Limits.RateBurst, config.Limits.RateBurst)
+		}
+		if principalsChanged {
+			changed("limits.principals", oldConfig.Limits.Principals, config.Limits.Principals)
+		}
+	} else {
+		restartRequired("limits.rate_limit", oldConfig.Limits.RateLimit, config.Limits.RateLimit)
+		restartRequired("limits.rate_burst", oldConfig.Limits.RateBurst, config.Limits.RateBurst)
+		restartRequired("limits.principals", oldConfig.Limits.Principals, config.Limits.Principals)
+	}
+
+	restartRequired("network", oldConfig.Network, config.Network)
+	restartRequired("address", oldConfig.Address, config.Address)
+	restartRequired("port", oldConfig.Port, config.Port)
+	restartRequired("http_port", oldConfig.HttpPort, config.HttpPort)
+	if options.tlsStore == nil || len(config.TLS.CertFile) == 0 {
+		restartRequired("tls.cert_file", oldConfig.TLS.CertFile, config.TLS.CertFile)
+	}
+	restartRequired("tls.client_ca_file", oldConfig.TLS.ClientCAFile, config.TLS.ClientCAFile)
+	restartRequired("tls.peers", oldConfig.TLS.Peers, config.TLS.Peers)
+	restartRequired("limits.idle_timeout", time.Duration(oldConfig.Limits.IdleTimeout), time.Duration(config.Limits.IdleTimeout))
+	restartRequired("limits.max_connection_age", time.Duration(oldConfig.Limits.MaxConnectionAge), time.Duration(config.Limits.MaxConnectionAge))
+	restartRequired("limits.max_connection_age_grace", time.Duration(oldConfig.Limits.MaxConnectionAgeGrace), time.Duration(config.Limits.MaxConnectionAgeGrace))
+	restartRequired("middleware", oldConfig.Middleware, config.Middleware)
+	restartRequired("discovery", oldConfig.Discovery, config.Discovery)
+	restartRequired("pid_file", oldConfig.PIDFile, config.PIDFile)
+
+	report.Diff = DiffConfig(oldConfig, config)
+	for i, change := range report.Diff {
+		for _, key := range restartRequiredKeys {
+			if change.Key == key || strings.HasPrefix(change.Key, key+".") {
+				report.Diff[i].RestartRequired = true
+			}
+		}
+	}
+
+	pSelf.configMutex.Lock()
+	options.config = config
+	pSelf.configMutex.Unlock()
+	return report
+}
+
+// handleReload 는 Signal 을 받거나 설정 파일이 바뀌면 설정을 다시 읽는다.
+func (pSelf *GrpcServer) handleReload() {
+	cReload := make(chan os.Signal, 1)
+	signal.Notify(cReload, pSelf.options.reload.Signal)
+
+	var cTick <-chan time.Time
+	var lastModified time.Time
+	if pSelf.options.reload.WatchInterval > 0 && len(pSelf.options.configPath) > 0 {
+		ticker := time.NewTicker(pSelf.options.reload.WatchInterval)
+		defer ticker.Stop()
+		cTick = ticker.C
+		lastModified = configModTime(configFiles(pSelf.options.configPath, pSelf.options.configProfile))
+	}
+
+	for {
+		select {
+		case sig := <-cReload:
+			gLogger.Printf("Caught signal: %s", sig)
+			pSelf.Reload()
+		case <-cTick:
+			// Kubernetes ConfigMap 처럼 symlink 를 바꾸는 경우에도 감지하도록 symlink 를 따라간 파일의 시각을 비교.
+			modified := configModTime(configFiles(pSelf.options.configPath, pSelf.options.configProfile))
+			if modified.IsZero() || modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+			gLogger.Printf("Config file changed: %s\n", pSelf.options.configPath)
+			pSelf.Reload()
+		}
+	}
+}
+
+// configModTime 은 설정 파일 중 가장 최근에 바뀐 시각이다.
+func configModTime(paths []string) time.Time {
+	var modified time.Time
+	for _, path := range paths {
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		if fileInfo.ModTime().After(modified) {
+			modified = fileInfo.ModTime()
+		}
+	}
+	return modified
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSignatureNonceHeader = "x-signature-nonce"
+	// nonceSweepInterval 는 MemoryNonceStore 가 만료된 nonce 를 정리하는 주기이다.
+	nonceSweepInterval = time.Minute
+)
+
+// NonceStore 는 사용한 nonce 를 기록한다. Server 를 여러 개 실행하면 공유 저장소 (e.g. Redis SET NX) 로 구현한다.
+type NonceStore interface {
+	// Add 는 nonce 를 expires 까지 기록한다. 이미 기록된 nonce 이면 false 이다.
+	Add(ctx context.Context, nonce string, expires time.Time) (bool, error)
+}
+
+// ReplayOptions 는 서명한 요청의 재전송 방지 설정이다.
+type ReplayOptions struct {
+	// NonceHeader 는 요청마다 다른 nonce 를 담은 metadata 이름이다. (기본값: x-signature-nonce)
+	NonceHeader string
+	// Store 는 사용한 nonce 저장소이다. (기본값: NewMemoryNonceStore)
+	Store NonceStore
+}
+
+// WithReplayProtection 은 WithRequestSignature 로 서명을 확인한 요청의 nonce 를 기록하고, 이미 사용한 nonce 의 요청을 Unauthenticated 로 거부한다.
+// nonce 는 서명 시각의 허용 범위 (SignatureOptions.MaxSkew) 가 지날 때까지 기록하며, 범위를 벗어난 요청은 서명 확인에서 거부한다.
+// client 는 SignRequestWithNonce 로 nonce 를 포함하여 서명한다.
+func WithReplayProtection(replayOptions ReplayOptions) Option {
+	return func(options *serverOptions) {
+		if len(replayOptions.NonceHeader) == 0 {
+			replayOptions.NonceHeader = defaultSignatureNonceHeader
+		}
+		if replayOptions.Store == nil {
+			replayOptions.Store = NewMemoryNonceStore()
+		}
+		options.replay = &replayOptions
+	}
+}
+
+// MemoryNonceStore 는 프로세스 메모리에 nonce 를 기록하는 NonceStore 이다.
+type MemoryNonceStore struct {
+	mutex     sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryNonceStore 는 MemoryNonceStore 를 생성한다.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: map[string]time.Time{}}
+}
+
+func (pSelf *MemoryNonceStore) Add(_ context.Context, nonce string, expires time.Time) (bool, error) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	now := time.Now()
+	if now.After(pSelf.nextSweep) {
+		for key, keyExpires := range pSelf.nonces {
+			if now.After(keyExpires) {
+				delete(pSelf.nonces, key)
+			}
+		}
+		pSelf.nextSweep = now.Add(nonceSweepInterval)
+	}
+
+	if keyExpires, ok := pSelf.nonces[nonce]; ok && now.Before(keyExpires) {
+		return false, nil
+	}
+	pSelf.nonces[nonce] = expires
+	return true, nil
+}
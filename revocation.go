@@ -0,0 +1,417 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"golang.org/x/sync/singleflight"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRevocationCacheTTL   = time.Hour
+	defaultRevocationTimeout    = 5 * time.Second
+	defaultRevocationStaleGrace = time.Hour
+	// revocationFailureBackoff 는 CRL 을 받지 못한 뒤 다시 받기까지 기다리는 시간이다.
+	revocationFailureBackoff = time.Minute
+	// maxRevocationResponseSize 는 CRL, OCSP 응답의 최대 크기이다.
+	maxRevocationResponseSize = 32 << 20
+	// maxOCSPResponses 는 cache 하는 OCSP 응답의 최대 수이다. 넘으면 만료된 응답을 지우고, 그래도 넘으면 임의의 응답을 지운다.
+	maxOCSPResponses = 10000
+)
+
+// ErrCertificateRevoked 는 폐기된 client 인증서로 연결한 경우의 오류이다.
+var ErrCertificateRevoked = errors.New("certificate is revoked")
+
+// RevocationOptions 는 mTLS client 인증서의 폐기 확인 설정이다.
+type RevocationOptions struct {
+	// CRLFiles 는 CRL 파일 (PEM 또는 DER) 이다.
+	CRLFiles []string
+	// CRLURLs 는 CRL 을 받을 URL 이다.
+	CRLURLs []string
+	// OCSP 가 true 이면 인증서의 OCSP responder 에 폐기 여부를 묻는다.
+	OCSP bool
+	// CacheTTL 은 CRL, OCSP 응답을 다시 받기 전까지 사용하는 시간이다.
+	// 응답의 NextUpdate 가 더 빠르면 NextUpdate 까지 사용한다. (기본값: 1h)
+	CacheTTL time.Duration
+	// SoftFail 이 true 이면 CRL, OCSP 응답을 받지 못해 확인할 수 없는 인증서를 허용한다. (false 이면 연결을 거부)
+	SoftFail bool
+	// StaleGrace 는 CRL 을 다시 받지 못할 때 마지막으로 받은 CRL 을 NextUpdate 뒤에도 사용하는 시간이다.
+	// SoftFail 이 false 이면 이 시간이 지난 CRL 로는 확인하지 않고 연결을 거부한다. (기본값: 1h)
+	StaleGrace time.Duration
+	// Timeout 은 CRL, OCSP 요청 제한 시간이다. (기본값: 5s)
+	Timeout time.Duration
+	// Client 는 CRL, OCSP 요청에 사용할 Http Client 이다. (기본값: http.DefaultClient)
+	Client *http.Client
+}
+
+// WithRevocationCheck 는 mTLS 에서 검증한 client 인증서 chain 의 폐기 여부를 CRL, OCSP 로 확인한다.
+// WithTLS 와 Listener 별 TLS 설정 중 client 인증서를 검증하는 설정에 적용한다.
+func WithRevocationCheck(revocationOptions RevocationOptions) Option {
+	return func(options *serverOptions) {
+		options.revocation = &revocationOptions
+	}
+}
+
+// applyRevocation 은 client 인증서를 검증하는 TLS 설정에 폐기 확인을 추가한다.
+func applyRevocation(options *serverOptions) {
+	if options.revocation == nil {
+		return
+	}
+
+	checker := newRevocationChecker(*options.revocation)
+	options.revocationChecker = checker
+	options.tlsConfig = checker.apply(options.tlsConfig)
+	for i := range options.additionalListeners {
+		options.additionalListeners[i].TLS = checker.apply(options.additionalListeners[i].TLS)
+	}
+}
+
+// revocationChecker 는 CRL, OCSP 응답을 cache 하며 인증서의 폐기 여부를 확인한다.
+type revocationChecker struct {
+	options RevocationOptions
+
+	mutex sync.Mutex
+	// crls 는 CRL 파일, URL 별로 마지막으로 받은 CRL 이다.
+	crls map[string]*cachedCRL
+	// crlFailures 는 CRL 파일, URL 별 마지막 실패이다. retryAt 까지 다시 받지 않는다.
+	crlFailures map[string]crlFailure
+	// crlLoads 는 CRL 파일, URL 별로 하나의 요청만 받도록 한다.
+	crlLoads singleflight.Group
+	// ocspResponses 는 발급자와 serial 별 OCSP 응답이다. (최대 maxOCSPResponses)
+	ocspResponses map[string]*cachedOCSP
+	// ocspLookups 는 발급자와 serial 별로 하나의 OCSP 요청만 보내도록 한다.
+	ocspLookups singleflight.Group
+}
+
+type cachedCRL struct {
+	list    *x509.RevocationList
+	revoked map[string]struct{}
+	expires time.Time
+}
+
+type crlFailure struct {
+	err     error
+	retryAt time.Time
+}
+
+type cachedOCSP struct {
+	status  int
+	expires time.Time
+}
+
+func newRevocationChecker(revocationOptions RevocationOptions) *revocationChecker {
+	if revocationOptions.CacheTTL <= 0 {
+		revocationOptions.CacheTTL = defaultRevocationCacheTTL
+	}
+	if revocationOptions.Timeout <= 0 {
+		revocationOptions.Timeout = defaultRevocationTimeout
+	}
+	if revocationOptions.StaleGrace <= 0 {
+		revocationOptions.StaleGrace = defaultRevocationStaleGrace
+	}
+	if revocationOptions.Client == nil {
+		revocationOptions.Client = http.DefaultClient
+	}
+	return &revocationChecker{
+		options:       revocationOptions,
+		crls:          map[string]*cachedCRL{},
+		crlFailures:   map[string]crlFailure{},
+		ocspResponses: map[string]*cachedOCSP{},
+	}
+}
+
+// apply 는 tlsConfig 의 복사본에 폐기 확인을 추가한다. client 인증서를 검증하지 않는 설정은 그대로 반환한다.
+func (pSelf *revocationChecker) apply(tlsConfig *tls.Config) *tls.Config {
+	if pSelf == nil || tlsConfig == nil || tlsConfig.ClientAuth < tls.VerifyClientCertIfGiven {
+		return tlsConfig
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	verifyConnection := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+		return pSelf.verifyConnection(state)
+	}
+	return tlsConfig
+}
+
+// verifyConnection 은 검증한 chain 의 인증서 (root 제외) 를 발급자와 함께 확인한다.
+func (pSelf *revocationChecker) verifyConnection(state tls.ConnectionState) error {
+	if len(state.VerifiedChains) == 0 {
+		return nil
+	}
+
+	chain := state.VerifiedChains[0]
+	for i := 0; i < len(chain)-1; i++ {
+		if err := pSelf.check(chain[i], chain[i+1]); err != nil {
+			if errors.Is(err, ErrCertificateRevoked) || !pSelf.options.SoftFail {
+				addLabeledMetric("revocation_checks", "rejected", 1)
+				return err
+			}
+			addLabeledMetric("revocation_checks", "soft_failed", 1)
+			gLogger.Printf("Failed to check revocation of %s: %v\n", chain[i].Subject, err)
+		}
+	}
+	return nil
+}
+
+// check 는 CRL, OCSP 순서로 인증서의 폐기 여부를 확인한다. 확인한 방법이 하나라도 있으면 나머지의 오류는 무시한다.
+func (pSelf *revocationChecker) check(certificate, issuer *x509.Certificate) error {
+	var errs []error
+	checked := false
+
+	for _, source := range pSelf.crlSources() {
+		revoked, ok, err := pSelf.checkCRL(source, certificate, issuer)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if revoked {
+			return fmt.Errorf("%w: %s (serial %s)", ErrCertificateRevoked, certificate.Subject, certificate.SerialNumber)
+		}
+		checked = checked || ok
+	}
+
+	if pSelf.options.OCSP && len(certificate.OCSPServer) > 0 {
+		revoked, err := pSelf.checkOCSP(certificate, issuer)
+		if err != nil {
+			errs = append(errs, err)
+		} else if revoked {
+			return fmt.Errorf("%w: %s (serial %s)", ErrCertificateRevoked, certificate.Subject, certificate.SerialNumber)
+		} else {
+			checked = true
+		}
+	}
+
+	if checked || len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func (pSelf *revocationChecker) crlSources() []string {
+	return append(append([]string{}, pSelf.options.CRLFiles...), pSelf.options.CRLURLs...)
+}
+
+// checkCRL 은 source 의 CRL 로 인증서를 확인한다. CRL 의 발급자가 다르면 ok 가 false 이다.
+func (pSelf *revocationChecker) checkCRL(source string, certificate, issuer *x509.Certificate) (revoked, ok bool, err error) {
+	crl, err := pSelf.loadCRL(source)
+	if err != nil {
+		return false, false, err
+	}
+	if !bytes.Equal(crl.list.RawIssuer, issuer.RawSubject) {
+		return false, false, nil
+	}
+	if err := crl.list.CheckSignatureFrom(issuer); err != nil {
+		return false, false, fmt.Errorf("invalid CRL signature of %s: %w", source, err)
+	}
+	_, revoked = crl.revoked[certificate.SerialNumber.String()]
+	return revoked, true, nil
+}
+
+// loadCRL 은 cache 된 CRL 을 반환하고, 만료되었으면 다시 읽는다.
+// 다시 읽지 못하면 revocationFailureBackoff 동안 다시 시도하지 않으며, 그동안 마지막으로 받은 CRL 을 사용한다. (stale 참고)
+// handshake 가 lock 을 기다리지 않도록 CRL 은 lock 밖에서 source 별로 하나씩만 받는다.
+func (pSelf *revocationChecker) loadCRL(source string) (*cachedCRL, error) {
+	now := time.Now()
+	pSelf.mutex.Lock()
+	crl, cached := pSelf.crls[source]
+	failure, failed := pSelf.crlFailures[source]
+	pSelf.mutex.Unlock()
+	if cached && now.Before(crl.expires) {
+		return crl, nil
+	}
+	if failed && now.Before(failure.retryAt) {
+		if cached {
+			return pSelf.stale(source, crl, now, failure.err)
+		}
+		return nil, failure.err
+	}
+
+	v, err, _ := pSelf.crlLoads.Do(source, func() (any, error) {
+		crl, err := pSelf.readCRL(source, now)
+		pSelf.mutex.Lock()
+		defer pSelf.mutex.Unlock()
+		if err != nil {
+			pSelf.crlFailures[source] = crlFailure{err: err, retryAt: now.Add(revocationFailureBackoff)}
+			return nil, err
+		}
+		delete(pSelf.crlFailures, source)
+		pSelf.crls[source] = crl
+		return crl, nil
+	})
+	if err != nil {
+		if cached {
+			gLogger.Printf("Failed to reload CRL: %v\n", err)
+			return pSelf.stale(source, crl, now, err)
+		}
+		return nil, err
+	}
+	return v.(*cachedCRL), nil
+}
+
+// stale 은 다시 받지 못한 source 의 마지막 CRL 이다.
+// SoftFail 이 아니면 NextUpdate (없으면 cache 만료 시각) 에서 StaleGrace 가 지난 CRL 은 사용하지 않는다.
+func (pSelf *revocationChecker) stale(source string, crl *cachedCRL, now time.Time, err error) (*cachedCRL, error) {
+	if pSelf.options.SoftFail {
+		return crl, nil
+	}
+	nextUpdate := crl.list.NextUpdate
+	if nextUpdate.IsZero() {
+		nextUpdate = crl.expires
+	}
+	if now.After(nextUpdate.Add(pSelf.options.StaleGrace)) {
+		return nil, fmt.Errorf("CRL %s is stale since %s: %w", source, nextUpdate.Format(time.RFC3339), err)
+	}
+	return crl, nil
+}
+
+// readCRL 은 source 의 CRL 을 읽는다.
+func (pSelf *revocationChecker) readCRL(source string, now time.Time) (*cachedCRL, error) {
+	var b []byte
+	var err error
+	if slices.Contains(pSelf.options.CRLFiles, source) {
+		b, err = os.ReadFile(source)
+	} else {
+		b, err = pSelf.fetch(http.MethodGet, source, "", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CRL %s: %w", source, err)
+	}
+	if block, _ := pem.Decode(b); block != nil {
+		b = block.Bytes
+	}
+	list, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CRL %s: %w", source, err)
+	}
+
+	crl := &cachedCRL{list: list, revoked: map[string]struct{}{}, expires: pSelf.expires(now, list.NextUpdate)}
+	for _, entry := range list.RevokedCertificateEntries {
+		crl.revoked[entry.SerialNumber.String()] = struct{}{}
+	}
+	return crl, nil
+}
+
+// checkOCSP 는 인증서의 OCSP responder 에 폐기 여부를 묻는다.
+func (pSelf *revocationChecker) checkOCSP(certificate, issuer *x509.Certificate) (bool, error) {
+	digest := sha256.Sum256(issuer.Raw)
+	key := hex.EncodeToString(digest[:]) + "/" + certificate.SerialNumber.String()
+
+	now := time.Now()
+	pSelf.mutex.Lock()
+	cached, ok := pSelf.ocspResponses[key]
+	pSelf.mutex.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.status == ocsp.Revoked, nil
+	}
+
+	// 같은 인증서의 handshake 가 동시에 오면 OCSP 요청을 하나만 보낸다.
+	v, err, _ := pSelf.ocspLookups.Do(key, func() (any, error) {
+		return pSelf.requestOCSP(key, certificate, issuer, now)
+	})
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+// requestOCSP 는 인증서의 OCSP responder 에 차례로 요청하고, 받은 응답을 cache 한다.
+func (pSelf *revocationChecker) requestOCSP(key string, certificate, issuer *x509.Certificate, now time.Time) (bool, error) {
+	request, err := ocsp.CreateRequest(certificate, issuer, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+	var lastErr error
+	for _, server := range certificate.OCSPServer {
+		b, err := pSelf.fetch(http.MethodPost, server, "application/ocsp-request", request)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to request OCSP %s: %w", server, err)
+			continue
+		}
+		response, err := ocsp.ParseResponseForCert(b, certificate, issuer)
+		if err != nil {
+			lastErr = fmt.Errorf("invalid OCSP response of %s: %w", server, err)
+			continue
+		}
+		if response.Status == ocsp.Unknown {
+			lastErr = fmt.Errorf("OCSP %s does not know %s", server, certificate.Subject)
+			continue
+		}
+
+		pSelf.mutex.Lock()
+		pSelf.evictOCSP(now)
+		pSelf.ocspResponses[key] = &cachedOCSP{status: response.Status, expires: pSelf.expires(now, response.NextUpdate)}
+		pSelf.mutex.Unlock()
+		return response.Status == ocsp.Revoked, nil
+	}
+	return false, lastErr
+}
+
+// evictOCSP 는 OCSP 응답이 maxOCSPResponses 에 이르면 만료된 응답을 지우고, 그래도 가득 차 있으면 임의의 응답을 지운다.
+// 지운 응답은 다음 handshake 에서 다시 요청한다. mutex 를 잠근 상태에서 호출한다.
+func (pSelf *revocationChecker) evictOCSP(now time.Time) {
+	if len(pSelf.ocspResponses) < maxOCSPResponses {
+		return
+	}
+	for key, cached := range pSelf.ocspResponses {
+		if !now.Before(cached.expires) {
+			delete(pSelf.ocspResponses, key)
+		}
+	}
+	for key := range pSelf.ocspResponses {
+		if len(pSelf.ocspResponses) < maxOCSPResponses {
+			break
+		}
+		delete(pSelf.ocspResponses, key)
+	}
+}
+
+// expires 는 CacheTTL 과 nextUpdate 중 빠른 만료 시각이다.
+func (pSelf *revocationChecker) expires(now, nextUpdate time.Time) time.Time {
+	expires := now.Add(pSelf.options.CacheTTL)
+	if !nextUpdate.IsZero() && nextUpdate.Before(expires) {
+		return nextUpdate
+	}
+	return expires
+}
+
+func (pSelf *revocationChecker) fetch(method, url, contentType string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pSelf.options.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if len(contentType) > 0 {
+		request.Header.Set("Content-Type", contentType)
+	}
+	response, err := pSelf.options.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxRevocationResponseSize))
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Secret 은 SecretProvider 가 가져온 값이다.
+type Secret struct {
+	Data map[string]string
+	// TTL 은 lease 기간이다. 0 보다 크면 ManagedSecret 이 lease 가 끝나기 전에 다시 가져온다.
+	TTL time.Duration
+	// Version 은 값이 바뀌었는지 구분하는 값이다. (e.g. KV version, lease ID) 비어 있을 수 있다.
+	Version string
+}
+
+// SecretProvider 는 외부 저장소에서 Secret 을 가져온다. (e.g. HashiCorp Vault, AWS Secrets Manager)
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (*Secret, error)
+}
+
+// ManagedSecret 은 시작할 때 가져오고, lease 가 끝나기 전이나 refreshInterval 마다 다시 가져오는 Secret 이다.
+// TLS 개인 키, JWT 서명 키, API key salt 등 실행 중에 바뀔 수 있는 값을 읽을 때 사용한다.
+//
+//	jwtKey, err := server.NewManagedSecret(ctx, provider, "secret/data/myapp/jwt", 0)
+//	...
+//	key := jwtKey.Value("signing_key")
+type ManagedSecret struct {
+	provider        SecretProvider
+	name            string
+	refreshInterval time.Duration
+
+	secret atomic.Pointer[Secret]
+
+	mutex     sync.Mutex
+	onChanges []func(secret *Secret)
+	cancel    context.CancelFunc
+}
+
+// NewManagedSecret 은 name 의 Secret 을 가져온다. 처음 가져오지 못하면 오류를 반환한다.
+// Secret 의 TTL 이 있으면 TTL 의 2/3 가 지났을 때, 없으면 refreshInterval 마다 다시 가져온다. (0 이면 다시 가져오지 않음)
+// ctx 가 끝나거나 Close 를 호출하면 다시 가져오지 않는다.
+func NewManagedSecret(ctx context.Context, provider SecretProvider, name string, refreshInterval time.Duration) (*ManagedSecret, error) {
+	secret, err := provider.GetSecret(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	managedSecret := &ManagedSecret{
+		provider:        provider,
+		name:            name,
+		refreshInterval: refreshInterval,
+		cancel:          cancel,
+	}
+	managedSecret.secret.Store(secret)
+	go managedSecret.refresh(ctx)
+	return managedSecret, nil
+}
+
+// Value 는 Secret 의 key 항목이다.
+func (pSelf *ManagedSecret) Value(key string) string {
+	return pSelf.secret.Load().Data[key]
+}
+
+// Secret 은 마지막으로 가져온 Secret 이다.
+func (pSelf *ManagedSecret) Secret() *Secret {
+	return pSelf.secret.Load()
+}
+
+// OnChange 는 Secret 을 다시 가져올 때마다 onChange 를 호출한다.
+func (pSelf *ManagedSecret) OnChange(onChange func(secret *Secret)) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.onChanges = append(pSelf.onChanges, onChange)
+}
+
+// Close 는 Secret 을 더 이상 다시 가져오지 않는다.
+func (pSelf *ManagedSecret) Close() {
+	pSelf.cancel()
+}
+
+// refreshDelay 는 Secret 을 다시 가져올 때까지 기다리는 시간이다. 0 이면 다시 가져오지 않는다.
+func (pSelf *ManagedSecret) refreshDelay(secret *Secret) time.Duration {
+	if secret.TTL > 0 {
+		return secret.TTL * 2 / 3
+	}
+	return pSelf.refreshInterval
+}
+
+func (pSelf *ManagedSecret) refresh(ctx context.Context) {
+	const retryDelay = 10 * time.Second
+
+	delay := pSelf.refreshDelay(pSelf.secret.Load())
+	for delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		secret, err := pSelf.provider.GetSecret(ctx, pSelf.name)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			// 이전 값을 계속 사용하며, lease 가 끝나기 전에 다시 시도.
+			gLogger.Printf("Failed to refresh secret %s: %v\n", pSelf.name, err)
+			delay = retryDelay
+			continue
+		}
+
+		previous := pSelf.secret.Swap(secret)
+		if len(secret.Version) == 0 || secret.Version != previous.Version || !maps.Equal(secret.Data, previous.Data) {
+			gLogger.Printf("Refreshed secret %s\n", pSelf.name)
+			pSelf.mutex.Lock()
+			onChanges := pSelf.onChanges
+			pSelf.mutex.Unlock()
+			for _, onChange := range onChanges {
+				onChange(secret)
+			}
+		}
+		delay = pSelf.refreshDelay(secret)
+	}
+}
+
+// TLSConfigFromSecret 은 Secret 의 certKey, keyKey 항목 (PEM) 으로 TLS 설정을 만든다.
+// Secret 을 다시 가져오면 새 인증서를 사용하며, 새 인증서를 읽지 못하면 이전 인증서를 계속 사용한다.
+//
+//	tlsConfig, err := server.TLSConfigFromSecret(certSecret, "certificate", "private_key")
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, server.WithTLS(tlsConfig))
+func TLSConfigFromSecret(secret *ManagedSecret, certKey, keyKey string) (*tls.Config, error) {
+	load := func(s *Secret) (*tls.Certificate, error) {
+		certificate, err := tls.X509KeyPair([]byte(s.Data[certKey]), []byte(s.Data[keyKey]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate from secret %s: %w", secret.name, err)
+		}
+		return &certificate, nil
+	}
+
+	certificate, err := load(secret.Secret())
+	if err != nil {
+		return nil, err
+	}
+	store := &tlsStore{}
+	store.certificate.Store(certificate)
+
+	secret.OnChange(func(s *Secret) {
+		certificate, err := load(s)
+		if err != nil {
+			gLogger.Println(err)
+			return
+		}
+		store.certificate.Store(certificate)
+	})
+	return &tls.Config{GetCertificate: store.getCertificate}, nil
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"time"
+)
+
+// SecurityEventType 은 보안 event 의 종류이다.
+type SecurityEventType string
+
+const (
+	// SecurityEventAuthFailure 는 인증에 실패한 요청이다. (gRPC Unauthenticated, 관리용 Handler 의 401)
+	SecurityEventAuthFailure SecurityEventType = "auth_failure"
+	// SecurityEventAccessDenied 는 인증했지만 권한이 없어 거부한 요청이다. (gRPC PermissionDenied)
+	SecurityEventAccessDenied SecurityEventType = "access_denied"
+	// SecurityEventAuthLockout 은 인증 실패가 반복되어 잠근 client 이다. (WithAuthLockout)
+	SecurityEventAuthLockout SecurityEventType = "auth_lockout"
+	// SecurityEventAdminAction 은 관리 작업이다. (e.g. 설정 다시 읽기, 잠금 해제)
+	SecurityEventAdminAction SecurityEventType = "admin_action"
+)
+
+// SecurityEvent 는 SIEM 으로 보낼 보안 event 이다.
+type SecurityEvent struct {
+	// Time 은 발생 시각이다. 비어 있으면 현재 시각이다.
+	Time time.Time
+	Type SecurityEventType
+	// Severity 는 CEF 와 같은 0 (낮음) ~ 10 (높음) 의 심각도이다. 0 이면 Type 의 기본값이다.
+	Severity int
+	// Message 는 사람이 읽을 설명이다.
+	Message string
+	// Method 는 gRPC Method 이거나 HTTP 요청 경로이다.
+	Method string
+	// Source 는 client 주소이다.
+	Source string
+	// Principal 은 인증한 주체이다. (DefaultPrincipal 참고)
+	Principal string
+	// Extensions 는 추가 속성이다. CEF, LEEF 의 extension 으로 그대로 보낸다.
+	Extensions map[string]string
+}
+
+// SecurityEventExporter 는 보안 event 를 외부로 보낸다. 요청을 처리하는 Goroutine 에서 호출하므로 기다리지 않아야 한다. (SyslogExporter 참고)
+type SecurityEventExporter interface {
+	Export(event SecurityEvent)
+}
+
+// WithSecurityEvents 는 인증 실패, 권한 거부, 인증 잠금, 관리 작업을 exporter 로 보낸다.
+// gRPC 요청은 Unauthenticated, PermissionDenied 로 끝난 요청을 보내며, 관리용 Handler (ConfigHandler, AuthLockoutHandler) 와 Reload 도 보낸다.
+// Handler 의 관리 작업은 GrpcServer.SecurityEvent 로 보낸다. exporter 가 Worker 이면 WithWorker 로 함께 시작한다.
+func WithSecurityEvents(exporter SecurityEventExporter) Option {
+	return func(options *serverOptions) {
+		options.securityEvents = exporter
+	}
+}
+
+// SecurityEvent 는 WithSecurityEvents 의 exporter 로 event 를 보낸다. WithSecurityEvents 를 사용하지 않으면 무시한다.
+func (pSelf *GrpcServer) SecurityEvent(event SecurityEvent) {
+	pSelf.options.securityEvent(event)
+}
+
+// securityEvent 는 event 의 기본값을 채워 보낸다.
+func (pSelf *serverOptions) securityEvent(event SecurityEvent) {
+	if pSelf.securityEvents == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Severity <= 0 {
+		event.Severity = defaultSecuritySeverity(event.Type)
+	}
+	addLabeledMetric("security_events", string(event.Type), 1)
+	pSelf.securityEvents.Export(event)
+}
+
+// adminAuthFailure 는 관리용 Handler 의 인증 실패 event 를 보낸다.
+func (pSelf *GrpcServer) adminAuthFailure(r *http.Request) {
+	pSelf.options.securityEvent(SecurityEvent{
+		Type:    SecurityEventAuthFailure,
+		Message: "admin request unauthorized",
+		Method:  r.URL.Path,
+		Source:  requestHost(r),
+	})
+}
+
+// requestHost 는 HTTP 요청의 client 주소의 host 이다.
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func defaultSecuritySeverity(eventType SecurityEventType) int {
+	switch eventType {
+	case SecurityEventAuthLockout:
+		return 7
+	case SecurityEventAuthFailure, SecurityEventAccessDenied:
+		return 5
+	}
+	return 3
+}
+
+// grpcSecurityEvent 는 요청이 인증 실패나 권한 거부로 끝났으면 event 를 보낸다.
+func (pSelf *serverOptions) grpcSecurityEvent(ctx context.Context, fullMethod string, err error) {
+	var eventType SecurityEventType
+	switch status.Code(err) {
+	case codes.Unauthenticated:
+		eventType = SecurityEventAuthFailure
+	case codes.PermissionDenied:
+		eventType = SecurityEventAccessDenied
+	default:
+		return
+	}
+	principal, _ := DefaultPrincipal(ctx)
+	pSelf.securityEvent(SecurityEvent{
+		Type:      eventType,
+		Message:   status.Convert(err).Message(),
+		Method:    fullMethod,
+		Source:    peerHost(ctx),
+		Principal: principal,
+	})
+}
+
+func (pSelf *serverOptions) securityEventUnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		pSelf.grpcSecurityEvent(ctx, info.FullMethod, err)
+	}
+	return resp, err
+}
+
+func (pSelf *serverOptions) securityEventStreamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	if err != nil {
+		pSelf.grpcSecurityEvent(ss.Context(), info.FullMethod, err)
+	}
+	return err
+}
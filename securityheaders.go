@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultHSTSMaxAge            = 365 * 24 * time.Hour
+	defaultReferrerPolicy        = "no-referrer"
+	defaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	defaultFrameOptions          = "DENY"
+)
+
+// SecurityHeadersOptions 는 gRPC Gateway (Http Proxy) 응답에 추가할 보안 header 이다. 비어 있는 항목은 추가하지 않는다.
+// Handler 가 같은 header 를 설정하면 Handler 의 값을 사용한다.
+type SecurityHeadersOptions struct {
+	// HSTSMaxAge 는 Strict-Transport-Security 의 max-age 이다. TLS (HTTPS, HTTP/3) 응답에만 추가한다.
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains 가 true 이면 includeSubDomains 를 추가한다. 모든 subdomain 이 HTTPS 를 지원할 때만 사용한다.
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// NoSniff 가 true 이면 X-Content-Type-Options: nosniff 를 추가한다.
+	NoSniff bool
+	// ReferrerPolicy 는 Referrer-Policy 이다. (e.g. no-referrer, strict-origin-when-cross-origin)
+	ReferrerPolicy string
+	// ContentSecurityPolicy 는 Content-Security-Policy 이다.
+	ContentSecurityPolicy string
+	// FrameOptions 는 X-Frame-Options 이다. (e.g. DENY, SAMEORIGIN)
+	FrameOptions string
+	// Header 는 추가할 다른 header 이다. (e.g. Permissions-Policy)
+	Header http.Header
+}
+
+// DefaultSecurityHeaders 는 JSON API 에 맞는 보안 header 이다. NewProduction 은 이 설정을 기본으로 사용한다.
+//
+//   - Strict-Transport-Security: max-age=31536000 (includeSubDomains 는 HSTSIncludeSubdomains 로 직접 추가)
+//   - X-Content-Type-Options: nosniff
+//   - Referrer-Policy: no-referrer
+//   - Content-Security-Policy: default-src 'none'; frame-ancestors 'none'
+//   - X-Frame-Options: DENY
+func DefaultSecurityHeaders() SecurityHeadersOptions {
+	return SecurityHeadersOptions{
+		HSTSMaxAge:            defaultHSTSMaxAge,
+		NoSniff:               true,
+		ReferrerPolicy:        defaultReferrerPolicy,
+		ContentSecurityPolicy: defaultContentSecurityPolicy,
+		FrameOptions:          defaultFrameOptions,
+	}
+}
+
+// WithSecurityHeaders 는 gRPC Gateway (Http Proxy) 의 모든 응답에 보안 header 를 추가한다.
+// HTML 을 제공하는 경로가 있으면 ContentSecurityPolicy 를 맞게 바꾼다. 빈 SecurityHeadersOptions 이면 header 를 추가하지 않는다.
+func WithSecurityHeaders(securityHeadersOptions SecurityHeadersOptions) Option {
+	return func(options *serverOptions) {
+		options.securityHeaders = &securityHeadersOptions
+	}
+}
+
+// header 는 TLS 여부에 따라 추가할 header 이다.
+func (pSelf *SecurityHeadersOptions) header(tls bool) http.Header {
+	header := http.Header{}
+	for name, values := range pSelf.Header {
+		header[http.CanonicalHeaderKey(name)] = values
+	}
+	if tls && pSelf.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(pSelf.HSTSMaxAge/time.Second), 10)
+		if pSelf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if pSelf.HSTSPreload {
+			hsts += "; preload"
+		}
+		header.Set("Strict-Transport-Security", hsts)
+	}
+	if pSelf.NoSniff {
+		header.Set("X-Content-Type-Options", "nosniff")
+	}
+	if len(pSelf.ReferrerPolicy) > 0 {
+		header.Set("Referrer-Policy", pSelf.ReferrerPolicy)
+	}
+	if len(pSelf.ContentSecurityPolicy) > 0 {
+		header.Set("Content-Security-Policy", pSelf.ContentSecurityPolicy)
+	}
+	if len(pSelf.FrameOptions) > 0 {
+		header.Set("X-Frame-Options", pSelf.FrameOptions)
+	}
+	return header
+}
+
+// handler 는 응답에 보안 header 를 추가한다. header 는 시작할 때 한 번만 만든다.
+func (pSelf *SecurityHeadersOptions) handler(handler http.Handler) http.Handler {
+	plainHeader, tlsHeader := pSelf.header(false), pSelf.header(true)
+	if len(tlsHeader) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := plainHeader
+		if r.TLS != nil || r.ProtoMajor == 3 {
+			header = tlsHeader
+		}
+		for name, values := range header {
+			w.Header()[name] = slices.Clone(values)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -2,22 +2,32 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"github.com/berryons/log"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/quic-go/quic-go/http3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
+// signalShutdownTimeout 은 Run 이 종료 signal 을 받은 뒤 처리 중인 요청을 기다리는 시간이다.
+const signalShutdownTimeout = 30 * time.Second
+
 var (
-	supportedNetworks = []string{"unix", "tcp"}
+	supportedNetworks = []string{"unix", "tcp", "tcp4", "tcp6", "vsock"}
 )
 
 type HttpProxyServerHandler func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) (err error)
@@ -27,20 +37,216 @@ func New(
 	port int,
 	unaryServerInterceptors []grpc.UnaryServerInterceptor,
 	streamServerInterceptors []grpc.StreamServerInterceptor,
+	opts ...Option,
 ) *GrpcServer {
-	fullAddress := fmt.Sprintf("%s:%d", address, port)
+	fullAddress := joinAddress(network, address, port)
+	options := newServerOptions(opts)
+	options.prepareReload()
+	if options.maxProcs != nil {
+		options.maxProcs.apply()
+	}
+	if err := options.upgrader.loadInherited(); err != nil {
+		gLogger.Fatalf("Failed to use inherited listeners: %v\n", err)
+	}
+
+	// 이미 실행 중인 프로세스가 있으면 listen 하기 전에 종료.
+	if len(options.pidFile) > 0 {
+		if err := checkPIDFile(options.pidFile); err != nil {
+			gLogger.Fatal(err)
+		}
+	}
 
 	// Check Network
 	checkNetwork(network, fullAddress)
+	if err := checkAddressFamily(network, address); err != nil {
+		gLogger.Fatal(err)
+	}
+	if err := applyFIPS(options); err != nil {
+		gLogger.Fatalf("FIPS mode: %v\n", err)
+	}
+	applyRevocation(options)
+	applySessionTicketRotation(options)
+	if err := checkHttp3(network, options); err != nil {
+		options.recoverable("HTTP/3: %v", err)
+		options.http3 = nil
+	}
+	if err := checkWebTransport(options); err != nil {
+		options.recoverable("WebTransport: %v", err)
+		options.webTransport = nil
+	}
+	if err := checkALPN(options); err != nil {
+		options.recoverable("ALPN: %v", err)
+		options.alpn = nil
+	}
+	applyALPN(options)
+
+	// systemd Socket Activation 으로 전달 된 Listener 사용.
+	var listener, httpProxyListener net.Listener
+	socketActivated := false
+	if options.systemdSocketActivation {
+		activatedListeners, err := systemdListeners()
+		if err != nil {
+			options.recoverable("Failed to use systemd socket activation: %v", err)
+		}
+		listener, httpProxyListener = pickSystemdListeners(activatedListeners)
+		if listener != nil {
+			socketActivated = true
+			gLogger.Printf("Use systemd activated listener: %s\n", listener.Addr())
+		} else {
+			gLogger.Println("systemd socket activation is enabled, but no listener was passed")
+		}
+	}
 
 	// Network Listener 생성.
-	listener, err := net.Listen(strings.ToLower(network), fullAddress)
+	if listener == nil {
+		var err error
+		listener, err = listen(network, address, port, options)
+		if err != nil {
+			gLogger.Fatalf("Failed to listen: %v\n", err)
+		}
+	}
+	listener, err := options.wrapListener(listener)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v\n", err)
+		gLogger.Fatalf("Failed to listen: %v\n", err)
+	}
+	// port 0 이면 OS 가 할당한 port 사용.
+	ephemeralPort := port == 0
+	port = boundPort(listener.Addr(), port)
+
+	// 추가 Listener 생성.
+	namedListeners := make([]*namedListener, 0, len(options.additionalListeners))
+	for i, listenerConfig := range options.additionalListeners {
+		checkNetwork(listenerConfig.Network, joinAddress(listenerConfig.Network, listenerConfig.Address, listenerConfig.Port))
+		if err := checkAddressFamily(listenerConfig.Network, listenerConfig.Address); err != nil {
+			gLogger.Fatal(err)
+		}
+
+		l, err := listen(listenerConfig.Network, listenerConfig.Address, listenerConfig.Port, options)
+		if err == nil {
+			l, err = options.wrapListener(l)
+		}
+		if err != nil {
+			options.recoverable("Failed to listen grpc-%d: %v", i+1, err)
+			continue
+		}
+
+		listenerPort := boundPort(l.Addr(), listenerConfig.Port)
+		namedListeners = append(namedListeners, &namedListener{
+			name:      fmt.Sprintf("grpc-%d", i+1),
+			network:   listenerConfig.Network,
+			address:   joinAddress(listenerConfig.Network, listenerConfig.Address, listenerPort),
+			port:      listenerPort,
+			listener:  l,
+			tlsConfig: listenerConfig.TLS,
+		})
+	}
+
+	// Listener 별 TLS 설정.
+	hasTLS := options.tlsConfig != nil
+	for _, listenerConfig := range options.additionalListeners {
+		hasTLS = hasTLS || listenerConfig.TLS != nil
+	}
+	if hasTLS && options.factoryCredentials {
+		gLogger.Fatal("Server factory with its own credentials cannot be used with listener TLS.")
+	}
+	var creds listenerCredentials
+	if hasTLS {
+		credsListener := newCredentialsListener(listener, options.tlsConfig)
+		creds.info = credsListener.creds.Info()
+		listener = credsListener
+		for _, l := range namedListeners {
+			l.listener = newCredentialsListener(l.listener, l.tlsConfig)
+		}
 	}
 
 	// Server options
 	var serverOptions []grpc.ServerOption
+	if hasTLS {
+		serverOptions = append(serverOptions, grpc.Creds(creds))
+	}
+	if keepaliveParams, ok := options.keepaliveParams(); ok {
+		serverOptions = append(serverOptions, grpc.KeepaliveParams(keepaliveParams))
+	}
+	serverOptions = append(serverOptions, options.transportServerOptions()...)
+	if options.redactor != nil {
+		// 다른 Interceptor 가 context 에 기록한 role 을 사용하도록 Handler 와 가장 가까이에서 실행.
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.redactor.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.redactor.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.delegation != nil {
+		// 인증한 요청의 token 으로 위임 정보를 확인. redactor 의 Roles 가 위임 정보를 사용할 수 있다.
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.delegation.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.delegation.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.rateLimiter != nil {
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.rateLimiter.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.rateLimiter.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.tenancy != nil {
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.tenancy.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.tenancy.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.tlsIdentity != nil {
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.tlsIdentity.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.tlsIdentity.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.signature != nil {
+		if options.signature.KeyLookup == nil {
+			gLogger.Fatal("Request signature requires a KeyLookup.")
+		}
+		options.signature.replay = options.replay
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.signature.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.signature.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.replay != nil && options.signature == nil {
+		gLogger.Fatal("Replay protection requires request signature.")
+	}
+	if options.authLockout != nil {
+		// 잠긴 client 의 요청은 인증하지 않도록 인증 Interceptor 보다 먼저 실행.
+		options.authLockout.onLockout = func(key string, failures int, lockedUntil time.Time) {
+			options.securityEvent(SecurityEvent{
+				Type:       SecurityEventAuthLockout,
+				Message:    fmt.Sprintf("%s locked after %d authentication failures", key, failures),
+				Extensions: map[string]string{"key": key, "lockedUntil": lockedUntil.UTC().Format(time.RFC3339)},
+			})
+		}
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.authLockout.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.authLockout.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.securityEvents != nil {
+		// 모든 인증, 권한 Interceptor 의 거부를 보내도록 그보다 먼저 실행.
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.securityEventUnaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.securityEventStreamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.compression != nil {
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.compression.unaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.compression.streamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.flagProvider != nil {
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{featureFlagUnaryServerInterceptor(options.flagProvider)}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{featureFlagStreamServerInterceptor(options.flagProvider)}, streamServerInterceptors...)
+	}
+	// Service 별 요청 수 지표.
+	unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{options.services.unaryServerInterceptor}, unaryServerInterceptors...)
+	streamServerInterceptors = append([]grpc.StreamServerInterceptor{options.services.streamServerInterceptor}, streamServerInterceptors...)
+	if options.requestLogContext {
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{requestLogContextUnaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{requestLogContextStreamServerInterceptor}, streamServerInterceptors...)
+	}
+	if options.recovery {
+		// 다른 Interceptor 의 panic 도 복구하도록 가장 먼저 실행.
+		unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{recoveryUnaryServerInterceptor}, unaryServerInterceptors...)
+		streamServerInterceptors = append([]grpc.StreamServerInterceptor{recoveryStreamServerInterceptor}, streamServerInterceptors...)
+	}
+	var mirror *trafficMirror
+	if options.mirror != nil {
+		if mirror, err = newTrafficMirror(options.mirror); err != nil {
+			gLogger.Fatalf("Failed to create traffic mirror: %v\n", err)
+		}
+		// 다른 Interceptor 를 모두 통과한 요청만 복제하도록 가장 나중에 실행.
+		unaryServerInterceptors = append(unaryServerInterceptors, mirror.unaryServerInterceptor)
+		streamServerInterceptors = append(streamServerInterceptors, mirror.streamServerInterceptor)
+	}
 	if len(unaryServerInterceptors) > 0 {
 		serverOptions = append(serverOptions, grpc.ChainUnaryInterceptor(unaryServerInterceptors...))
 	}
@@ -48,27 +254,81 @@ func New(
 		serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(streamServerInterceptors...))
 	}
 
+	// 등록되지 않은 Service 의 요청은 backend 로 전달.
+	var proxy *grpcProxy
+	if options.proxy != nil {
+		if proxy, err = newGrpcProxy(options.proxy); err != nil {
+			gLogger.Fatalf("Failed to create gRPC proxy: %v\n", err)
+		}
+		serverOptions = append(serverOptions, proxy.serverOptions()...)
+		if options.redactor != nil {
+			gLogger.Println("Response redaction is not applied to proxied responses.")
+		}
+	}
+	if options.forcedCodec != nil {
+		if proxy != nil {
+			gLogger.Fatal("Forced codec cannot be used with gRPC proxy.")
+		}
+		serverOptions = append(serverOptions, grpc.ForceServerCodec(options.forcedCodec))
+	}
+	if options.pooledCodec != nil {
+		if proxy != nil || options.forcedCodec != nil {
+			gLogger.Fatal("Pooled codec cannot be used with forced codec or gRPC proxy.")
+		}
+		// 모든 요청을 pooled codec 으로 처리하므로 content-subtype 별 codec 을 사용할 수 없다.
+		if len(options.codecs) > 0 {
+			gLogger.Fatalf("Pooled codec cannot be used with codecs %v.\n", options.codecs)
+		}
+		serverOptions = append(serverOptions, grpc.ForceServerCodecV2(options.pooledCodec))
+	}
+	// 받은 요청 bytes 로 서명을 확인하기 위해, 사용할 codec 을 감싸 모든 요청을 처리한다.
+	if options.signature != nil {
+		if len(options.codecs) > 0 {
+			gLogger.Fatalf("Request signature cannot be used with codecs %v.\n", options.codecs)
+		}
+		signatureServerOptions, err := options.signature.serverOptions(options, proxy != nil)
+		if err != nil {
+			gLogger.Fatalf("Failed to set up request signature: %v\n", err)
+		}
+		serverOptions = append(serverOptions, signatureServerOptions...)
+	}
+
 	// gRPC Server 생성.
-	grpcServer := grpc.NewServer(serverOptions...)
+	serviceServer, err := newServiceServer(options, serverOptions)
+	if err != nil {
+		gLogger.Fatalf("Failed to create gRPC server: %v\n", err)
+	}
+	healthServer := registerBuiltinServices(serviceServer, options)
+	grpcServer, _ := serviceServer.(*grpc.Server)
 
 	return &GrpcServer{
-		listener:      listener,
-		Server:        grpcServer,
-		network:       network,
-		address:       address,
-		port:          port,
-		httpProxyMux:  nil,
-		httpProxyPort: -1,
+		listener:               listener,
+		Server:                 grpcServer,
+		serviceServer:          serviceServer,
+		network:                network,
+		address:                address,
+		port:                   port,
+		options:                options,
+		socketActivated:        socketActivated,
+		namedListeners:         namedListeners,
+		hasListenerCredentials: hasTLS,
+		httpProxyMux:           nil,
+		httpProxyPort:          -1,
+		ephemeralPort:          ephemeralPort,
+		httpProxyListener:      httpProxyListener,
+		healthServer:           healthServer,
+		proxy:                  proxy,
+		mirror:                 mirror,
 	}
 }
 
 func checkNetwork(network, address string) {
 	if len(network) == 0 || len(address) == 0 {
-		log.Fatal("Server network or address environment variable not set.")
+		gLogger.Fatal("Server network or address environment variable not set.")
 	}
 
 	if !slices.Contains(supportedNetworks, network) {
-		log.Fatalf("This network is not supported: %s\n", network)
+		gLogger.Fatalf("This network is not supported: %s\n", network)
 	}
 }
 
@@ -79,19 +339,61 @@ type Server interface {
 
 type GrpcServer struct {
 	listener net.Listener
-	Server   *grpc.Server
-	network  string
-	address  string
-	port     int
-
-	httpProxyMux  *runtime.ServeMux
-	httpProxyPort int
+	// Server 는 Service 를 등록할 gRPC Server 이다. WithServerFactory 로 다른 Server 를 생성한 경우 nil 이다.
+	Server  *grpc.Server
+	network string
+	address string
+	port    int
+	options *serverOptions
+	// serviceServer 는 요청을 처리하는 gRPC Server 이다. (Server 또는 WithServerFactory 로 생성한 Server)
+	serviceServer ServiceServer
+
+	// systemd 로 부터 전달 받은 Listener 여부. (socket 파일은 systemd 가 관리)
+	socketActivated bool
+
+	// WithListener, AddListener 로 추가한 Listener.
+	namedListeners []*namedListener
+	// Listener 별 TLS 설정 사용 여부.
+	hasListenerCredentials bool
+
+	// port 0 으로 생성하여 OS 가 port 를 할당한 경우, Http Proxy Server 도 OS 가 할당한 port 를 사용.
+	ephemeralPort bool
+
+	httpProxyMux *runtime.ServeMux
+	// RegisterHttpProxyHost 로 등록한 Host 별 ServeMux.
+	httpProxyHosts map[string]*runtime.ServeMux
+	// RegisterHttpProxyVersion 으로 등록한 API version 별 ServeMux.
+	httpProxyVersions []*apiVersionMount
+	httpProxyPort     int
+	httpProxyListener net.Listener
+	httpProxyServer   *http.Server
+	http3Server       *http3.Server
+	// WithWebTransport 의 gRPC over WebTransport 처리.
+	webTransport *webTransportGateway
+
+	healthServer *health.Server
+	// WithProxy 의 backend 연결.
+	proxy *grpcProxy
+	// WithMirror 의 shadow backend 연결.
+	mirror *trafficMirror
+	// 등록에 성공한 WithRegistrar 의 Registrar 와 등록한 Server 정보.
+	registrars []Registrar
+	instance   ServiceInstance
+	// 시작에 성공한 WithWorker 의 Worker 와 Worker 가 사용하는 gRPC 연결.
+	workers    []namedWorker
+	workerConn *grpc.ClientConn
+	// 설정을 다시 읽는 동안 다른 요청이 겹치지 않도록 한다.
+	reloadMutex sync.Mutex
+	// EffectiveConfig 가 다시 읽는 중인 설정을 읽지 않도록 한다.
+	configMutex sync.Mutex
+
+	shuttingDown atomic.Bool
 }
 
 func (pSelf *GrpcServer) Run() {
 
-	if pSelf.Server == nil {
-		log.Fatal("gRPC Server is nil...")
+	if pSelf.serviceServer == nil {
+		gLogger.Fatal("gRPC Server is nil...")
 	}
 
 	// signal handler
@@ -101,85 +403,453 @@ func (pSelf *GrpcServer) Run() {
 	// Run shut down Goroutine
 	go pSelf.postDestroy(cSig)
 
-	// gRPC Gateway (Http Proxy) 실행.
-	if pSelf.httpProxyMux != nil && pSelf.port != pSelf.httpProxyPort && pSelf.httpProxyPort > 0 {
-		go pSelf.runHttpProxy()
-	}
+	pSelf.start()
 
-	log.Printf("Start gRPC server on %s, %s\n", pSelf.network, fmt.Sprintf("%s:%d", pSelf.address, pSelf.port))
 	// Network Listener 에 등록 된 Handler 에 들어오는 연결을 수락하고,
 	// gRPC Service Handler 와 연결하는 새 연결을 생성하여 요청을 Handler 에 전달.
-	if err := pSelf.Server.Serve(pSelf.listener); err != nil {
-		log.Fatalf("Failed to serve: %v\n", err)
+	pSelf.serve(pSelf.listener)
+}
+
+// Start 는 Signal 을 처리하지 않고 Server 를 background 에서 실행한다.
+// fx 등 다른 lifecycle 에 포함할 때 사용하며, 종료는 Shutdown 으로 한다.
+func (pSelf *GrpcServer) Start() error {
+	if pSelf.serviceServer == nil {
+		return errors.New("gRPC server is nil")
+	}
+
+	pSelf.start()
+
+	cErr := pSelf.serveBackground()
+	go func() {
+		if err := <-cErr; err != nil {
+			gLogger.Fatalf("Failed to serve: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// serveBackground 는 gRPC Server 를 Goroutine 에서 실행하고, 종료되면 오류를 전달한다. Shutdown 으로 종료하면 nil 이다.
+func (pSelf *GrpcServer) serveBackground() <-chan error {
+	cErr := make(chan error, 1)
+	go func() {
+		err := pSelf.serviceServer.Serve(pSelf.listener)
+		if pSelf.shuttingDown.Load() {
+			err = nil
+		}
+		cErr <- err
+	}()
+	return cErr
+}
+
+// Shutdown 은 Start 로 실행한 Server 를 종료한다.
+// 새 연결을 받지 않고, 처리 중인 요청이 끝나거나 ctx 가 끝날 때까지 기다린다.
+func (pSelf *GrpcServer) Shutdown(ctx context.Context) error {
+	if !pSelf.shuttingDown.CompareAndSwap(false, true) {
+		return nil
+	}
+	gLogger.Println("Shutting down the server...")
+
+	err := pSelf.shutdown(ctx)
+
+	if strings.EqualFold("unix", pSelf.network) && !pSelf.socketActivated {
+		removeUnixSocket(pSelf.address)
+	}
+	pSelf.closeNamedListeners()
+	pSelf.removePIDFile()
+	return err
+}
+
+// start 는 gRPC Server 외의 Listener 와 Goroutine 을 실행한다.
+func (pSelf *GrpcServer) start() {
+	// 무중단 binary 교체 Goroutine
+	if pSelf.options.upgrader != nil {
+		if pSelf.options.upgrader.options.Signal == nil {
+			gLogger.Println("Upgrade is not supported on this platform")
+		} else {
+			cUpgrade := make(chan os.Signal, 1)
+			signal.Notify(cUpgrade, pSelf.options.upgrader.options.Signal)
+			go pSelf.handleUpgrade(cUpgrade)
+		}
+	}
+
+	// 설정 다시 읽기 Goroutine
+	if pSelf.options.reload != nil {
+		go pSelf.handleReload()
+	}
+	if pSelf.options.kubernetesWatch != nil {
+		go pSelf.watchKubernetes()
+	}
+
+	// 인증서 만료 감시 Goroutine
+	if pSelf.options.certificateExpiry != nil {
+		go pSelf.watchCertificateExpiry()
+	}
+
+	// gRPC Gateway (Http Proxy) Listener 생성.
+	var proxyListener net.Listener
+	if pSelf.hasHttpProxy() && pSelf.httpProxyPort >= 0 && (pSelf.httpProxyPort == 0 || pSelf.port != pSelf.httpProxyPort) {
+		proxyListener = pSelf.listenHttpProxy()
+	}
+	var http3Conn net.PacketConn
+	if proxyListener != nil && pSelf.options.http3 != nil {
+		http3Conn = pSelf.listenHttp3()
+	}
+	if err := pSelf.checkPlaintextPolicy(proxyListener, http3Conn); err != nil {
+		gLogger.Fatalf("Plaintext policy violated: %v\n", err)
+	}
+
+	// PID 파일은 권한을 전환하기 전에 기록.
+	if len(pSelf.options.pidFile) > 0 {
+		if err := writePIDFile(pSelf.options.pidFile); err != nil {
+			pSelf.options.recoverable("Failed to write pid file: %v", err)
+			pSelf.options.pidFile = ""
+		}
+	}
+
+	// 실제로 bind 한 주소 알림.
+	if pSelf.options.portExport != nil {
+		if err := exportPorts(pSelf.options.portExport, pSelf.boundListeners(proxyListener, http3Conn)); err != nil {
+			pSelf.options.recoverable("Failed to export listener ports: %v", err)
+		}
+	}
+
+	// 모든 Listener 를 bind 한 뒤, 요청을 처리하기 전에 권한 전환.
+	if pSelf.options.dropPrivileges != nil {
+		if err := pSelf.options.dropPrivileges.drop(); err != nil {
+			gLogger.Fatalf("Failed to drop privileges: %v\n", err)
+		}
+		gLogger.Printf("Dropped privileges to uid=%d, gid=%d\n", os.Getuid(), os.Getgid())
+	}
+
+	// gRPC Gateway (Http Proxy) 실행.
+	if proxyListener != nil {
+		handler := pSelf.httpProxyHandler()
+		if pSelf.options.signature != nil {
+			handler = pSelf.options.signature.bodyDigestHandler(handler)
+		}
+		if pSelf.options.contentTypes != nil {
+			handler = pSelf.options.contentTypes.handler(handler)
+		}
+		if pSelf.options.csrf != nil {
+			handler = pSelf.options.csrf.handler(handler)
+		}
+		if pSelf.options.headerLimits != nil {
+			handler = pSelf.options.headerLimits.handler(handler)
+		}
+		if pSelf.options.securityHeaders != nil {
+			handler = pSelf.options.securityHeaders.handler(handler)
+		}
+		var tlsConfig *tls.Config
+		if http3Conn != nil {
+			pSelf.http3Server = pSelf.newHttp3Server(handler, http3Conn)
+			handler = pSelf.altSvcHandler(handler)
+			tlsConfig = pSelf.options.http3.TLS.Clone()
+		}
+		if tlsConfig == nil && pSelf.options.h2c {
+			handler = pSelf.h2cHandler(handler)
+		}
+
+		pSelf.httpProxyServer = &http.Server{
+			Handler:   handler,
+			TLSConfig: tlsConfig,
+		}
+		if err := pSelf.options.httpTimeouts.apply(pSelf.httpProxyServer, pSelf.options.idleTimeout); err != nil {
+			pSelf.options.recoverable("failed to configure HTTP/2 for Http Proxy Server: %v", err)
+		}
+		if pSelf.options.headerLimits != nil {
+			pSelf.httpProxyServer.MaxHeaderBytes = pSelf.options.headerLimits.MaxBytes
+		}
+		pSelf.options.alpn.gateway(pSelf.httpProxyServer)
+		go pSelf.runHttpProxy(proxyListener)
+		if http3Conn != nil {
+			go pSelf.runHttp3(http3Conn)
+		}
+	}
+
+	// 추가 Listener 실행.
+	for _, l := range pSelf.namedListeners {
+		go pSelf.serveNamedListener(l)
+	}
+
+	// 이전 프로세스에 준비 완료 알림.
+	pSelf.options.upgrader.notifyReady()
+
+	// service discovery 에 등록.
+	if len(pSelf.options.registrars) > 0 {
+		pSelf.register()
+	}
+
+	// background 작업 시작.
+	if len(pSelf.options.workers) > 0 {
+		pSelf.startWorkers()
+	}
+
+	gLogger.Printf("Start gRPC server on %s, %s\n", pSelf.network, joinAddress(pSelf.network, pSelf.address, pSelf.port))
+}
+
+func (pSelf *GrpcServer) serve(listener net.Listener) {
+	err := pSelf.serviceServer.Serve(listener)
+	if pSelf.shuttingDown.Load() {
+		// 종료 중에 gRPC Server 가 멈춘 경우, postDestroy 가 정리를 마치고 종료할 때까지 대기.
+		select {}
+	}
+	if err != nil {
+		gLogger.Fatalf("Failed to serve: %v\n", err)
 	}
 }
 
-func (pSelf *GrpcServer) runHttpProxy() {
-	if pSelf.httpProxyMux == nil || pSelf.httpProxyPort == -1 {
-		log.Println("Http Proxy Server is not set")
+func (pSelf *GrpcServer) listenHttpProxy() net.Listener {
+	proxyListener := pSelf.httpProxyListener
+	if proxyListener == nil {
+		var err error
+		proxyListener, err = listen(pSelf.httpProxyNetwork(), pSelf.address, pSelf.httpProxyPort, pSelf.options)
+		if err != nil {
+			pSelf.options.recoverable("failed to listen Http proxy server: %v", err)
+			pSelf.httpProxyPort = -1
+			return nil
+		}
+	}
+
+	proxyListener, err := pSelf.options.wrapListener(proxyListener)
+	if err != nil {
+		pSelf.options.recoverable("failed to listen Http proxy server: %v", err)
+		pSelf.httpProxyPort = -1
+		return nil
+	}
+	pSelf.httpProxyPort = boundPort(proxyListener.Addr(), pSelf.httpProxyPort)
+	return proxyListener
+}
+
+func (pSelf *GrpcServer) runHttpProxy(proxyListener net.Listener) {
+	if !pSelf.hasHttpProxy() || pSelf.httpProxyPort == -1 {
+		gLogger.Println("Http Proxy Server is not set")
 		return
 	}
 
-	proxyFullAddress := fmt.Sprintf("%s:%d", pSelf.address, pSelf.httpProxyPort)
-	log.Printf("Start HTTP proxy server on %s, %s\n", pSelf.network, proxyFullAddress)
-	if err := http.ListenAndServe(proxyFullAddress, pSelf.httpProxyMux); err != nil {
-		log.Fatalf("failed to listen and serve Http proxy server: %v", err)
+	proxyFullAddress := joinAddress(pSelf.httpProxyNetwork(), pSelf.address, pSelf.httpProxyPort)
+	gLogger.Printf("Start HTTP proxy server on %s, %s\n", pSelf.network, proxyFullAddress)
+
+	var err error
+	if pSelf.httpProxyServer.TLSConfig != nil {
+		err = pSelf.httpProxyServer.ServeTLS(proxyListener, "", "")
+	} else {
+		err = pSelf.httpProxyServer.Serve(proxyListener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		gLogger.Fatalf("failed to serve Http proxy server: %v", err)
+	}
+}
+
+func (pSelf *GrpcServer) handleUpgrade(cUpgrade chan os.Signal) {
+	for sig := range cUpgrade {
+		gLogger.Printf("Caught signal: %s", sig)
+		gLogger.Println("Upgrading the server...")
+
+		if err := pSelf.options.upgrader.spawn(); err != nil {
+			gLogger.Printf("Failed to upgrade: %v\n", err)
+			continue
+		}
+
+		gLogger.Println("New process is ready, draining connections...")
+		pSelf.options.upgrader.release()
+		pSelf.drain(pSelf.options.upgrader.options.DrainTimeout)
+		pSelf.removePIDFile()
+
+		gLogger.Println("Bye Bye!!!")
+		os.Exit(0)
+	}
+}
+
+// drain 은 새 연결을 받지 않고 처리 중인 요청이 끝날 때까지 timeout 만큼 기다린다.
+// 새 프로세스가 같은 ID 로 이미 등록했으므로 service discovery 에서 해제하지 않는다.
+func (pSelf *GrpcServer) drain(timeout time.Duration) {
+	pSelf.shuttingDown.Store(true)
+	pSelf.registrars = nil
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_ = pSelf.shutdown(ctx)
+}
+
+// shutdown 은 새 연결을 받지 않고, 처리 중인 요청이 끝나거나 ctx 가 끝날 때까지 기다린다.
+func (pSelf *GrpcServer) shutdown(ctx context.Context) error {
+	// 새 요청이 오지 않도록 service discovery 에서 먼저 해제.
+	pSelf.deregister(ctx)
+	if pSelf.healthServer != nil {
+		pSelf.healthServer.Shutdown()
+	}
+	// Worker 가 처리 중인 요청은 gRPC Server 를 종료하기 전에 끝낸다.
+	pSelf.stopWorkers(ctx)
+	if pSelf.options.certificateExpiry != nil {
+		pSelf.options.certificateExpiry.stop()
+	}
+	if pSelf.proxy != nil {
+		defer pSelf.proxy.close()
+	}
+	if pSelf.mirror != nil {
+		defer pSelf.mirror.close()
+	}
+
+	cStopped := make(chan struct{})
+	go func() {
+		pSelf.serviceServer.GracefulStop()
+		close(cStopped)
+	}()
+
+	if pSelf.httpProxyServer != nil {
+		if err := pSelf.httpProxyServer.Shutdown(ctx); err != nil {
+			gLogger.Printf("Failed to shut down Http proxy server gracefully: %v\n", err)
+		}
+	}
+	pSelf.shutdownNamedListeners(ctx)
+	if pSelf.webTransport != nil {
+		// WebTransport session 이 남아 있으면 HTTP/3 Server 가 종료되지 않으므로 먼저 닫는다.
+		defer pSelf.webTransport.close()
+		pSelf.webTransport.closeSessions()
+	}
+	if pSelf.http3Server != nil {
+		if err := pSelf.http3Server.Shutdown(ctx); err != nil {
+			gLogger.Printf("Failed to shut down HTTP/3 server gracefully: %v\n", err)
+		}
+	}
+
+	select {
+	case <-cStopped:
+		return nil
+	case <-ctx.Done():
+		gLogger.Println("Timed out waiting for connections to drain")
+		pSelf.serviceServer.Stop()
+		return ctx.Err()
+	}
+}
+
+// httpProxyNetwork 는 Http Proxy Server 가 listen 할 network 이다.
+// vsock 은 TCP 로 listen 할 수 없으므로, tcp4 와 tcp6 는 주소 체계를 맞추기 위해 gRPC Server 와 같은 network 를 사용.
+func (pSelf *GrpcServer) httpProxyNetwork() string {
+	switch strings.ToLower(pSelf.network) {
+	case "vsock", "tcp4", "tcp6":
+		return pSelf.network
+	}
+	return "tcp"
+}
+
+// WithHttpProxyPort 는 RegisterHttpProxyServer 의 httpProxyPort 가 -1 일 때 사용할 Http Proxy Server 의 port 이다.
+func WithHttpProxyPort(port int) Option {
+	return func(options *serverOptions) {
+		options.httpProxyPort = &port
 	}
 }
 
 func (pSelf *GrpcServer) RegisterHttpProxyServer(httpProxyServerHandlerFuncSlice []HttpProxyServerHandler, ctx context.Context, mux *runtime.ServeMux, opts []grpc.DialOption, httpProxyPort int) {
 	if httpProxyServerHandlerFuncSlice == nil || len(httpProxyServerHandlerFuncSlice) == 0 {
-		log.Fatal("Http Proxy Server is nil...")
+		pSelf.options.recoverable("Http Proxy Server is nil...")
+		return
 	}
 
+	pSelf.setHttpProxyPort(httpProxyPort)
+	if mux == nil {
+		mux = runtime.NewServeMux()
+	}
+	pSelf.httpProxyMux = mux
+	pSelf.registerHttpGateways(httpProxyServerHandlerFuncSlice, ctx, mux, opts)
+}
+
+// setHttpProxyPort 는 Http Proxy Server 의 port 를 설정한다. -1 이면 gRPC port + 1 (또는 WithHttpProxyPort) 을 사용한다.
+func (pSelf *GrpcServer) setHttpProxyPort(httpProxyPort int) {
 	pSelf.httpProxyPort = httpProxyPort
 	if pSelf.httpProxyPort == -1 {
 		pSelf.httpProxyPort = pSelf.port + 1
+		if pSelf.ephemeralPort {
+			pSelf.httpProxyPort = 0
+		}
+		if pSelf.options.httpProxyPort != nil {
+			pSelf.httpProxyPort = *pSelf.options.httpProxyPort
+		}
 	}
+}
 
+// registerHttpGateways 는 gRPC Gateway handler 를 mux 에 등록한다.
+func (pSelf *GrpcServer) registerHttpGateways(httpProxyServerHandlerFuncSlice []HttpProxyServerHandler, ctx context.Context, mux *runtime.ServeMux, opts []grpc.DialOption) {
 	checkedCtx := ctx
-	checkedMux := mux
 	checkedOptions := opts
 
 	if checkedCtx == nil {
 		checkedCtx = context.Background()
 	}
 
-	if checkedMux == nil {
-		checkedMux = runtime.NewServeMux()
+	if checkedOptions == nil {
+		checkedOptions = pSelf.selfDialOptions()
+	}
+	if pSelf.options.signature != nil {
+		// 서명을 확인할 Method 를 호출할 때 HTTP body 의 SHA-256 을 전달.
+		checkedOptions = append(slices.Clip(checkedOptions),
+			grpc.WithChainUnaryInterceptor(pSelf.options.signature.gatewayUnaryClientInterceptor),
+			grpc.WithChainStreamInterceptor(pSelf.options.signature.gatewayStreamClientInterceptor))
 	}
-	pSelf.httpProxyMux = mux
 
-	if checkedOptions == nil {
-		checkedOptions = []grpc.DialOption{
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
+	for _, httpProxyServerHandlerFunc := range httpProxyServerHandlerFuncSlice {
+		if err := httpProxyServerHandlerFunc(checkedCtx, mux, pSelf.grpcEndpoint(), checkedOptions); err != nil {
+			pSelf.options.recoverable("failed to register Http gateway: %v (%v)", err, &httpProxyServerHandlerFunc)
 		}
 	}
+}
 
-	for _, httpProxyServerHandlerFunc := range httpProxyServerHandlerFuncSlice {
-		if err := httpProxyServerHandlerFunc(checkedCtx, checkedMux, fmt.Sprintf("%s:%d", pSelf.address, pSelf.port), checkedOptions); err != nil {
-			log.Fatalf("failed to register Http gateway: %v (%v)", err, &httpProxyServerHandlerFunc)
+// selfDialOptions 는 같은 프로세스의 gRPC Server 에 연결할 때 사용하는 기본 DialOption 이다.
+func (pSelf *GrpcServer) selfDialOptions() []grpc.DialOption {
+	dialOptions := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	if pSelf.options.tlsConfig != nil {
+		// 같은 프로세스의 gRPC Server 에 연결하므로 인증서 검증은 생략.
+		dialOptions = []grpc.DialOption{
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})),
 		}
 	}
+	if strings.EqualFold("vsock", pSelf.network) {
+		dialOptions = append(dialOptions, grpc.WithContextDialer(dialVsock))
+	}
+	return dialOptions
+}
+
+// grpcEndpoint 는 gRPC Gateway 가 gRPC Server 에 연결할 때 사용하는 주소이다.
+func (pSelf *GrpcServer) grpcEndpoint() string {
+	endpoint := joinAddress(pSelf.network, pSelf.address, pSelf.port)
+	if strings.EqualFold("unix", pSelf.network) {
+		return "unix:" + endpoint
+	}
+	if strings.EqualFold("vsock", pSelf.network) {
+		// vsock 주소는 DNS 로 해석할 수 없으므로 ContextDialer 로 그대로 전달.
+		return "passthrough:///" + endpoint
+	}
+	return endpoint
 }
 
 func (pSelf *GrpcServer) postDestroy(cSig chan os.Signal) {
 	sig := <-cSig
-	log.Printf("Caught signal: %s", sig)
-	log.Println("Shutting down the server...")
-
-	err := pSelf.listener.Close()
-	if err != nil {
-		log.Fatal(err)
+	gLogger.Printf("Caught signal: %s", sig)
+	if pSelf.shuttingDown.Load() {
+		// 이미 종료 중이면 (e.g. binary 교체 후 drain) Worker 를 종료하는 도중에 끝내지 않도록 그 종료를 기다린다.
+		select {}
 	}
 
-	if strings.EqualFold("unix", pSelf.network) {
-		err = os.Remove(pSelf.address)
-		if err != nil {
-			log.Fatal(err)
-		}
+	// Shutdown 과 같이 service discovery 해제, Worker 종료를 거쳐 처리 중인 요청을 기다린다.
+	ctx, cancel := context.WithTimeout(context.Background(), signalShutdownTimeout)
+	if err := pSelf.Shutdown(ctx); err != nil {
+		gLogger.Printf("Failed to shut down gracefully: %v\n", err)
 	}
+	cancel()
 
-	log.Println("Bye Bye!!!")
+	gLogger.Println("Bye Bye!!!")
 	os.Exit(0)
 }
+
+func (pSelf *GrpcServer) removePIDFile() {
+	if len(pSelf.options.pidFile) == 0 {
+		return
+	}
+
+	// 권한을 전환한 경우 삭제하지 못할 수 있으므로 종료를 막지 않는다.
+	if err := removePIDFile(pSelf.options.pidFile); err != nil {
+		gLogger.Printf("Failed to remove pid file: %v\n", err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package serveraws 는 AWS Secrets Manager, SSM Parameter Store 에서 Secret 을 가져오는 server.SecretProvider 이다.
+// ECS task role, EKS IRSA 등의 credential 은 aws.Config 로 전달한다.
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	...
+//	provider := serveraws.NewSecretsManager(cfg)
+//	certSecret, err := server.NewManagedSecret(ctx, provider, "myapp/tls", time.Hour)
+//	tlsConfig, err := server.TLSConfigFromSecret(certSecret, "certificate", "private_key")
+package serveraws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/berryons/server"
+	"strconv"
+	"strings"
+)
+
+// ValueKey 는 JSON 객체가 아닌 Secret, Parameter 의 값을 담는 key 이다.
+const ValueKey = "value"
+
+// SecretsManagerClient 는 SecretsManagerProvider 가 사용하는 Secrets Manager API 이다.
+type SecretsManagerClient interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// SecretsManagerProvider 는 AWS Secrets Manager 에서 Secret 을 읽는다.
+type SecretsManagerProvider struct {
+	client SecretsManagerClient
+}
+
+// NewSecretsManager 는 cfg 로 Secrets Manager Provider 를 생성한다.
+func NewSecretsManager(cfg aws.Config) *SecretsManagerProvider {
+	return NewSecretsManagerFromClient(secretsmanager.NewFromConfig(cfg))
+}
+
+// NewSecretsManagerFromClient 는 client 로 Secrets Manager Provider 를 생성한다.
+func NewSecretsManagerFromClient(client SecretsManagerClient) *SecretsManagerProvider {
+	return &SecretsManagerProvider{client: client}
+}
+
+// GetSecret 은 name (Secret 이름 또는 ARN) 의 현재 버전 (AWSCURRENT) 을 읽는다.
+// SecretString 이 JSON 객체이면 항목별로, 아니면 ValueKey 에 담는다.
+func (pSelf *SecretsManagerProvider) GetSecret(ctx context.Context, name string) (*server.Secret, error) {
+	output, err := pSelf.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(name)})
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &server.Secret{Version: aws.ToString(output.VersionId)}
+	switch {
+	case output.SecretString != nil:
+		secret.Data = parseValue(*output.SecretString)
+	case output.SecretBinary != nil:
+		secret.Data = map[string]string{ValueKey: string(output.SecretBinary)}
+	default:
+		return nil, errors.New("secret has no value: " + name)
+	}
+	return secret, nil
+}
+
+// SSMClient 는 ParameterStoreProvider 가 사용하는 SSM API 이다.
+type SSMClient interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	ssm.GetParametersByPathAPIClient
+}
+
+// ParameterStoreProvider 는 SSM Parameter Store 에서 Parameter 를 읽는다. SecureString 은 복호화한다.
+type ParameterStoreProvider struct {
+	client SSMClient
+}
+
+// NewParameterStore 는 cfg 로 Parameter Store Provider 를 생성한다.
+func NewParameterStore(cfg aws.Config) *ParameterStoreProvider {
+	return NewParameterStoreFromClient(ssm.NewFromConfig(cfg))
+}
+
+// NewParameterStoreFromClient 는 client 로 Parameter Store Provider 를 생성한다.
+func NewParameterStoreFromClient(client SSMClient) *ParameterStoreProvider {
+	return &ParameterStoreProvider{client: client}
+}
+
+// GetSecret 은 name 의 Parameter 를 읽는다.
+// name 이 "/" 로 끝나면 그 아래의 모든 Parameter 를 경로를 뺀 이름으로 읽는다. (e.g. /myapp/tls/ → certificate, private_key)
+// 하나의 Parameter 는 값이 JSON 객체이면 항목별로, 아니면 ValueKey 에 담는다.
+func (pSelf *ParameterStoreProvider) GetSecret(ctx context.Context, name string) (*server.Secret, error) {
+	if strings.HasSuffix(name, "/") {
+		return pSelf.getParametersByPath(ctx, name)
+	}
+
+	output, err := pSelf.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &server.Secret{
+		Data:    parseValue(aws.ToString(output.Parameter.Value)),
+		Version: strconv.FormatInt(output.Parameter.Version, 10),
+	}, nil
+}
+
+func (pSelf *ParameterStoreProvider) getParametersByPath(ctx context.Context, path string) (*server.Secret, error) {
+	secret := &server.Secret{Data: map[string]string{}}
+	var versions []string
+
+	paginator := ssm.NewGetParametersByPathPaginator(pSelf.client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, parameter := range page.Parameters {
+			key := strings.TrimPrefix(aws.ToString(parameter.Name), path)
+			secret.Data[key] = aws.ToString(parameter.Value)
+			versions = append(versions, key+"="+strconv.FormatInt(parameter.Version, 10))
+		}
+	}
+	secret.Version = strings.Join(versions, ",")
+	return secret, nil
+}
+
+// parseValue 는 JSON 객체를 항목별로 나누고, 아니면 ValueKey 에 담는다.
+func parseValue(value string) map[string]string {
+	var object map[string]any
+	if err := json.Unmarshal([]byte(value), &object); err != nil {
+		return map[string]string{ValueKey: value}
+	}
+
+	data := make(map[string]string, len(object))
+	for key, v := range object {
+		if s, ok := v.(string); ok {
+			data[key] = s
+			continue
+		}
+		b, _ := json.Marshal(v)
+		data[key] = string(b)
+	}
+	return data
+}
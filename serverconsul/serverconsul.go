@@ -0,0 +1,183 @@
+// Package serverconsul 은 Consul agent 에 Server 를 등록하는 server.Registrar 이다.
+//
+//	registrar, err := serverconsul.New(serverconsul.Config{})  // CONSUL_HTTP_ADDR, CONSUL_HTTP_TOKEN 사용
+//	...
+//	grpcServer := server.NewFromConfig("server.yaml", nil, nil, server.WithHealthCheck(), server.WithRegistrar(registrar))
+package serverconsul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/berryons/server"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAddress                        = "http://127.0.0.1:8500"
+	defaultTimeout                        = 10 * time.Second
+	defaultCheckInterval                  = 10 * time.Second
+	defaultDeregisterCriticalServiceAfter = time.Minute
+)
+
+// Config 는 Consul 연결과 health check 설정이다. 비어 있는 항목은 Consul CLI 와 같은 환경 변수를 사용한다.
+type Config struct {
+	// Address 는 Consul agent 주소이다. (기본값: CONSUL_HTTP_ADDR, http://127.0.0.1:8500)
+	Address string
+	// Token 은 ACL token 이다. (기본값: CONSUL_HTTP_TOKEN)
+	Token string
+	// Namespace 는 Consul Enterprise namespace 이다. (기본값: CONSUL_NAMESPACE)
+	Namespace string
+	// CheckInterval 은 gRPC health check 주기이다. (기본값: 10초)
+	CheckInterval time.Duration
+	// DeregisterCriticalServiceAfter 는 health check 가 계속 실패한 Server 를 Consul 이 해제하기까지의 시간이다. (기본값: 1분)
+	// 프로세스가 종료 처리 없이 끝난 경우에도 등록이 남지 않도록 한다.
+	DeregisterCriticalServiceAfter time.Duration
+	// HTTPClient 가 nil 이면 timeout 10초의 http.Client 를 사용한다.
+	HTTPClient *http.Client
+}
+
+// Registrar 는 Consul agent HTTP API 로 Server 를 등록한다.
+type Registrar struct {
+	config Config
+}
+
+// New 는 Consul Registrar 를 생성한다.
+func New(config Config) (*Registrar, error) {
+	if len(config.Address) == 0 {
+		config.Address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if len(config.Address) == 0 {
+		config.Address = defaultAddress
+	}
+	if !strings.Contains(config.Address, "://") {
+		config.Address = "http://" + config.Address
+	}
+	if len(config.Token) == 0 {
+		config.Token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if len(config.Namespace) == 0 {
+		config.Namespace = os.Getenv("CONSUL_NAMESPACE")
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaultCheckInterval
+	}
+	if config.DeregisterCriticalServiceAfter <= 0 {
+		config.DeregisterCriticalServiceAfter = defaultDeregisterCriticalServiceAfter
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	if _, err := url.Parse(config.Address); err != nil {
+		return nil, fmt.Errorf("invalid consul address: %w", err)
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &Registrar{config: config}, nil
+}
+
+type serviceRegistration struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *serviceCheck     `json:"Check,omitempty"`
+}
+
+type serviceCheck struct {
+	Name                           string `json:"Name"`
+	GRPC                           string `json:"GRPC,omitempty"`
+	GRPCUseTLS                     bool   `json:"GRPCUseTLS,omitempty"`
+	TLSSkipVerify                  bool   `json:"TLSSkipVerify,omitempty"`
+	TCP                            string `json:"TCP,omitempty"`
+	Interval                       string `json:"Interval"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+// Register 는 Server 를 등록한다. Metadata 에 http_port 를 추가한다.
+// WithHealthCheck 를 사용하면 gRPC health check, 아니면 TCP check 를 등록한다.
+func (pSelf *Registrar) Register(ctx context.Context, instance server.ServiceInstance) error {
+	address := net.JoinHostPort(instance.Address, strconv.Itoa(instance.Port))
+	check := &serviceCheck{
+		Name:                           "gRPC " + address,
+		Interval:                       pSelf.config.CheckInterval.String(),
+		DeregisterCriticalServiceAfter: pSelf.config.DeregisterCriticalServiceAfter.String(),
+	}
+	if instance.Health {
+		check.GRPC = address
+		check.GRPCUseTLS = instance.TLS
+		// 인증서의 이름과 등록한 주소가 다를 수 있으므로 연결 여부만 확인.
+		check.TLSSkipVerify = instance.TLS
+	} else {
+		check.TCP = address
+	}
+
+	meta := make(map[string]string, len(instance.Metadata)+1)
+	for k, v := range instance.Metadata {
+		meta[k] = v
+	}
+	if instance.HttpPort > 0 {
+		meta["http_port"] = strconv.Itoa(instance.HttpPort)
+	}
+
+	return pSelf.put(ctx, "/v1/agent/service/register", serviceRegistration{
+		ID:      instance.ID,
+		Name:    instance.Name,
+		Tags:    instance.Tags,
+		Address: instance.Address,
+		Port:    instance.Port,
+		Meta:    meta,
+		Check:   check,
+	})
+}
+
+// Deregister 는 Server 의 등록을 해제한다.
+func (pSelf *Registrar) Deregister(ctx context.Context, instance server.ServiceInstance) error {
+	return pSelf.put(ctx, "/v1/agent/service/deregister/"+url.PathEscape(instance.ID), nil)
+}
+
+func (pSelf *Registrar) put(ctx context.Context, path string, body any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, pSelf.config.Address+path, reader)
+	if err != nil {
+		return err
+	}
+	if len(pSelf.config.Token) > 0 {
+		request.Header.Set("X-Consul-Token", pSelf.config.Token)
+	}
+	if len(pSelf.config.Namespace) > 0 {
+		query := request.URL.Query()
+		query.Set("ns", pSelf.config.Namespace)
+		request.URL.RawQuery = query.Encode()
+	}
+
+	response, err := pSelf.config.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("consul returned %s for %s: %s", response.Status, path, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package serverfx 는 fx 를 사용하는 application 에서 gRPC Server 를 구성하는 fx.Module 이다.
+//
+//	fx.New(
+//		fx.Supply(config), // *server.Config
+//		serverfx.Module,
+//		serverfx.AsService(func(s *grpc.Server) { pb.RegisterGreeterServer(s, &greeter{}) }),
+//		serverfx.AsGateway(pb.RegisterGreeterHandlerFromEndpoint),
+//	).Run()
+package serverfx
+
+import (
+	"context"
+	"github.com/berryons/server"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+)
+
+const (
+	unaryInterceptorGroup  = "grpc_unary_interceptors"
+	streamInterceptorGroup = "grpc_stream_interceptors"
+	optionGroup            = "server_options"
+	serviceGroup           = "grpc_services"
+	gatewayGroup           = "http_gateway_handlers"
+)
+
+// Module 은 *server.GrpcServer, *grpc.Server 를 제공하고, fx lifecycle 에 맞춰 Server 를 실행, 종료한다.
+// application 은 *server.Config 를 제공해야 한다.
+var Module = fx.Module("server",
+	fx.Provide(New),
+	fx.Invoke(Register),
+)
+
+// ServiceRegistration 은 gRPC Server 에 Service 를 등록하는 함수이다.
+type ServiceRegistration func(grpcServer *grpc.Server)
+
+// Params 는 New 가 fx 로 부터 전달 받는 값이다.
+type Params struct {
+	fx.In
+
+	Config             *server.Config
+	UnaryInterceptors  []grpc.UnaryServerInterceptor  `group:"grpc_unary_interceptors"`
+	StreamInterceptors []grpc.StreamServerInterceptor `group:"grpc_stream_interceptors"`
+	Options            []server.Option                `group:"server_options"`
+}
+
+// Result 는 New 가 fx 에 제공하는 값이다.
+type Result struct {
+	fx.Out
+
+	GrpcServer *server.GrpcServer
+	Server     *grpc.Server
+}
+
+// New 는 Config 와 group 으로 제공된 Interceptor, Option 으로 gRPC Server 를 생성한다.
+func New(params Params) (Result, error) {
+	if err := params.Config.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	grpcServer := server.NewWithConfig(params.Config, params.UnaryInterceptors, params.StreamInterceptors, params.Options...)
+	return Result{GrpcServer: grpcServer, Server: grpcServer.Server}, nil
+}
+
+// RegisterParams 는 Register 가 fx 로 부터 전달 받는 값이다.
+type RegisterParams struct {
+	fx.In
+
+	Lifecycle  fx.Lifecycle
+	GrpcServer *server.GrpcServer
+	Services   []ServiceRegistration           `group:"grpc_services"`
+	Gateways   []server.HttpProxyServerHandler `group:"http_gateway_handlers"`
+}
+
+// Register 는 Service 와 gRPC Gateway Handler 를 등록하고, fx lifecycle 에 Server 의 Start, Shutdown 을 추가한다.
+func Register(params RegisterParams) {
+	for _, registerService := range params.Services {
+		registerService(params.GrpcServer.Server)
+	}
+	if len(params.Gateways) > 0 {
+		params.GrpcServer.RegisterHttpProxyServer(params.Gateways, context.Background(), runtime.NewServeMux(), nil, -1)
+	}
+
+	params.Lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return params.GrpcServer.Start()
+		},
+		OnStop: params.GrpcServer.Shutdown,
+	})
+}
+
+// AsService 는 Service 등록 함수를 fx 에 제공한다.
+func AsService(registration ServiceRegistration) fx.Option {
+	return fx.Supply(fx.Annotated{Group: serviceGroup, Target: registration})
+}
+
+// AsGateway 는 gRPC Gateway Handler 를 fx 에 제공한다.
+func AsGateway(handler server.HttpProxyServerHandler) fx.Option {
+	return fx.Supply(fx.Annotated{Group: gatewayGroup, Target: handler})
+}
+
+// AsUnaryInterceptor 는 Unary Interceptor 를 fx 에 제공한다. 제공한 순서대로 실행되지는 않는다.
+func AsUnaryInterceptor(interceptor grpc.UnaryServerInterceptor) fx.Option {
+	return fx.Supply(fx.Annotated{Group: unaryInterceptorGroup, Target: interceptor})
+}
+
+// AsStreamInterceptor 는 Stream Interceptor 를 fx 에 제공한다. 제공한 순서대로 실행되지는 않는다.
+func AsStreamInterceptor(interceptor grpc.StreamServerInterceptor) fx.Option {
+	return fx.Supply(fx.Annotated{Group: streamInterceptorGroup, Target: interceptor})
+}
+
+// AsOption 은 server.Option 을 fx 에 제공한다.
+func AsOption(option server.Option) fx.Option {
+	return fx.Supply(fx.Annotated{Group: optionGroup, Target: option})
+}
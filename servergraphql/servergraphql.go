@@ -0,0 +1,569 @@
+// Package servergraphql 은 gRPC Service 를 Http Proxy Server 의 GraphQL endpoint 로 제공한다.
+// GraphQL schema 는 protobuf 정의로 생성하며, 요청은 gRPC Gateway 와 같이 Server 의 gRPC endpoint 로 전달한다.
+//
+//	grpcServer.RegisterHttpProxyServer([]server.HttpProxyServerHandler{
+//		pb.RegisterGreeterHandlerFromEndpoint,
+//		servergraphql.Handler(servergraphql.Options{Services: []string{"myapp.Greeter"}}),
+//	}, context.Background(), nil, nil, -1)
+//
+// Unary Method 는 "greeterSayHello(input: myapp_HelloRequestInput): myapp_HelloReply" 형식의 field 가 되며,
+// 이름의 첫 단어가 Get, List 등이거나 idempotency_level 이 NO_SIDE_EFFECTS 인 Method 는 Query, 나머지는 Mutation 이다.
+// Streaming Method 는 제공하지 않는다.
+package servergraphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultPath = "/graphql"
+	// maxRequestSize 는 GraphQL 요청 body 의 최대 크기이다.
+	maxRequestSize = 1 << 20
+
+	defaultMaxFields  = 500
+	defaultMaxAliases = 50
+)
+
+// defaultQueryPrefixes 는 Query 로 제공할 Method 이름의 기본 prefix 이다.
+var defaultQueryPrefixes = []string{"Get", "List", "Search", "Find", "Check", "Count", "BatchGet"}
+
+// Options 는 GraphQL endpoint 설정이다.
+type Options struct {
+	// Services 는 GraphQL 로 제공할 gRPC Service 의 전체 이름이다. (e.g. myapp.Greeter)
+	// Service 의 pb 패키지를 import 하여 protobuf registry 에 등록되어 있어야 한다.
+	Services []string
+	// Path 는 GraphQL endpoint 의 path 이다. (기본값: /graphql)
+	Path string
+	// QueryPrefixes 는 Query 로 제공할 Method 이름의 첫 단어이다. (기본값: Get, List, Search, Find, Check, Count, BatchGet)
+	// 단어 단위로 비교하므로 Check 는 CheckStatus 와 같지만 CheckoutOrder 와는 다르다.
+	QueryPrefixes []string
+	// MaxFields 는 요청 하나가 선택하는 field 의 최대 수이다. fragment 는 펼쳐서 센다. (기본값: 500)
+	MaxFields int
+	// MaxAliases 는 요청 하나의 alias 의 최대 수이다. 같은 Method 를 alias 로 여러 번 호출하는 요청을 제한한다. (기본값: 50)
+	MaxAliases int
+	// Files 는 Service 를 찾을 protobuf registry 이다. (기본값: protoregistry.GlobalFiles)
+	Files *protoregistry.Files
+}
+
+// Handler 는 Options.Path 에 GraphQL endpoint 를 등록하는 HttpProxyServerHandler 이다.
+// POST 는 {"query", "variables", "operationName"} JSON body 를, GET 은 같은 이름의 query parameter 를 받는다. (GET 은 Query 만 실행)
+// HTTP header 는 gRPC Gateway 와 같은 규칙으로 gRPC metadata 로 전달하며, gRPC status 는 error 의 extensions.code 로 응답한다.
+func Handler(options Options) server.HttpProxyServerHandler {
+	return func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+		if len(options.Path) == 0 {
+			options.Path = defaultPath
+		}
+		if options.QueryPrefixes == nil {
+			options.QueryPrefixes = defaultQueryPrefixes
+		}
+		if options.Files == nil {
+			options.Files = protoregistry.GlobalFiles
+		}
+		if options.MaxFields <= 0 {
+			options.MaxFields = defaultMaxFields
+		}
+		if options.MaxAliases <= 0 {
+			options.MaxAliases = defaultMaxAliases
+		}
+
+		conn, err := grpc.NewClient(endpoint, opts...)
+		if err != nil {
+			return err
+		}
+		schema, err := newSchemaBuilder(options, conn).build()
+		if err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("graphql schema: %w", err)
+		}
+		go func() {
+			<-ctx.Done()
+			_ = conn.Close()
+		}()
+
+		endpointHandler := &graphqlHandler{schema: schema, mux: mux, path: options.Path, maxFields: options.MaxFields, maxAliases: options.MaxAliases}
+		if err := mux.HandlePath(http.MethodPost, options.Path, endpointHandler.serveHTTP); err != nil {
+			return err
+		}
+		return mux.HandlePath(http.MethodGet, options.Path, endpointHandler.serveHTTP)
+	}
+}
+
+// graphqlRequest 는 GraphQL over HTTP 요청이다.
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+type graphqlHandler struct {
+	schema     graphql.Schema
+	mux        *runtime.ServeMux
+	path       string
+	maxFields  int
+	maxAliases int
+}
+
+func (pSelf *graphqlHandler) serveHTTP(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	var request graphqlRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); len(variables) > 0 {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				http.Error(w, "invalid variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&request); err != nil {
+		http.Error(w, "invalid graphql request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 해석하지 못한 요청의 오류는 graphql.Do 에서 응답.
+	if document, err := parser.Parse(parser.ParseParams{Source: request.Query}); err == nil {
+		if r.Method == http.MethodGet && isMutation(document, request.OperationName) {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "mutation is not allowed with GET", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := pSelf.checkSelections(document); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, err := runtime.AnnotateContext(r.Context(), pSelf.mux, r, pSelf.path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         pSelf.schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        ctx,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// isMutation 은 요청이 실행할 operation 이 mutation 인지 여부이다.
+func isMutation(document *ast.Document, operationName string) bool {
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if len(operationName) == 0 || (operation.Name != nil && operation.Name.Value == operationName) {
+			return operation.Operation == ast.OperationTypeMutation
+		}
+	}
+	return false
+}
+
+// checkSelections 는 요청의 field 와 alias 수가 MaxFields, MaxAliases 를 넘는지 확인한다.
+func (pSelf *graphqlHandler) checkSelections(document *ast.Document) error {
+	counter := &selectionCounter{fragments: map[string]*ast.FragmentDefinition{}, visiting: map[string]bool{}, maxFields: pSelf.maxFields}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok && fragment.Name != nil {
+			counter.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			counter.count(operation.SelectionSet)
+		}
+	}
+
+	if counter.fields > pSelf.maxFields {
+		return fmt.Errorf("too many fields: more than %d", pSelf.maxFields)
+	}
+	if counter.aliases > pSelf.maxAliases {
+		return fmt.Errorf("too many aliases: %d > %d", counter.aliases, pSelf.maxAliases)
+	}
+	return nil
+}
+
+// selectionCounter 는 fragment 를 펼쳐 field 와 alias 를 센다.
+type selectionCounter struct {
+	fragments map[string]*ast.FragmentDefinition
+	// visiting 은 순환하는 fragment 를 다시 펼치지 않도록 펼치는 중인 fragment 이다.
+	visiting  map[string]bool
+	maxFields int
+
+	fields  int
+	aliases int
+}
+
+func (pSelf *selectionCounter) count(selectionSet *ast.SelectionSet) {
+	if selectionSet == nil {
+		return
+	}
+	for _, selection := range selectionSet.Selections {
+		// 제한을 넘으면 fragment 를 더 펼치지 않는다.
+		if pSelf.fields > pSelf.maxFields {
+			return
+		}
+		switch selection := selection.(type) {
+		case *ast.Field:
+			pSelf.fields++
+			if selection.Alias != nil {
+				pSelf.aliases++
+			}
+			pSelf.count(selection.SelectionSet)
+		case *ast.InlineFragment:
+			pSelf.count(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if selection.Name == nil {
+				continue
+			}
+			name := selection.Name.Value
+			fragment, ok := pSelf.fragments[name]
+			if !ok || pSelf.visiting[name] {
+				continue
+			}
+			pSelf.visiting[name] = true
+			pSelf.count(fragment.SelectionSet)
+			delete(pSelf.visiting, name)
+		}
+	}
+}
+
+// grpcError 는 gRPC status 를 extensions 로 응답하는 GraphQL error 이다.
+type grpcError struct {
+	status *status.Status
+}
+
+func (pSelf *grpcError) Error() string {
+	return pSelf.status.Message()
+}
+
+func (pSelf *grpcError) Extensions() map[string]any {
+	return map[string]any{"code": pSelf.status.Code().String()}
+}
+
+// schemaBuilder 는 protobuf 정의로 GraphQL schema 를 생성한다.
+type schemaBuilder struct {
+	options Options
+	conn    *grpc.ClientConn
+
+	objects map[protoreflect.FullName]*graphql.Object
+	inputs  map[protoreflect.FullName]*graphql.InputObject
+	enums   map[protoreflect.FullName]*graphql.Enum
+}
+
+func newSchemaBuilder(options Options, conn *grpc.ClientConn) *schemaBuilder {
+	return &schemaBuilder{
+		options: options,
+		conn:    conn,
+		objects: map[protoreflect.FullName]*graphql.Object{},
+		inputs:  map[protoreflect.FullName]*graphql.InputObject{},
+		enums:   map[protoreflect.FullName]*graphql.Enum{},
+	}
+}
+
+func (pSelf *schemaBuilder) build() (graphql.Schema, error) {
+	if len(pSelf.options.Services) == 0 {
+		return graphql.Schema{}, errors.New("no service to expose")
+	}
+
+	queries, mutations := graphql.Fields{}, graphql.Fields{}
+	for _, serviceName := range pSelf.options.Services {
+		descriptor, err := pSelf.options.Files.FindDescriptorByName(protoreflect.FullName(serviceName))
+		if err != nil {
+			return graphql.Schema{}, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		service, ok := descriptor.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return graphql.Schema{}, fmt.Errorf("%s is not a service", serviceName)
+		}
+
+		methods := service.Methods()
+		for i := range methods.Len() {
+			method := methods.Get(i)
+			if method.IsStreamingClient() || method.IsStreamingServer() {
+				continue
+			}
+			fields := mutations
+			if pSelf.isQuery(method) {
+				fields = queries
+			}
+			name := lowerFirst(string(service.Name())) + string(method.Name())
+			if _, ok := queries[name]; ok {
+				return graphql.Schema{}, fmt.Errorf("duplicate graphql field %s (%s)", name, method.FullName())
+			}
+			if _, ok := mutations[name]; ok {
+				return graphql.Schema{}, fmt.Errorf("duplicate graphql field %s (%s)", name, method.FullName())
+			}
+			fields[name] = pSelf.methodField(method)
+		}
+	}
+
+	if len(queries) == 0 {
+		// GraphQL schema 에는 Query 가 있어야 한다.
+		queries["_empty"] = &graphql.Field{Type: graphql.Boolean}
+	}
+	config := graphql.SchemaConfig{Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: queries})}
+	if len(mutations) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutations})
+	}
+	return graphql.NewSchema(config)
+}
+
+// isQuery 는 method 를 Query 로 제공할지 여부이다.
+func (pSelf *schemaBuilder) isQuery(method protoreflect.MethodDescriptor) bool {
+	if options, ok := method.Options().(*descriptorpb.MethodOptions); ok && options.GetIdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS {
+		return true
+	}
+	return slices.ContainsFunc(pSelf.options.QueryPrefixes, func(prefix string) bool {
+		return hasWordPrefix(string(method.Name()), prefix)
+	})
+}
+
+// hasWordPrefix 는 name 이 prefix 단어로 시작하는지 여부이다. prefix 다음은 끝이거나 대문자, 숫자로 시작하는 다음 단어이다.
+func hasWordPrefix(name, prefix string) bool {
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return false
+	}
+	if len(rest) == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return unicode.IsUpper(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// methodField 는 method 를 호출하는 GraphQL field 이다.
+func (pSelf *schemaBuilder) methodField(method protoreflect.MethodDescriptor) *graphql.Field {
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	field := &graphql.Field{
+		Type:        pSelf.outputType(method.Output()),
+		Description: fullMethod,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			request := dynamicpb.NewMessage(method.Input())
+			if input, ok := p.Args["input"]; ok && input != nil {
+				data, err := json.Marshal(input)
+				if err != nil {
+					return nil, err
+				}
+				if err := protojson.Unmarshal(data, request); err != nil {
+					return nil, err
+				}
+			}
+
+			response := dynamicpb.NewMessage(method.Output())
+			if err := pSelf.conn.Invoke(p.Context, fullMethod, request, response); err != nil {
+				return nil, &grpcError{status: status.Convert(err)}
+			}
+
+			data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(response)
+			if err != nil {
+				return nil, err
+			}
+			var result any
+			return result, json.Unmarshal(data, &result)
+		},
+	}
+	if method.Input().Fields().Len() > 0 {
+		field.Args = graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: pSelf.inputType(method.Input())}}
+	}
+	return field
+}
+
+// outputType 은 message 의 GraphQL 응답 type 이다.
+func (pSelf *schemaBuilder) outputType(message protoreflect.MessageDescriptor) graphql.Output {
+	if scalar, ok := wellKnownType(message); ok {
+		return scalar
+	}
+	if object, ok := pSelf.objects[message.FullName()]; ok {
+		return object
+	}
+
+	object := graphql.NewObject(graphql.ObjectConfig{
+		Name: typeName(message.FullName()),
+		// 재귀 message 를 위해 field 는 나중에 생성.
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{}
+			for i := range message.Fields().Len() {
+				fd := message.Fields().Get(i)
+				fields[fd.JSONName()] = &graphql.Field{Type: pSelf.fieldType(fd, false)}
+			}
+			return fields
+		}),
+	})
+	pSelf.objects[message.FullName()] = object
+	return object
+}
+
+// inputType 은 message 의 GraphQL 입력 type 이다.
+func (pSelf *schemaBuilder) inputType(message protoreflect.MessageDescriptor) graphql.Input {
+	if scalar, ok := wellKnownType(message); ok {
+		return scalar
+	}
+	if input, ok := pSelf.inputs[message.FullName()]; ok {
+		return input
+	}
+
+	input := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: typeName(message.FullName()) + "Input",
+		Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+			fields := graphql.InputObjectConfigFieldMap{}
+			for i := range message.Fields().Len() {
+				fd := message.Fields().Get(i)
+				fields[fd.JSONName()] = &graphql.InputObjectFieldConfig{Type: pSelf.fieldType(fd, true)}
+			}
+			return fields
+		}),
+	})
+	pSelf.inputs[message.FullName()] = input
+	return input
+}
+
+// fieldType 은 field 의 GraphQL type 이다. 값은 protojson 형식이므로 64bit 정수는 String 이다.
+func (pSelf *schemaBuilder) fieldType(fd protoreflect.FieldDescriptor, input bool) graphql.Type {
+	if fd.IsMap() {
+		return jsonScalar
+	}
+
+	var fieldType graphql.Type
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		fieldType = graphql.Boolean
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		fieldType = graphql.Int
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.FloatKind, protoreflect.DoubleKind:
+		// uint32 는 GraphQL Int (32bit signed) 범위를 넘을 수 있다.
+		fieldType = graphql.Float
+	case protoreflect.EnumKind:
+		fieldType = pSelf.enumType(fd.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if input {
+			fieldType = pSelf.inputType(fd.Message())
+		} else {
+			fieldType = pSelf.outputType(fd.Message())
+		}
+	default:
+		// string, bytes (base64), 64bit 정수.
+		fieldType = graphql.String
+	}
+
+	if fd.IsList() {
+		return graphql.NewList(graphql.NewNonNull(fieldType))
+	}
+	return fieldType
+}
+
+func (pSelf *schemaBuilder) enumType(enum protoreflect.EnumDescriptor) *graphql.Enum {
+	if enumType, ok := pSelf.enums[enum.FullName()]; ok {
+		return enumType
+	}
+
+	values := graphql.EnumValueConfigMap{}
+	for i := range enum.Values().Len() {
+		name := string(enum.Values().Get(i).Name())
+		values[name] = &graphql.EnumValueConfig{Value: name}
+	}
+	enumType := graphql.NewEnum(graphql.EnumConfig{Name: typeName(enum.FullName()), Values: values})
+	pSelf.enums[enum.FullName()] = enumType
+	return enumType
+}
+
+// wellKnownType 은 protojson 이 JSON 값으로 표현하는 well-known type 의 GraphQL type 이다.
+func wellKnownType(message protoreflect.MessageDescriptor) (*graphql.Scalar, bool) {
+	switch message.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask",
+		"google.protobuf.StringValue", "google.protobuf.BytesValue", "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return graphql.String, true
+	case "google.protobuf.BoolValue":
+		return graphql.Boolean, true
+	case "google.protobuf.Int32Value":
+		return graphql.Int, true
+	case "google.protobuf.UInt32Value", "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		return graphql.Float, true
+	case "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue", "google.protobuf.Any", "google.protobuf.Empty":
+		return jsonScalar, true
+	}
+	if message.Fields().Len() == 0 {
+		// GraphQL object 는 field 가 있어야 한다.
+		return jsonScalar, true
+	}
+	return nil, false
+}
+
+// jsonScalar 는 map, Struct 등 임의의 JSON 값이다.
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "임의의 JSON 값",
+	Serialize:   func(value any) any { return value },
+	ParseValue:  func(value any) any { return value },
+	ParseLiteral: func(value ast.Value) any {
+		return literalValue(value)
+	},
+})
+
+// literalValue 는 GraphQL literal 의 JSON 값이다.
+func literalValue(value ast.Value) any {
+	switch value := value.(type) {
+	case *ast.ObjectValue:
+		object := map[string]any{}
+		for _, field := range value.Fields {
+			object[field.Name.Value] = literalValue(field.Value)
+		}
+		return object
+	case *ast.ListValue:
+		list := make([]any, len(value.Values))
+		for i, item := range value.Values {
+			list[i] = literalValue(item)
+		}
+		return list
+	case *ast.IntValue:
+		number, _ := strconv.ParseInt(value.Value, 10, 64)
+		return number
+	case *ast.FloatValue:
+		number, _ := strconv.ParseFloat(value.Value, 64)
+		return number
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.StringValue:
+		return value.Value
+	case *ast.EnumValue:
+		return value.Value
+	}
+	return nil
+}
+
+// typeName 은 protobuf 전체 이름의 GraphQL type 이름이다. (e.g. myapp.v1.HelloRequest → myapp_v1_HelloRequest)
+func typeName(name protoreflect.FullName) string {
+	return strings.ReplaceAll(string(name), ".", "_")
+}
+
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
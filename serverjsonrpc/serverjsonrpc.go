@@ -0,0 +1,320 @@
+// Package serverjsonrpc 은 unary gRPC Method 를 Http Proxy Server 의 JSON-RPC 2.0 endpoint 로 제공한다.
+// 요청은 gRPC Gateway 와 같이 Server 의 gRPC endpoint 로 전달하며, params 와 result 는 protojson 형식이다.
+//
+//	grpcServer.RegisterHttpProxyServer([]server.HttpProxyServerHandler{
+//		serverjsonrpc.Handler(serverjsonrpc.Options{Services: []string{"myapp.Greeter"}}),
+//	}, context.Background(), nil, nil, -1)
+//
+//	POST /jsonrpc
+//	{"jsonrpc": "2.0", "id": 1, "method": "myapp.Greeter.SayHello", "params": {"name": "berry"}}
+//
+// method 는 "package.Service.Method" 또는 "package.Service/Method" 이며, batch 요청과 notification (id 없는 요청) 도 처리한다.
+package serverjsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultPath = "/jsonrpc"
+	// maxRequestSize 는 JSON-RPC 요청 body 의 최대 크기이다.
+	maxRequestSize = 4 << 20
+	version        = "2.0"
+
+	defaultMaxBatchSize   = 100
+	defaultMaxConcurrency = 8
+)
+
+// JSON-RPC 2.0 error code.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeServerError 는 gRPC Method 가 실패한 경우의 code 이다. gRPC status 는 error 의 data 로 전달한다.
+	CodeServerError = -32000
+)
+
+// Options 는 JSON-RPC endpoint 설정이다.
+type Options struct {
+	// Services 는 JSON-RPC 로 제공할 gRPC Service 의 전체 이름이다. (e.g. myapp.Greeter)
+	// Service 의 pb 패키지를 import 하여 protobuf registry 에 등록되어 있어야 한다.
+	Services []string
+	// Path 는 JSON-RPC endpoint 의 path 이다. (기본값: /jsonrpc)
+	Path string
+	// Files 는 Service 를 찾을 protobuf registry 이다. (기본값: protoregistry.GlobalFiles)
+	Files *protoregistry.Files
+	// MaxBatchSize 는 batch 요청 하나에 담을 수 있는 요청의 최대 수이다. 넘으면 batch 전체를 거부한다. (기본값: 100)
+	MaxBatchSize int
+	// MaxConcurrency 는 batch 요청 하나에서 동시에 실행하는 요청의 최대 수이다. (기본값: 8)
+	MaxConcurrency int
+}
+
+// Handler 는 Options.Path 에 JSON-RPC 2.0 endpoint 를 등록하는 HttpProxyServerHandler 이다.
+// HTTP header 는 gRPC Gateway 와 같은 규칙으로 gRPC metadata 로 전달하며, Streaming Method 는 제공하지 않는다.
+func Handler(options Options) server.HttpProxyServerHandler {
+	return func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+		if len(options.Path) == 0 {
+			options.Path = defaultPath
+		}
+		if options.Files == nil {
+			options.Files = protoregistry.GlobalFiles
+		}
+		if options.MaxBatchSize <= 0 {
+			options.MaxBatchSize = defaultMaxBatchSize
+		}
+		if options.MaxConcurrency <= 0 {
+			options.MaxConcurrency = defaultMaxConcurrency
+		}
+
+		methods, err := findMethods(options)
+		if err != nil {
+			return fmt.Errorf("json-rpc: %w", err)
+		}
+		conn, err := grpc.NewClient(endpoint, opts...)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			_ = conn.Close()
+		}()
+
+		endpointHandler := &jsonrpcHandler{conn: conn, methods: methods, mux: mux, path: options.Path, maxBatchSize: options.MaxBatchSize, maxConcurrency: options.MaxConcurrency}
+		return mux.HandlePath(http.MethodPost, options.Path, endpointHandler.serveHTTP)
+	}
+}
+
+// findMethods 는 Services 의 unary Method 를 JSON-RPC method 이름으로 찾는 map 이다.
+func findMethods(options Options) (map[string]protoreflect.MethodDescriptor, error) {
+	if len(options.Services) == 0 {
+		return nil, errors.New("no service to expose")
+	}
+
+	methods := map[string]protoreflect.MethodDescriptor{}
+	for _, serviceName := range options.Services {
+		descriptor, err := options.Files.FindDescriptorByName(protoreflect.FullName(serviceName))
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", serviceName, err)
+		}
+		service, ok := descriptor.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a service", serviceName)
+		}
+
+		for i := range service.Methods().Len() {
+			method := service.Methods().Get(i)
+			if method.IsStreamingClient() || method.IsStreamingServer() {
+				continue
+			}
+			methods[string(method.FullName())] = method
+		}
+	}
+	return methods, nil
+}
+
+// request 는 JSON-RPC 2.0 요청이다.
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	// ID 가 nil 이면 응답하지 않는 notification 이다.
+	ID *json.RawMessage `json:"id"`
+}
+
+// response 는 JSON-RPC 2.0 응답이다.
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error 는 JSON-RPC 2.0 error 이다.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// StatusData 는 gRPC Method 가 실패한 경우 Error.Data 로 전달하는 gRPC status 이다.
+type StatusData struct {
+	GrpcCode   int    `json:"grpc_code"`
+	GrpcStatus string `json:"grpc_status"`
+}
+
+type jsonrpcHandler struct {
+	conn           *grpc.ClientConn
+	methods        map[string]protoreflect.MethodDescriptor
+	mux            *runtime.ServeMux
+	path           string
+	maxBatchSize   int
+	maxConcurrency int
+}
+
+func (pSelf *jsonrpcHandler) serveHTTP(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, err := runtime.AnnotateContext(r.Context(), pSelf.mux, r, pSelf.path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		pSelf.serveBatch(ctx, w, body)
+		return
+	}
+
+	var call request
+	if err := json.Unmarshal(body, &call); err != nil {
+		writeJSON(w, &response{Version: version, Error: &Error{Code: CodeParseError, Message: err.Error()}, ID: json.RawMessage("null")})
+		return
+	}
+	if result := pSelf.call(ctx, call); result != nil {
+		writeJSON(w, result)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveBatch 는 batch 요청의 각 요청을 maxConcurrency 개까지 동시에 실행하고, notification 을 제외한 응답을 요청 순서대로 응답한다.
+func (pSelf *jsonrpcHandler) serveBatch(ctx context.Context, w http.ResponseWriter, body []byte) {
+	var calls []json.RawMessage
+	if err := json.Unmarshal(body, &calls); err != nil {
+		writeJSON(w, &response{Version: version, Error: &Error{Code: CodeParseError, Message: err.Error()}, ID: json.RawMessage("null")})
+		return
+	}
+	if len(calls) == 0 {
+		writeJSON(w, &response{Version: version, Error: &Error{Code: CodeInvalidRequest, Message: "empty batch"}, ID: json.RawMessage("null")})
+		return
+	}
+	if len(calls) > pSelf.maxBatchSize {
+		writeJSON(w, &response{Version: version, Error: &Error{Code: CodeInvalidRequest, Message: fmt.Sprintf("batch exceeds %d requests", pSelf.maxBatchSize)}, ID: json.RawMessage("null")})
+		return
+	}
+
+	results := make([]*response, len(calls))
+	slots := make(chan struct{}, pSelf.maxConcurrency)
+	var waitGroup sync.WaitGroup
+	for i, raw := range calls {
+		waitGroup.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() {
+				<-slots
+				waitGroup.Done()
+			}()
+			var call request
+			if err := json.Unmarshal(raw, &call); err != nil {
+				results[i] = &response{Version: version, Error: &Error{Code: CodeInvalidRequest, Message: err.Error()}, ID: json.RawMessage("null")}
+				return
+			}
+			results[i] = pSelf.call(ctx, call)
+		}()
+	}
+	waitGroup.Wait()
+
+	var responses []*response
+	for _, result := range results {
+		if result != nil {
+			responses = append(responses, result)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, responses)
+}
+
+// call 은 요청 하나를 gRPC Method 로 실행한다. notification 이면 nil 이다.
+func (pSelf *jsonrpcHandler) call(ctx context.Context, call request) *response {
+	result := &response{Version: version, ID: json.RawMessage("null")}
+	if call.ID != nil {
+		result.ID = *call.ID
+	}
+	result.Result, result.Error = pSelf.invoke(ctx, call)
+	if call.ID == nil {
+		return nil
+	}
+	return result
+}
+
+func (pSelf *jsonrpcHandler) invoke(ctx context.Context, call request) (json.RawMessage, *Error) {
+	if call.Version != version || len(call.Method) == 0 {
+		return nil, &Error{Code: CodeInvalidRequest, Message: "invalid json-rpc 2.0 request"}
+	}
+	// "package.Service/Method" 도 허용.
+	method, ok := pSelf.methods[strings.Replace(strings.TrimPrefix(call.Method, "/"), "/", ".", 1)]
+	if !ok {
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + call.Method}
+	}
+
+	params := bytes.TrimSpace(call.Params)
+	if len(params) > 0 && params[0] == '[' {
+		// by-position params 는 요청 message 하나만 허용.
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil || len(positional) > 1 {
+			return nil, &Error{Code: CodeInvalidParams, Message: "params must be an object or an array of one object"}
+		}
+		params = nil
+		if len(positional) == 1 {
+			params = positional[0]
+		}
+	}
+	request := dynamicpb.NewMessage(method.Input())
+	if len(params) > 0 && !bytes.Equal(params, []byte("null")) {
+		if err := protojson.Unmarshal(params, request); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+	}
+
+	reply := dynamicpb.NewMessage(method.Output())
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	if err := pSelf.conn.Invoke(ctx, fullMethod, request, reply); err != nil {
+		grpcStatus := status.Convert(err)
+		return nil, &Error{
+			Code:    CodeServerError,
+			Message: grpcStatus.Message(),
+			Data:    StatusData{GrpcCode: int(grpcStatus.Code()), GrpcStatus: grpcStatus.Code().String()},
+		}
+	}
+
+	data, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(reply)
+	if err != nil {
+		return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	return data, nil
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,213 @@
+// Package serverkafka 는 Kafka consumer group 으로 받은 record 를 handler 에 전달하는 server.Worker 이다.
+// Server 가 요청을 받을 준비를 마친 뒤 consume 을 시작하고, 종료할 때는 gRPC Server 를 종료하기 전에
+// 처리 중인 record 를 마치고 commit 한 뒤 consumer group 에서 나간다.
+//
+//	consumer, err := serverkafka.New(serverkafka.Options{
+//		Brokers: []string{"kafka:9092"},
+//		GroupID: "order-service",
+//		Handlers: map[string]serverkafka.Handler{
+//			"orders.created": func(ctx context.Context, record kafka.Message) error { ... },
+//		},
+//	})
+//	...
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, server.WithWorker("kafka", consumer))
+package serverkafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = time.Second
+	maxBackoff        = 30 * time.Second
+)
+
+// Handler 는 record 하나를 처리한다. 오류를 반환하면 Options.MaxRetries 만큼 다시 시도한다.
+type Handler func(ctx context.Context, record kafka.Message) error
+
+// Options 는 Kafka consumer 설정이다.
+type Options struct {
+	// Brokers 는 Kafka broker 주소이다. (e.g. kafka:9092)
+	Brokers []string
+	// GroupID 는 consumer group id 이다. 같은 group 의 Server 가 partition 을 나누어 consume 한다.
+	GroupID string
+	// Handlers 는 topic 별 handler 이다.
+	Handlers map[string]Handler
+	// StartOffset 은 commit 한 offset 이 없는 partition 을 읽기 시작할 위치이다. (kafka.FirstOffset, kafka.LastOffset. 기본값: kafka.FirstOffset)
+	StartOffset int64
+	// Dialer 는 TLS, SASL 등 broker 연결 설정이다. nil 이면 kafka.DefaultDialer 를 사용한다.
+	Dialer *kafka.Dialer
+	// MaxRetries 는 handler 가 실패한 record 를 다시 시도하는 횟수이다. (기본값: 3, 음수이면 다시 시도하지 않음)
+	MaxRetries int
+	// Backoff 는 첫 번째 재시도까지의 대기 시간이며, 재시도마다 두 배로 늘린다. (기본값: 1초, 최대 30초)
+	Backoff time.Duration
+	// OnError 는 재시도를 모두 실패한 record 를 받는다. (e.g. dead letter topic 에 기록)
+	// nil 을 반환하면 record 를 commit 하고 다음 record 를 처리하며, 오류를 반환하면 consume 을 멈추고 consumer group 에서 나간다.
+	// nil 이면 실패한 record 도 commit 한다.
+	OnError func(ctx context.Context, record kafka.Message, err error) error
+	// OnStop 은 OnError 의 오류로 consume 을 멈추면 그 오류를 받는다. (e.g. 지표, 알림)
+	// consumer group 에서 나간 뒤에 호출하므로 partition 은 group 의 다른 Server 가 consume 한다.
+	OnStop func(err error)
+}
+
+// New 는 Options.Handlers 에 record 를 전달하는 Kafka consumer 를 생성한다. server.WithWorker 에 전달한다.
+func New(options Options) (server.Worker, error) {
+	if len(options.Brokers) == 0 {
+		return nil, errors.New("kafka: broker is required")
+	}
+	if len(options.GroupID) == 0 {
+		return nil, errors.New("kafka: group id is required")
+	}
+	if len(options.Handlers) == 0 {
+		return nil, errors.New("kafka: no handler")
+	}
+	if options.StartOffset == 0 {
+		options.StartOffset = kafka.FirstOffset
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = defaultMaxRetries
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaultBackoff
+	}
+	return &kafkaConsumer{options: options}, nil
+}
+
+type kafkaConsumer struct {
+	options Options
+
+	reader *kafka.Reader
+	// cancel 은 새 record 를 읽지 않도록 consume loop 를 멈춘다.
+	cancel context.CancelFunc
+	// handlerCtx 는 handler 에 전달하는 context 이며, Stop 의 ctx 가 끝나면 취소한다.
+	handlerCtx    context.Context
+	handlerCancel context.CancelFunc
+	cDone         chan struct{}
+	stopOnce      sync.Once
+}
+
+// Start 는 consumer group 에 참여하고 Goroutine 에서 record 를 consume 한다.
+func (pSelf *kafkaConsumer) Start(_ context.Context, _ *grpc.ClientConn) error {
+	topics := make([]string, 0, len(pSelf.options.Handlers))
+	for topic := range pSelf.options.Handlers {
+		topics = append(topics, topic)
+	}
+	readerConfig := kafka.ReaderConfig{
+		Brokers:     pSelf.options.Brokers,
+		GroupID:     pSelf.options.GroupID,
+		GroupTopics: topics,
+		StartOffset: pSelf.options.StartOffset,
+		Dialer:      pSelf.options.Dialer,
+	}
+	if err := readerConfig.Validate(); err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	pSelf.reader = kafka.NewReader(readerConfig)
+
+	var ctx context.Context
+	ctx, pSelf.cancel = context.WithCancel(context.Background())
+	pSelf.handlerCtx, pSelf.handlerCancel = context.WithCancel(context.Background())
+	pSelf.cDone = make(chan struct{})
+	go pSelf.consume(ctx)
+	return nil
+}
+
+// Stop 은 새 record 를 읽지 않고, 처리 중인 record 를 마치고 commit 한 뒤 consumer group 에서 나간다.
+// ctx 가 먼저 끝나면 handler 의 context 를 취소하며, 처리 중이던 record 는 commit 하지 않으므로 다시 전달된다.
+func (pSelf *kafkaConsumer) Stop(ctx context.Context) error {
+	if pSelf.reader == nil {
+		return nil
+	}
+
+	var err error
+	pSelf.stopOnce.Do(func() {
+		pSelf.cancel()
+		select {
+		case <-pSelf.cDone:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		pSelf.handlerCancel()
+		if closeErr := pSelf.reader.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// consume 은 ctx 가 끝날 때까지 record 를 읽어 handler 에 전달하고, 처리한 record 를 commit 한다.
+func (pSelf *kafkaConsumer) consume(ctx context.Context) {
+	defer close(pSelf.cDone)
+
+	for {
+		record, err := pSelf.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// reader 가 broker 연결 오류는 다시 시도하므로, 닫힌 경우만 멈춘다.
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			select {
+			case <-time.After(pSelf.options.Backoff):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		if err := pSelf.dispatch(record); err != nil {
+			if pSelf.handlerCtx.Err() == nil {
+				// partition 을 가진 채로 멈추지 않도록 consumer group 에서 나간다.
+				_ = pSelf.reader.Close()
+				if pSelf.options.OnStop != nil {
+					pSelf.options.OnStop(fmt.Errorf("kafka: stopped consuming %s[%d]@%d: %w", record.Topic, record.Partition, record.Offset, err))
+				}
+			}
+			return
+		}
+		// 처리를 마친 record 는 종료 중이라도 commit 한다.
+		if err := pSelf.reader.CommitMessages(context.WithoutCancel(ctx), record); err != nil && ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// dispatch 는 record 를 handler 에 전달하고, 실패하면 backoff 하며 다시 시도한다.
+// 재시도를 모두 실패하면 OnError 에 전달하며, OnError 가 실패하면 오류를 반환한다.
+// Stop 으로 handler 의 context 가 취소되면 commit 하지 않도록 오류를 반환한다.
+func (pSelf *kafkaConsumer) dispatch(record kafka.Message) error {
+	handler := pSelf.options.Handlers[record.Topic]
+	if handler == nil {
+		return nil
+	}
+
+	backoff := pSelf.options.Backoff
+	err := handler(pSelf.handlerCtx, record)
+	for attempt := 0; err != nil && attempt < pSelf.options.MaxRetries; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-pSelf.handlerCtx.Done():
+			return pSelf.handlerCtx.Err()
+		}
+		backoff = min(backoff*2, maxBackoff)
+		err = handler(pSelf.handlerCtx, record)
+	}
+	if err != nil && pSelf.handlerCtx.Err() != nil {
+		return pSelf.handlerCtx.Err()
+	}
+	if err == nil || pSelf.options.OnError == nil {
+		return nil
+	}
+	return pSelf.options.OnError(pSelf.handlerCtx, record, err)
+}
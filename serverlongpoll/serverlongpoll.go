@@ -0,0 +1,505 @@
+// Package serverlongpoll 은 server-streaming gRPC Method 를 Http Proxy Server 에서 long-polling 으로 제공한다.
+// WebSocket 이나 chunked 응답을 통과시키지 않는 proxy 뒤의 client 가 stream 을 받을 때 사용한다.
+// stream 의 메시지는 Server 에 보관하고, client 는 cursor 로 다음 메시지를 batch 로 가져간다.
+//
+//	grpcServer.RegisterHttpProxyServer([]server.HttpProxyServerHandler{
+//		serverlongpoll.Handler(serverlongpoll.Options{Services: []string{"myapp.Ticker"}}),
+//	}, context.Background(), nil, nil, -1)
+//
+//	POST   /longpoll/myapp.Ticker/Watch  {"symbol": "BRRY"}  stream 시작, 첫 batch 응답
+//	GET    /longpoll/myapp.Ticker/Watch?cursor=...           다음 batch 를 Wait 만큼 기다려 응답
+//	DELETE /longpoll/myapp.Ticker/Watch?cursor=...           stream 취소
+//
+// 응답:
+//
+//	{"cursor": "...", "messages": [{...}, ...], "done": false}
+//	{"cursor": "...", "messages": [], "done": true, "error": {"code": 5, "message": "..."}}
+//
+// 같은 cursor 로 다시 요청하면 같은 메시지를 다시 받으므로, 응답을 받지 못한 경우 같은 cursor 로 다시 요청한다.
+package serverlongpoll
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPrefix      = "/longpoll"
+	defaultWait        = 25 * time.Second
+	defaultMaxBatch    = 100
+	defaultMaxBuffer   = 1000
+	defaultIdleTimeout = time.Minute
+	defaultMaxSessions = 10000
+	// defaultMaxClientSessions 는 client 주소 하나가 동시에 여는 stream 의 기본 최대 수이다.
+	defaultMaxClientSessions = 10
+	// maxRequestSize 는 stream 시작 요청 body 의 최대 크기이다.
+	maxRequestSize = 4 << 20
+)
+
+// Options 는 long-polling endpoint 설정이다.
+type Options struct {
+	// Services 는 long-polling 으로 제공할 gRPC Service 의 전체 이름이다. server-streaming Method 만 제공한다.
+	Services []string
+	// Prefix 는 route 의 path prefix 이다. (기본값: /longpoll)
+	Prefix string
+	// Wait 는 보낼 메시지가 없을 때 응답을 기다리는 최대 시간이다. proxy 의 timeout 보다 짧아야 한다. (기본값: 25초)
+	Wait time.Duration
+	// MaxBatch 는 응답 하나에 담는 메시지의 최대 수이다. (기본값: 100)
+	MaxBatch int
+	// MaxBuffer 는 client 가 가져가지 않은 메시지의 최대 수이다. 넘으면 ResourceExhausted 로 stream 을 종료한다. (기본값: 1000)
+	MaxBuffer int
+	// IdleTimeout 은 client 가 요청하지 않으면 stream 을 취소하는 시간이다. (기본값: 1분)
+	IdleTimeout time.Duration
+	// MaxSessions 는 동시에 진행하는 stream 의 최대 수이다. 넘는 시작 요청은 ResourceExhausted 로 거부한다. (기본값: 10000)
+	MaxSessions int
+	// MaxClientSessions 는 client 주소 하나가 동시에 진행하는 stream 의 최대 수이다. (기본값: 10)
+	MaxClientSessions int
+	// Files 는 Service 를 찾을 protobuf registry 이다. (기본값: protoregistry.GlobalFiles)
+	Files *protoregistry.Files
+}
+
+// Handler 는 "Prefix/package.Service/Method" 에 long-polling route 를 등록하는 HttpProxyServerHandler 이다.
+// stream 시작 요청의 HTTP header 는 gRPC Gateway 와 같은 규칙으로 gRPC metadata 로 전달한다.
+func Handler(options Options) server.HttpProxyServerHandler {
+	return func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+		if len(options.Prefix) == 0 {
+			options.Prefix = defaultPrefix
+		}
+		options.Prefix = "/" + strings.Trim(options.Prefix, "/")
+		if options.Wait <= 0 {
+			options.Wait = defaultWait
+		}
+		if options.MaxBatch <= 0 {
+			options.MaxBatch = defaultMaxBatch
+		}
+		if options.MaxBuffer <= 0 {
+			options.MaxBuffer = defaultMaxBuffer
+		}
+		if options.IdleTimeout <= 0 {
+			options.IdleTimeout = defaultIdleTimeout
+		}
+		if options.MaxSessions <= 0 {
+			options.MaxSessions = defaultMaxSessions
+		}
+		if options.MaxClientSessions <= 0 {
+			options.MaxClientSessions = defaultMaxClientSessions
+		}
+		if options.Files == nil {
+			options.Files = protoregistry.GlobalFiles
+		}
+		if len(options.Services) == 0 {
+			return errors.New("long-polling: no service to expose")
+		}
+
+		var methods []protoreflect.MethodDescriptor
+		for _, serviceName := range options.Services {
+			descriptor, err := options.Files.FindDescriptorByName(protoreflect.FullName(serviceName))
+			if err != nil {
+				return fmt.Errorf("long-polling: service %s: %w", serviceName, err)
+			}
+			service, ok := descriptor.(protoreflect.ServiceDescriptor)
+			if !ok {
+				return fmt.Errorf("long-polling: %s is not a service", serviceName)
+			}
+			for i := range service.Methods().Len() {
+				method := service.Methods().Get(i)
+				if method.IsStreamingServer() && !method.IsStreamingClient() {
+					methods = append(methods, method)
+				}
+			}
+		}
+
+		conn, err := grpc.NewClient(endpoint, opts...)
+		if err != nil {
+			return err
+		}
+		poller := &poller{options: options, conn: conn, mux: mux, sessions: map[string]*session{}, clients: map[string]int{}}
+		go poller.expire(ctx)
+
+		for _, method := range methods {
+			route := &pollRoute{poller: poller, method: method}
+			path := options.Prefix + "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+			if err := mux.HandlePath(http.MethodPost, path, route.start); err != nil {
+				return err
+			}
+			if err := mux.HandlePath(http.MethodGet, path, route.poll); err != nil {
+				return err
+			}
+			if err := mux.HandlePath(http.MethodDelete, path, route.cancel); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// poller 는 진행 중인 stream 을 관리한다.
+type poller struct {
+	options Options
+	conn    *grpc.ClientConn
+	mux     *runtime.ServeMux
+
+	mutex    sync.Mutex
+	sessions map[string]*session
+	// clients 는 client 주소 별, reserved 는 전체 진행 중인 stream 의 수이다.
+	clients  map[string]int
+	reserved int
+}
+
+// expire 는 IdleTimeout 동안 요청하지 않은 stream 을 취소한다. ctx 가 끝나면 모든 stream 을 취소하고 연결을 닫는다.
+func (pSelf *poller) expire(ctx context.Context) {
+	ticker := time.NewTicker(pSelf.options.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			pSelf.mutex.Lock()
+			for id := range pSelf.sessions {
+				pSelf.delete(id)
+			}
+			pSelf.mutex.Unlock()
+			_ = pSelf.conn.Close()
+			return
+		case now := <-ticker.C:
+			pSelf.mutex.Lock()
+			for id, session := range pSelf.sessions {
+				if session.idle(now) > pSelf.options.IdleTimeout {
+					pSelf.delete(id)
+				}
+			}
+			pSelf.mutex.Unlock()
+		}
+	}
+}
+
+// httpError 는 gRPC Gateway 와 같은 형식으로 오류를 응답한다.
+func (pSelf *poller) httpError(marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	ctx := runtime.NewServerMetadataContext(r.Context(), runtime.ServerMetadata{})
+	runtime.HTTPError(ctx, pSelf.mux, marshaler, w, r, err)
+}
+
+func (pSelf *poller) find(method protoreflect.MethodDescriptor, id string) (*session, bool) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	session, ok := pSelf.sessions[id]
+	if !ok || session.method != method {
+		return nil, false
+	}
+	return session, true
+}
+
+func (pSelf *poller) remove(id string) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.delete(id)
+}
+
+// reserve 는 client 의 stream 하나를 센다. MaxSessions, MaxClientSessions 를 넘으면 ResourceExhausted 이다.
+// stream 을 시작하지 못하면 release 로 되돌린다.
+func (pSelf *poller) reserve(client string) error {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if pSelf.reserved >= pSelf.options.MaxSessions {
+		return status.Error(codes.ResourceExhausted, "too many long-polling streams")
+	}
+	if pSelf.clients[client] >= pSelf.options.MaxClientSessions {
+		return status.Error(codes.ResourceExhausted, "too many long-polling streams from this client")
+	}
+	pSelf.clients[client]++
+	pSelf.reserved++
+	return nil
+}
+
+func (pSelf *poller) release(client string) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.releaseLocked(client)
+}
+
+func (pSelf *poller) releaseLocked(client string) {
+	pSelf.reserved--
+	if pSelf.clients[client]--; pSelf.clients[client] <= 0 {
+		delete(pSelf.clients, client)
+	}
+}
+
+// delete 는 stream 을 취소하고 지운다. mutex 를 잠근 상태에서 호출한다.
+func (pSelf *poller) delete(id string) {
+	if session, ok := pSelf.sessions[id]; ok {
+		session.cancel()
+		delete(pSelf.sessions, id)
+		pSelf.releaseLocked(session.client)
+	}
+}
+
+// pollRoute 는 Method 하나의 long-polling route 이다.
+type pollRoute struct {
+	poller *poller
+	method protoreflect.MethodDescriptor
+}
+
+func (pSelf *pollRoute) fullMethod() string {
+	return "/" + string(pSelf.method.Parent().FullName()) + "/" + string(pSelf.method.Name())
+}
+
+// start 는 stream 을 시작하고 첫 batch 를 응답한다.
+func (pSelf *pollRoute) start(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	_, outboundMarshaler := runtime.MarshalerForRequest(pSelf.poller.mux, r)
+	annotatedCtx, err := runtime.AnnotateContext(r.Context(), pSelf.poller.mux, r, pSelf.fullMethod())
+	if err != nil {
+		pSelf.poller.httpError(outboundMarshaler, w, r, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		pSelf.poller.httpError(outboundMarshaler, w, r, status.Errorf(codes.InvalidArgument, "%v", err))
+		return
+	}
+	request := dynamicpb.NewMessage(pSelf.method.Input())
+	if len(body) > 0 {
+		if err := protojson.Unmarshal(body, request); err != nil {
+			pSelf.poller.httpError(outboundMarshaler, w, r, status.Errorf(codes.InvalidArgument, "%v", err))
+			return
+		}
+	}
+
+	client := clientAddress(r)
+	if err := pSelf.poller.reserve(client); err != nil {
+		pSelf.poller.httpError(outboundMarshaler, w, r, err)
+		return
+	}
+
+	// stream 은 요청이 끝난 뒤에도 계속되므로, 요청의 metadata 만 옮긴다.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	if md, ok := metadata.FromOutgoingContext(annotatedCtx); ok {
+		streamCtx = metadata.NewOutgoingContext(streamCtx, md)
+	}
+	stream, err := pSelf.poller.conn.NewStream(streamCtx, &grpc.StreamDesc{ServerStreams: true}, pSelf.fullMethod())
+	if err == nil {
+		err = stream.SendMsg(request)
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	if err != nil {
+		cancel()
+		pSelf.poller.release(client)
+		pSelf.poller.httpError(outboundMarshaler, w, r, err)
+		return
+	}
+
+	session := newSession(newSessionID(), pSelf.method, client, cancel)
+	pSelf.poller.mutex.Lock()
+	pSelf.poller.sessions[session.id] = session
+	pSelf.poller.mutex.Unlock()
+	go session.receive(stream, pSelf.poller.options.MaxBuffer)
+
+	pSelf.respond(w, r, session, 0)
+}
+
+// poll 은 cursor 다음의 메시지를 응답한다.
+func (pSelf *pollRoute) poll(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	session, offset, ok := pSelf.session(w, r)
+	if !ok {
+		return
+	}
+	pSelf.respond(w, r, session, offset)
+}
+
+// cancel 은 stream 을 취소한다.
+func (pSelf *pollRoute) cancel(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	session, _, ok := pSelf.session(w, r)
+	if !ok {
+		return
+	}
+	pSelf.poller.remove(session.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// session 은 cursor 의 stream 과 offset 이다. 찾지 못하면 오류를 응답한다.
+func (pSelf *pollRoute) session(w http.ResponseWriter, r *http.Request) (*session, uint64, bool) {
+	_, outboundMarshaler := runtime.MarshalerForRequest(pSelf.poller.mux, r)
+	id, offsetText, _ := strings.Cut(r.URL.Query().Get("cursor"), ".")
+	offset, err := strconv.ParseUint(offsetText, 10, 64)
+	if err != nil {
+		pSelf.poller.httpError(outboundMarshaler, w, r, status.Error(codes.InvalidArgument, "invalid cursor"))
+		return nil, 0, false
+	}
+	session, ok := pSelf.poller.find(pSelf.method, id)
+	if !ok {
+		pSelf.poller.httpError(outboundMarshaler, w, r, status.Error(codes.NotFound, "stream not found or expired"))
+		return nil, 0, false
+	}
+	return session, offset, true
+}
+
+// batch 는 long-polling 응답이다.
+type batch struct {
+	Cursor   string            `json:"cursor"`
+	Messages []json.RawMessage `json:"messages"`
+	Done     bool              `json:"done"`
+	Error    json.RawMessage   `json:"error,omitempty"`
+}
+
+// respond 는 offset 다음의 메시지를 Wait 만큼 기다려 응답한다.
+// 끝난 stream 도 마지막 응답을 다시 받을 수 있도록 IdleTimeout 까지 보관한다.
+func (pSelf *pollRoute) respond(w http.ResponseWriter, r *http.Request, session *session, offset uint64) {
+	_, outboundMarshaler := runtime.MarshalerForRequest(pSelf.poller.mux, r)
+	ctx, cancel := context.WithTimeout(r.Context(), pSelf.poller.options.Wait)
+	defer cancel()
+
+	messages, next, done, err := session.next(ctx, offset, pSelf.poller.options.MaxBatch)
+	if err != nil {
+		pSelf.poller.httpError(outboundMarshaler, w, r, err)
+		return
+	}
+	result := batch{Cursor: session.id + "." + strconv.FormatUint(next, 10), Messages: messages, Done: done != nil}
+	if result.Messages == nil {
+		result.Messages = []json.RawMessage{}
+	}
+	if done != nil && done.Code() != codes.OK {
+		result.Error, _ = protojson.Marshal(done.Proto())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// session 은 진행 중인 stream 과 client 가 가져가지 않은 메시지이다.
+type session struct {
+	id     string
+	method protoreflect.MethodDescriptor
+	// client 는 stream 을 시작한 client 의 주소이다.
+	client string
+	cancel context.CancelFunc
+
+	mutex sync.Mutex
+	// messages[0] 의 offset.
+	base     uint64
+	messages []json.RawMessage
+	// status 는 stream 이 끝나면 설정된다.
+	status *status.Status
+	// cNotify 는 메시지가 추가되거나 stream 이 끝나면 닫고 새로 만든다.
+	cNotify  chan struct{}
+	lastPoll time.Time
+}
+
+func newSession(id string, method protoreflect.MethodDescriptor, client string, cancel context.CancelFunc) *session {
+	return &session{id: id, method: method, client: client, cancel: cancel, cNotify: make(chan struct{}), lastPoll: time.Now()}
+}
+
+// receive 는 stream 의 메시지를 보관한다. MaxBuffer 를 넘으면 stream 을 취소한다.
+func (pSelf *session) receive(stream grpc.ClientStream, maxBuffer int) {
+	for {
+		reply := dynamicpb.NewMessage(pSelf.method.Output())
+		err := stream.RecvMsg(reply)
+		var data []byte
+		if err == nil {
+			data, err = protojson.Marshal(reply)
+		}
+
+		pSelf.mutex.Lock()
+		switch {
+		case errors.Is(err, io.EOF):
+			pSelf.status = status.New(codes.OK, "")
+		case err != nil:
+			pSelf.status = status.Convert(err)
+		case len(pSelf.messages) >= maxBuffer:
+			pSelf.status = status.New(codes.ResourceExhausted, "long-polling client is too slow")
+			pSelf.cancel()
+		default:
+			pSelf.messages = append(pSelf.messages, data)
+		}
+		close(pSelf.cNotify)
+		pSelf.cNotify = make(chan struct{})
+		done := pSelf.status != nil
+		pSelf.mutex.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// next 는 offset 부터 최대 maxBatch 개의 메시지와 다음 offset 이다. 보낼 메시지가 없으면 ctx 가 끝날 때까지 기다린다.
+// offset 전의 메시지는 client 가 받았으므로 버린다. 마지막 메시지까지 반환하고 stream 이 끝났으면 status 도 반환한다.
+func (pSelf *session) next(ctx context.Context, offset uint64, maxBatch int) ([]json.RawMessage, uint64, *status.Status, error) {
+	for {
+		pSelf.mutex.Lock()
+		pSelf.lastPoll = time.Now()
+		end := pSelf.base + uint64(len(pSelf.messages))
+		if offset < pSelf.base || offset > end {
+			pSelf.mutex.Unlock()
+			return nil, 0, nil, status.Errorf(codes.OutOfRange, "cursor offset %d is out of range [%d, %d]", offset, pSelf.base, end)
+		}
+		pSelf.messages = pSelf.messages[offset-pSelf.base:]
+		pSelf.base = offset
+
+		if len(pSelf.messages) > 0 || pSelf.status != nil {
+			messages := pSelf.messages[:min(len(pSelf.messages), maxBatch)]
+			next := offset + uint64(len(messages))
+			var done *status.Status
+			if next == end {
+				done = pSelf.status
+			}
+			pSelf.mutex.Unlock()
+			return messages, next, done, nil
+		}
+		cNotify := pSelf.cNotify
+		pSelf.mutex.Unlock()
+
+		select {
+		case <-cNotify:
+		case <-ctx.Done():
+			return nil, offset, nil, nil
+		}
+	}
+}
+
+func (pSelf *session) idle(now time.Time) time.Duration {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	return now.Sub(pSelf.lastPoll)
+}
+
+// clientAddress 는 요청한 client 주소의 host 이다.
+func clientAddress(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
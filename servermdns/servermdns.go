@@ -0,0 +1,147 @@
+// Package servermdns 는 Server 의 gRPC, Http Proxy endpoint 를 mDNS (Bonjour, zeroconf) 로 알리는 server.Registrar 이다.
+// 같은 LAN 의 장비가 registry 없이 Server 를 찾아야 하는 on-premise, IoT 환경에서 사용한다.
+//
+//	grpcServer := server.New("tcp", "", 50051, nil, nil,
+//		server.WithRegistration(server.Registration{Name: "camera"}),
+//		server.WithRegistrar(servermdns.New(servermdns.Config{})))
+//
+// client 는 "dns-sd -B _grpc._tcp" 또는 zeroconf.Browse 로 찾는다.
+package servermdns
+
+import (
+	"context"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/grandcat/zeroconf"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultServiceType     = "_grpc._tcp"
+	defaultHttpServiceType = "_http._tcp"
+	defaultDomain          = "local."
+)
+
+// Config 는 mDNS 광고 설정이다.
+type Config struct {
+	// ServiceType 은 gRPC endpoint 의 service type 이다. (기본값: _grpc._tcp)
+	ServiceType string
+	// HttpServiceType 은 gRPC Gateway (Http Proxy) endpoint 의 service type 이다. (기본값: _http._tcp)
+	HttpServiceType string
+	// Domain 은 광고할 domain 이다. (기본값: local.)
+	Domain string
+	// Interfaces 는 광고할 network interface 이다. nil 이면 multicast 를 지원하는 모든 interface 를 사용한다.
+	Interfaces []net.Interface
+}
+
+// Registrar 는 Server 를 mDNS 로 광고한다.
+type Registrar struct {
+	config Config
+
+	mutex sync.Mutex
+	// servers 는 ServiceInstance ID 별 광고 중인 mDNS responder 이다.
+	servers map[string][]*zeroconf.Server
+}
+
+// New 는 mDNS Registrar 를 생성한다.
+func New(config Config) *Registrar {
+	if len(config.ServiceType) == 0 {
+		config.ServiceType = defaultServiceType
+	}
+	if len(config.HttpServiceType) == 0 {
+		config.HttpServiceType = defaultHttpServiceType
+	}
+	if len(config.Domain) == 0 {
+		config.Domain = defaultDomain
+	}
+	return &Registrar{config: config, servers: map[string][]*zeroconf.Server{}}
+}
+
+// Register 는 gRPC endpoint 를 광고하고, Http Proxy Server 가 있으면 Http endpoint 도 광고한다.
+// instance 이름은 ServiceInstance 의 ID 이며, TXT 에 name, tag, metadata, tls 를 기록한다.
+func (pSelf *Registrar) Register(ctx context.Context, instance server.ServiceInstance) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	text := txtRecords(instance)
+	grpcServer, err := pSelf.advertise(instance, pSelf.config.ServiceType, instance.Port, text)
+	if err != nil {
+		return err
+	}
+	servers := []*zeroconf.Server{grpcServer}
+	if instance.HttpPort > 0 {
+		httpServer, err := pSelf.advertise(instance, pSelf.config.HttpServiceType, instance.HttpPort, text)
+		if err != nil {
+			grpcServer.Shutdown()
+			return err
+		}
+		servers = append(servers, httpServer)
+	}
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	for _, previous := range pSelf.servers[instance.ID] {
+		previous.Shutdown()
+	}
+	pSelf.servers[instance.ID] = servers
+	return nil
+}
+
+// Deregister 는 광고를 멈추고 goodbye 응답을 보내 client 의 cache 에서 지운다.
+func (pSelf *Registrar) Deregister(ctx context.Context, instance server.ServiceInstance) error {
+	pSelf.mutex.Lock()
+	servers := pSelf.servers[instance.ID]
+	delete(pSelf.servers, instance.ID)
+	pSelf.mutex.Unlock()
+
+	for _, mdnsServer := range servers {
+		mdnsServer.Shutdown()
+	}
+	return nil
+}
+
+// advertise 는 등록 주소의 IP 를 광고한다. 등록 주소가 host 이름이면 host 이름의 IP 를 광고한다.
+// 등록 주소는 Server 가 광고할 수 있는 주소로 정하므로 0.0.0.0 같은 주소는 오지 않는다.
+func (pSelf *Registrar) advertise(instance server.ServiceInstance, serviceType string, port int, text []string) (*zeroconf.Server, error) {
+	ips := []string{instance.Address}
+	if net.ParseIP(instance.Address) == nil {
+		var err error
+		if ips, err = net.LookupHost(instance.Address); err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", instance.Address, err)
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	return zeroconf.RegisterProxy(instance.ID, serviceType, pSelf.config.Domain, port, hostname, ips, text, pSelf.config.Interfaces)
+}
+
+// txtRecords 는 TXT 에 기록할 key=value 목록이다.
+func txtRecords(instance server.ServiceInstance) []string {
+	text := []string{"name=" + instance.Name}
+	for _, tag := range instance.Tags {
+		text = append(text, "tag="+tag)
+	}
+	keys := make([]string, 0, len(instance.Metadata))
+	for key := range instance.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		text = append(text, key+"="+instance.Metadata[key])
+	}
+	if instance.HttpPort > 0 {
+		text = append(text, "http_port="+strconv.Itoa(instance.HttpPort))
+	}
+	if instance.TLS {
+		text = append(text, "tls=true")
+	}
+	return text
+}
@@ -0,0 +1,286 @@
+// Package servermqtt 는 MQTT topic 을 구독하여 받은 message 로 gRPC Method 를 호출하고, 응답을 MQTT 로 발행하는 server.Worker 이다.
+// IoT 장치가 MQTT 로 보낸 message 를 gRPC Service 의 handler 로 처리할 때 사용하며, Server 와 함께 시작하고 종료한다.
+//
+//	bridge, err := servermqtt.New(servermqtt.Options{
+//		Brokers: []string{"tcp://broker:1883"},
+//		Routes: []servermqtt.Route{
+//			{Topic: "devices/+/telemetry", RPC: "myapp.DeviceService/Report", QoS: 1, ResponseTopic: "{topic}/ack"},
+//		},
+//	})
+//	...
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, server.WithWorker("mqtt", bridge))
+package servermqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/berryons/server/internal/protomethod"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout        = 30 * time.Second
+	defaultConnectTimeout = 30 * time.Second
+	// disconnectQuiesce 는 연결을 끊기 전에 발행 중인 message 를 기다리는 시간(ms)이다.
+	disconnectQuiesce = 250
+
+	// TopicMetadataKey 는 message 를 받은 topic 을 전달하는 gRPC metadata key 이다.
+	TopicMetadataKey = "mqtt-topic"
+)
+
+// Route 는 MQTT topic 과 gRPC Method 의 연결이다.
+type Route struct {
+	// Topic 은 구독할 topic filter 이다. wildcard (+, #) 를 사용할 수 있다. (e.g. devices/+/telemetry)
+	Topic string
+	// RPC 는 호출할 unary gRPC Method 이다. (e.g. myapp.DeviceService/Report)
+	RPC string
+	// QoS 는 구독과 응답 발행의 QoS 이다. (0, 1, 2)
+	QoS byte
+	// ResponseTopic 은 응답 message 를 발행할 topic 이다. 비어 있으면 응답을 발행하지 않는다.
+	// "{topic}" 은 message 를 받은 topic 으로 바꾼다. (e.g. {topic}/ack)
+	ResponseTopic string
+	// ErrorTopic 은 gRPC Method 가 실패하면 google.rpc.Status 를 발행할 topic 이다. 비어 있으면 발행하지 않는다.
+	ErrorTopic string
+}
+
+// Options 는 MQTT bridge 설정이다.
+type Options struct {
+	// Brokers 는 MQTT broker 주소이다. (e.g. tcp://broker:1883, ssl://broker:8883, ws://broker/mqtt)
+	Brokers []string
+	// ClientID 는 MQTT client id 이다. 비어 있으면 broker 가 할당한다.
+	ClientID string
+	Username string
+	Password string
+	TLS      *tls.Config
+	// PersistentSession 이면 clean session 을 사용하지 않아, 연결이 끊긴 동안의 QoS 1, 2 message 를 다시 받는다.
+	PersistentSession bool
+	Routes            []Route
+	// Protobuf 이면 payload 를 protobuf binary 로 읽고 쓴다. 아니면 protojson 이다.
+	Protobuf bool
+	// Timeout 은 gRPC Method 호출의 timeout 이다. (기본값: 30초)
+	Timeout time.Duration
+	// OnError 는 message 를 처리하지 못하면 호출된다.
+	OnError func(topic string, err error)
+	// Files 는 RPC 를 찾을 protobuf registry 이다. (기본값: protoregistry.GlobalFiles)
+	Files *protoregistry.Files
+}
+
+// New 는 Options.Routes 를 처리하는 MQTT bridge 를 생성한다. server.WithWorker 에 전달한다.
+func New(options Options) (server.Worker, error) {
+	if len(options.Brokers) == 0 {
+		return nil, errors.New("mqtt: broker is required")
+	}
+	if len(options.Routes) == 0 {
+		return nil, errors.New("mqtt: no route")
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+	if options.Files == nil {
+		options.Files = protoregistry.GlobalFiles
+	}
+
+	bridge := &mqttBridge{options: options}
+	for _, route := range options.Routes {
+		if len(route.Topic) == 0 || route.QoS > 2 {
+			return nil, fmt.Errorf("mqtt: invalid route %s", route.Topic)
+		}
+		method, err := protomethod.FindUnary(options.Files, route.RPC)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: %s: %w", route.Topic, err)
+		}
+		bridge.routes = append(bridge.routes, &mqttRoute{route: route, method: method})
+	}
+	return bridge, nil
+}
+
+type mqttRoute struct {
+	route  Route
+	method protoreflect.MethodDescriptor
+}
+
+func (pSelf *mqttRoute) fullMethod() string {
+	return protomethod.FullMethod(pSelf.method)
+}
+
+type mqttBridge struct {
+	options Options
+	routes  []*mqttRoute
+
+	client mqtt.Client
+	conn   *grpc.ClientConn
+	// 종료를 시작하면 새 message 를 처리하지 않는다.
+	// Stop 이 inFlight 를 기다리는 동안 inFlight 가 늘지 않도록 stopping 과 함께 잠금으로 보호한다.
+	mutex    sync.Mutex
+	stopping bool
+	inFlight sync.WaitGroup
+}
+
+// Start 는 broker 에 연결하고 route 의 topic 을 구독한다. 다시 연결하면 구독도 다시 한다.
+func (pSelf *mqttBridge) Start(ctx context.Context, conn *grpc.ClientConn) error {
+	pSelf.conn = conn
+
+	clientOptions := mqtt.NewClientOptions().
+		SetClientID(pSelf.options.ClientID).
+		SetUsername(pSelf.options.Username).
+		SetPassword(pSelf.options.Password).
+		SetTLSConfig(pSelf.options.TLS).
+		SetCleanSession(!pSelf.options.PersistentSession).
+		SetOrderMatters(false).
+		SetAutoAckDisabled(true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(defaultConnectTimeout).
+		SetOnConnectHandler(pSelf.subscribe)
+	for _, broker := range pSelf.options.Brokers {
+		clientOptions.AddBroker(broker)
+	}
+
+	pSelf.client = mqtt.NewClient(clientOptions)
+	token := pSelf.client.Connect()
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("mqtt: failed to connect: %w", err)
+	}
+	return nil
+}
+
+func (pSelf *mqttBridge) subscribe(client mqtt.Client) {
+	for _, route := range pSelf.routes {
+		token := client.Subscribe(route.route.Topic, route.route.QoS, func(_ mqtt.Client, message mqtt.Message) {
+			pSelf.handle(route, message)
+		})
+		if !token.WaitTimeout(defaultConnectTimeout) {
+			pSelf.reportError(route.route.Topic, fmt.Errorf("failed to subscribe: timed out after %s", defaultConnectTimeout))
+		} else if err := token.Error(); err != nil {
+			pSelf.reportError(route.route.Topic, fmt.Errorf("failed to subscribe: %w", err))
+		}
+	}
+}
+
+// Stop 은 구독을 해제하고, 처리 중인 message 가 끝나거나 ctx 가 끝날 때까지 기다린 뒤 연결을 끊는다.
+func (pSelf *mqttBridge) Stop(ctx context.Context) error {
+	pSelf.mutex.Lock()
+	if pSelf.client == nil || pSelf.stopping {
+		pSelf.mutex.Unlock()
+		return nil
+	}
+	pSelf.stopping = true
+	pSelf.mutex.Unlock()
+
+	topics := make([]string, 0, len(pSelf.routes))
+	for _, route := range pSelf.routes {
+		topics = append(topics, route.route.Topic)
+	}
+	pSelf.client.Unsubscribe(topics...).WaitTimeout(time.Second)
+
+	cDone := make(chan struct{})
+	go func() {
+		pSelf.inFlight.Wait()
+		close(cDone)
+	}()
+	var err error
+	select {
+	case <-cDone:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	pSelf.client.Disconnect(disconnectQuiesce)
+	return err
+}
+
+// handle 은 message 로 gRPC Method 를 호출하고 응답을 발행한다.
+// 처리를 끝낸 뒤 ack 하므로, 종료 중에 받은 QoS 1, 2 message 는 ack 하지 않아 broker 가 다시 전달한다.
+func (pSelf *mqttBridge) handle(route *mqttRoute, message mqtt.Message) {
+	if !pSelf.begin() {
+		return
+	}
+	defer pSelf.inFlight.Done()
+	defer message.Ack()
+
+	request := dynamicpb.NewMessage(route.method.Input())
+	if err := pSelf.unmarshal(message.Payload(), request); err != nil {
+		pSelf.reportError(message.Topic(), fmt.Errorf("failed to decode payload: %w", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pSelf.options.Timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, TopicMetadataKey, message.Topic())
+
+	reply := dynamicpb.NewMessage(route.method.Output())
+	if err := pSelf.conn.Invoke(ctx, route.fullMethod(), request, reply); err != nil {
+		pSelf.reportError(message.Topic(), err)
+		if len(route.route.ErrorTopic) > 0 {
+			pSelf.publish(route, route.route.ErrorTopic, message.Topic(), status.Convert(err).Proto())
+		}
+		return
+	}
+	if len(route.route.ResponseTopic) > 0 {
+		pSelf.publish(route, route.route.ResponseTopic, message.Topic(), reply)
+	}
+}
+
+// begin 은 종료 중이 아니면 처리 중인 message 로 기록한다.
+func (pSelf *mqttBridge) begin() bool {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if pSelf.stopping {
+		return false
+	}
+	pSelf.inFlight.Add(1)
+	return true
+}
+
+func (pSelf *mqttBridge) publish(route *mqttRoute, topicTemplate, topic string, message proto.Message) {
+	responseTopic := strings.ReplaceAll(topicTemplate, "{topic}", topic)
+	payload, err := pSelf.marshal(message)
+	if err != nil {
+		pSelf.reportError(topic, fmt.Errorf("failed to encode response: %w", err))
+		return
+	}
+	token := pSelf.client.Publish(responseTopic, route.route.QoS, false, payload)
+	if token.WaitTimeout(pSelf.options.Timeout) && token.Error() != nil {
+		pSelf.reportError(topic, fmt.Errorf("failed to publish to %s: %w", responseTopic, token.Error()))
+	}
+}
+
+func (pSelf *mqttBridge) unmarshal(payload []byte, message proto.Message) error {
+	if pSelf.options.Protobuf {
+		return proto.Unmarshal(payload, message)
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(payload, message)
+}
+
+func (pSelf *mqttBridge) marshal(message proto.Message) ([]byte, error) {
+	if pSelf.options.Protobuf {
+		return proto.Marshal(message)
+	}
+	return protojson.Marshal(message)
+}
+
+func (pSelf *mqttBridge) reportError(topic string, err error) {
+	if pSelf.options.OnError != nil {
+		pSelf.options.OnError(topic, err)
+	}
+}
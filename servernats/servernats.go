@@ -0,0 +1,278 @@
+// Package servernats 는 NATS subject 로 받은 request 를 gRPC Method 로 처리하고 reply 하는 server.Worker 이다.
+// 같은 프로세스에서 같은 Service handler 로 gRPC 요청과 NATS request-reply 요청을 함께 처리할 때 사용한다.
+//
+//	grpcServer.RegisterServices(func(r grpc.ServiceRegistrar) {
+//		pb.RegisterGreeterServer(r, &greeter{})
+//	})
+//	bridge, err := servernats.New(servernats.Options{URL: "nats://nats:4222", Services: grpcServer.Services()})
+//	...
+//	grpcServer.AddWorker("nats", bridge)
+//	grpcServer.Run()
+//
+// subject 는 "Prefix.package.Service.Method" 이며, (e.g. grpc.myapp.Greeter.SayHello)
+// 같은 QueueGroup 의 Server 중 하나가 요청을 처리한다.
+package servernats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPrefix        = "grpc"
+	defaultTimeout       = 30 * time.Second
+	defaultMaxConcurrent = 64
+
+	// StatusHeader, MessageHeader 는 gRPC Method 가 실패한 경우 reply 에 gRPC status code 와 message 를 전달하는 header 이다.
+	// 성공하면 header 없이 응답 message 만 reply 한다.
+	StatusHeader  = "Grpc-Status"
+	MessageHeader = "Grpc-Message"
+	// SubjectMetadataKey 는 요청을 받은 subject 를 전달하는 gRPC metadata key 이다.
+	SubjectMetadataKey = "nats-subject"
+)
+
+// Options 는 NATS bridge 설정이다.
+type Options struct {
+	// URL 은 NATS server 주소이다. 여러 개이면 쉼표로 구분한다. (기본값: nats://127.0.0.1:4222)
+	URL string
+	// NatsOptions 는 인증, TLS 등 추가 연결 설정이다. (e.g. nats.UserCredentials("app.creds"))
+	NatsOptions []nats.Option
+	// Services 는 NATS 로 제공할 gRPC Service 의 전체 이름이다. (e.g. GrpcServer.Services())
+	Services []string
+	// Prefix 는 subject 의 prefix 이다. (기본값: grpc)
+	Prefix string
+	// QueueGroup 은 구독의 queue group 이다. (기본값: Prefix)
+	QueueGroup string
+	// Protobuf 이면 payload 를 protobuf binary 로 읽고 쓴다. 아니면 protojson 이다.
+	Protobuf bool
+	// Timeout 은 gRPC Method 호출의 timeout 이다. (기본값: 30초)
+	Timeout time.Duration
+	// MaxConcurrent 는 동시에 처리하는 요청의 최대 수이다. (기본값: 64)
+	MaxConcurrent int
+	// OnError 는 요청을 처리하지 못하면 호출된다.
+	OnError func(subject string, err error)
+	// Files 는 Service 를 찾을 protobuf registry 이다. (기본값: protoregistry.GlobalFiles)
+	Files *protoregistry.Files
+}
+
+// New 는 Options.Services 의 unary Method 를 subject 로 제공하는 NATS bridge 를 생성한다.
+// server.WithWorker 나 GrpcServer.AddWorker 에 전달한다.
+func New(options Options) (server.Worker, error) {
+	if len(options.URL) == 0 {
+		options.URL = nats.DefaultURL
+	}
+	if len(options.Prefix) == 0 {
+		options.Prefix = defaultPrefix
+	}
+	if len(options.QueueGroup) == 0 {
+		options.QueueGroup = options.Prefix
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+	if options.MaxConcurrent <= 0 {
+		options.MaxConcurrent = defaultMaxConcurrent
+	}
+	if options.Files == nil {
+		options.Files = protoregistry.GlobalFiles
+	}
+	if len(options.Services) == 0 {
+		return nil, errors.New("nats: no service to expose")
+	}
+
+	bridge := &natsBridge{options: options, methods: map[string]protoreflect.MethodDescriptor{}, cSlots: make(chan struct{}, options.MaxConcurrent)}
+	for _, serviceName := range options.Services {
+		descriptor, err := options.Files.FindDescriptorByName(protoreflect.FullName(serviceName))
+		if err != nil {
+			return nil, fmt.Errorf("nats: service %s: %w", serviceName, err)
+		}
+		service, ok := descriptor.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("nats: %s is not a service", serviceName)
+		}
+		for i := range service.Methods().Len() {
+			method := service.Methods().Get(i)
+			if method.IsStreamingClient() || method.IsStreamingServer() {
+				continue
+			}
+			bridge.methods[options.Prefix+"."+string(method.FullName())] = method
+		}
+	}
+	return bridge, nil
+}
+
+type natsBridge struct {
+	options Options
+	// subject 별 Method.
+	methods map[string]protoreflect.MethodDescriptor
+
+	conn          *grpc.ClientConn
+	nc            *nats.Conn
+	subscriptions []*nats.Subscription
+	// 동시에 처리하는 요청 수를 제한한다.
+	cSlots   chan struct{}
+	inFlight sync.WaitGroup
+}
+
+// Start 는 NATS server 에 연결하고 Method 의 subject 를 구독한다.
+func (pSelf *natsBridge) Start(_ context.Context, conn *grpc.ClientConn) error {
+	pSelf.conn = conn
+
+	nc, err := nats.Connect(pSelf.options.URL, pSelf.options.NatsOptions...)
+	if err != nil {
+		return fmt.Errorf("nats: failed to connect: %w", err)
+	}
+	pSelf.nc = nc
+
+	for subject, method := range pSelf.methods {
+		subscription, err := nc.QueueSubscribe(subject, pSelf.options.QueueGroup, func(message *nats.Msg) {
+			pSelf.cSlots <- struct{}{}
+			pSelf.inFlight.Add(1)
+			go func() {
+				defer func() {
+					<-pSelf.cSlots
+					pSelf.inFlight.Done()
+				}()
+				pSelf.handle(method, message)
+			}()
+		})
+		if err != nil {
+			nc.Close()
+			return fmt.Errorf("nats: failed to subscribe %s: %w", subject, err)
+		}
+		pSelf.subscriptions = append(pSelf.subscriptions, subscription)
+	}
+	return nc.Flush()
+}
+
+// Stop 은 구독을 drain 하여 이미 받은 요청까지 처리하고, 처리 중인 요청이 끝나거나 ctx 가 끝날 때까지 기다린 뒤 연결을 끊는다.
+func (pSelf *natsBridge) Stop(ctx context.Context) error {
+	if pSelf.nc == nil || pSelf.nc.IsClosed() {
+		return nil
+	}
+	defer pSelf.nc.Close()
+
+	var cClosed []<-chan nats.SubStatus
+	for _, subscription := range pSelf.subscriptions {
+		cClosed = append(cClosed, subscription.StatusChanged(nats.SubscriptionClosed))
+		_ = subscription.Drain()
+	}
+	for _, cStatus := range cClosed {
+		select {
+		case <-cStatus:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	cDone := make(chan struct{})
+	go func() {
+		pSelf.inFlight.Wait()
+		close(cDone)
+	}()
+	select {
+	case <-cDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return pSelf.nc.FlushWithContext(ctx)
+}
+
+// handle 은 요청으로 gRPC Method 를 호출하고 reply subject 로 응답한다.
+func (pSelf *natsBridge) handle(method protoreflect.MethodDescriptor, message *nats.Msg) {
+	ctx, cancel := context.WithTimeout(context.Background(), pSelf.options.Timeout)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, requestMetadata(message))
+
+	request := dynamicpb.NewMessage(method.Input())
+	if err := pSelf.unmarshal(message.Data, request); err != nil {
+		pSelf.respondError(message, status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err))
+		return
+	}
+
+	reply := dynamicpb.NewMessage(method.Output())
+	fullMethod := "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+	if err := pSelf.conn.Invoke(ctx, fullMethod, request, reply); err != nil {
+		pSelf.respondError(message, err)
+		return
+	}
+
+	data, err := pSelf.marshal(reply)
+	if err != nil {
+		pSelf.respondError(message, status.Errorf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+	if len(message.Reply) == 0 {
+		return
+	}
+	if err := message.Respond(data); err != nil {
+		pSelf.reportError(message.Subject, fmt.Errorf("failed to reply: %w", err))
+	}
+}
+
+func (pSelf *natsBridge) respondError(message *nats.Msg, err error) {
+	pSelf.reportError(message.Subject, err)
+	if len(message.Reply) == 0 {
+		return
+	}
+
+	grpcStatus := status.Convert(err)
+	response := nats.NewMsg(message.Reply)
+	response.Header.Set(StatusHeader, strconv.Itoa(int(grpcStatus.Code())))
+	response.Header.Set(MessageHeader, grpcStatus.Message())
+	if err := message.RespondMsg(response); err != nil {
+		pSelf.reportError(message.Subject, fmt.Errorf("failed to reply: %w", err))
+	}
+}
+
+// requestMetadata 는 NATS header 를 gRPC metadata 로 전달한다. NATS 와 gRPC 의 예약 header 는 제외한다.
+func requestMetadata(message *nats.Msg) metadata.MD {
+	md := metadata.Pairs(SubjectMetadataKey, message.Subject)
+	for key, values := range message.Header {
+		lowerKey := strings.ToLower(key)
+		if strings.HasPrefix(lowerKey, "nats-") || strings.HasPrefix(lowerKey, "grpc-") {
+			continue
+		}
+		md.Append(lowerKey, values...)
+	}
+	return md
+}
+
+func (pSelf *natsBridge) unmarshal(data []byte, message proto.Message) error {
+	if pSelf.options.Protobuf {
+		return proto.Unmarshal(data, message)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, message)
+}
+
+func (pSelf *natsBridge) marshal(message proto.Message) ([]byte, error) {
+	if pSelf.options.Protobuf {
+		return proto.Marshal(message)
+	}
+	return protojson.Marshal(message)
+}
+
+func (pSelf *natsBridge) reportError(subject string, err error) {
+	if pSelf.options.OnError != nil {
+		pSelf.options.OnError(subject, err)
+	}
+}
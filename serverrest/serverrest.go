@@ -0,0 +1,286 @@
+// Package serverrest 는 google.api.http annotation 없이 HTTP route 를 gRPC Method 에 연결한다.
+// proto 를 다시 생성할 수 없는 Service 도 설정 파일이나 코드로 REST API 를 제공할 수 있으며,
+// 요청 변환, 오류, header 전달은 gRPC Gateway 가 생성한 handler 와 같다.
+//
+//	routes, err := serverrest.LoadRoutes("routes.yaml")
+//	...
+//	grpcServer.RegisterHttpProxyServer([]server.HttpProxyServerHandler{
+//		serverrest.Handler(serverrest.Options{Routes: routes}),
+//	}, context.Background(), nil, nil, -1)
+//
+// routes.yaml:
+//
+//	routes:
+//	  - method: GET
+//	    path: /v1/users/{user_id}
+//	    rpc: myapp.UserService/GetUser
+//	  - method: POST
+//	    path: /v1/users
+//	    rpc: myapp.UserService/CreateUser
+//	    body: user
+package serverrest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/berryons/server"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Route 는 HTTP route 와 gRPC Method 의 연결이다. (google.api.http annotation 과 같은 의미)
+type Route struct {
+	// Method 는 HTTP method 이다. (e.g. GET, POST, PATCH)
+	Method string `json:"method" yaml:"method" toml:"method"`
+	// Path 는 URL path template 이다. 변수는 같은 이름의 요청 field 에 넣는다.
+	// (e.g. /v1/users/{user_id}, /v1/{name=projects/*/items/*})
+	Path string `json:"path" yaml:"path" toml:"path"`
+	// RPC 는 호출할 gRPC Method 이다. (e.g. myapp.UserService/GetUser)
+	RPC string `json:"rpc" yaml:"rpc" toml:"rpc"`
+	// Body 는 HTTP body 를 넣을 요청 field 이다. "*" 이면 요청 message 전체에 넣고, 비어 있으면 body 를 읽지 않는다.
+	// path 변수와 body 에 없는 field 는 query parameter 로 받는다.
+	Body string `json:"body" yaml:"body" toml:"body"`
+	// ResponseBody 는 응답할 응답 message 의 field 이다. 비어 있으면 응답 message 전체를 응답한다.
+	ResponseBody string `json:"response_body" yaml:"response_body" toml:"response_body"`
+}
+
+// Options 는 route 설정이다.
+type Options struct {
+	Routes []Route
+	// Files 는 RPC 를 찾을 protobuf registry 이다. (기본값: protoregistry.GlobalFiles)
+	Files *protoregistry.Files
+}
+
+// routeFile 은 LoadRoutes 가 읽는 파일 형식이다.
+type routeFile struct {
+	Routes []Route `json:"routes" yaml:"routes" toml:"routes"`
+}
+
+// LoadRoutes 는 route 설정 파일을 읽는다. 설정 파일과 같이 yaml, json, toml 형식을 지원한다.
+func LoadRoutes(path string) ([]Route, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file routeFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(b))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	case ".toml":
+		var metaData toml.MetaData
+		metaData, err = toml.Decode(string(b), &file)
+		if err == nil && len(metaData.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown fields: %v", metaData.Undecoded())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported route file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse route file %s: %w", path, err)
+	}
+	return file.Routes, nil
+}
+
+// Handler 는 Options.Routes 를 등록하는 HttpProxyServerHandler 이다. 잘못된 route 가 있으면 등록하지 않고 오류를 반환한다.
+func Handler(options Options) server.HttpProxyServerHandler {
+	return func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+		if options.Files == nil {
+			options.Files = protoregistry.GlobalFiles
+		}
+		if len(options.Routes) == 0 {
+			return errors.New("rest: no route")
+		}
+
+		var routes []*restRoute
+		for _, route := range options.Routes {
+			restRoute, err := newRestRoute(route, options.Files)
+			if err != nil {
+				return fmt.Errorf("rest: %s %s: %w", route.Method, route.Path, err)
+			}
+			routes = append(routes, restRoute)
+		}
+
+		conn, err := grpc.NewClient(endpoint, opts...)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			_ = conn.Close()
+		}()
+
+		for _, route := range routes {
+			route.conn, route.mux = conn, mux
+			if err := mux.HandlePath(route.route.Method, route.route.Path, route.serveHTTP); err != nil {
+				return fmt.Errorf("rest: %s %s: %w", route.route.Method, route.route.Path, err)
+			}
+		}
+		return nil
+	}
+}
+
+// restRoute 는 확인한 Route 이다.
+type restRoute struct {
+	route      Route
+	method     protoreflect.MethodDescriptor
+	fullMethod string
+	// bodyField, responseField 는 Body, ResponseBody 의 field 이다. 메시지 전체이면 nil 이다.
+	bodyField     protoreflect.FieldDescriptor
+	responseField protoreflect.FieldDescriptor
+
+	conn *grpc.ClientConn
+	mux  *runtime.ServeMux
+}
+
+func newRestRoute(route Route, files *protoregistry.Files) (*restRoute, error) {
+	route.Method = strings.ToUpper(route.Method)
+	if len(route.Method) == 0 || !strings.HasPrefix(route.Path, "/") {
+		return nil, errors.New("method and path are required")
+	}
+
+	rpc := strings.TrimPrefix(route.RPC, "/")
+	serviceName, methodName, ok := strings.Cut(rpc, "/")
+	if !ok {
+		return nil, fmt.Errorf("rpc must be package.Service/Method: %s", route.RPC)
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", serviceName, err)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("method %s not found", rpc)
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, fmt.Errorf("streaming method %s is not supported", rpc)
+	}
+
+	restRoute := &restRoute{route: route, method: method, fullMethod: "/" + rpc}
+	if len(route.Body) > 0 && route.Body != "*" {
+		if restRoute.bodyField, err = messageField(method.Input(), route.Body); err != nil {
+			return nil, fmt.Errorf("body: %w", err)
+		}
+	}
+	if len(route.ResponseBody) > 0 {
+		if restRoute.responseField, err = messageField(method.Output(), route.ResponseBody); err != nil {
+			return nil, fmt.Errorf("response_body: %w", err)
+		}
+	}
+	return restRoute, nil
+}
+
+// messageField 는 message 의 message type field 이다.
+func messageField(message protoreflect.MessageDescriptor, name string) (protoreflect.FieldDescriptor, error) {
+	field := message.Fields().ByName(protoreflect.Name(name))
+	if field == nil {
+		field = message.Fields().ByJSONName(name)
+	}
+	if field == nil {
+		return nil, fmt.Errorf("field %s not found in %s", name, message.FullName())
+	}
+	if field.Kind() != protoreflect.MessageKind || field.IsList() || field.IsMap() {
+		return nil, fmt.Errorf("field %s must be a message", name)
+	}
+	return field, nil
+}
+
+// serveHTTP 는 gRPC Gateway 가 생성한 handler 와 같은 방식으로 요청을 변환하여 gRPC Method 를 호출한다.
+func (pSelf *restRoute) serveHTTP(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	inboundMarshaler, outboundMarshaler := runtime.MarshalerForRequest(pSelf.mux, r)
+
+	ctx, err := runtime.AnnotateContext(ctx, pSelf.mux, r, pSelf.fullMethod, runtime.WithHTTPPathPattern(pSelf.route.Path))
+	if err != nil {
+		runtime.HTTPError(ctx, pSelf.mux, outboundMarshaler, w, r, err)
+		return
+	}
+	request, err := pSelf.request(r, inboundMarshaler, pathParams)
+	if err != nil {
+		runtime.HTTPError(ctx, pSelf.mux, outboundMarshaler, w, r, err)
+		return
+	}
+
+	var metadata runtime.ServerMetadata
+	reply := dynamicpb.NewMessage(pSelf.method.Output())
+	err = pSelf.conn.Invoke(ctx, pSelf.fullMethod, request, reply, grpc.Header(&metadata.HeaderMD), grpc.Trailer(&metadata.TrailerMD))
+	ctx = runtime.NewServerMetadataContext(ctx, metadata)
+	if err != nil {
+		runtime.HTTPError(ctx, pSelf.mux, outboundMarshaler, w, r, err)
+		return
+	}
+
+	var response proto.Message = reply
+	if pSelf.responseField != nil {
+		response = reply.Get(pSelf.responseField).Message().Interface()
+	}
+	runtime.ForwardResponseMessage(ctx, pSelf.mux, outboundMarshaler, w, r, response)
+}
+
+// request 는 HTTP body, path 변수, query parameter 로 요청 message 를 만든다.
+func (pSelf *restRoute) request(r *http.Request, marshaler runtime.Marshaler, pathParams map[string]string) (proto.Message, error) {
+	request := dynamicpb.NewMessage(pSelf.method.Input())
+	invalid := func(format string, args ...any) error {
+		return status.Errorf(codes.InvalidArgument, format, args...)
+	}
+
+	if len(pSelf.route.Body) > 0 {
+		var target proto.Message = request
+		if pSelf.bodyField != nil {
+			target = request.Mutable(pSelf.bodyField).Message().Interface()
+		}
+		if err := marshaler.NewDecoder(r.Body).Decode(target); err != nil && !errors.Is(err, io.EOF) {
+			return nil, invalid("%v", err)
+		}
+	}
+
+	filter := make([][]string, 0, len(pathParams)+1)
+	for fieldPath, value := range pathParams {
+		if err := runtime.PopulateFieldFromPath(request, fieldPath, value); err != nil {
+			return nil, invalid("type mismatch, parameter: %s, error: %v", fieldPath, err)
+		}
+		filter = append(filter, strings.Split(fieldPath, "."))
+	}
+
+	// body 가 메시지 전체이면 query parameter 는 사용하지 않는다.
+	if pSelf.route.Body != "*" {
+		if pSelf.bodyField != nil {
+			filter = append(filter, []string{string(pSelf.bodyField.Name())})
+		}
+		if err := r.ParseForm(); err != nil {
+			return nil, invalid("%v", err)
+		}
+		if err := runtime.PopulateQueryParameters(request, r.Form, utilities.NewDoubleArray(filter)); err != nil {
+			return nil, invalid("%v", err)
+		}
+	}
+	return request, nil
+}
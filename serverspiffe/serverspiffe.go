@@ -0,0 +1,159 @@
+// Package serverspiffe 는 SPIFFE Workload API (e.g. SPIRE Agent) 에서 받은 X.509 SVID 로 gRPC Server 에 mTLS 를 적용하고,
+// client 를 SPIFFE ID 로 인가하는 Interceptor 를 제공한다. SVID 와 trust bundle 은 Workload API 가 갱신하면 바로 교체한다.
+//
+//	source, err := serverspiffe.New(ctx, serverspiffe.Options{})  // SPIFFE_ENDPOINT_SOCKET 사용
+//	...
+//	defer source.Close()
+//	policy := serverspiffe.Policy{Methods: map[string]spiffeid.Matcher{
+//		"/myapp.AdminService/*": spiffeid.MatchID(spiffeid.RequireFromString("spiffe://example.org/ops")),
+//	}}
+//	grpcServer := server.New("tcp", "", 50051,
+//		[]grpc.UnaryServerInterceptor{serverspiffe.UnaryServerInterceptor(policy)},
+//		[]grpc.StreamServerInterceptor{serverspiffe.StreamServerInterceptor(policy)},
+//		server.WithTLS(source.TLSConfig()))
+package serverspiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// Options 는 Workload API 연결과 TLS handshake 의 인가 설정이다.
+type Options struct {
+	// Address 는 Workload API 주소이다. (e.g. unix:///run/spire/agent.sock. 기본값: SPIFFE_ENDPOINT_SOCKET)
+	Address string
+	// Authorizer 는 TLS handshake 에서 client 의 SPIFFE ID 를 확인한다.
+	// nil 이면 Server 와 같은 trust domain 의 client 만 허용한다.
+	Authorizer tlsconfig.Authorizer
+}
+
+// Source 는 Workload API 에서 받은 SVID 와 trust bundle 이다.
+type Source struct {
+	options    Options
+	x509Source *workloadapi.X509Source
+}
+
+// New 는 Workload API 에 연결하여 첫 SVID 를 받을 때까지 기다린다. 이후의 갱신은 background 에서 받는다.
+func New(ctx context.Context, options Options) (*Source, error) {
+	var sourceOptions []workloadapi.X509SourceOption
+	if len(options.Address) > 0 {
+		sourceOptions = append(sourceOptions, workloadapi.WithClientOptions(workloadapi.WithAddr(options.Address)))
+	}
+	x509Source, err := workloadapi.NewX509Source(ctx, sourceOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &Source{options: options, x509Source: x509Source}
+	if source.options.Authorizer == nil {
+		svid, err := x509Source.GetX509SVID()
+		if err != nil {
+			_ = x509Source.Close()
+			return nil, err
+		}
+		source.options.Authorizer = tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain())
+	}
+	return source, nil
+}
+
+// TLSConfig 는 client 인증서를 요구하는 mTLS 설정이다. server.WithTLS 에 전달한다.
+// handshake 마다 현재 SVID 와 trust bundle 을 사용하므로 갱신된 인증서를 다시 설정할 필요가 없다.
+func (pSelf *Source) TLSConfig() *tls.Config {
+	return tlsconfig.MTLSServerConfig(pSelf.x509Source, pSelf.x509Source, pSelf.options.Authorizer)
+}
+
+// ID 는 Server 의 SPIFFE ID 이다.
+func (pSelf *Source) ID() (spiffeid.ID, error) {
+	svid, err := pSelf.x509Source.GetX509SVID()
+	if err != nil {
+		return spiffeid.ID{}, err
+	}
+	return svid.ID, nil
+}
+
+// Close 는 Workload API 연결을 닫는다.
+func (pSelf *Source) Close() error {
+	return pSelf.x509Source.Close()
+}
+
+// PeerID 는 요청한 client 의 인증서에서 읽은 SPIFFE ID 이다. mTLS 가 아니거나 SVID 가 아니면 false 이다.
+func PeerID(ctx context.Context) (spiffeid.ID, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return spiffeid.ID{}, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return spiffeid.ID{}, false
+	}
+	id, err := x509svid.IDFromCert(tlsInfo.State.PeerCertificates[0])
+	if err != nil {
+		return spiffeid.ID{}, false
+	}
+	return id, true
+}
+
+// Policy 는 Method 별로 호출할 수 있는 SPIFFE ID 이다.
+type Policy struct {
+	// Methods 는 Method 별 matcher 이다. key 는 "/package.Service/Method" 또는 Service 의 모든 Method 인 "/package.Service/*" 이다.
+	// (e.g. spiffeid.MatchID, spiffeid.MatchOneOf, spiffeid.MatchMemberOf)
+	Methods map[string]spiffeid.Matcher
+	// Default 는 Methods 에 없는 Method 의 matcher 이다. nil 이면 TLS handshake 에서 인가한 client 를 모두 허용한다.
+	Default spiffeid.Matcher
+}
+
+// authorize 는 ctx 의 client 가 fullMethod 를 호출할 수 있는지 확인한다.
+func (pSelf Policy) authorize(ctx context.Context, fullMethod string) error {
+	id, ok := PeerID(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "spiffe id is required")
+	}
+
+	matcher, ok := pSelf.Methods[fullMethod]
+	if !ok {
+		if i := strings.LastIndex(fullMethod, "/"); i > 0 {
+			matcher, ok = pSelf.Methods[fullMethod[:i]+"/*"]
+		}
+	}
+	if !ok {
+		matcher = pSelf.Default
+	}
+	if matcher == nil {
+		return nil
+	}
+	if err := matcher(id); err != nil {
+		return status.Errorf(codes.PermissionDenied, "%s is not allowed to call %s", id, fullMethod)
+	}
+	return nil
+}
+
+// UnaryServerInterceptor 는 client 의 SPIFFE ID 를 policy 로 인가하는 Interceptor 이다.
+// SPIFFE ID 가 없으면 Unauthenticated, 허용하지 않는 ID 이면 PermissionDenied 로 응답한다.
+func UnaryServerInterceptor(policy Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := policy.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 는 UnaryServerInterceptor 의 Stream 버전이다.
+func StreamServerInterceptor(policy Policy) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := policy.authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
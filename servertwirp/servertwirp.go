@@ -0,0 +1,224 @@
+// Package servertwirp 은 unary gRPC Method 를 Http Proxy Server 에서 Twirp protocol 로 제공한다.
+// 기존 Twirp client 를 수정하지 않고 gRPC Server 로 옮길 때 사용하며, 요청은 Server 의 gRPC endpoint 로 전달한다.
+//
+//	grpcServer.RegisterHttpProxyServer([]server.HttpProxyServerHandler{
+//		servertwirp.Handler(servertwirp.Options{Services: []string{"myapp.Haberdasher"}}),
+//	}, context.Background(), nil, nil, -1)
+//
+//	POST /twirp/myapp.Haberdasher/MakeHat  (Content-Type: application/json 또는 application/protobuf)
+package servertwirp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultPrefix = "/twirp"
+	// maxRequestSize 는 Twirp 요청 body 의 최대 크기이다.
+	maxRequestSize = 4 << 20
+
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/protobuf"
+)
+
+// Options 는 Twirp endpoint 설정이다.
+type Options struct {
+	// Services 는 Twirp 로 제공할 gRPC Service 의 전체 이름이다. (e.g. myapp.Haberdasher)
+	// Service 의 pb 패키지를 import 하여 protobuf registry 에 등록되어 있어야 한다.
+	Services []string
+	// Prefix 는 Twirp route 의 path prefix 이다. (기본값: /twirp)
+	Prefix string
+	// CamelCase 이면 JSON field 이름으로 proto 이름 대신 lowerCamelCase 를 사용한다. (Twirp WithServerJSONCamelCaseNames)
+	CamelCase bool
+	// SkipDefaults 이면 JSON 응답에서 기본값 field 를 생략한다. (Twirp WithServerJSONSkipDefaults)
+	SkipDefaults bool
+	// Files 는 Service 를 찾을 protobuf registry 이다. (기본값: protoregistry.GlobalFiles)
+	Files *protoregistry.Files
+}
+
+// Handler 는 "Prefix/package.Service/Method" 에 Twirp route 를 등록하는 HttpProxyServerHandler 이다.
+// HTTP header 는 gRPC Gateway 와 같은 규칙으로 gRPC metadata 로 전달하며, gRPC status 는 Twirp error code 로 응답한다.
+func Handler(options Options) server.HttpProxyServerHandler {
+	return func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error {
+		if len(options.Prefix) == 0 {
+			options.Prefix = defaultPrefix
+		}
+		options.Prefix = "/" + strings.Trim(options.Prefix, "/")
+		if options.Files == nil {
+			options.Files = protoregistry.GlobalFiles
+		}
+		if len(options.Services) == 0 {
+			return errors.New("twirp: no service to expose")
+		}
+
+		var services []protoreflect.ServiceDescriptor
+		for _, serviceName := range options.Services {
+			descriptor, err := options.Files.FindDescriptorByName(protoreflect.FullName(serviceName))
+			if err != nil {
+				return fmt.Errorf("twirp: service %s: %w", serviceName, err)
+			}
+			service, ok := descriptor.(protoreflect.ServiceDescriptor)
+			if !ok {
+				return fmt.Errorf("twirp: %s is not a service", serviceName)
+			}
+			services = append(services, service)
+		}
+
+		conn, err := grpc.NewClient(endpoint, opts...)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			_ = conn.Close()
+		}()
+
+		for _, service := range services {
+			for i := range service.Methods().Len() {
+				method := service.Methods().Get(i)
+				if method.IsStreamingClient() || method.IsStreamingServer() {
+					continue
+				}
+				route := &twirpRoute{options: options, conn: conn, mux: mux, method: method}
+				if err := mux.HandlePath(http.MethodPost, route.path(), route.serveHTTP); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// twirpRoute 는 Method 하나의 Twirp route 이다.
+type twirpRoute struct {
+	options Options
+	conn    *grpc.ClientConn
+	mux     *runtime.ServeMux
+	method  protoreflect.MethodDescriptor
+}
+
+func (pSelf *twirpRoute) path() string {
+	return pSelf.options.Prefix + "/" + string(pSelf.method.Parent().FullName()) + "/" + string(pSelf.method.Name())
+}
+
+func (pSelf *twirpRoute) serveHTTP(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeJSON && contentType != contentTypeProtobuf {
+		writeError(w, "malformed", http.StatusBadRequest, "unexpected Content-Type: "+r.Header.Get("Content-Type"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		writeError(w, "resource_exhausted", http.StatusRequestEntityTooLarge, "request body is too large")
+		return
+	}
+	if err != nil {
+		writeError(w, "malformed", http.StatusBadRequest, "failed to read request body: "+err.Error())
+		return
+	}
+	request := dynamicpb.NewMessage(pSelf.method.Input())
+	if contentType == contentTypeJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, request)
+	} else {
+		err = proto.Unmarshal(body, request)
+	}
+	if err != nil {
+		writeError(w, "malformed", http.StatusBadRequest, "the request could not be decoded: "+err.Error())
+		return
+	}
+
+	fullMethod := "/" + string(pSelf.method.Parent().FullName()) + "/" + string(pSelf.method.Name())
+	ctx, err := runtime.AnnotateContext(r.Context(), pSelf.mux, r, fullMethod)
+	if err != nil {
+		writeError(w, "malformed", http.StatusBadRequest, err.Error())
+		return
+	}
+	reply := dynamicpb.NewMessage(pSelf.method.Output())
+	if err := pSelf.conn.Invoke(ctx, fullMethod, request, reply); err != nil {
+		grpcStatus := status.Convert(err)
+		code, httpStatus := twirpCode(grpcStatus.Code())
+		writeError(w, code, httpStatus, grpcStatus.Message())
+		return
+	}
+
+	var data []byte
+	if contentType == contentTypeJSON {
+		data, err = protojson.MarshalOptions{UseProtoNames: !pSelf.options.CamelCase, EmitUnpopulated: !pSelf.options.SkipDefaults}.Marshal(reply)
+	} else {
+		data, err = proto.Marshal(reply)
+	}
+	if err != nil {
+		writeError(w, "internal", http.StatusInternalServerError, "failed to marshal response: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+}
+
+// twirpError 는 Twirp error 응답이다.
+type twirpError struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func writeError(w http.ResponseWriter, code string, httpStatus int, msg string) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(httpStatus)
+	_ = json.NewEncoder(w).Encode(twirpError{Code: code, Msg: msg})
+}
+
+// twirpCode 는 gRPC code 의 Twirp error code 와 HTTP status 이다.
+func twirpCode(code codes.Code) (string, int) {
+	switch code {
+	case codes.Canceled:
+		return "canceled", http.StatusRequestTimeout
+	case codes.InvalidArgument:
+		return "invalid_argument", http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return "deadline_exceeded", http.StatusRequestTimeout
+	case codes.NotFound:
+		return "not_found", http.StatusNotFound
+	case codes.AlreadyExists:
+		return "already_exists", http.StatusConflict
+	case codes.PermissionDenied:
+		return "permission_denied", http.StatusForbidden
+	case codes.ResourceExhausted:
+		return "resource_exhausted", http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return "failed_precondition", http.StatusPreconditionFailed
+	case codes.Aborted:
+		return "aborted", http.StatusConflict
+	case codes.OutOfRange:
+		return "out_of_range", http.StatusBadRequest
+	case codes.Unimplemented:
+		return "unimplemented", http.StatusNotImplemented
+	case codes.Internal:
+		return "internal", http.StatusInternalServerError
+	case codes.Unavailable:
+		return "unavailable", http.StatusServiceUnavailable
+	case codes.DataLoss:
+		return "dataloss", http.StatusInternalServerError
+	case codes.Unauthenticated:
+		return "unauthenticated", http.StatusUnauthorized
+	}
+	return "unknown", http.StatusInternalServerError
+}
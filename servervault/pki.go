@@ -0,0 +1,201 @@
+package servervault
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPKIMount         = "pki"
+	defaultPKIRetryInterval = 30 * time.Second
+	minPKIRenewDelay        = time.Second
+)
+
+// PKIOptions 는 Vault PKI secrets engine 에서 발급받을 인증서 설정이다.
+type PKIOptions struct {
+	// Mount 는 PKI secrets engine 의 경로이다. (기본값: pki)
+	Mount string
+	// Role 은 인증서를 발급할 PKI role 이다.
+	Role string
+	// CommonName 은 인증서의 CN 이다.
+	CommonName string
+	// AltNames 는 DNS SAN 이다.
+	AltNames []string
+	// IPSANs 는 IP SAN 이다.
+	IPSANs []string
+	// TTL 은 인증서 유효 기간이다. 0 이면 role 의 기본값이다.
+	TTL time.Duration
+	// RetryInterval 은 갱신에 실패했을 때 다시 요청하는 간격이다. (기본값: 30s)
+	RetryInterval time.Duration
+	// OnRenew 는 인증서를 새로 발급받을 때 호출한다.
+	OnRenew func(certificate *x509.Certificate)
+	// OnError 는 갱신에 실패할 때 호출한다. 이전 인증서를 만료할 때까지 계속 사용한다.
+	OnError func(err error)
+}
+
+// PKISource 는 Vault PKI 에서 발급받은 단기 인증서이다. 유효 기간의 2/3 가 지나면 background 에서 새로 발급받는다.
+//
+//	source, err := provider.NewPKISource(ctx, servervault.PKIOptions{Role: "myapp", CommonName: "myapp.service.internal", TTL: 24 * time.Hour})
+//	...
+//	defer source.Close()
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, server.WithTLS(source.TLSConfig()))
+type PKISource struct {
+	provider *Provider
+	options  PKIOptions
+
+	certificate atomic.Pointer[tls.Certificate]
+	caPool      atomic.Pointer[x509.CertPool]
+
+	closeOnce sync.Once
+	cancel    context.CancelFunc
+}
+
+// NewPKISource 는 첫 인증서를 발급받는다. 발급받지 못하면 오류를 반환한다.
+// ctx 가 끝나거나 Close 를 호출하면 더 이상 갱신하지 않는다.
+func (pSelf *Provider) NewPKISource(ctx context.Context, options PKIOptions) (*PKISource, error) {
+	if len(options.Role) == 0 {
+		return nil, errors.New("vault PKI role is required")
+	}
+	if len(options.CommonName) == 0 {
+		return nil, errors.New("vault PKI common name is required")
+	}
+	if len(options.Mount) == 0 {
+		options.Mount = defaultPKIMount
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = defaultPKIRetryInterval
+	}
+
+	source := &PKISource{provider: pSelf, options: options}
+	if err := source.issue(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, source.cancel = context.WithCancel(ctx)
+	go source.renew(ctx)
+	return source, nil
+}
+
+// TLSConfig 는 발급받은 인증서를 사용하는 TLS 설정이다. server.WithTLS 에 전달한다.
+// handshake 마다 현재 인증서를 사용하므로 갱신된 인증서를 다시 설정할 필요가 없다.
+func (pSelf *PKISource) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return pSelf.certificate.Load(), nil
+		},
+	}
+}
+
+// Certificate 는 현재 인증서이다.
+func (pSelf *PKISource) Certificate() *x509.Certificate {
+	return pSelf.certificate.Load().Leaf
+}
+
+// CAPool 은 발급한 CA 의 인증서이다. client 인증서를 같은 PKI 에서 발급할 때 tls.Config.ClientCAs 로 사용한다.
+func (pSelf *PKISource) CAPool() *x509.CertPool {
+	return pSelf.caPool.Load()
+}
+
+// Close 는 갱신을 멈춘다.
+func (pSelf *PKISource) Close() error {
+	pSelf.closeOnce.Do(pSelf.cancel)
+	return nil
+}
+
+type pkiIssueRequest struct {
+	CommonName string `json:"common_name"`
+	AltNames   string `json:"alt_names,omitempty"`
+	IPSANs     string `json:"ip_sans,omitempty"`
+	TTL        string `json:"ttl,omitempty"`
+	Format     string `json:"format"`
+}
+
+// issue 는 {Mount}/issue/{Role} 로 인증서를 발급받는다.
+func (pSelf *PKISource) issue(ctx context.Context) error {
+	payload := pkiIssueRequest{
+		CommonName: pSelf.options.CommonName,
+		AltNames:   strings.Join(pSelf.options.AltNames, ","),
+		IPSANs:     strings.Join(pSelf.options.IPSANs, ","),
+		Format:     "pem",
+	}
+	if pSelf.options.TTL > 0 {
+		payload.TTL = pSelf.options.TTL.String()
+	}
+	path := strings.Trim(pSelf.options.Mount, "/") + "/issue/" + pSelf.options.Role
+	body, err := pSelf.provider.do(ctx, http.MethodPost, path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to issue vault PKI certificate: %w", err)
+	}
+
+	certificatePEM := stringValue(body.Data["certificate"])
+	chain := []string{certificatePEM}
+	if caChain, ok := body.Data["ca_chain"].([]any); ok && len(caChain) > 0 {
+		for _, ca := range caChain {
+			chain = append(chain, stringValue(ca))
+		}
+	} else if issuingCA, ok := body.Data["issuing_ca"].(string); ok && len(issuingCA) > 0 {
+		chain = append(chain, issuingCA)
+	}
+	certificate, err := tls.X509KeyPair([]byte(strings.Join(chain, "\n")), []byte(stringValue(body.Data["private_key"])))
+	if err != nil {
+		return fmt.Errorf("failed to load vault PKI certificate: %w", err)
+	}
+	if certificate.Leaf == nil {
+		if certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse vault PKI certificate: %w", err)
+		}
+	}
+
+	caPool := x509.NewCertPool()
+	for _, der := range certificate.Certificate[1:] {
+		if ca, err := x509.ParseCertificate(der); err == nil {
+			caPool.AddCert(ca)
+		}
+	}
+	pSelf.certificate.Store(&certificate)
+	pSelf.caPool.Store(caPool)
+	return nil
+}
+
+// renew 는 유효 기간의 2/3 가 지나면 인증서를 새로 발급받는다. 실패하면 RetryInterval 뒤에 다시 요청한다.
+func (pSelf *PKISource) renew(ctx context.Context) {
+	delay := pSelf.renewDelay()
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := pSelf.issue(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if pSelf.options.OnError != nil {
+				pSelf.options.OnError(err)
+			}
+			delay = pSelf.options.RetryInterval
+			continue
+		}
+		if pSelf.options.OnRenew != nil {
+			pSelf.options.OnRenew(pSelf.Certificate())
+		}
+		delay = pSelf.renewDelay()
+	}
+}
+
+func (pSelf *PKISource) renewDelay() time.Duration {
+	leaf := pSelf.Certificate()
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	return max(time.Until(leaf.NotBefore.Add(lifetime*2/3)), minPKIRenewDelay)
+}
@@ -0,0 +1,171 @@
+// Package servervault 는 HashiCorp Vault 에서 Secret 을 가져오는 server.SecretProvider 이다.
+//
+//	provider, err := servervault.New(servervault.Config{})  // VAULT_ADDR, VAULT_TOKEN 사용
+//	...
+//	certSecret, err := server.NewManagedSecret(ctx, provider, "secret/data/myapp/tls", time.Hour)
+//	tlsConfig, err := server.TLSConfigFromSecret(certSecret, "certificate", "private_key")
+//
+// PKI secrets engine 에서 단기 인증서를 발급받아 자동으로 갱신할 수도 있다. (NewPKISource 참고)
+package servervault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/berryons/server"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Config 는 Vault 연결 설정이다. 비어 있는 항목은 Vault CLI 와 같은 환경 변수를 사용한다.
+type Config struct {
+	// Address 는 Vault 주소이다. (기본값: VAULT_ADDR)
+	Address string
+	// Token 은 Vault token 이다. (기본값: VAULT_TOKEN)
+	Token string
+	// TokenFile 이 있으면 요청마다 token 을 파일에서 읽는다. (e.g. Vault Agent 의 token sink)
+	TokenFile string
+	// Namespace 는 Vault Enterprise namespace 이다. (기본값: VAULT_NAMESPACE)
+	Namespace string
+	// HTTPClient 가 nil 이면 timeout 10초의 http.Client 를 사용한다.
+	HTTPClient *http.Client
+}
+
+// Provider 는 Vault HTTP API 로 Secret 을 읽는다.
+type Provider struct {
+	config Config
+}
+
+// New 는 Vault Provider 를 생성한다.
+func New(config Config) (*Provider, error) {
+	if len(config.Address) == 0 {
+		config.Address = os.Getenv("VAULT_ADDR")
+	}
+	if len(config.Token) == 0 && len(config.TokenFile) == 0 {
+		config.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if len(config.Namespace) == 0 {
+		config.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	if len(config.Address) == 0 {
+		return nil, errors.New("vault address is required (VAULT_ADDR)")
+	}
+	if len(config.Token) == 0 && len(config.TokenFile) == 0 {
+		return nil, errors.New("vault token is required (VAULT_TOKEN)")
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &Provider{config: config}, nil
+}
+
+type secretResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Data          map[string]any `json:"data"`
+	Errors        []string       `json:"errors"`
+}
+
+// GetSecret 은 name (e.g. secret/data/myapp, database/creds/readonly) 의 Secret 을 읽는다.
+// KV v2 의 경우 data.data 를 Secret 의 값으로, metadata.version 을 Version 으로 사용한다.
+// 동적 Secret 은 lease_duration 을 TTL 로 사용한다.
+func (pSelf *Provider) GetSecret(ctx context.Context, name string) (*server.Secret, error) {
+	body, err := pSelf.do(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data := body.Data
+	version := body.LeaseID
+	if inner, ok := body.Data["data"].(map[string]any); ok {
+		if metadata, ok := body.Data["metadata"].(map[string]any); ok {
+			data = inner
+			if v, ok := metadata["version"].(float64); ok {
+				version = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+	}
+
+	secret := &server.Secret{
+		Data:    make(map[string]string, len(data)),
+		TTL:     time.Duration(body.LeaseDuration) * time.Second,
+		Version: version,
+	}
+	for key, value := range data {
+		secret.Data[key] = stringValue(value)
+	}
+	return secret, nil
+}
+
+// do 는 Vault HTTP API 의 path 로 요청한다. payload 가 nil 이 아니면 JSON 으로 보낸다.
+func (pSelf *Provider) do(ctx context.Context, method, path string, payload any) (*secretResponse, error) {
+	token, err := pSelf.token()
+	if err != nil {
+		return nil, err
+	}
+
+	var requestBody io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		requestBody = bytes.NewReader(b)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, pSelf.config.Address+"/v1/"+strings.TrimPrefix(path, "/"), requestBody)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", token)
+	if len(pSelf.config.Namespace) > 0 {
+		request.Header.Set("X-Vault-Namespace", pSelf.config.Namespace)
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := pSelf.config.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var body secretResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil && response.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s for %s: %s", response.Status, path, strings.Join(body.Errors, "; "))
+	}
+	return &body, nil
+}
+
+func (pSelf *Provider) token() (string, error) {
+	if len(pSelf.config.TokenFile) == 0 {
+		return pSelf.config.Token, nil
+	}
+	b, err := os.ReadFile(pSelf.config.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault token file: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// stringValue 는 문자열이 아닌 값을 JSON 으로 바꾼다.
+func stringValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
@@ -0,0 +1,60 @@
+// Package serverwire 는 Wire 를 사용하는 application 에서 gRPC Server 를 구성하는 Provider Set 이다.
+//
+//	func initServer(config *server.Config) (*server.GrpcServer, error) {
+//		wire.Build(serverwire.ProviderSet, serverwire.EmptyBundleSet)
+//		return nil, nil
+//	}
+//
+// Interceptor, Option 을 추가할 때는 EmptyBundleSet 대신 Bundle 을 제공한다.
+//
+//	func provideBundle(auth *Auth) serverwire.Bundle {
+//		return serverwire.MergeBundles(
+//			serverwire.Bundle{Unary: []grpc.UnaryServerInterceptor{auth.Unary}},
+//			serverwire.Bundle{Options: []server.Option{server.WithRecovery()}},
+//		)
+//	}
+package serverwire
+
+import (
+	"github.com/berryons/server"
+	"github.com/google/wire"
+	"google.golang.org/grpc"
+)
+
+// ProviderSet 은 *server.Config 와 Bundle 로 *server.GrpcServer, *grpc.Server 를 제공한다.
+var ProviderSet = wire.NewSet(New, GrpcServer)
+
+// EmptyBundleSet 은 Interceptor, Option 없이 Server 를 생성할 때 ProviderSet 과 함께 사용한다.
+var EmptyBundleSet = wire.NewSet(wire.Value(Bundle{}))
+
+// Bundle 은 Server 에 추가할 Interceptor 와 Option 의 묶음이다.
+type Bundle struct {
+	Unary   []grpc.UnaryServerInterceptor
+	Stream  []grpc.StreamServerInterceptor
+	Options []server.Option
+}
+
+// MergeBundles 는 여러 Bundle 을 순서대로 합친다.
+// Wire 는 같은 type 을 하나만 제공할 수 있으므로, 여러 Bundle 은 합쳐서 제공한다.
+func MergeBundles(bundles ...Bundle) Bundle {
+	var merged Bundle
+	for _, bundle := range bundles {
+		merged.Unary = append(merged.Unary, bundle.Unary...)
+		merged.Stream = append(merged.Stream, bundle.Stream...)
+		merged.Options = append(merged.Options, bundle.Options...)
+	}
+	return merged
+}
+
+// New 는 config 와 bundle 로 gRPC Server 를 생성한다.
+func New(config *server.Config, bundle Bundle) (*server.GrpcServer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return server.NewWithConfig(config, bundle.Unary, bundle.Stream, bundle.Options...), nil
+}
+
+// GrpcServer 는 Service 를 등록할 수 있도록 *grpc.Server 를 제공한다.
+func GrpcServer(grpcServer *server.GrpcServer) *grpc.Server {
+	return grpcServer.Server
+}
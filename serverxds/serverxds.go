@@ -0,0 +1,70 @@
+// Package serverxds 는 xDS control plane (Istio, Traffic Director 등) 에서 Listener, Route, Security 설정을 받는 gRPC Server 를 생성한다.
+//
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, serverxds.WithXDS(serverxds.Options{}))
+//	grpcServer.RegisterServices(func(r grpc.ServiceRegistrar) {
+//		pb.RegisterGreeterServer(r, &greeter{})
+//	})
+//	grpcServer.Run()
+package serverxds
+
+import (
+	"errors"
+	"github.com/berryons/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	xdscreds "google.golang.org/grpc/credentials/xds"
+	"google.golang.org/grpc/xds"
+	"net"
+	"os"
+)
+
+const (
+	// BootstrapFileEnv 는 xDS bootstrap 파일 경로의 환경 변수이다.
+	BootstrapFileEnv = "GRPC_XDS_BOOTSTRAP"
+	// BootstrapConfigEnv 는 xDS bootstrap 내용의 환경 변수이다.
+	BootstrapConfigEnv = "GRPC_XDS_BOOTSTRAP_CONFIG"
+)
+
+// Options 는 xDS Server 설정이다.
+// bootstrap 설정은 grpc 가 프로세스를 시작할 때 읽는 GRPC_XDS_BOOTSTRAP (파일 경로) 또는 GRPC_XDS_BOOTSTRAP_CONFIG (JSON) 환경 변수로 전달한다.
+type Options struct {
+	// FallbackCredentials 는 control plane 이 Security 설정을 보내지 않은 연결의 credentials 이다. nil 이면 TLS 없이 연결한다.
+	FallbackCredentials credentials.TransportCredentials
+	// OnServingModeChange 는 Listener 의 serving mode 가 바뀌면 호출된다. (e.g. control plane 에서 Listener 설정을 받기 전에는 NOT_SERVING)
+	OnServingModeChange func(address net.Addr, mode xds.ServingModeChangeArgs)
+}
+
+// WithXDS 는 xds.NewGRPCServer 로 gRPC Server 를 생성한다.
+// xDS Server 는 *grpc.Server 가 아니므로 GrpcServer.Server 는 nil 이며, Service 는 RegisterServices 로 등록한다.
+// Security 설정은 xDS credentials 로 적용하므로 server.WithTLS 대신 FallbackCredentials 를 사용한다. 함께 사용하면 시작하지 않는다.
+func WithXDS(options Options) server.Option {
+	return server.WithCredentialsServerFactory(func(serverOptions ...grpc.ServerOption) (server.ServiceServer, error) {
+		if err := checkBootstrap(); err != nil {
+			return nil, err
+		}
+
+		fallbackCredentials := options.FallbackCredentials
+		if fallbackCredentials == nil {
+			fallbackCredentials = insecure.NewCredentials()
+		}
+		transportCredentials, err := xdscreds.NewServerCredentials(xdscreds.ServerOptions{FallbackCreds: fallbackCredentials})
+		if err != nil {
+			return nil, err
+		}
+
+		serverOptions = append(serverOptions, grpc.Creds(transportCredentials))
+		if options.OnServingModeChange != nil {
+			serverOptions = append(serverOptions, xds.ServingModeCallback(options.OnServingModeChange))
+		}
+		return xds.NewGRPCServer(serverOptions...)
+	})
+}
+
+// checkBootstrap 은 bootstrap 환경 변수가 설정되었는지 확인한다.
+func checkBootstrap() error {
+	if len(os.Getenv(BootstrapFileEnv)) == 0 && len(os.Getenv(BootstrapConfigEnv)) == 0 {
+		return errors.New("xDS bootstrap is not set: set " + BootstrapFileEnv + " or " + BootstrapConfigEnv)
+	}
+	return nil
+}
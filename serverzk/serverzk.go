@@ -0,0 +1,223 @@
+// Package serverzk 는 ZooKeeper 에 Server 를 ephemeral node 로 등록하는 server.Registrar 이다.
+// node 의 경로와 내용은 Apache Curator Service Discovery 와 같은 형식이므로 Curator 를 사용하는 client 가 찾을 수 있다.
+//
+//	registrar, err := serverzk.New(serverzk.Config{Servers: []string{"zk1:2181", "zk2:2181"}})
+//	...
+//	defer registrar.Close()
+//	grpcServer := server.NewFromConfig("server.yaml", nil, nil, server.WithRegistrar(registrar))
+package serverzk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/berryons/server"
+	"github.com/go-zookeeper/zk"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionTimeout = 10 * time.Second
+	defaultBasePath       = "/services"
+)
+
+// Config 는 ZooKeeper 연결 설정이다.
+type Config struct {
+	// Servers 는 ZooKeeper ensemble 의 주소이다. (e.g. zk1:2181)
+	Servers []string
+	// SessionTimeout 은 연결이 끊긴 뒤 ephemeral node 가 삭제되기까지의 시간이다. (기본값: 10초)
+	SessionTimeout time.Duration
+	// BasePath 는 Service 를 등록할 경로이다. node 는 BasePath/이름/ID 에 만든다. (기본값: /services)
+	BasePath string
+	// AuthScheme, Auth 는 ZooKeeper 인증 정보이다. (e.g. "digest", "user:password")
+	AuthScheme string
+	Auth       string
+	// ACL 은 만드는 node 의 ACL 이다. nil 이면 zk.WorldACL(zk.PermAll) 을 사용한다.
+	ACL []zk.ACL
+}
+
+// Registrar 는 ZooKeeper 에 Server 를 등록한다. session 이 만료되면 등록한 node 를 다시 만든다.
+type Registrar struct {
+	config Config
+	conn   *zk.Conn
+
+	mutex sync.Mutex
+	// nodes 는 등록한 node 의 경로와 내용이다.
+	nodes   map[string][]byte
+	expired bool
+}
+
+// New 는 ZooKeeper 에 연결하는 Registrar 를 생성한다. 연결은 background 에서 맺는다.
+func New(config Config) (*Registrar, error) {
+	if len(config.Servers) == 0 {
+		return nil, errors.New("zookeeper servers are required")
+	}
+	if config.SessionTimeout <= 0 {
+		config.SessionTimeout = defaultSessionTimeout
+	}
+	if len(config.BasePath) == 0 {
+		config.BasePath = defaultBasePath
+	}
+	if config.ACL == nil {
+		config.ACL = zk.WorldACL(zk.PermAll)
+	}
+
+	registrar := &Registrar{config: config, nodes: map[string][]byte{}}
+	conn, _, err := zk.Connect(config.Servers, config.SessionTimeout, zk.WithLogInfo(false), zk.WithEventCallback(registrar.handleEvent))
+	if err != nil {
+		return nil, err
+	}
+	if len(config.AuthScheme) > 0 {
+		if err := conn.AddAuth(config.AuthScheme, []byte(config.Auth)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	registrar.conn = conn
+	return registrar, nil
+}
+
+// Close 는 ZooKeeper 연결을 닫는다. 등록한 node 는 session 이 끝나면 삭제된다.
+func (pSelf *Registrar) Close() {
+	pSelf.conn.Close()
+}
+
+// instance 는 Curator Service Discovery 의 ServiceInstance 형식이다.
+type instance struct {
+	Name                string   `json:"name"`
+	ID                  string   `json:"id"`
+	Address             string   `json:"address"`
+	Port                *int     `json:"port"`
+	SSLPort             *int     `json:"sslPort"`
+	Payload             *payload `json:"payload"`
+	RegistrationTimeUTC int64    `json:"registrationTimeUTC"`
+	ServiceType         string   `json:"serviceType"`
+	URISpec             any      `json:"uriSpec"`
+}
+
+// payload 는 Curator 형식에 없는 Server 정보이다.
+type payload struct {
+	Tags     []string          `json:"tags,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	HttpPort int               `json:"http_port,omitempty"`
+}
+
+// Register 는 BasePath/이름/ID 에 Server 정보를 ephemeral node 로 만든다. TLS 를 사용하면 port 대신 sslPort 에 기록한다.
+func (pSelf *Registrar) Register(ctx context.Context, serviceInstance server.ServiceInstance) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	port := serviceInstance.Port
+	node := instance{
+		Name:                serviceInstance.Name,
+		ID:                  serviceInstance.ID,
+		Address:             serviceInstance.Address,
+		Port:                &port,
+		RegistrationTimeUTC: time.Now().UnixMilli(),
+		ServiceType:         "DYNAMIC",
+		Payload: &payload{
+			Tags:     serviceInstance.Tags,
+			Metadata: serviceInstance.Metadata,
+			HttpPort: serviceInstance.HttpPort,
+		},
+	}
+	if serviceInstance.TLS {
+		node.Port, node.SSLPort = nil, &port
+	}
+	data, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	nodePath := pSelf.nodePath(serviceInstance)
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if err := pSelf.createNode(nodePath, data); err != nil {
+		return err
+	}
+	pSelf.nodes[nodePath] = data
+	return nil
+}
+
+// Deregister 는 Server 의 node 를 삭제한다.
+func (pSelf *Registrar) Deregister(ctx context.Context, serviceInstance server.ServiceInstance) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	nodePath := pSelf.nodePath(serviceInstance)
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	delete(pSelf.nodes, nodePath)
+	if err := pSelf.conn.Delete(nodePath, -1); err != nil && !errors.Is(err, zk.ErrNoNode) {
+		return err
+	}
+	return nil
+}
+
+func (pSelf *Registrar) nodePath(serviceInstance server.ServiceInstance) string {
+	return path.Join(pSelf.config.BasePath, serviceInstance.Name, serviceInstance.ID)
+}
+
+// createNode 는 상위 경로를 만들고 ephemeral node 를 만든다. 같은 ID 의 이전 session node 가 남아 있으면 바꾼다.
+func (pSelf *Registrar) createNode(nodePath string, data []byte) error {
+	if err := pSelf.createParents(path.Dir(nodePath)); err != nil {
+		return err
+	}
+
+	_, err := pSelf.conn.Create(nodePath, data, zk.FlagEphemeral, pSelf.config.ACL)
+	if errors.Is(err, zk.ErrNodeExists) {
+		if err := pSelf.conn.Delete(nodePath, -1); err != nil && !errors.Is(err, zk.ErrNoNode) {
+			return err
+		}
+		_, err = pSelf.conn.Create(nodePath, data, zk.FlagEphemeral, pSelf.config.ACL)
+	}
+	return err
+}
+
+func (pSelf *Registrar) createParents(parentPath string) error {
+	current := ""
+	for _, name := range strings.Split(strings.Trim(parentPath, "/"), "/") {
+		current += "/" + name
+		if _, err := pSelf.conn.Create(current, nil, 0, pSelf.config.ACL); err != nil && !errors.Is(err, zk.ErrNodeExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleEvent 는 session 이 만료된 뒤 새 session 을 맺으면 등록한 node 를 다시 만든다.
+func (pSelf *Registrar) handleEvent(event zk.Event) {
+	if event.Type != zk.EventSession {
+		return
+	}
+
+	switch event.State {
+	case zk.StateExpired:
+		pSelf.mutex.Lock()
+		pSelf.expired = true
+		pSelf.mutex.Unlock()
+	case zk.StateHasSession:
+		// event callback 안에서는 요청을 보낼 수 없으므로 Goroutine 에서 처리.
+		go pSelf.restore()
+	}
+}
+
+func (pSelf *Registrar) restore() {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if !pSelf.expired {
+		return
+	}
+	pSelf.expired = false
+	for nodePath, data := range pSelf.nodes {
+		if err := pSelf.createNode(nodePath, data); err != nil {
+			// 다음 session 에서 다시 시도.
+			pSelf.expired = true
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// otherServiceLabel 은 RegisterServices 로 등록하지 않은 Service 의 요청에 사용하는 service_requests 지표 label 이다.
+const otherServiceLabel = "other"
+
+// RegisterServices 는 register 로 gRPC Service 를 등록한다.
+// Server 필드에 직접 등록하는 대신 사용하면 등록한 Service 를 기록하여,
+// WithHealthCheck 를 사용하는 경우 Service 별 상태를 SERVING 으로 설정하고
+// service_requests 지표를 Service 이름 label 로 나누어 기록한다. (등록하지 않은 Service 는 "other")
+// Reflection 에서 Service 를 숨기려면 WithReflection 에 Service 이름을 지정한다.
+//
+//	grpcServer.RegisterServices(func(r grpc.ServiceRegistrar) {
+//		pb.RegisterGreeterServer(r, &greeter{})
+//	})
+func (pSelf *GrpcServer) RegisterServices(register func(r grpc.ServiceRegistrar)) {
+	register(&serviceRegistrar{server: pSelf})
+}
+
+// Services 는 RegisterServices 로 등록한 Service 의 이름 목록이다.
+func (pSelf *GrpcServer) Services() []string {
+	return pSelf.options.services.list()
+}
+
+// serviceRegistrar 는 등록한 Service 를 기록하는 grpc.ServiceRegistrar 이다.
+type serviceRegistrar struct {
+	server *GrpcServer
+}
+
+func (pSelf *serviceRegistrar) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	pSelf.server.serviceServer.RegisterService(desc, impl)
+	pSelf.server.options.services.add(desc.ServiceName)
+
+	if pSelf.server.healthServer != nil {
+		pSelf.server.healthServer.SetServingStatus(desc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+}
+
+// serviceSet 은 RegisterServices 로 등록한 Service 이름이다.
+// 요청을 처리하는 동안에도 등록할 수 있으므로 잠금으로 보호한다.
+type serviceSet struct {
+	mutex sync.RWMutex
+	names []string
+}
+
+func (pSelf *serviceSet) add(name string) {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.names = append(pSelf.names, name)
+}
+
+func (pSelf *serviceSet) list() []string {
+	pSelf.mutex.RLock()
+	defer pSelf.mutex.RUnlock()
+	return slices.Clone(pSelf.names)
+}
+
+// label 은 fullMethod 의 service_requests 지표 label 이다.
+// 지표의 label 수가 늘어나지 않도록 등록한 Service 만 이름을 사용한다.
+func (pSelf *serviceSet) label(fullMethod string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+
+	pSelf.mutex.RLock()
+	defer pSelf.mutex.RUnlock()
+	if slices.Contains(pSelf.names, service) {
+		return service
+	}
+	return otherServiceLabel
+}
+
+func (pSelf *serviceSet) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	addLabeledMetric("service_requests", pSelf.label(info.FullMethod), 1)
+	return handler(ctx, req)
+}
+
+func (pSelf *serviceSet) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	addLabeledMetric("service_requests", pSelf.label(info.FullMethod), 1)
+	return handler(srv, ss)
+}
+
+// reflectionServices 는 WithReflection 으로 숨긴 Service 를 제외한 Service 목록을 Reflection 에 제공한다.
+type reflectionServices struct {
+	server ServiceServer
+	hidden []string
+}
+
+func (pSelf *reflectionServices) GetServiceInfo() map[string]grpc.ServiceInfo {
+	services := maps.Clone(pSelf.server.GetServiceInfo())
+	maps.DeleteFunc(services, func(name string, _ grpc.ServiceInfo) bool {
+		return slices.Contains(pSelf.hidden, name)
+	})
+	return services
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ViperSettings 는 *viper.Viper 가 구현하는 interface 이다.
+type ViperSettings interface {
+	AllSettings() map[string]any
+}
+
+// KoanfSettings 는 *koanf.Koanf 가 구현하는 interface 이다.
+type KoanfSettings interface {
+	Raw() map[string]any
+}
+
+// ConfigFromViper 는 viper 가 file, 환경 변수, flag 의 우선 순위에 따라 합친 값으로 설정을 만든다.
+// 다른 설정과 함께 쓰는 경우 v.Sub("server") 와 같이 Server 설정만 전달한다.
+func ConfigFromViper(v ViperSettings) (*Config, error) {
+	return ConfigFromSettings(v.AllSettings())
+}
+
+// ConfigFromKoanf 는 koanf 가 합친 값으로 설정을 만든다.
+// 다른 설정과 함께 쓰는 경우 k.Cut("server") 와 같이 Server 설정만 전달한다.
+func ConfigFromKoanf(k KoanfSettings) (*Config, error) {
+	return ConfigFromSettings(k.Raw())
+}
+
+// ConfigFromSettings 는 설정 파일과 같은 key (e.g. "port", "tls.cert_file") 의 map 으로 설정을 만든다.
+// 환경 변수나 flag 에서 온 문자열 값도 필드 타입에 맞게 변환하며, 없는 key 는 DefaultConfig 의 값을 사용한다.
+func ConfigFromSettings(settings map[string]any) (*Config, error) {
+	config := DefaultConfig()
+	if err := assignSettings(reflect.ValueOf(config).Elem(), settings, ""); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// assignSettings 는 json tag 를 key 로 settings 의 값을 struct 필드에 넣는다.
+func assignSettings(v reflect.Value, settings map[string]any, path string) error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(key) == 0 {
+			continue
+		}
+
+		value, ok := lookupSetting(settings, key)
+		if !ok || value == nil {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			nested, ok := value.(map[string]any)
+			if !ok {
+				errs = append(errs, fmt.Errorf("invalid %s%s: expected a map", path, key))
+				continue
+			}
+			if err := assignSettings(v.Field(i), nested, path+key+"."); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if err := assignSetting(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s%s %v: %w", path, key, value, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lookupSetting 은 대소문자를 구분하지 않고 key 의 값을 찾는다. (viper 는 key 를 소문자로 바꾼다.)
+func lookupSetting(settings map[string]any, key string) (any, bool) {
+	if value, ok := settings[key]; ok {
+		return value, true
+	}
+	for k, value := range settings {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func assignSetting(field reflect.Value, value any) error {
+	if field.Type() == durationType {
+		switch d := value.(type) {
+		case time.Duration:
+			field.SetInt(int64(d))
+			return nil
+		case string:
+			duration, err := time.ParseDuration(d)
+			if err != nil {
+				return errors.New("not a duration (e.g. 30s, 5m)")
+			}
+			field.SetInt(int64(duration))
+			return nil
+		}
+		return errors.New("not a duration (e.g. 30s, 5m)")
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(fmt.Sprint(value))
+	case reflect.Int:
+		i, err := strconv.Atoi(fmt.Sprint(value))
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		field.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		field.SetFloat(f)
+	case reflect.Struct:
+		entries, err := settingEntries(value)
+		if err != nil {
+			return err
+		}
+		return assignSettings(field, entries, "")
+	case reflect.Bool:
+		b, err := strconv.ParseBool(fmt.Sprint(value))
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		items, err := settingItems(value)
+		if err != nil {
+			return err
+		}
+		// 요소도 필드 타입에 맞게 변환. (e.g. []string, []any)
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := assignSetting(slice.Index(i), item); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		field.Set(slice)
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		entries, err := settingEntries(value)
+		if err != nil {
+			return err
+		}
+		// 값도 필드 타입에 맞게 변환. (e.g. map[string]string, map[string][]string)
+		m := reflect.MakeMapWithSize(field.Type(), len(entries))
+		for k, v := range entries {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := assignSetting(elem, v); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(field.Type().Key()), elem)
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// settingItems 는 쉼표로 구분한 문자열이나 slice 값의 요소이다.
+func settingItems(value any) ([]any, error) {
+	if list, ok := value.(string); ok {
+		var strs []string
+		_ = listValue(&strs)(list)
+		items := make([]any, len(strs))
+		for i, str := range strs {
+			items[i] = str
+		}
+		return items, nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.New("not a list")
+	}
+	items := make([]any, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items, nil
+}
+
+// settingEntries 는 key=value 목록 문자열이나 string key map 값의 항목이다.
+func settingEntries(value any) (map[string]any, error) {
+	if str, ok := value.(string); ok {
+		var strs map[string]string
+		if err := mapValue(&strs)(str); err != nil {
+			return nil, err
+		}
+		entries := make(map[string]any, len(strs))
+		for k, v := range strs {
+			entries[k] = v
+		}
+		return entries, nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, errors.New("not a map")
+	}
+	entries := make(map[string]any, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		entries[iter.Key().String()] = iter.Value().Interface()
+	}
+	return entries, nil
+}
@@ -0,0 +1,497 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSignatureKeyIDHeader     = "x-signature-key-id"
+	defaultSignatureHeader          = "x-signature"
+	defaultSignatureTimestampHeader = "x-signature-timestamp"
+	defaultSignatureMaxSkew         = 5 * time.Minute
+	defaultSignatureMaxBodySize     = 4 << 20
+	// signatureBodyDigestHeader 는 gRPC Gateway 가 받은 HTTP body 의 SHA-256 을 전달하는 metadata 이름이다.
+	signatureBodyDigestHeader = "x-signature-body-sha256"
+)
+
+// ErrSignatureKeyNotFound 는 SignatureKeyLookup 이 key ID 의 key 를 찾지 못한 경우의 오류이다.
+var ErrSignatureKeyNotFound = errors.New("signature key not found")
+
+type signatureKeyIDContextKey struct{}
+
+type signatureBodyContextKey struct{}
+
+// SignatureKeyLookup 은 요청의 key ID 로 서명 key 를 찾는다. 찾지 못하면 ErrSignatureKeyNotFound 를 반환한다.
+type SignatureKeyLookup func(ctx context.Context, keyID string) ([]byte, error)
+
+// StaticSignatureKeys 는 key ID 별 key 로 찾는 SignatureKeyLookup 이다.
+func StaticSignatureKeys(keys map[string][]byte) SignatureKeyLookup {
+	return func(_ context.Context, keyID string) ([]byte, error) {
+		key, ok := keys[keyID]
+		if !ok {
+			return nil, ErrSignatureKeyNotFound
+		}
+		return key, nil
+	}
+}
+
+// SignatureOptions 는 요청 서명 검증 설정이다.
+type SignatureOptions struct {
+	// KeyLookup 은 서명 key 를 찾는다. (필수)
+	KeyLookup SignatureKeyLookup
+	// KeyIDHeader, Header, TimestampHeader 는 key ID, 서명, 서명 시각 (unix seconds) 을 담은 metadata 이름이다.
+	// (기본값: x-signature-key-id, x-signature, x-signature-timestamp)
+	// gRPC Gateway 로 받은 요청은 Grpc-Metadata-X-Signature 처럼 Grpc-Metadata- 를 붙인 header 로 전달한다.
+	KeyIDHeader     string
+	Header          string
+	TimestampHeader string
+	// MaxSkew 는 서명 시각과 Server 시각의 최대 차이이다. (기본값: 5m)
+	MaxSkew time.Duration
+	// Methods 는 서명을 확인할 Method 이다. "/package.Service/Method" 또는 Service 의 모든 Method 인 "/package.Service/*" 이다.
+	// 비어 있으면 health check, reflection 같은 기본 Service 를 제외한 모든 Method 의 서명을 확인한다.
+	Methods []string
+	// MaxBodySize 는 gRPC Gateway 가 받을 HTTP body 의 최대 크기 (bytes) 이다. 넘으면 413 으로 거부한다. (기본값: 4MiB)
+	MaxBodySize int64
+
+	// replay 는 WithReplayProtection 설정이다.
+	replay *ReplayOptions
+	// codec 은 요청 message 의 SHA-256 을 기록하는 codec 이다.
+	codec *signatureCodec
+	// gatewayKey 는 gRPC Gateway 가 전달한 HTTP body 의 SHA-256 을 확인하는 key 이다.
+	gatewayKey []byte
+}
+
+// WithRequestSignature 는 요청의 HMAC-SHA256 서명을 확인한다. 서명이 없거나 맞지 않는 요청은 Unauthenticated 로 거부한다.
+// 서명은 SignRequest 와 같이 Method, 서명 시각, 전송한 요청 body 로 만든다. 검증한 key ID 는 SignatureKeyID 로 확인한다.
+// gRPC 요청의 body 는 직렬화한 첫 요청 message (Stream 요청도 첫 message) 이고, gRPC Gateway 요청의 body 는 HTTP body 이다.
+// 받은 bytes 로 서명을 확인하기 위해 모든 요청을 하나의 codec 으로 처리하므로 WithCodec 과 함께 사용할 수 없다.
+// gRPC Gateway 는 서명을 확인할 Method 의 요청만 HTTP body 를 모두 읽어 SHA-256 을 전달한다. (RegisterHttpProxyServer 의 DialOption 으로 연결한 경우)
+func WithRequestSignature(signatureOptions SignatureOptions) Option {
+	return func(options *serverOptions) {
+		if len(signatureOptions.KeyIDHeader) == 0 {
+			signatureOptions.KeyIDHeader = defaultSignatureKeyIDHeader
+		}
+		if len(signatureOptions.Header) == 0 {
+			signatureOptions.Header = defaultSignatureHeader
+		}
+		if len(signatureOptions.TimestampHeader) == 0 {
+			signatureOptions.TimestampHeader = defaultSignatureTimestampHeader
+		}
+		if signatureOptions.MaxSkew <= 0 {
+			signatureOptions.MaxSkew = defaultSignatureMaxSkew
+		}
+		if signatureOptions.MaxBodySize <= 0 {
+			signatureOptions.MaxBodySize = defaultSignatureMaxBodySize
+		}
+		options.signature = &signatureOptions
+	}
+}
+
+// SignatureKeyID 는 WithRequestSignature 가 검증한 요청의 key ID 이다.
+func SignatureKeyID(ctx context.Context) string {
+	keyID, _ := ctx.Value(signatureKeyIDContextKey{}).(string)
+	return keyID
+}
+
+// SignRequest 는 client 가 요청에 담을 서명이다.
+// body 는 전송하는 요청 그대로의 bytes 로, gRPC 요청은 직렬화한 첫 요청 message, gRPC Gateway 요청은 HTTP body 이다.
+// 서명은 "fullMethod\ntimestamp\n" 과 body 의 SHA-256 (hex) 의 HMAC-SHA256 을 base64 (std) 로 encoding 한 값이다.
+func SignRequest(key []byte, fullMethod string, timestamp time.Time, body []byte) string {
+	return SignRequestWithNonce(key, fullMethod, timestamp, "", body)
+}
+
+// SignRequestWithNonce 는 WithReplayProtection 을 사용하는 Server 에 보낼 요청의 서명이다.
+// nonce 는 요청마다 다른 값이며, "fullMethod\ntimestamp\nnonce\n" 과 body 로 서명한다.
+func SignRequestWithNonce(key []byte, fullMethod string, timestamp time.Time, nonce string, body []byte) string {
+	digest := sha256.Sum256(body)
+	mac := requestMAC(key, fullMethod, strconv.FormatInt(timestamp.Unix(), 10), nonce, hex.EncodeToString(digest[:]))
+	return base64.StdEncoding.EncodeToString(mac)
+}
+
+// requestMAC 은 요청 body 의 SHA-256 (hex) 인 digest 로 서명을 만든다.
+func requestMAC(key []byte, fullMethod, timestamp, nonce, digest string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(fullMethod + "\n" + timestamp + "\n"))
+	if len(nonce) > 0 {
+		h.Write([]byte(nonce + "\n"))
+	}
+	h.Write([]byte(digest))
+	return h.Sum(nil)
+}
+
+// signed 는 fullMethod 가 서명을 확인할 Method 인지 여부이다.
+func (pSelf *SignatureOptions) signed(fullMethod string) bool {
+	if builtinMethod(fullMethod) {
+		return false
+	}
+	if len(pSelf.Methods) == 0 {
+		return true
+	}
+	for _, method := range pSelf.Methods {
+		if method == fullMethod || (strings.HasSuffix(method, "/*") && strings.HasPrefix(fullMethod, method[:len(method)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// verify 는 요청의 서명을 확인하고, key ID 를 context 에 기록한다.
+func (pSelf *SignatureOptions) verify(ctx context.Context, fullMethod, digest string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(name string) string {
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	keyID, signature, timestamp := first(pSelf.KeyIDHeader), first(pSelf.Header), first(pSelf.TimestampHeader)
+	if len(keyID) == 0 || len(signature) == 0 || len(timestamp) == 0 {
+		return nil, pSelf.reject("missing", "request signature is required")
+	}
+	var nonce string
+	if pSelf.replay != nil {
+		if nonce = first(pSelf.replay.NonceHeader); len(nonce) == 0 {
+			return nil, pSelf.reject("missing", "request nonce is required")
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, pSelf.reject("invalid", "invalid signature timestamp")
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); skew > pSelf.MaxSkew || skew < -pSelf.MaxSkew {
+		return nil, pSelf.reject("expired", "signature timestamp is out of range")
+	}
+
+	key, err := pSelf.KeyLookup(ctx, keyID)
+	if err != nil {
+		return nil, pSelf.reject("unknown_key", "unknown signature key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, pSelf.reject("invalid", "invalid signature encoding")
+	}
+	// 자체 gRPC Gateway 가 전달한 요청은 Gateway 가 받은 HTTP body 로 서명을 확인한다.
+	if gatewayDigest := pSelf.gatewayDigest(md); len(gatewayDigest) > 0 {
+		digest = gatewayDigest
+	}
+	if len(digest) == 0 {
+		return nil, status.Error(codes.Internal, "request body is not available to verify signature")
+	}
+	if !hmac.Equal(decoded, requestMAC(key, fullMethod, timestamp, nonce, digest)) {
+		return nil, pSelf.reject("mismatch", "invalid request signature")
+	}
+
+	// 서명 시각이 허용 범위를 벗어나면 서명 확인에서 거부하므로, 그때까지만 nonce 를 기록.
+	if pSelf.replay != nil {
+		added, err := pSelf.replay.Store.Add(ctx, keyID+"/"+nonce, time.Unix(seconds, 0).Add(pSelf.MaxSkew))
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "failed to record nonce: %v", err)
+		}
+		if !added {
+			return nil, pSelf.reject("replayed", "replayed request")
+		}
+	}
+	return context.WithValue(ctx, signatureKeyIDContextKey{}, keyID), nil
+}
+
+func (pSelf *SignatureOptions) reject(reason, message string) error {
+	addLabeledMetric("signature_rejected", reason, 1)
+	return status.Error(codes.Unauthenticated, message)
+}
+
+func (pSelf *SignatureOptions) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !pSelf.signed(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	var digest string
+	if holder, _ := ctx.Value(signatureDigestContextKey{}).(*signatureDigest); holder != nil {
+		digest = holder.value
+	}
+	ctx, err := pSelf.verify(ctx, info.FullMethod, digest)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (pSelf *SignatureOptions) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !pSelf.signed(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	// 첫 요청 message 를 decode 하지 않고 받아 서명을 확인한 뒤, handler 가 다시 받도록 한다.
+	// 요청 message 없이 끝난 Stream 은 빈 body 로 서명을 확인한다.
+	payload := &signaturePayload{}
+	recvErr := ss.RecvMsg(payload)
+	if recvErr != nil && !errors.Is(recvErr, io.EOF) {
+		return recvErr
+	}
+	ctx, err := pSelf.verify(ss.Context(), info.FullMethod, payloadDigest(mem.BufferSlice{mem.SliceBuffer(payload.data)}))
+	if err != nil {
+		return err
+	}
+	return handler(srv, &signatureServerStream{ServerStream: ss, ctx: ctx, codec: pSelf.codec.CodecV2, first: payload, firstErr: recvErr})
+}
+
+// serverOptions 는 Server 가 사용할 codec 을 감싸 요청 message 의 SHA-256 을 기록하는 gRPC ServerOption 이다.
+func (pSelf *SignatureOptions) serverOptions(options *serverOptions, proxy bool) ([]grpc.ServerOption, error) {
+	codec := encoding.GetCodecV2("proto")
+	switch {
+	case options.pooledCodec != nil:
+		codec = options.pooledCodec
+	case options.forcedCodec != nil:
+		codec = codecV1Adapter{Codec: options.forcedCodec}
+	case proxy:
+		codec = rawCodec{}
+	}
+	pSelf.codec = &signatureCodec{CodecV2: codec}
+	pSelf.gatewayKey = make([]byte, 32)
+	if _, err := rand.Read(pSelf.gatewayKey); err != nil {
+		return nil, err
+	}
+	return []grpc.ServerOption{
+		grpc.ForceServerCodecV2(pSelf.codec),
+		grpc.StatsHandler(&signatureStatsHandler{codec: pSelf.codec}),
+	}, nil
+}
+
+// bodyDigestHandler 는 gRPC Gateway 요청의 HTTP body 를 읽으면서 SHA-256 을 계산하도록 감싼다.
+// Gateway 가 다시 직렬화한 message 는 client 가 서명한 bytes 와 다르므로, 받은 HTTP body 로 서명을 확인한다.
+// client 가 보낸 값은 신뢰하지 않으며, 서명을 확인할 Method 를 호출할 때 gatewayContext 가 Server 의 key 로 만든 MAC 을 함께 전달한다.
+func (pSelf *SignatureOptions) bodyDigestHandler(handler http.Handler) http.Handler {
+	header := "Grpc-Metadata-" + signatureBodyDigestHeader
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(header)
+		r.Header.Del(signatureBodyDigestHeader)
+		if r.ContentLength > pSelf.MaxBodySize {
+			http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		body := &signatureBody{ReadCloser: http.NoBody, hash: sha256.New()}
+		if r.Body != nil && r.Body != http.NoBody {
+			body.ReadCloser = http.MaxBytesReader(w, r.Body, pSelf.MaxBodySize)
+		}
+		r.Body = body
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signatureBodyContextKey{}, body)))
+	})
+}
+
+// gatewayContext 는 gRPC Gateway 가 서명을 확인할 method 를 호출하면 HTTP body 의 SHA-256 을 metadata 에 추가한 context 이다.
+func (pSelf *SignatureOptions) gatewayContext(ctx context.Context, method string) (context.Context, error) {
+	body, _ := ctx.Value(signatureBodyContextKey{}).(*signatureBody)
+	if body == nil || !pSelf.signed(method) {
+		return ctx, nil
+	}
+	digest, err := body.digest()
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return nil, status.Error(codes.ResourceExhausted, "request body is too large")
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "failed to read request body: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, signatureBodyDigestHeader, digest+"."+pSelf.gatewayMAC(digest)), nil
+}
+
+func (pSelf *SignatureOptions) gatewayUnaryClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, err := pSelf.gatewayContext(ctx, method)
+	if err != nil {
+		return err
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (pSelf *SignatureOptions) gatewayStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, err := pSelf.gatewayContext(ctx, method)
+	if err != nil {
+		return nil, err
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+// signatureBody 는 읽은 HTTP body 의 SHA-256 을 계산한다.
+type signatureBody struct {
+	io.ReadCloser
+	hash hash.Hash
+
+	once sync.Once
+	// pending 은 digest 를 계산하기 위해 미리 읽은 나머지 body 이다. Gateway 는 이어서 읽는다.
+	pending *bytes.Reader
+	value   string
+	err     error
+}
+
+func (pSelf *signatureBody) Read(p []byte) (int, error) {
+	if pSelf.pending != nil {
+		return pSelf.pending.Read(p)
+	}
+	n, err := pSelf.ReadCloser.Read(p)
+	pSelf.hash.Write(p[:n])
+	return n, err
+}
+
+// digest 는 HTTP body 전체의 SHA-256 (hex) 이다. 아직 읽지 않은 body 는 미리 읽어 둔다.
+func (pSelf *signatureBody) digest() (string, error) {
+	pSelf.once.Do(func() {
+		rest, err := io.ReadAll(pSelf.ReadCloser)
+		pSelf.hash.Write(rest)
+		pSelf.pending = bytes.NewReader(rest)
+		pSelf.value, pSelf.err = hex.EncodeToString(pSelf.hash.Sum(nil)), err
+	})
+	return pSelf.value, pSelf.err
+}
+
+// gatewayDigest 는 bodyDigestHandler 가 전달한 HTTP body 의 SHA-256 이다. 없거나 MAC 이 맞지 않으면 빈 문자열이다.
+func (pSelf *SignatureOptions) gatewayDigest(md metadata.MD) string {
+	values := md.Get(signatureBodyDigestHeader)
+	if len(values) == 0 {
+		return ""
+	}
+	digest, mac, ok := strings.Cut(values[0], ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(pSelf.gatewayMAC(digest))) {
+		return ""
+	}
+	return digest
+}
+
+func (pSelf *SignatureOptions) gatewayMAC(digest string) string {
+	h := hmac.New(sha256.New, pSelf.gatewayKey)
+	h.Write([]byte(digest))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// payloadDigest 는 data 의 SHA-256 (hex) 이다.
+func payloadDigest(data mem.BufferSlice) string {
+	h := sha256.New()
+	for _, buffer := range data {
+		h.Write(buffer.ReadOnlyData())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signaturePayload 는 decode 하지 않고 받은 요청 message 이다.
+type signaturePayload struct {
+	data []byte
+}
+
+// signatureCodec 은 요청 message 를 decode 하면서 받은 bytes 의 SHA-256 을 기록하는 codec 이다.
+// 기록한 값은 signatureStatsHandler 가 같은 message 의 InPayload 를 받으면 RPC 의 context 로 옮긴다.
+type signatureCodec struct {
+	encoding.CodecV2
+	// digests 는 decode 한 message (pointer) 별 SHA-256 이다.
+	digests sync.Map
+}
+
+func (pSelf *signatureCodec) Unmarshal(data mem.BufferSlice, v any) error {
+	if payload, ok := v.(*signaturePayload); ok {
+		// data 는 Unmarshal 이 끝나면 반환되므로 복사한다.
+		payload.data = data.Materialize()
+		return nil
+	}
+	if err := pSelf.CodecV2.Unmarshal(data, v); err != nil {
+		return err
+	}
+	pSelf.digests.Store(v, payloadDigest(data))
+	return nil
+}
+
+type signatureDigestContextKey struct{}
+
+// signatureDigest 는 RPC 의 첫 요청 message 의 SHA-256 이다.
+type signatureDigest struct {
+	value string
+}
+
+// signatureStatsHandler 는 signatureCodec 이 기록한 SHA-256 을 RPC 의 context 로 옮긴다.
+// gRPC Server 는 요청 message 를 decode 한 뒤 같은 goroutine 에서 InPayload 를 전달한다.
+type signatureStatsHandler struct {
+	codec *signatureCodec
+}
+
+func (pSelf *signatureStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, signatureDigestContextKey{}, &signatureDigest{})
+}
+
+func (pSelf *signatureStatsHandler) HandleRPC(ctx context.Context, rpcStats stats.RPCStats) {
+	in, ok := rpcStats.(*stats.InPayload)
+	if !ok {
+		return
+	}
+	digest, ok := pSelf.codec.digests.LoadAndDelete(in.Payload)
+	if !ok {
+		return
+	}
+	if holder, _ := ctx.Value(signatureDigestContextKey{}).(*signatureDigest); holder != nil && len(holder.value) == 0 {
+		holder.value = digest.(string)
+	}
+}
+
+func (pSelf *signatureStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (pSelf *signatureStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// signatureServerStream 은 서명 확인에 사용한 첫 요청 message 를 다시 전달하는 grpc.ServerStream 이다.
+type signatureServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	codec    encoding.CodecV2
+	first    *signaturePayload
+	firstErr error
+}
+
+func (pSelf *signatureServerStream) Context() context.Context {
+	return pSelf.ctx
+}
+
+func (pSelf *signatureServerStream) RecvMsg(m any) error {
+	if first := pSelf.first; first != nil {
+		pSelf.first = nil
+		if pSelf.firstErr != nil {
+			return pSelf.firstErr
+		}
+		return pSelf.codec.Unmarshal(mem.BufferSlice{mem.SliceBuffer(first.data)}, m)
+	}
+	return pSelf.ServerStream.RecvMsg(m)
+}
+
+// codecV1Adapter 는 encoding.Codec 을 encoding.CodecV2 로 사용한다.
+type codecV1Adapter struct {
+	encoding.Codec
+}
+
+func (pSelf codecV1Adapter) Marshal(v any) (mem.BufferSlice, error) {
+	data, err := pSelf.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return mem.BufferSlice{mem.SliceBuffer(data)}, nil
+}
+
+func (pSelf codecV1Adapter) Unmarshal(data mem.BufferSlice, v any) error {
+	return pSelf.Codec.Unmarshal(data.Materialize(), v)
+}
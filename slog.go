@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"log/slog"
+)
+
+// requestIDMetadataKey 는 client 가 전달하거나 Server 가 응답 header 로 돌려주는 request ID 의 metadata key 이다.
+const requestIDMetadataKey = "x-request-id"
+
+type logAttrsContextKey struct{}
+
+// WithRequestLogContext 는 요청마다 request ID, method, peer 를 context 에 기록하여,
+// NewContextHandler 로 만든 slog.Handler 가 요청 처리 중의 모든 log 에 함께 기록하도록 한다.
+// client 가 x-request-id metadata 를 보내면 그 값을 사용하고, 없으면 새로 만들어 응답 header 로 돌려준다.
+func WithRequestLogContext() Option {
+	return func(options *serverOptions) {
+		options.requestLogContext = true
+	}
+}
+
+// NewContextHandler 는 context 에 기록된 요청 정보를 log 에 추가하는 slog.Handler 를 만든다.
+//
+//	slog.SetDefault(slog.New(server.NewContextHandler(slog.NewJSONHandler(os.Stdout, nil))))
+//	slog.InfoContext(ctx, "processing") // request_id, grpc.method, peer.address 가 함께 기록됨
+func NewContextHandler(handler slog.Handler) slog.Handler {
+	return &contextHandler{Handler: handler}
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (pSelf *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsContextKey{}).([]slog.Attr); ok {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return pSelf.Handler.Handle(ctx, record)
+}
+
+func (pSelf *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: pSelf.Handler.WithAttrs(attrs)}
+}
+
+func (pSelf *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: pSelf.Handler.WithGroup(name)}
+}
+
+// RequestID 는 WithRequestLogContext 가 context 에 기록한 request ID 를 반환한다.
+func RequestID(ctx context.Context) string {
+	attrs, _ := ctx.Value(logAttrsContextKey{}).([]slog.Attr)
+	for _, attr := range attrs {
+		if attr.Key == "request_id" {
+			return attr.Value.String()
+		}
+	}
+	return ""
+}
+
+// ContextWithLogAttrs 는 NewContextHandler 가 log 에 추가할 속성을 context 에 더한다.
+func ContextWithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(logAttrsContextKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, logAttrsContextKey{}, merged)
+}
+
+// requestLogContext 는 요청 정보를 context 에 기록한다.
+func requestLogContext(ctx context.Context, fullMethod string) context.Context {
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if len(requestID) == 0 {
+		requestID = newRequestID()
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, requestID))
+	}
+
+	attrs := []slog.Attr{
+		slog.String("request_id", requestID),
+		slog.String("grpc.method", fullMethod),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer.address", p.Addr.String()))
+	}
+	return ContextWithLogAttrs(ctx, attrs...)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func requestLogContextUnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(requestLogContext(ctx, info.FullMethod), req)
+}
+
+func requestLogContextStreamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: requestLogContext(ss.Context(), info.FullMethod)})
+}
+
+// contextServerStream 은 Context 를 바꾼 grpc.ServerStream 이다.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (pSelf *contextServerStream) Context() context.Context {
+	return pSelf.ctx
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+// SocketOptions 는 Listener 를 bind 할 때 적용하는 socket 설정이다.
+// 0 (nil) 인 항목은 OS 기본값을 그대로 사용한다.
+type SocketOptions struct {
+	// NoDelay 는 수락한 TCP 연결의 TCP_NODELAY 설정이다. (Go 기본값: true)
+	NoDelay *bool
+	// ReceiveBufferSize 는 SO_RCVBUF 이다.
+	ReceiveBufferSize int
+	// SendBufferSize 는 SO_SNDBUF 이다.
+	SendBufferSize int
+	// TOS 는 IPv4 의 IP_TOS, IPv6 의 IPV6_TCLASS 이다.
+	TOS int
+	// Priority 는 SO_PRIORITY 이다. (Linux 만 지원)
+	Priority int
+}
+
+// WithSocketOptions 는 Listener 에 SocketOptions 를 적용한다.
+func WithSocketOptions(socketOptions SocketOptions) Option {
+	return func(options *serverOptions) {
+		options.socketOptions = &socketOptions
+	}
+}
+
+// WithListenControl 은 net.ListenConfig.Control 과 같이 Listener 를 bind 하기 전에 호출할 함수를 추가한다.
+// 여러 번 지정하면 지정한 순서대로 호출된다.
+func WithListenControl(control func(network, address string, c syscall.RawConn) error) Option {
+	return func(options *serverOptions) {
+		if control != nil {
+			options.listenControls = append(options.listenControls, control)
+		}
+	}
+}
+
+func (pSelf SocketOptions) control(network, _ string, c syscall.RawConn) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = setSocketOptions(network, fd, pSelf)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// noDelayListener 는 수락한 TCP 연결에 TCP_NODELAY 를 설정한다.
+type noDelayListener struct {
+	net.Listener
+	noDelay bool
+}
+
+func (pSelf *noDelayListener) Accept() (net.Conn, error) {
+	conn, err := pSelf.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetNoDelay(pSelf.noDelay)
+	}
+	return conn, nil
+}
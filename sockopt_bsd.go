@@ -0,0 +1,9 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package server
+
+import "errors"
+
+func setSocketPriority(_, _ int) error {
+	return errors.New("SO_PRIORITY is not supported on this platform")
+}
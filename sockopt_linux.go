@@ -0,0 +1,7 @@
+package server
+
+import "golang.org/x/sys/unix"
+
+func setSocketPriority(sock, priority int) error {
+	return unix.SetsockoptInt(sock, unix.SOL_SOCKET, unix.SO_PRIORITY, priority)
+}
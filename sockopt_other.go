@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package server
+
+import "errors"
+
+func setSocketOptions(_ string, _ uintptr, socketOptions SocketOptions) error {
+	if socketOptions.ReceiveBufferSize > 0 || socketOptions.SendBufferSize > 0 || socketOptions.TOS > 0 || socketOptions.Priority > 0 {
+		return errors.New("socket options are not supported on this platform")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package server
+
+import (
+	"golang.org/x/sys/unix"
+	"strings"
+)
+
+func setSocketOptions(network string, fd uintptr, socketOptions SocketOptions) error {
+	sock := int(fd)
+
+	if socketOptions.ReceiveBufferSize > 0 {
+		if err := unix.SetsockoptInt(sock, unix.SOL_SOCKET, unix.SO_RCVBUF, socketOptions.ReceiveBufferSize); err != nil {
+			return err
+		}
+	}
+
+	if socketOptions.SendBufferSize > 0 {
+		if err := unix.SetsockoptInt(sock, unix.SOL_SOCKET, unix.SO_SNDBUF, socketOptions.SendBufferSize); err != nil {
+			return err
+		}
+	}
+
+	if socketOptions.TOS > 0 {
+		if strings.HasSuffix(network, "6") {
+			if err := unix.SetsockoptInt(sock, unix.IPPROTO_IPV6, unix.IPV6_TCLASS, socketOptions.TOS); err != nil {
+				return err
+			}
+			// Dual-stack socket 의 IPv4 연결에도 적용. (지원하지 않는 OS 는 무시)
+			_ = unix.SetsockoptInt(sock, unix.IPPROTO_IP, unix.IP_TOS, socketOptions.TOS)
+		} else if err := unix.SetsockoptInt(sock, unix.IPPROTO_IP, unix.IP_TOS, socketOptions.TOS); err != nil {
+			return err
+		}
+	}
+
+	if socketOptions.Priority > 0 {
+		if err := setSocketPriority(sock, socketOptions.Priority); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"google.golang.org/grpc"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSyslogFacility    = 10 // authpriv
+	defaultSyslogQueueSize   = 1024
+	defaultSyslogDialTimeout = 5 * time.Second
+	defaultSyslogVendor      = "berryons"
+	defaultSyslogProduct     = "server"
+
+	// syslogStructuredDataID 는 RFC 5424 structured data 의 ID 이다. (32473 은 문서용 Private Enterprise Number)
+	syslogStructuredDataID = "security@32473"
+)
+
+// SyslogFormat 은 syslog message 의 형식이다.
+type SyslogFormat int
+
+const (
+	// SyslogRFC5424 는 event 속성을 structured data 로 보내는 RFC 5424 형식이다.
+	SyslogRFC5424 SyslogFormat = iota
+	// SyslogCEF 는 ArcSight Common Event Format 이다. (e.g. ArcSight, Splunk, Sentinel)
+	SyslogCEF
+	// SyslogLEEF 는 IBM QRadar Log Event Extended Format 1.0 이다.
+	SyslogLEEF
+)
+
+// SyslogOptions 는 SyslogExporter 설정이다.
+type SyslogOptions struct {
+	// Network 는 udp, tcp, tls, unix, unixgram 이다. tcp 와 tls 는 octet counting (RFC 6587) 으로 message 를 구분한다.
+	Network string
+	// Address 는 syslog 수신 주소이다. (e.g. siem.example.com:514, /dev/log)
+	Address string
+	// TLS 는 Network 가 tls 일 때 사용할 설정이다.
+	TLS    *tls.Config
+	Format SyslogFormat
+	// Facility 는 syslog facility 이다. (기본값: 10, authpriv)
+	Facility int
+	// AppName, Hostname 은 syslog header 의 값이다. (기본값: 실행 파일 이름, os.Hostname)
+	AppName  string
+	Hostname string
+	// Vendor, Product, Version 은 CEF, LEEF header 의 장비 정보이다. (기본값: berryons, server, 빈 값)
+	Vendor  string
+	Product string
+	Version string
+	// QueueSize 는 전송 대기열의 크기이다. 가득 차면 event 를 버린다. (기본값: 1024)
+	QueueSize int
+	// OnError 는 연결하거나 보내지 못한 오류를 받는다. 실패한 event 는 버린다.
+	OnError func(err error)
+}
+
+// SyslogExporter 는 보안 event 를 syslog 로 보내는 SecurityEventExporter 이다.
+// Worker 이므로 WithWorker 로 Server 와 함께 시작하고, 종료할 때 대기열의 event 를 모두 보낸다.
+// 연결이 끊기면 다음 event 를 보낼 때 다시 연결한다.
+//
+//	exporter := server.NewSyslogExporter(server.SyslogOptions{Network: "tls", Address: "siem.example.com:6514", Format: server.SyslogCEF})
+//	grpcServer := server.New("tcp", "", 50051, nil, nil,
+//		server.WithSecurityEvents(exporter),
+//		server.WithWorker("syslog", exporter),
+//	)
+//
+// 지표: syslog_events (sent, dropped, failed)
+type SyslogExporter struct {
+	options SyslogOptions
+	cQueue  chan SecurityEvent
+
+	mutex  sync.RWMutex
+	closed bool
+	cDone  chan struct{}
+	conn   net.Conn
+}
+
+// NewSyslogExporter 는 SyslogExporter 를 생성한다.
+func NewSyslogExporter(options SyslogOptions) *SyslogExporter {
+	if options.Facility <= 0 {
+		options.Facility = defaultSyslogFacility
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultSyslogQueueSize
+	}
+	if len(options.AppName) == 0 {
+		options.AppName = filepath.Base(os.Args[0])
+	}
+	if len(options.Hostname) == 0 {
+		options.Hostname, _ = os.Hostname()
+	}
+	if len(options.Vendor) == 0 {
+		options.Vendor = defaultSyslogVendor
+	}
+	if len(options.Product) == 0 {
+		options.Product = defaultSyslogProduct
+	}
+	return &SyslogExporter{options: options, cQueue: make(chan SecurityEvent, options.QueueSize), cDone: make(chan struct{})}
+}
+
+// Export 는 event 를 전송 대기열에 추가한다. 대기열이 가득 찼거나 종료했으면 버린다.
+func (pSelf *SyslogExporter) Export(event SecurityEvent) {
+	pSelf.mutex.RLock()
+	defer pSelf.mutex.RUnlock()
+	if pSelf.closed {
+		addLabeledMetric("syslog_events", "dropped", 1)
+		return
+	}
+	select {
+	case pSelf.cQueue <- event:
+	default:
+		addLabeledMetric("syslog_events", "dropped", 1)
+	}
+}
+
+// Start 는 전송 Goroutine 을 시작한다.
+func (pSelf *SyslogExporter) Start(_ context.Context, _ *grpc.ClientConn) error {
+	go pSelf.work()
+	return nil
+}
+
+// Stop 은 새 event 를 받지 않고, 대기열의 event 를 모두 보내거나 ctx 가 끝날 때까지 기다린다.
+func (pSelf *SyslogExporter) Stop(ctx context.Context) error {
+	pSelf.mutex.Lock()
+	if pSelf.closed {
+		pSelf.mutex.Unlock()
+		return nil
+	}
+	pSelf.closed = true
+	close(pSelf.cQueue)
+	pSelf.mutex.Unlock()
+
+	select {
+	case <-pSelf.cDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (pSelf *SyslogExporter) work() {
+	defer close(pSelf.cDone)
+	defer func() {
+		if pSelf.conn != nil {
+			_ = pSelf.conn.Close()
+		}
+	}()
+	for event := range pSelf.cQueue {
+		if err := pSelf.send(pSelf.Format(event)); err != nil {
+			addLabeledMetric("syslog_events", "failed", 1)
+			if pSelf.options.OnError != nil {
+				pSelf.options.OnError(err)
+			}
+			continue
+		}
+		addLabeledMetric("syslog_events", "sent", 1)
+	}
+}
+
+// send 는 message 를 보낸다. 연결이 끊겼으면 한 번 다시 연결하여 보낸다.
+func (pSelf *SyslogExporter) send(message string) error {
+	stream := pSelf.options.Network == "tcp" || pSelf.options.Network == "tls"
+	if stream {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+
+	var err error
+	for range 2 {
+		if pSelf.conn == nil {
+			if pSelf.conn, err = pSelf.dial(); err != nil {
+				return err
+			}
+		}
+		if _, err = pSelf.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		_ = pSelf.conn.Close()
+		pSelf.conn = nil
+	}
+	return err
+}
+
+func (pSelf *SyslogExporter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: defaultSyslogDialTimeout}
+	switch pSelf.options.Network {
+	case "tls":
+		return tls.DialWithDialer(dialer, "tcp", pSelf.options.Address, pSelf.options.TLS)
+	case "udp", "tcp", "unix", "unixgram":
+		return dialer.Dial(pSelf.options.Network, pSelf.options.Address)
+	}
+	return nil, fmt.Errorf("unsupported syslog network: %s", pSelf.options.Network)
+}
+
+// Format 은 event 를 Format 형식의 syslog message 로 만든다. (framing 제외)
+func (pSelf *SyslogExporter) Format(event SecurityEvent) string {
+	priority := pSelf.options.Facility*8 + syslogSeverity(event.Severity)
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s ", priority, event.Time.UTC().Format(time.RFC3339Nano),
+		syslogHeaderValue(pSelf.options.Hostname), syslogHeaderValue(pSelf.options.AppName), os.Getpid(), syslogHeaderValue(string(event.Type)))
+
+	switch pSelf.options.Format {
+	case SyslogCEF:
+		return header + "- " + pSelf.cef(event)
+	case SyslogLEEF:
+		return header + "- " + pSelf.leef(event)
+	}
+	return header + structuredData(event) + " " + event.Message
+}
+
+// cef 는 CEF:Version|Vendor|Product|Version|SignatureID|Name|Severity|Extension 이다.
+func (pSelf *SyslogExporter) cef(event SecurityEvent) string {
+	extensions := [][2]string{
+		{"rt", strconv.FormatInt(event.Time.UnixMilli(), 10)},
+		{"src", event.Source},
+		{"suser", event.Principal},
+		{"request", event.Method},
+		{"msg", event.Message},
+	}
+	extensions = appendExtensions(extensions, event.Extensions)
+
+	var builder strings.Builder
+	builder.WriteString("CEF:0")
+	for _, value := range []string{pSelf.options.Vendor, pSelf.options.Product, pSelf.options.Version, string(event.Type), string(event.Type), strconv.Itoa(event.Severity)} {
+		builder.WriteString("|" + strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ", "\r", " ").Replace(value))
+	}
+	builder.WriteString("|")
+	escape := strings.NewReplacer(`\`, `\\`, "=", `\=`, "\n", `\n`, "\r", `\r`)
+	for i, extension := range extensions {
+		if i > 0 {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(extension[0] + "=" + escape.Replace(extension[1]))
+	}
+	return builder.String()
+}
+
+// leef 는 LEEF:1.0|Vendor|Product|Version|EventID|Extension 이며, extension 은 tab 으로 구분한다.
+func (pSelf *SyslogExporter) leef(event SecurityEvent) string {
+	extensions := [][2]string{
+		{"devTime", event.Time.UTC().Format("Jan 02 2006 15:04:05.000 MST")},
+		{"devTimeFormat", "MMM dd yyyy HH:mm:ss.SSS z"},
+		{"sev", strconv.Itoa(event.Severity)},
+		{"src", event.Source},
+		{"usrName", event.Principal},
+		{"resource", event.Method},
+		{"msg", event.Message},
+	}
+	extensions = appendExtensions(extensions, event.Extensions)
+
+	var builder strings.Builder
+	builder.WriteString("LEEF:1.0")
+	for _, value := range []string{pSelf.options.Vendor, pSelf.options.Product, pSelf.options.Version, string(event.Type)} {
+		builder.WriteString("|" + strings.NewReplacer("|", " ", "\n", " ", "\r", " ").Replace(value))
+	}
+	builder.WriteString("|")
+	escape := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	for i, extension := range extensions {
+		if i > 0 {
+			builder.WriteString("\t")
+		}
+		builder.WriteString(extension[0] + "=" + escape.Replace(extension[1]))
+	}
+	return builder.String()
+}
+
+// structuredData 는 event 속성을 담은 RFC 5424 structured data 이다.
+func structuredData(event SecurityEvent) string {
+	params := [][2]string{
+		{"severity", strconv.Itoa(event.Severity)},
+		{"method", event.Method},
+		{"src", event.Source},
+		{"principal", event.Principal},
+	}
+	params = appendExtensions(params, event.Extensions)
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`)
+	var builder strings.Builder
+	builder.WriteString("[" + syslogStructuredDataID)
+	for _, param := range params {
+		builder.WriteString(" " + syslogParamName(param[0]) + `="` + escape.Replace(param[1]) + `"`)
+	}
+	builder.WriteString("]")
+	return builder.String()
+}
+
+// appendExtensions 는 값이 있는 속성만 남기고 extensions 를 이름 순서로 추가한다.
+func appendExtensions(params [][2]string, extensions map[string]string) [][2]string {
+	params = slices.DeleteFunc(params, func(param [2]string) bool { return len(param[1]) == 0 })
+	keys := make([]string, 0, len(extensions))
+	for key := range extensions {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		params = append(params, [2]string{key, extensions[key]})
+	}
+	return params
+}
+
+// syslogSeverity 는 0 ~ 10 의 심각도를 syslog severity 로 바꾼다.
+func syslogSeverity(severity int) int {
+	switch {
+	case severity >= 9:
+		return 2 // critical
+	case severity >= 7:
+		return 3 // error
+	case severity >= 5:
+		return 4 // warning
+	case severity >= 3:
+		return 5 // notice
+	}
+	return 6 // informational
+}
+
+// syslogHeaderValue 는 RFC 5424 header 에 쓸 수 있는 값이다. 비어 있으면 "-" 이다.
+func syslogHeaderValue(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
+
+// syslogParamName 은 RFC 5424 SD-NAME 에 쓸 수 없는 문자 (=, 공백, ], ") 를 지운 이름이다.
+func syslogParamName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// systemd 가 전달하는 첫 번째 File Descriptor. (SD_LISTEN_FDS_START)
+	systemdListenFdsStart = 3
+
+	systemdGrpcListenerName      = "grpc"
+	systemdHttpProxyListenerName = "http"
+)
+
+// WithSystemdSocketActivation 은 systemd 가 LISTEN_FDS 로 전달한 Listener 를 사용하도록 설정한다.
+// "grpc" 로 이름 붙은 (FileDescriptorName=grpc) Listener 가 없으면 첫 번째 Listener 를 gRPC Server 에,
+// "http" 로 이름 붙은 Listener 가 없으면 두 번째 Listener 를 Http Proxy Server 에 사용한다.
+// systemd 가 Listener 를 전달하지 않은 경우에는 직접 listen 한다.
+func WithSystemdSocketActivation() Option {
+	return func(options *serverOptions) {
+		options.systemdSocketActivation = true
+	}
+}
+
+type systemdListener struct {
+	name     string
+	listener net.Listener
+}
+
+// systemdListeners 는 sd_listen_fds(3) 와 같은 방식으로 systemd 가 전달한 Listener 를 가져온다.
+// 자식 프로세스가 같은 Listener 를 다시 가져가지 않도록 관련 환경 변수는 제거한다.
+func systemdListeners() ([]systemdListener, error) {
+	pidStr := os.Getenv("LISTEN_PID")
+	fdsStr := os.Getenv("LISTEN_FDS")
+	namesStr := os.Getenv("LISTEN_FDNAMES")
+
+	if len(pidStr) == 0 || len(fdsStr) == 0 {
+		return nil, nil
+	}
+
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_PID %q: %w", pidStr, err)
+	}
+	if pid != os.Getpid() {
+		// 다른 프로세스에 전달 된 Listener.
+		return nil, nil
+	}
+
+	fds, err := strconv.Atoi(fdsStr)
+	if err != nil || fds < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fdsStr)
+	}
+
+	var names []string
+	if len(namesStr) > 0 {
+		names = strings.Split(namesStr, ":")
+	}
+
+	listeners := make([]systemdListener, 0, fds)
+	for i := 0; i < fds; i++ {
+		name := fmt.Sprintf("LISTEN_FD_%d", systemdListenFdsStart+i)
+		if i < len(names) && len(names[i]) > 0 {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(systemdListenFdsStart+i), name)
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.listener.Close()
+			}
+			return nil, fmt.Errorf("failed to use systemd listener %s: %w", name, err)
+		}
+
+		listeners = append(listeners, systemdListener{name: name, listener: listener})
+	}
+
+	return listeners, nil
+}
+
+// pickSystemdListeners 는 systemd 가 전달한 Listener 중 gRPC Server 와 Http Proxy Server 에 사용할 Listener 를 고른다.
+func pickSystemdListeners(listeners []systemdListener) (grpcListener, httpProxyListener net.Listener) {
+	var unnamed []net.Listener
+	for _, l := range listeners {
+		switch l.name {
+		case systemdGrpcListenerName:
+			grpcListener = l.listener
+		case systemdHttpProxyListenerName:
+			httpProxyListener = l.listener
+		default:
+			unnamed = append(unnamed, l.listener)
+		}
+	}
+
+	if grpcListener == nil && len(unnamed) > 0 {
+		grpcListener, unnamed = unnamed[0], unnamed[1:]
+	}
+	if httpProxyListener == nil && len(unnamed) > 0 {
+		httpProxyListener, unnamed = unnamed[0], unnamed[1:]
+	}
+
+	// 사용하지 않는 Listener 는 닫는다.
+	for _, l := range unnamed {
+		_ = l.Close()
+	}
+
+	return grpcListener, httpProxyListener
+}
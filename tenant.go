@@ -0,0 +1,300 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTenantHeader      = "x-tenant-id"
+	defaultTenantQuotaPeriod = 24 * time.Hour
+	defaultMaxTenants        = 10000
+
+	// otherTenantLabel 는 Tenants 에 없는 tenant 의 지표 label 이다.
+	otherTenantLabel = "other"
+)
+
+type tenantContextKey struct{}
+
+// TenantOptions 는 요청의 tenant 를 구분하고 tenant 별 제한을 적용하는 설정이다.
+type TenantOptions struct {
+	// Header 는 tenant ID 를 담은 metadata 이름이다. (기본값: x-tenant-id)
+	// gRPC Gateway 로 받은 요청은 Grpc-Metadata-X-Tenant-Id header 로 전달한다.
+	Header string
+	// Extract 가 있으면 Header 대신 사용한다. (e.g. 인증 token 의 claim)
+	Extract func(ctx context.Context) (string, bool)
+	// Default 는 tenant ID 가 없는 요청의 tenant 이다. 비어 있으면 tenant ID 가 없는 요청은 InvalidArgument 로 거부한다.
+	Default string
+	// Tenants 는 tenant 별 정책이다.
+	Tenants map[string]TenantPolicy
+	// DefaultPolicy 는 Tenants 에 없는 tenant 의 정책이다.
+	// Validate 로 확인한 tenant 는 tenant 별로 적용하고, 확인하지 않은 tenant 는 모두 하나의 제한을 함께 사용한다.
+	// client 가 보낸 tenant ID 를 바꿔 가며 제한을 피할 수 없도록 하기 위함이다.
+	DefaultPolicy TenantPolicy
+	// Validate 가 있으면 Tenants 에 없는 tenant ID 가 있는 tenant 인지 확인한다. (e.g. DB 의 tenant 목록)
+	// tenant 의 첫 요청과 상태를 지운 뒤의 첫 요청에서만 호출한다.
+	Validate func(ctx context.Context, tenantID string) bool
+	// RejectUnknown 이면 Tenants 에 없고 Validate 로 확인하지 않은 tenant 의 요청은 PermissionDenied 로 거부한다.
+	RejectUnknown bool
+	// MaxTenants 는 상태를 유지하는 Validate 로 확인한 tenant 의 최대 수이다.
+	// 넘으면 가장 오래 요청이 없던 tenant 의 상태를 지우며, 그 tenant 는 다음 요청에서 rate limit, quota 를 다시 시작한다. (기본값: 10000)
+	MaxTenants int
+}
+
+// TenantPolicy 는 tenant 하나의 요청 제한과 Interceptor 이다.
+type TenantPolicy struct {
+	// RateLimit 는 초당 요청 수이다. 넘는 요청은 ResourceExhausted 로 거부한다. 0 이면 제한하지 않는다.
+	RateLimit float64
+	// Burst 는 한 번에 허용하는 요청 수이다. (기본값: RateLimit 을 올림한 값)
+	Burst int
+	// Quota 는 QuotaPeriod 동안 허용하는 요청 수이다. 넘는 요청은 ResourceExhausted 로 거부한다. 0 이면 제한하지 않는다.
+	Quota int
+	// QuotaPeriod 는 Quota 를 다시 채우는 주기이다. (기본값: 1일)
+	QuotaPeriod time.Duration
+	// LogTags 는 tenant 의 요청 log 에 추가할 속성이다. (NewContextHandler 참고)
+	LogTags map[string]string
+	// Unary, Stream 은 tenant 의 요청에만 실행할 Interceptor 이다.
+	Unary  []grpc.UnaryServerInterceptor
+	Stream []grpc.StreamServerInterceptor
+}
+
+// WithTenancy 는 요청의 metadata 에서 tenant ID 를 찾아 context 에 기록하고, tenant 별 정책을 적용한다.
+// health check, reflection 같은 기본 Service 에는 적용하지 않는다.
+// Handler 에서는 TenantID 로 tenant 를 확인하며, log 에는 tenant 속성이 추가된다.
+// 거부한 요청은 tenant_requests_rejected 지표로 확인하며, Tenants 에 없는 tenant 는 하나의 label (other) 로 합친다.
+func WithTenancy(tenantOptions TenantOptions) Option {
+	return func(options *serverOptions) {
+		if len(tenantOptions.Header) == 0 {
+			tenantOptions.Header = defaultTenantHeader
+		}
+		if tenantOptions.MaxTenants <= 0 {
+			tenantOptions.MaxTenants = defaultMaxTenants
+		}
+		options.tenancy = newTenancy(tenantOptions)
+	}
+}
+
+// TenantID 는 WithTenancy 가 context 에 기록한 tenant ID 이다.
+func TenantID(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenantID
+}
+
+// tenancy 는 tenant 별 정책과 제한 상태이다.
+type tenancy struct {
+	options TenantOptions
+
+	mutex sync.Mutex
+	// states 는 Tenants 에 있는 tenant 의 상태이다.
+	states map[string]*tenantState
+	// validated 는 Validate 로 확인한 tenant 의 상태이며, recent 는 최근에 요청한 순서이다.
+	validated map[string]*list.Element
+	recent    *list.List
+	// unknown 은 Validate 로 확인하지 않은 tenant 가 함께 사용하는 상태이다.
+	unknown *tenantState
+}
+
+// tenantState 는 tenant 하나의 token bucket 과 quota 사용량이다.
+type tenantState struct {
+	tenantID string
+	policy   *TenantPolicy
+	// label 은 지표의 tenant label 이다.
+	label string
+
+	mutex       sync.Mutex
+	tokens      float64
+	lastRefill  time.Time
+	used        int
+	periodStart time.Time
+}
+
+func newTenancy(tenantOptions TenantOptions) *tenancy {
+	return &tenancy{
+		options:   tenantOptions,
+		states:    map[string]*tenantState{},
+		validated: map[string]*list.Element{},
+		recent:    list.New(),
+	}
+}
+
+// tenantID 는 요청의 tenant ID 이다.
+func (pSelf *tenancy) tenantID(ctx context.Context) (string, bool) {
+	if pSelf.options.Extract != nil {
+		if tenantID, ok := pSelf.options.Extract(ctx); ok && len(tenantID) > 0 {
+			return tenantID, true
+		}
+	} else if values := metadata.ValueFromIncomingContext(ctx, pSelf.options.Header); len(values) > 0 && len(values[0]) > 0 {
+		return values[0], true
+	}
+	return pSelf.options.Default, len(pSelf.options.Default) > 0
+}
+
+// admit 은 요청의 tenant 를 찾아 제한을 확인하고, tenant 를 기록한 context 와 정책을 반환한다.
+func (pSelf *tenancy) admit(ctx context.Context) (context.Context, *TenantPolicy, error) {
+	tenantID, ok := pSelf.tenantID(ctx)
+	if !ok {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "%s metadata is required", pSelf.options.Header)
+	}
+
+	state := pSelf.state(ctx, tenantID)
+	if state == nil {
+		addLabeledMetric("tenant_requests_rejected", "unknown", 1)
+		return nil, nil, status.Errorf(codes.PermissionDenied, "unknown tenant: %s", tenantID)
+	}
+	if err := state.take(time.Now()); err != nil {
+		addLabeledMetric("tenant_requests_rejected", state.label, 1)
+		return nil, nil, err
+	}
+
+	ctx = context.WithValue(ctx, tenantContextKey{}, tenantID)
+	attrs := []slog.Attr{slog.String("tenant", tenantID)}
+	for key, value := range state.policy.LogTags {
+		attrs = append(attrs, slog.String(key, value))
+	}
+	return ContextWithLogAttrs(ctx, attrs...), state.policy, nil
+}
+
+// state 는 tenant 의 제한 상태이다. RejectUnknown 이고 Tenants 에 없으며 Validate 로 확인하지 않은 tenant 이면 nil 이다.
+func (pSelf *tenancy) state(ctx context.Context, tenantID string) *tenantState {
+	pSelf.mutex.Lock()
+	if state, ok := pSelf.states[tenantID]; ok {
+		pSelf.mutex.Unlock()
+		return state
+	}
+	if element, ok := pSelf.validated[tenantID]; ok {
+		pSelf.recent.MoveToFront(element)
+		pSelf.mutex.Unlock()
+		return element.Value.(*tenantState)
+	}
+	if policy, ok := pSelf.options.Tenants[tenantID]; ok {
+		defer pSelf.mutex.Unlock()
+		state := newTenantState(tenantID, tenantID, policy)
+		pSelf.states[tenantID] = state
+		return state
+	}
+	pSelf.mutex.Unlock()
+
+	// Validate 는 외부 저장소를 조회할 수 있으므로 lock 밖에서 호출.
+	validated := pSelf.options.Validate != nil && pSelf.options.Validate(ctx, tenantID)
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if !validated {
+		if pSelf.options.RejectUnknown {
+			return nil
+		}
+		if pSelf.unknown == nil {
+			pSelf.unknown = newTenantState("", otherTenantLabel, pSelf.options.DefaultPolicy)
+		}
+		return pSelf.unknown
+	}
+	if element, ok := pSelf.validated[tenantID]; ok {
+		pSelf.recent.MoveToFront(element)
+		return element.Value.(*tenantState)
+	}
+	if pSelf.recent.Len() >= pSelf.options.MaxTenants {
+		oldest := pSelf.recent.Back()
+		pSelf.recent.Remove(oldest)
+		delete(pSelf.validated, oldest.Value.(*tenantState).tenantID)
+	}
+	state := newTenantState(tenantID, otherTenantLabel, pSelf.options.DefaultPolicy)
+	pSelf.validated[tenantID] = pSelf.recent.PushFront(state)
+	return state
+}
+
+func newTenantState(tenantID, label string, policy TenantPolicy) *tenantState {
+	if policy.Burst <= 0 {
+		policy.Burst = int(math.Ceil(policy.RateLimit))
+	}
+	if policy.QuotaPeriod <= 0 {
+		policy.QuotaPeriod = defaultTenantQuotaPeriod
+	}
+	now := time.Now()
+	return &tenantState{tenantID: tenantID, policy: &policy, label: label, tokens: float64(policy.Burst), lastRefill: now, periodStart: now}
+}
+
+// take 는 요청 하나를 rate limit, quota 에서 뺀다.
+func (pSelf *tenantState) take(now time.Time) error {
+	policy := pSelf.policy
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	if policy.RateLimit > 0 {
+		pSelf.tokens = math.Min(float64(policy.Burst), pSelf.tokens+now.Sub(pSelf.lastRefill).Seconds()*policy.RateLimit)
+		pSelf.lastRefill = now
+		if pSelf.tokens < 1 {
+			return status.Error(codes.ResourceExhausted, "tenant rate limit exceeded")
+		}
+	}
+	if policy.Quota > 0 {
+		if now.Sub(pSelf.periodStart) >= policy.QuotaPeriod {
+			pSelf.used, pSelf.periodStart = 0, now
+		}
+		if pSelf.used >= policy.Quota {
+			return status.Error(codes.ResourceExhausted, "tenant quota exceeded")
+		}
+		pSelf.used++
+	}
+	if policy.RateLimit > 0 {
+		pSelf.tokens--
+	}
+	return nil
+}
+
+func (pSelf *tenancy) unaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if builtinMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, policy, err := pSelf.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return chainUnary(policy.Unary, info, handler)(ctx, req)
+}
+
+func (pSelf *tenancy) streamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if builtinMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, policy, err := pSelf.admit(ss.Context())
+	if err != nil {
+		return err
+	}
+	return chainStream(policy.Stream, info, handler)(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// builtinMethod 는 health check, reflection 처럼 tenant 없이 호출하는 Method 인지 여부이다.
+func builtinMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.") || strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
+
+// chainUnary 는 interceptors 를 순서대로 실행한 뒤 handler 를 실행하는 handler 이다.
+func chainUnary(interceptors []grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req any) (any, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return handler
+}
+
+// chainStream 은 interceptors 를 순서대로 실행한 뒤 handler 를 실행하는 handler 이다.
+func chainStream(interceptors []grpc.StreamServerInterceptor, info *grpc.StreamServerInfo, handler grpc.StreamHandler) grpc.StreamHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(srv any, ss grpc.ServerStream) error {
+			return interceptor(srv, ss, info, next)
+		}
+	}
+	return handler
+}
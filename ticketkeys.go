@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionTicketRotation = time.Hour
+	defaultSessionTicketKeys     = 3
+
+	sessionTicketKeyNameSize = 16
+)
+
+// SessionTicketOptions 는 TLS session ticket 의 암호화 key 교체 설정이다.
+type SessionTicketOptions struct {
+	// RotationInterval 은 새 key 로 ticket 을 암호화하기 시작하는 주기이다. (기본값: 1h)
+	RotationInterval time.Duration
+	// Keys 는 복호화에 사용할 수 있는 key 의 수이다. 현재 key 를 포함하며, ticket 은 RotationInterval * Keys 동안 재사용할 수 있다. (기본값: 3)
+	Keys int
+}
+
+// WithSessionTicketRotation 은 TLS session ticket 을 암호화하는 key 를 RotationInterval 마다 새로 만들고, 이전 key 는 Keys 개까지만 복호화에 사용한다.
+// key 가 유출되어도 그 key 로 암호화한 ticket 의 session 만 복호화할 수 있도록 key 의 수명을 제한한다. (forward secrecy)
+// key 는 프로세스 메모리에만 있으므로 Server 를 여러 개 실행하면 다른 Server 의 ticket 은 전체 handshake 를 한다.
+// WithTLS, Listener 별 TLS, HTTP/3 의 TLS 설정 중 session ticket 을 사용하는 설정에 적용한다.
+func WithSessionTicketRotation(sessionTicketOptions SessionTicketOptions) Option {
+	return func(options *serverOptions) {
+		if sessionTicketOptions.RotationInterval <= 0 {
+			sessionTicketOptions.RotationInterval = defaultSessionTicketRotation
+		}
+		if sessionTicketOptions.Keys <= 0 {
+			sessionTicketOptions.Keys = defaultSessionTicketKeys
+		}
+		options.sessionTicket = &sessionTicketOptions
+	}
+}
+
+// applySessionTicketRotation 은 TLS 설정이 교체하는 key 로 session ticket 을 암호화하도록 바꾼다.
+func applySessionTicketRotation(options *serverOptions) {
+	if options.sessionTicket == nil {
+		return
+	}
+
+	keys := &sessionTicketKeys{options: *options.sessionTicket}
+	options.sessionTicketKeys = keys
+	options.tlsConfig = keys.apply(options.tlsConfig)
+	for i := range options.additionalListeners {
+		options.additionalListeners[i].TLS = keys.apply(options.additionalListeners[i].TLS)
+	}
+	if options.http3 != nil {
+		options.http3.TLS = keys.apply(options.http3.TLS)
+	}
+}
+
+// sessionTicketKeys 는 session ticket 을 암호화하는 key 이다. 처음 key 로 암호화하고, 모든 key 로 복호화한다.
+// 별도 Goroutine 없이 ticket 을 암호화할 때 교체 시각이 지났으면 key 를 교체한다.
+type sessionTicketKeys struct {
+	options SessionTicketOptions
+
+	mutex      sync.RWMutex
+	keys       []sessionTicketKey
+	nextRotate time.Time
+}
+
+type sessionTicketKey struct {
+	name    [sessionTicketKeyNameSize]byte
+	aead    cipher.AEAD
+	created time.Time
+}
+
+// apply 는 tlsConfig 의 복사본에 session ticket 암호화를 설정한다. ticket 을 사용하지 않거나 직접 암호화하는 설정은 그대로 반환한다.
+func (pSelf *sessionTicketKeys) apply(tlsConfig *tls.Config) *tls.Config {
+	if pSelf == nil || tlsConfig == nil || tlsConfig.SessionTicketsDisabled || tlsConfig.WrapSession != nil || tlsConfig.UnwrapSession != nil {
+		return tlsConfig
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.WrapSession = pSelf.wrapSession
+	tlsConfig.UnwrapSession = pSelf.unwrapSession
+	return tlsConfig
+}
+
+// current 는 암호화에 사용할 key 이다. 교체 시각이 지났으면 새 key 를 만든다.
+func (pSelf *sessionTicketKeys) current() (sessionTicketKey, error) {
+	now := time.Now()
+	pSelf.mutex.RLock()
+	if len(pSelf.keys) > 0 && now.Before(pSelf.nextRotate) {
+		key := pSelf.keys[0]
+		pSelf.mutex.RUnlock()
+		return key, nil
+	}
+	pSelf.mutex.RUnlock()
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	if len(pSelf.keys) > 0 && now.Before(pSelf.nextRotate) {
+		return pSelf.keys[0], nil
+	}
+
+	key := sessionTicketKey{created: now}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(key.name[:]); err != nil {
+		return key, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return key, err
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return key, err
+	}
+	if key.aead, err = cipher.NewGCM(block); err != nil {
+		return key, err
+	}
+
+	pSelf.keys = append([]sessionTicketKey{key}, pSelf.keys...)
+	if len(pSelf.keys) > pSelf.options.Keys {
+		pSelf.keys = pSelf.keys[:pSelf.options.Keys]
+	}
+	pSelf.nextRotate = now.Add(pSelf.options.RotationInterval)
+	addMetric("session_ticket_key_rotations", 1)
+	return key, nil
+}
+
+// wrapSession 은 session 을 현재 key 로 암호화한다. ticket 은 key 이름, nonce, 암호문이다.
+func (pSelf *sessionTicketKeys) wrapSession(_ tls.ConnectionState, session *tls.SessionState) ([]byte, error) {
+	key, err := pSelf.current()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := session.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := make([]byte, sessionTicketKeyNameSize+key.aead.NonceSize(), sessionTicketKeyNameSize+key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	copy(ticket, key.name[:])
+	nonce := ticket[sessionTicketKeyNameSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return key.aead.Seal(ticket, nonce, plaintext, key.name[:]), nil
+}
+
+// unwrapSession 은 ticket 을 복호화한다. 교체되어 없는 key 이거나 복호화하지 못하면 전체 handshake 를 하도록 nil 을 반환한다.
+func (pSelf *sessionTicketKeys) unwrapSession(ticket []byte, _ tls.ConnectionState) (*tls.SessionState, error) {
+	if len(ticket) < sessionTicketKeyNameSize {
+		return nil, nil
+	}
+	name := ticket[:sessionTicketKeyNameSize]
+
+	pSelf.mutex.RLock()
+	var key *sessionTicketKey
+	for i := range pSelf.keys {
+		if bytes.Equal(pSelf.keys[i].name[:], name) {
+			found := pSelf.keys[i]
+			key = &found
+			break
+		}
+	}
+	pSelf.mutex.RUnlock()
+	// 암호화할 때만 key 를 교체하므로, 요청이 없던 동안 지난 key 는 여기서 거부.
+	if key == nil || time.Since(key.created) > pSelf.options.RotationInterval*time.Duration(pSelf.options.Keys) {
+		return nil, nil
+	}
+	if len(ticket) < sessionTicketKeyNameSize+key.aead.NonceSize() {
+		return nil, nil
+	}
+
+	nonce := ticket[sessionTicketKeyNameSize : sessionTicketKeyNameSize+key.aead.NonceSize()]
+	plaintext, err := key.aead.Open(nil, nonce, ticket[sessionTicketKeyNameSize+key.aead.NonceSize():], name)
+	if err != nil {
+		return nil, nil
+	}
+	session, err := tls.ParseSessionState(plaintext)
+	if err != nil {
+		return nil, nil
+	}
+	return session, nil
+}
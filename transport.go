@@ -0,0 +1,75 @@
+package server
+
+import (
+	"google.golang.org/grpc"
+)
+
+// BufferOptions 는 gRPC 연결의 읽기, 쓰기 buffer 설정이다.
+// 연결이 많은 Server 는 buffer 를 줄이거나 SharedWriteBuffer 를 사용하여 처리량 대신 memory 를 아낄 수 있다.
+type BufferOptions struct {
+	// WriteBufferSize 는 연결마다 쓰기를 모아 보내는 buffer 크기이다. 0 이면 gRPC 기본값 (32KiB), 음수이면 buffer 없이 바로 쓴다.
+	WriteBufferSize int
+	// ReadBufferSize 는 연결마다 한 번에 읽는 buffer 크기이다. 0 이면 gRPC 기본값 (32KiB), 음수이면 buffer 없이 읽는다.
+	ReadBufferSize int
+	// SharedWriteBuffer 이면 쓰기 buffer 를 연결마다 두지 않고 쓸 때만 pool 에서 빌린다. idle 연결이 많을 때 memory 를 줄인다.
+	SharedWriteBuffer bool
+}
+
+// WithBufferSizes 는 gRPC 연결의 읽기, 쓰기 buffer 크기를 설정한다.
+func WithBufferSizes(bufferOptions BufferOptions) Option {
+	return func(options *serverOptions) {
+		options.buffers = &bufferOptions
+	}
+}
+
+// minFlowControlWindow 는 gRPC 가 허용하는 가장 작은 HTTP/2 flow control window 이다.
+const minFlowControlWindow = 64 * 1024
+
+// FlowControlOptions 는 gRPC 연결의 HTTP/2 flow control window 설정이다. 0 인 항목은 gRPC 기본값 (64KiB) 에서 시작하여 BDP 에 맞춰 늘린다.
+// 지정하면 BDP 추정을 사용하지 않고 고정한다. 대역폭과 지연이 큰 streaming 에서는 bandwidth × RTT 이상으로 설정해야 회선 속도를 낸다.
+type FlowControlOptions struct {
+	// InitialWindowSize 는 stream 하나가 받기 전에 상대가 보낼 수 있는 크기이다. 64KiB 이상이어야 한다.
+	InitialWindowSize int32
+	// InitialConnWindowSize 는 연결의 모든 stream 을 합쳐 상대가 보낼 수 있는 크기이다. 64KiB 이상이어야 한다.
+	InitialConnWindowSize int32
+}
+
+// WithFlowControlWindow 는 gRPC 연결의 HTTP/2 flow control window 크기를 설정한다.
+func WithFlowControlWindow(flowControlOptions FlowControlOptions) Option {
+	return func(options *serverOptions) {
+		for name, size := range map[string]int32{
+			"InitialWindowSize":     flowControlOptions.InitialWindowSize,
+			"InitialConnWindowSize": flowControlOptions.InitialConnWindowSize,
+		} {
+			if size != 0 && size < minFlowControlWindow {
+				gLogger.Fatalf("%s must be at least %d: %d", name, minFlowControlWindow, size)
+			}
+		}
+		options.flowControl = &flowControlOptions
+	}
+}
+
+// transportServerOptions 는 연결 buffer, flow control window 설정의 gRPC Server 설정이다.
+func (pSelf *serverOptions) transportServerOptions() []grpc.ServerOption {
+	var serverOptions []grpc.ServerOption
+	if buffers := pSelf.buffers; buffers != nil {
+		if buffers.WriteBufferSize != 0 {
+			serverOptions = append(serverOptions, grpc.WriteBufferSize(max(buffers.WriteBufferSize, 0)))
+		}
+		if buffers.ReadBufferSize != 0 {
+			serverOptions = append(serverOptions, grpc.ReadBufferSize(max(buffers.ReadBufferSize, 0)))
+		}
+		if buffers.SharedWriteBuffer {
+			serverOptions = append(serverOptions, grpc.SharedWriteBuffer(true))
+		}
+	}
+	if flowControl := pSelf.flowControl; flowControl != nil {
+		if flowControl.InitialWindowSize > 0 {
+			serverOptions = append(serverOptions, grpc.InitialWindowSize(flowControl.InitialWindowSize))
+		}
+		if flowControl.InitialConnWindowSize > 0 {
+			serverOptions = append(serverOptions, grpc.InitialConnWindowSize(flowControl.InitialConnWindowSize))
+		}
+	}
+	return serverOptions
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const unixSocketProbeTimeout = time.Second
+
+// cleanupStaleUnixSocket 은 unix socket 파일이 이미 있으면 연결을 시도하여,
+// 다른 Server 가 사용 중이면 오류를 반환하고 비정상 종료로 남은 파일이면 삭제한다.
+func cleanupStaleUnixSocket(path string) error {
+	stale, err := probeUnixSocket(path)
+	if err != nil || !stale {
+		return err
+	}
+
+	gLogger.Printf("Remove stale unix socket: %s\n", path)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// probeUnixSocket 은 unix socket 파일이 이미 있으면 연결을 시도하여, 다른 Server 가 사용 중이면 오류를 반환한다.
+// 비정상 종료로 남은 파일이면 stale 이다. 파일은 바꾸지 않는다.
+func probeUnixSocket(path string) (stale bool, err error) {
+	// Linux abstract namespace 는 파일이 없음.
+	if strings.HasPrefix(path, "@") {
+		return false, nil
+	}
+
+	fileInfo, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if fileInfo.Mode()&fs.ModeSocket == 0 {
+		return false, fmt.Errorf("%s already exists and is not a unix socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, unixSocketProbeTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return false, fmt.Errorf("%s is already in use by another server", path)
+	}
+	return true, nil
+}
+
+// removeUnixSocket 은 종료할 때 unix socket 파일을 삭제한다.
+// Listener 를 닫으면서 이미 삭제된 경우가 많으므로 실패해도 종료를 막지 않는다.
+func removeUnixSocket(path string) {
+	if strings.HasPrefix(path, "@") {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		gLogger.Printf("Failed to remove unix socket %s: %v\n", path, err)
+	}
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 새 프로세스에 전달하는 Listener 목록. ("<key>=<fd>;<key>=<fd>")
+	upgradeListenersEnv = "BERRYONS_SERVER_UPGRADE_LISTENERS"
+	// 새 프로세스가 준비 완료를 알리는 pipe 의 File Descriptor.
+	upgradeReadyFdEnv = "BERRYONS_SERVER_UPGRADE_READY_FD"
+
+	defaultUpgradeReadyTimeout = time.Minute
+	defaultUpgradeDrainTimeout = 30 * time.Second
+)
+
+// UpgradeOptions 는 무중단 binary 교체 설정이다.
+type UpgradeOptions struct {
+	// Signal 을 받으면 새 binary 를 실행하여 Listener 를 넘겨준다. (기본값: SIGUSR2)
+	Signal os.Signal
+	// ReadyTimeout 은 새 프로세스가 준비될 때까지 기다리는 시간이다. (기본값: 1분)
+	ReadyTimeout time.Duration
+	// DrainTimeout 은 기존 프로세스가 처리 중인 요청이 끝날 때까지 기다리는 시간이다. (기본값: 30초)
+	DrainTimeout time.Duration
+}
+
+// WithUpgrade 는 Signal 을 받으면 같은 경로의 binary 를 새로 실행하여 Listener 를 넘겨주고,
+// 새 프로세스가 준비되면 기존 연결을 정리한 뒤 종료하도록 한다.
+// 새 프로세스도 같은 Option 으로 생성되어야 넘겨받은 Listener 를 사용한다.
+func WithUpgrade(upgradeOptions UpgradeOptions) Option {
+	return func(options *serverOptions) {
+		if upgradeOptions.Signal == nil {
+			upgradeOptions.Signal = defaultUpgradeSignal()
+		}
+		if upgradeOptions.ReadyTimeout <= 0 {
+			upgradeOptions.ReadyTimeout = defaultUpgradeReadyTimeout
+		}
+		if upgradeOptions.DrainTimeout <= 0 {
+			upgradeOptions.DrainTimeout = defaultUpgradeDrainTimeout
+		}
+
+		options.upgrader = &upgrader{
+			options:   upgradeOptions,
+			listeners: map[string]net.Listener{},
+			inherited: map[string]net.Listener{},
+		}
+	}
+}
+
+// upgrader 는 새 프로세스에 넘겨줄 Listener 와 이전 프로세스로부터 넘겨받은 Listener 를 관리한다.
+type upgrader struct {
+	options UpgradeOptions
+
+	mutex     sync.Mutex
+	listeners map[string]net.Listener
+	inherited map[string]net.Listener
+	readyFile *os.File
+}
+
+func listenerKey(network, address string, port int) string {
+	return strings.ToLower(network) + "://" + joinAddress(network, address, port)
+}
+
+// loadInherited 는 이전 프로세스가 넘겨준 Listener 를 가져온다.
+func (pSelf *upgrader) loadInherited() error {
+	if pSelf == nil {
+		return nil
+	}
+
+	listenersStr := os.Getenv(upgradeListenersEnv)
+	readyFdStr := os.Getenv(upgradeReadyFdEnv)
+	_ = os.Unsetenv(upgradeListenersEnv)
+	_ = os.Unsetenv(upgradeReadyFdEnv)
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	if len(readyFdStr) > 0 {
+		readyFd, err := strconv.Atoi(readyFdStr)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", upgradeReadyFdEnv, readyFdStr, err)
+		}
+		pSelf.readyFile = os.NewFile(uintptr(readyFd), "upgrade-ready")
+	}
+
+	for _, entry := range strings.Split(listenersStr, ";") {
+		if len(entry) == 0 {
+			continue
+		}
+
+		separator := strings.LastIndex(entry, "=")
+		if separator < 0 {
+			return fmt.Errorf("invalid %s entry %q", upgradeListenersEnv, entry)
+		}
+		key := entry[:separator]
+		fd, err := strconv.Atoi(entry[separator+1:])
+		if err != nil {
+			return fmt.Errorf("invalid %s entry %q: %w", upgradeListenersEnv, entry, err)
+		}
+
+		file := os.NewFile(uintptr(fd), key)
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to use inherited listener %s: %w", key, err)
+		}
+		pSelf.inherited[key] = listener
+	}
+
+	if len(pSelf.inherited) > 0 {
+		gLogger.Printf("Inherited %d listener(s) from the previous process\n", len(pSelf.inherited))
+	}
+	return nil
+}
+
+// takeInherited 는 이전 프로세스로부터 넘겨받은 Listener 중 key 에 해당하는 Listener 를 반환한다.
+func (pSelf *upgrader) takeInherited(key string) net.Listener {
+	if pSelf == nil {
+		return nil
+	}
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	listener, ok := pSelf.inherited[key]
+	if !ok {
+		return nil
+	}
+	delete(pSelf.inherited, key)
+	return listener
+}
+
+// track 은 새 프로세스에 넘겨줄 Listener 를 등록한다.
+func (pSelf *upgrader) track(key string, listener net.Listener) {
+	if pSelf == nil {
+		return
+	}
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.listeners[key] = listener
+}
+
+// notifyReady 는 이전 프로세스에 준비 완료를 알리고, 사용하지 않은 Listener 를 닫는다.
+func (pSelf *upgrader) notifyReady() {
+	if pSelf == nil {
+		return
+	}
+
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	for key, listener := range pSelf.inherited {
+		gLogger.Printf("Close unused inherited listener: %s\n", key)
+		_ = listener.Close()
+	}
+	pSelf.inherited = map[string]net.Listener{}
+
+	if pSelf.readyFile != nil {
+		if _, err := pSelf.readyFile.Write([]byte{1}); err != nil {
+			gLogger.Printf("Failed to notify upgrade readiness: %v\n", err)
+		}
+		_ = pSelf.readyFile.Close()
+		pSelf.readyFile = nil
+	}
+}
+
+// release 는 기존 프로세스가 Listener 를 닫아도 새 프로세스가 사용하는 unix socket 파일이 지워지지 않도록 한다.
+func (pSelf *upgrader) release() {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+
+	for _, listener := range pSelf.listeners {
+		if unixListener, ok := listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package server
+
+import (
+	"errors"
+	"os"
+)
+
+func defaultUpgradeSignal() os.Signal {
+	return nil
+}
+
+func (pSelf *upgrader) spawn() error {
+	return errors.New("upgrade is not supported on this platform")
+}
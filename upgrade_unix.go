@@ -0,0 +1,106 @@
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func defaultUpgradeSignal() os.Signal {
+	return syscall.SIGUSR2
+}
+
+// spawn 은 같은 경로의 binary 를 새로 실행하여 Listener 를 넘겨주고, 새 프로세스가 준비될 때까지 기다린다.
+func (pSelf *upgrader) spawn() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	pSelf.mutex.Lock()
+	var files []*os.File
+	var entries []string
+	for key, listener := range pSelf.listeners {
+		fileListener, ok := listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			gLogger.Printf("Listener can not be passed to the new process: %s\n", key)
+			continue
+		}
+
+		file, err := fileListener.File()
+		if err != nil {
+			pSelf.mutex.Unlock()
+			closeFiles(files)
+			return fmt.Errorf("failed to get listener file %s: %w", key, err)
+		}
+
+		// ExtraFiles 는 3 번 File Descriptor 부터 전달된다.
+		entries = append(entries, fmt.Sprintf("%s=%d", key, 3+len(files)))
+		files = append(files, file)
+	}
+	pSelf.mutex.Unlock()
+	defer closeFiles(files)
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		upgradeListenersEnv+"="+strings.Join(entries, ";"),
+		fmt.Sprintf("%s=%d", upgradeReadyFdEnv, 3+len(files)),
+	)
+	cmd.ExtraFiles = append(files, readyWriter)
+
+	if err := cmd.Start(); err != nil {
+		_ = readyWriter.Close()
+		return err
+	}
+	_ = readyWriter.Close()
+	gLogger.Printf("Started new process: %d\n", cmd.Process.Pid)
+
+	cReady := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		if _, err := readyReader.Read(b); err != nil {
+			cReady <- fmt.Errorf("new process exited before it was ready: %w", err)
+			return
+		}
+		cReady <- nil
+	}()
+
+	timer := time.NewTimer(pSelf.options.ReadyTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-cReady:
+		if err != nil {
+			_ = cmd.Wait()
+			return err
+		}
+		// 새 프로세스는 기존 프로세스가 종료되면 init 프로세스가 회수한다.
+		go func() { _ = cmd.Wait() }()
+		return nil
+	case <-timer.C:
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return errors.New("timed out waiting for the new process to be ready")
+	}
+}
+
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		_ = file.Close()
+	}
+}
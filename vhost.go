@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RegisterHttpProxyHost 는 Host header 가 host 인 요청만 처리하는 gRPC Gateway 를 등록한다.
+// 한 Http Proxy Server 에서 여러 API hostname 을 Host 별로 다른 route 로 제공할 때 사용한다.
+// host 는 port 없이 지정하며, "*.example.com" 처럼 첫 label 을 wildcard 로 지정할 수 있다.
+// 등록한 host 와 일치하지 않는 요청은 RegisterHttpProxyVersion, RegisterHttpProxyServer 의 mux 가 처리하고, 없으면 404 로 응답한다.
+//
+//	grpcServer.RegisterHttpProxyHost("api.example.com", []server.HttpProxyServerHandler{pb.RegisterGreeterHandlerFromEndpoint}, nil, nil, nil, -1)
+//	grpcServer.RegisterHttpProxyHost("admin.example.com", []server.HttpProxyServerHandler{pb.RegisterAdminHandlerFromEndpoint}, nil, nil, nil, -1)
+func (pSelf *GrpcServer) RegisterHttpProxyHost(host string, httpProxyServerHandlerFuncSlice []HttpProxyServerHandler, ctx context.Context, mux *runtime.ServeMux, opts []grpc.DialOption, httpProxyPort int) {
+	if len(httpProxyServerHandlerFuncSlice) == 0 {
+		pSelf.options.recoverable("Http Proxy Server of %s is nil...", host)
+		return
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if mux == nil {
+		mux = pSelf.httpProxyHosts[host]
+	}
+	if mux == nil {
+		mux = runtime.NewServeMux()
+	}
+	if pSelf.httpProxyHosts == nil {
+		pSelf.httpProxyHosts = map[string]*runtime.ServeMux{}
+	}
+	pSelf.httpProxyHosts[host] = mux
+
+	pSelf.setHttpProxyPort(httpProxyPort)
+	pSelf.registerHttpGateways(httpProxyServerHandlerFuncSlice, ctx, mux, opts)
+}
+
+// hasHttpProxy 는 등록한 gRPC Gateway 가 있는지 여부이다.
+func (pSelf *GrpcServer) hasHttpProxy() bool {
+	return pSelf.httpProxyMux != nil || len(pSelf.httpProxyHosts) > 0 || len(pSelf.httpProxyVersions) > 0
+}
+
+// httpProxyHandler 는 Http Proxy Server 의 handler 이다.
+func (pSelf *GrpcServer) httpProxyHandler() http.Handler {
+	var handler http.Handler = http.NotFoundHandler()
+	if pSelf.httpProxyMux != nil {
+		handler = pSelf.httpProxyMux
+	}
+	if len(pSelf.httpProxyVersions) > 0 {
+		handler = &apiVersionHandler{versions: pSelf.httpProxyVersions, fallback: handler}
+	}
+	if len(pSelf.httpProxyHosts) > 0 {
+		handler = &virtualHostHandler{hosts: pSelf.httpProxyHosts, fallback: handler}
+	}
+	return handler
+}
+
+// virtualHostHandler 는 Host header 로 ServeMux 를 선택하는 handler 이다.
+type virtualHostHandler struct {
+	hosts    map[string]*runtime.ServeMux
+	fallback http.Handler
+}
+
+func (pSelf *virtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if mux, ok := pSelf.hosts[host]; ok {
+		mux.ServeHTTP(w, r)
+		return
+	}
+	if _, parent, ok := strings.Cut(host, "."); ok {
+		if mux, ok := pSelf.hosts["*."+parent]; ok {
+			mux.ServeHTTP(w, r)
+			return
+		}
+	}
+	pSelf.fallback.ServeHTTP(w, r)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/mdlayher/vsock"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// vsockAnyContextID 는 모든 Context ID 로 들어오는 연결을 수락한다. (VMADDR_CID_ANY)
+const vsockAnyContextID = math.MaxUint32
+
+// parseVsockContextID 는 address 를 vsock Context ID 로 해석한다.
+// 빈 문자열은 현재 VM 의 Context ID, "any" 는 VMADDR_CID_ANY 를 의미한다.
+func parseVsockContextID(address string) (cid uint32, isLocal bool, err error) {
+	switch strings.ToLower(address) {
+	case "":
+		return 0, true, nil
+	case "any":
+		return vsockAnyContextID, false, nil
+	}
+
+	parsed, err := strconv.ParseUint(address, 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid vsock context id %q: %w", address, err)
+	}
+	return uint32(parsed), false, nil
+}
+
+func parseVsockPort(port int) (uint32, error) {
+	if port < 0 || uint64(port) > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid vsock port: %d", port)
+	}
+	return uint32(port), nil
+}
+
+func listenVsock(address string, port int) (net.Listener, error) {
+	cid, isLocal, err := parseVsockContextID(address)
+	if err != nil {
+		return nil, err
+	}
+	vsockPort, err := parseVsockPort(port)
+	if err != nil {
+		return nil, err
+	}
+
+	if isLocal {
+		return vsock.Listen(vsockPort, nil)
+	}
+	return vsock.ListenContextID(cid, vsockPort, nil)
+}
+
+// vsockPort 는 vsock 주소의 port 이다.
+func vsockPort(addr net.Addr) (int, bool) {
+	if vsockAddr, ok := addr.(*vsock.Addr); ok {
+		return int(vsockAddr.Port), true
+	}
+	return 0, false
+}
+
+// dialVsock 은 gRPC Gateway 가 vsock 으로 gRPC Server 에 연결할 때 사용하는 ContextDialer 이다.
+// address 는 "<context id>:<port>" 형식이다.
+func dialVsock(_ context.Context, address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	cid, isLocal, err := parseVsockContextID(host)
+	if err != nil {
+		return nil, err
+	}
+	if isLocal || cid == vsockAnyContextID {
+		if cid, err = vsock.ContextID(); err != nil {
+			return nil, err
+		}
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vsock port %q: %w", portStr, err)
+	}
+
+	return vsock.Dial(cid, uint32(port), nil)
+}
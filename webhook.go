@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookWorkers     = 4
+	defaultWebhookQueueSize   = 1024
+	defaultWebhookMaxAttempts = 5
+	defaultWebhookBackoff     = time.Second
+	defaultWebhookMaxBackoff  = time.Minute
+	defaultWebhookTimeout     = 10 * time.Second
+
+	// Standard Webhooks (https://www.standardwebhooks.com) header.
+	webhookIDHeader        = "Webhook-Id"
+	webhookTimestampHeader = "Webhook-Timestamp"
+	webhookSignatureHeader = "Webhook-Signature"
+)
+
+var (
+	// ErrWebhookQueueFull 은 발송 대기열이 가득 차 event 를 추가하지 못한 경우의 오류이다.
+	ErrWebhookQueueFull = errors.New("webhook queue is full")
+	// ErrWebhookDispatcherClosed 는 종료한 WebhookDispatcher 에 event 를 추가하거나, 종료할 때 발송하지 못한 경우의 오류이다.
+	ErrWebhookDispatcherClosed = errors.New("webhook dispatcher is closed")
+)
+
+// WebhookEvent 는 발송할 webhook 이다.
+type WebhookEvent struct {
+	// ID 는 event 의 고유 id 이며, 수신 측이 중복을 확인할 때 사용한다. 비어 있으면 생성한다.
+	ID  string
+	URL string
+	// Payload 는 요청 body 이다.
+	Payload []byte
+	// ContentType 은 Payload 의 Content-Type 이다. (기본값: application/json)
+	ContentType string
+	// Header 는 추가 요청 header 이다.
+	Header http.Header
+}
+
+// WebhookOptions 는 WebhookDispatcher 설정이다.
+type WebhookOptions struct {
+	// Secret 은 서명 key 이다. 비어 있으면 서명하지 않는다.
+	// Standard Webhooks 와 같이 "id.timestamp.payload" 의 HMAC-SHA256 을 Webhook-Signature header 에 "v1,base64" 로 전달한다.
+	Secret []byte
+	// Workers 는 동시에 발송하는 Goroutine 수이다. (기본값: 4)
+	Workers int
+	// QueueSize 는 발송 대기열의 크기이다. (기본값: 1024)
+	QueueSize int
+	// MaxAttempts 는 재시도를 포함한 최대 발송 횟수이다. (기본값: 5)
+	MaxAttempts int
+	// Backoff, MaxBackoff 는 재시도 간격이다. 재시도마다 두 배로 늘리며 jitter 를 더한다. (기본값: 1초, 1분)
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout 은 발송 요청 하나의 timeout 이다. (기본값: 10초)
+	Timeout time.Duration
+	// Client 는 발송에 사용할 HTTP client 이다. (기본값: http.DefaultClient)
+	Client *http.Client
+	// DeadLetter 는 발송을 모두 실패하거나 종료할 때까지 발송하지 못한 event 를 받는다. (e.g. 저장 후 다시 발송)
+	DeadLetter func(event WebhookEvent, err error)
+}
+
+// WebhookDispatcher 는 event 를 대기열에 넣고 background 에서 webhook 으로 발송한다.
+// Worker 이므로 WithWorker 로 Server 와 함께 시작하고, 종료할 때 대기열의 event 를 모두 발송한다.
+//
+//	dispatcher := server.NewWebhookDispatcher(server.WebhookOptions{Secret: secret})
+//	grpcServer := server.New("tcp", "", 50051, nil, nil, server.WithWorker("webhook", dispatcher))
+//	...
+//	err := dispatcher.Enqueue(server.WebhookEvent{URL: endpoint, Payload: payload})
+//
+// 지표: webhook_deliveries (delivered, retried, failed, dropped), webhook_queued
+type WebhookDispatcher struct {
+	options WebhookOptions
+	cQueue  chan WebhookEvent
+
+	// mutex 는 종료 후 대기열에 추가하지 않도록 한다.
+	mutex  sync.RWMutex
+	closed bool
+	// ctx 는 Stop 의 ctx 가 끝나면 취소하여 발송 중인 요청과 재시도 대기를 중단한다.
+	ctx       context.Context
+	cancel    context.CancelFunc
+	waitGroup sync.WaitGroup
+}
+
+// NewWebhookDispatcher 는 WebhookDispatcher 를 생성한다.
+func NewWebhookDispatcher(options WebhookOptions) *WebhookDispatcher {
+	if options.Workers <= 0 {
+		options.Workers = defaultWebhookWorkers
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultWebhookQueueSize
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaultWebhookBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultWebhookMaxBackoff
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultWebhookTimeout
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookDispatcher{options: options, cQueue: make(chan WebhookEvent, options.QueueSize), ctx: ctx, cancel: cancel}
+}
+
+// Enqueue 는 event 를 발송 대기열에 추가한다. 대기열이 가득 차면 기다리지 않고 ErrWebhookQueueFull 을 반환한다.
+func (pSelf *WebhookDispatcher) Enqueue(event WebhookEvent) error {
+	if len(event.ID) == 0 {
+		event.ID = "msg_" + newRequestID()
+	}
+
+	pSelf.mutex.RLock()
+	defer pSelf.mutex.RUnlock()
+	if pSelf.closed {
+		return ErrWebhookDispatcherClosed
+	}
+	select {
+	case pSelf.cQueue <- event:
+		addMetric("webhook_queued", 1)
+		return nil
+	default:
+		addLabeledMetric("webhook_deliveries", "dropped", 1)
+		return ErrWebhookQueueFull
+	}
+}
+
+// Start 는 발송 Goroutine 을 시작한다.
+func (pSelf *WebhookDispatcher) Start(_ context.Context, _ *grpc.ClientConn) error {
+	for range pSelf.options.Workers {
+		pSelf.waitGroup.Add(1)
+		go pSelf.work()
+	}
+	return nil
+}
+
+// Stop 은 새 event 를 받지 않고, 대기열의 event 를 모두 발송하거나 ctx 가 끝날 때까지 기다린다.
+// ctx 가 끝나면 발송하지 못한 event 를 ErrWebhookDispatcherClosed 와 함께 DeadLetter 에 전달한다.
+func (pSelf *WebhookDispatcher) Stop(ctx context.Context) error {
+	pSelf.mutex.Lock()
+	if pSelf.closed {
+		pSelf.mutex.Unlock()
+		return nil
+	}
+	pSelf.closed = true
+	close(pSelf.cQueue)
+	pSelf.mutex.Unlock()
+
+	cDone := make(chan struct{})
+	go func() {
+		pSelf.waitGroup.Wait()
+		close(cDone)
+	}()
+	select {
+	case <-cDone:
+		pSelf.cancel()
+		return nil
+	case <-ctx.Done():
+		pSelf.cancel()
+		<-cDone
+		return ctx.Err()
+	}
+}
+
+func (pSelf *WebhookDispatcher) work() {
+	defer pSelf.waitGroup.Done()
+	for event := range pSelf.cQueue {
+		addMetric("webhook_queued", -1)
+		pSelf.deliver(event)
+	}
+}
+
+// deliver 는 event 를 발송하고, 실패하면 backoff 하며 다시 시도한다. 모두 실패하면 DeadLetter 에 전달한다.
+func (pSelf *WebhookDispatcher) deliver(event WebhookEvent) {
+	backoff := pSelf.options.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if pSelf.ctx.Err() != nil {
+			err = ErrWebhookDispatcherClosed
+			break
+		}
+
+		var retryable bool
+		if retryable, err = pSelf.send(event); err == nil {
+			addLabeledMetric("webhook_deliveries", "delivered", 1)
+			return
+		}
+		if !retryable || attempt >= pSelf.options.MaxAttempts {
+			break
+		}
+
+		addLabeledMetric("webhook_deliveries", "retried", 1)
+		// 여러 event 의 재시도가 겹치지 않도록 jitter 를 더한다.
+		select {
+		case <-time.After(backoff/2 + rand.N(backoff/2+1)):
+		case <-pSelf.ctx.Done():
+		}
+		backoff = min(backoff*2, pSelf.options.MaxBackoff)
+	}
+
+	addLabeledMetric("webhook_deliveries", "failed", 1)
+	if pSelf.options.DeadLetter != nil {
+		pSelf.options.DeadLetter(event, err)
+	}
+}
+
+// send 는 event 를 한 번 발송한다. 실패하면 다시 시도할 수 있는 오류인지 함께 반환한다.
+func (pSelf *WebhookDispatcher) send(event WebhookEvent) (bool, error) {
+	ctx, cancel := context.WithTimeout(pSelf.ctx, pSelf.options.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, event.URL, bytes.NewReader(event.Payload))
+	if err != nil {
+		return false, err
+	}
+	for key, values := range event.Header {
+		request.Header[key] = values
+	}
+	contentType := event.ContentType
+	if len(contentType) == 0 {
+		contentType = "application/json"
+	}
+	request.Header.Set("Content-Type", contentType)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set(webhookIDHeader, event.ID)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	if len(pSelf.options.Secret) > 0 {
+		request.Header.Set(webhookSignatureHeader, "v1,"+signWebhook(pSelf.options.Secret, event.ID, timestamp, event.Payload))
+	}
+
+	response, err := pSelf.options.Client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook %s responded %s", event.URL, response.Status)
+	retryable := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusRequestTimeout
+	return retryable, err
+}
+
+// signWebhook 은 Standard Webhooks 서명이다. base64(HMAC-SHA256(secret, "id.timestamp.payload"))
+func signWebhook(secret []byte, id, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(payload)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultWebTransportPath = "/grpc.webtransport"
+	// maxWebTransportFrameSize 는 frame 하나의 최대 크기이다. (gRPC 의 기본 최대 메시지 크기)
+	maxWebTransportFrameSize = 4 << 20
+
+	// gRPC-Web 과 같은 frame flag. header frame 은 WebTransport 에서만 사용한다.
+	webTransportMessageFrame = 0x00
+	webTransportHeaderFrame  = 0x40
+	webTransportTrailerFrame = 0x80
+)
+
+// WebTransportOptions 는 gRPC over WebTransport 설정이다.
+type WebTransportOptions struct {
+	// Path 는 WebTransport session 을 여는 path 이다. (기본값: /grpc.webtransport)
+	Path string
+	// CheckOrigin 은 session 을 여는 요청의 Origin 을 확인한다. nil 이면 Origin 이 Host 와 같은 요청만 허용한다.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// WithWebTransport 는 HTTP/3 Server 에서 WebTransport session 으로 gRPC 요청을 받는다. (실험 기능)
+// 브라우저 client 가 gRPC-Web 과 달리 client streaming 과 양방향 streaming 도 사용할 수 있으며, WithHttp3 가 필요하다.
+//
+// session 의 양방향 stream 하나가 RPC 하나이며, frame 은 gRPC-Web 과 같이 flag 1 byte 와 big-endian 길이 4 byte 뒤에 내용이 온다.
+//
+//	client → server  0x40 header  "/package.Service/Method\r\n" 다음 줄부터 "key: value\r\n" metadata
+//	                 0x00 message ... 마지막 메시지를 보내면 stream 의 쓰기를 닫는다.
+//	server → client  0x40 header  "key: value\r\n" header metadata
+//	                 0x00 message ...
+//	                 0x80 trailer "grpc-status: 0\r\ngrpc-message: ...\r\n" 와 trailer metadata
+//
+// 요청은 같은 프로세스의 gRPC Server 로 전달하므로 모든 Interceptor 를 통과한다.
+func WithWebTransport(webTransportOptions WebTransportOptions) Option {
+	return func(options *serverOptions) {
+		if len(webTransportOptions.Path) == 0 {
+			webTransportOptions.Path = defaultWebTransportPath
+		}
+		options.webTransport = &webTransportOptions
+	}
+}
+
+// checkWebTransport 는 WebTransport 를 사용할 수 있는 설정인지 확인한다.
+func checkWebTransport(options *serverOptions) error {
+	if options.webTransport != nil && options.http3 == nil {
+		return errors.New("WebTransport requires HTTP/3 (WithHttp3)")
+	}
+	return nil
+}
+
+// webTransportGateway 는 WebTransport stream 의 RPC 를 gRPC Server 로 전달한다.
+type webTransportGateway struct {
+	options *WebTransportOptions
+	server  *webtransport.Server
+	conn    *grpc.ClientConn
+
+	mutex    sync.Mutex
+	sessions map[*webtransport.Session]struct{}
+	closed   bool
+}
+
+func (pSelf *GrpcServer) newWebTransportGateway() (*webTransportGateway, error) {
+	conn, err := grpc.NewClient(pSelf.grpcEndpoint(), pSelf.selfDialOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return &webTransportGateway{
+		options:  pSelf.options.webTransport,
+		server:   &webtransport.Server{CheckOrigin: pSelf.options.webTransport.CheckOrigin},
+		conn:     conn,
+		sessions: map[*webtransport.Session]struct{}{},
+	}, nil
+}
+
+// http3Server 는 WebTransport 를 처리하는 HTTP/3 Server 이다.
+func (pSelf *webTransportGateway) http3Server() *http3.Server {
+	return &pSelf.server.H3
+}
+
+// handler 는 Path 의 CONNECT 요청을 WebTransport session 으로 전환하고, 나머지 요청은 handler 에 전달한다.
+func (pSelf *webTransportGateway) handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.URL.Path != pSelf.options.Path {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := pSelf.server.Upgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		addMetric("webtransport_sessions", 1)
+		go pSelf.serveSession(session)
+	})
+}
+
+func (pSelf *webTransportGateway) serveSession(session *webtransport.Session) {
+	pSelf.mutex.Lock()
+	if pSelf.closed {
+		pSelf.mutex.Unlock()
+		_ = session.CloseWithError(0, "server is shutting down")
+		return
+	}
+	pSelf.sessions[session] = struct{}{}
+	pSelf.mutex.Unlock()
+	defer func() {
+		pSelf.mutex.Lock()
+		delete(pSelf.sessions, session)
+		pSelf.mutex.Unlock()
+	}()
+
+	for {
+		stream, err := session.AcceptStream(session.Context())
+		if err != nil {
+			return
+		}
+		go pSelf.serveStream(session.Context(), stream, session.RemoteAddr())
+	}
+}
+
+// serveStream 은 stream 의 RPC 하나를 gRPC Server 로 전달한다.
+func (pSelf *webTransportGateway) serveStream(ctx context.Context, stream *webtransport.Stream, remoteAddr net.Addr) {
+	defer stream.Close()
+	reader := bufio.NewReader(stream)
+
+	flag, block, err := readWebTransportFrame(reader)
+	if err != nil || flag != webTransportHeaderFrame {
+		stream.CancelRead(0)
+		return
+	}
+	fullMethod, md := parseWebTransportHeader(block)
+	if !strings.HasPrefix(fullMethod, "/") {
+		_ = writeWebTransportTrailer(stream, status.New(codes.InvalidArgument, "invalid method: "+fullMethod), nil)
+		return
+	}
+
+	// gRPC Gateway 와 같이 session 의 client 주소를 전달한다. client 가 보낸 값은 신뢰하지 않는다.
+	if host := addrIP(remoteAddr); host != nil {
+		md.Set("x-forwarded-for", host.String())
+	} else {
+		md.Delete("x-forwarded-for")
+	}
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(ctx, md))
+	defer cancel()
+	clientStream, err := pSelf.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, fullMethod, grpc.ForceCodecV2(rawCodec{}))
+	if err != nil {
+		_ = writeWebTransportTrailer(stream, status.Convert(err), nil)
+		return
+	}
+
+	go func() {
+		for {
+			flag, data, err := readWebTransportFrame(reader)
+			if errors.Is(err, io.EOF) {
+				_ = clientStream.CloseSend()
+				return
+			}
+			if err != nil || flag != webTransportMessageFrame {
+				cancel()
+				return
+			}
+			if err := clientStream.SendMsg(&rawFrame{data: data}); err != nil {
+				// 응답의 status 는 RecvMsg 로 받는다.
+				return
+			}
+		}
+	}()
+
+	header, err := clientStream.Header()
+	if err == nil {
+		err = writeWebTransportFrame(stream, webTransportHeaderFrame, formatWebTransportHeader(header))
+	}
+	for err == nil {
+		frame := &rawFrame{}
+		if err = clientStream.RecvMsg(frame); err == nil {
+			err = writeWebTransportFrame(stream, webTransportMessageFrame, frame.data)
+		}
+	}
+	grpcStatus := status.New(codes.OK, "")
+	if !errors.Is(err, io.EOF) {
+		var ok bool
+		if grpcStatus, ok = status.FromError(err); !ok {
+			// client 가 stream 을 취소하거나 연결이 끊긴 경우.
+			stream.CancelWrite(0)
+			return
+		}
+	}
+	_ = writeWebTransportTrailer(stream, grpcStatus, clientStream.Trailer())
+}
+
+// closeSessions 는 새 session 을 받지 않고 열린 session 을 모두 닫는다.
+func (pSelf *webTransportGateway) closeSessions() {
+	pSelf.mutex.Lock()
+	defer pSelf.mutex.Unlock()
+	pSelf.closed = true
+	for session := range pSelf.sessions {
+		_ = session.CloseWithError(0, "server is shutting down")
+	}
+}
+
+func (pSelf *webTransportGateway) close() {
+	_ = pSelf.server.Close()
+	_ = pSelf.conn.Close()
+}
+
+func readWebTransportFrame(reader io.Reader) (byte, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxWebTransportFrameSize {
+		return 0, nil, fmt.Errorf("frame size %d exceeds %d", size, maxWebTransportFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return prefix[0], data, nil
+}
+
+func writeWebTransportFrame(writer io.Writer, flag byte, data []byte) error {
+	frame := make([]byte, 5+len(data))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	copy(frame[5:], data)
+	_, err := writer.Write(frame)
+	return err
+}
+
+func writeWebTransportTrailer(writer io.Writer, grpcStatus *status.Status, trailer metadata.MD) error {
+	md := trailer.Copy()
+	if md == nil {
+		md = metadata.MD{}
+	}
+	md.Set("grpc-status", fmt.Sprint(int(grpcStatus.Code())))
+	if len(grpcStatus.Message()) > 0 {
+		md.Set("grpc-message", grpcStatus.Message())
+	}
+	return writeWebTransportFrame(writer, webTransportTrailerFrame, formatWebTransportHeader(md))
+}
+
+// parseWebTransportHeader 는 요청 header frame 의 Method 와 metadata 이다. "-bin" metadata 는 base64 로 전달한다.
+func parseWebTransportHeader(block []byte) (string, metadata.MD) {
+	lines := strings.Split(strings.TrimRight(string(block), "\r\n"), "\r\n")
+	md := metadata.MD{}
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || len(key) == 0 || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasSuffix(key, "-bin") {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		md.Append(key, value)
+	}
+	return strings.TrimSpace(lines[0]), md
+}
+
+func formatWebTransportHeader(md metadata.MD) []byte {
+	var buffer bytes.Buffer
+	for key, values := range md {
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			buffer.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+)
+
+// Worker 는 Server 와 함께 시작하고 종료하는 background 작업이다. (e.g. message broker bridge, consumer)
+type Worker interface {
+	// Start 는 Server 의 Listener 를 모두 준비한 뒤 호출된다. conn 은 Server 의 gRPC endpoint 연결이며, Server 가 관리한다.
+	// 오래 실행되는 작업은 Goroutine 에서 실행하고 바로 반환해야 한다.
+	Start(ctx context.Context, conn *grpc.ClientConn) error
+	// Stop 은 gRPC Server 를 종료하기 전에 호출된다. 새 작업을 받지 않고, 처리 중인 작업이 끝나거나 ctx 가 끝날 때까지 기다린다.
+	Stop(ctx context.Context) error
+}
+
+// namedWorker 는 이름으로 구분하는 Worker 이다.
+type namedWorker struct {
+	name   string
+	worker Worker
+}
+
+// WithWorker 는 Server 를 시작하면 worker 를 시작하고, 종료하면 처리 중인 요청이 끝나기 전에 worker 를 먼저 종료한다.
+// 여러 번 사용하면 추가한 순서대로 시작하고 역순으로 종료한다. 시작하지 못하면 WithErrorPolicy 에 따라 종료하거나 경고를 기록한다.
+// Run 으로 실행하면 종료 signal 을 받았을 때도 프로세스를 끝내기 전에 worker 를 종료한다. (e.g. 구독 해제, offset commit)
+func WithWorker(name string, worker Worker) Option {
+	return func(options *serverOptions) {
+		options.workers = append(options.workers, namedWorker{name: name, worker: worker})
+	}
+}
+
+// AddWorker 는 WithWorker 와 같이 worker 를 추가한다. Run 이나 Start 를 호출하기 전에 추가해야 한다.
+// RegisterServices 로 등록한 Service 를 사용하는 Worker 처럼 Server 를 생성한 뒤에 만드는 Worker 에 사용한다.
+func (pSelf *GrpcServer) AddWorker(name string, worker Worker) {
+	pSelf.options.workers = append(pSelf.options.workers, namedWorker{name: name, worker: worker})
+}
+
+// startWorkers 는 WithWorker 의 Worker 를 시작한다.
+func (pSelf *GrpcServer) startWorkers() {
+	conn, err := grpc.NewClient(pSelf.grpcEndpoint(), pSelf.selfDialOptions()...)
+	if err != nil {
+		pSelf.options.recoverable("Failed to connect workers to gRPC server: %v", err)
+		return
+	}
+	pSelf.workerConn = conn
+
+	for _, w := range pSelf.options.workers {
+		if err := w.worker.Start(context.Background(), conn); err != nil {
+			pSelf.options.recoverable("Failed to start worker %s: %v", w.name, err)
+			continue
+		}
+		pSelf.workers = append(pSelf.workers, w)
+		gLogger.Printf("Started worker %s\n", w.name)
+	}
+}
+
+// stopWorkers 는 시작한 Worker 를 역순으로 종료한다.
+func (pSelf *GrpcServer) stopWorkers(ctx context.Context) {
+	for i := len(pSelf.workers) - 1; i >= 0; i-- {
+		w := pSelf.workers[i]
+		if err := w.worker.Stop(ctx); err != nil {
+			gLogger.Printf("Failed to stop worker %s: %v\n", w.name, err)
+			continue
+		}
+		gLogger.Printf("Stopped worker %s\n", w.name)
+	}
+	pSelf.workers = nil
+
+	if pSelf.workerConn != nil {
+		_ = pSelf.workerConn.Close()
+		pSelf.workerConn = nil
+	}
+}